@@ -0,0 +1,191 @@
+package zfs
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ACLType is the value of the acltype property.
+type ACLType string
+
+// ACL types.
+const (
+	ACLTypeOff   ACLType = "off"
+	ACLTypeNFSv4 ACLType = "nfsv4"
+	ACLTypePOSIX ACLType = "posix"
+)
+
+// ACLMode is the value of the aclmode property, which controls how chmod modifies an NFSv4 ACL.
+type ACLMode string
+
+// ACL modes.
+const (
+	ACLModeDiscard     ACLMode = "discard"
+	ACLModeGroupmask   ACLMode = "groupmask"
+	ACLModePassthrough ACLMode = "passthrough"
+	ACLModeRestricted  ACLMode = "restricted"
+)
+
+// ACLInherit is the value of the aclinherit property, which controls how NFSv4 ACL entries are inherited.
+type ACLInherit string
+
+// ACL inheritance modes.
+const (
+	ACLInheritDiscard      ACLInherit = "discard"
+	ACLInheritNoallow      ACLInherit = "noallow"
+	ACLInheritRestricted   ACLInherit = "restricted"
+	ACLInheritPassthrough  ACLInherit = "passthrough"
+	ACLInheritPassthroughX ACLInherit = "passthrough-x"
+)
+
+// XattrMode is the value of the xattr property.
+type XattrMode string
+
+// Extended attribute modes.
+const (
+	XattrOff XattrMode = "off"
+	XattrOn  XattrMode = "on"
+	// XattrDir stores extended attributes in hidden directories, the default for "on".
+	XattrDir XattrMode = "dir"
+	// XattrSA stores extended attributes as system attributes in the dnode, which is much faster for POSIX ACLs.
+	XattrSA XattrMode = "sa"
+)
+
+// ACLOptions are the ACL and extended attribute properties of a dataset.
+// Empty fields are left unchanged when set.
+//
+// More information regarding these properties can be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html
+type ACLOptions struct {
+	ACLType    ACLType
+	ACLMode    ACLMode
+	ACLInherit ACLInherit
+	Xattr      XattrMode
+}
+
+// Properties validates the options for the current platform and returns them as ZFS properties.
+func (o ACLOptions) Properties() (map[string]string, error) {
+	return o.properties(currentPlatform)
+}
+
+func (o ACLOptions) properties(p *platform) (map[string]string, error) {
+	props := map[string]string{}
+
+	switch o.ACLType {
+	case "":
+	case ACLTypeOff, ACLTypeNFSv4, ACLTypePOSIX:
+		if !p.supportsACLType(o.ACLType) {
+			return nil, fmt.Errorf("acltype %s is not supported on %s", o.ACLType, p.name)
+		}
+		props["acltype"] = string(o.ACLType)
+	default:
+		return nil, fmt.Errorf("invalid acltype %q", o.ACLType)
+	}
+
+	// aclmode and aclinherit only govern NFSv4 ACLs
+	nfsv4 := p.nfsv4ACLs && o.ACLType != ACLTypeOff && o.ACLType != ACLTypePOSIX
+
+	switch o.ACLMode {
+	case "":
+	case ACLModeDiscard, ACLModeGroupmask, ACLModePassthrough, ACLModeRestricted:
+		if !nfsv4 {
+			return nil, errors.New("aclmode only applies to NFSv4 ACLs")
+		}
+		props["aclmode"] = string(o.ACLMode)
+	default:
+		return nil, fmt.Errorf("invalid aclmode %q", o.ACLMode)
+	}
+
+	switch o.ACLInherit {
+	case "":
+	case ACLInheritDiscard, ACLInheritNoallow, ACLInheritRestricted, ACLInheritPassthrough, ACLInheritPassthroughX:
+		if !nfsv4 {
+			return nil, errors.New("aclinherit only applies to NFSv4 ACLs")
+		}
+		props["aclinherit"] = string(o.ACLInherit)
+	default:
+		return nil, fmt.Errorf("invalid aclinherit %q", o.ACLInherit)
+	}
+
+	switch o.Xattr {
+	case "":
+	case XattrOff, XattrOn, XattrDir, XattrSA:
+		if o.Xattr == XattrSA && !p.xattrSA {
+			return nil, fmt.Errorf("xattr sa is not supported on %s", p.name)
+		}
+		if o.Xattr == XattrOff && o.ACLType == ACLTypePOSIX {
+			return nil, errors.New("POSIX ACLs are stored as extended attributes and require xattr to be enabled")
+		}
+		props["xattr"] = string(o.Xattr)
+	default:
+		return nil, fmt.Errorf("invalid xattr %q", o.Xattr)
+	}
+
+	if len(props) == 0 {
+		return nil, errors.New("no ACL options given")
+	}
+	return props, nil
+}
+
+// SetACLOptions validates the options and sets them on the receiving dataset in a single `zfs set`.
+func (d *Dataset) SetACLOptions(opts ACLOptions) error {
+	props, err := opts.Properties()
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	args := []string{"set"}
+	for _, k := range keys {
+		args = append(args, k+"="+props[k])
+	}
+	return zfs(append(args, d.Name)...)
+}
+
+// GetACLOptions returns the ACL and extended attribute properties of the receiving dataset.
+// Properties that do not exist on the current platform are left empty.
+func (d *Dataset) GetACLOptions() (*ACLOptions, error) {
+	props := "aclmode,aclinherit,xattr"
+	if currentPlatform.aclTypes != nil {
+		props = "acltype," + props
+	}
+	out, err := zfsOutput("get", "-Hp", "-o", "property,value", props, d.Name)
+	if err != nil {
+		return nil, err
+	}
+	return parseACLOptions(out)
+}
+
+func parseACLOptions(lines [][]string) (*ACLOptions, error) {
+	o := &ACLOptions{}
+	for _, line := range lines {
+		if len(line) != 2 {
+			return nil, errors.New("output does not match what is expected on this platform")
+		}
+		var val string
+		setString(&val, line[1])
+		switch line[0] {
+		case "acltype":
+			// normalize the legacy aliases
+			switch val {
+			case "noacl":
+				val = string(ACLTypeOff)
+			case "posixacl":
+				val = string(ACLTypePOSIX)
+			}
+			o.ACLType = ACLType(val)
+		case "aclmode":
+			o.ACLMode = ACLMode(val)
+		case "aclinherit":
+			o.ACLInherit = ACLInherit(val)
+		case "xattr":
+			o.Xattr = XattrMode(val)
+		}
+	}
+	return o, nil
+}
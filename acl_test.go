@@ -0,0 +1,74 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestACLOptionsProperties(t *testing.T) {
+	tests := map[string]struct {
+		platform *platform
+		opts     ACLOptions
+		want     map[string]string
+		err      bool
+	}{
+		"linux posix with sa": {
+			platform: &linuxPlatform,
+			opts:     ACLOptions{ACLType: ACLTypePOSIX, Xattr: XattrSA},
+			want:     map[string]string{"acltype": "posix", "xattr": "sa"},
+		},
+		"freebsd nfsv4 passthrough": {
+			platform: &freebsdPlatform,
+			opts:     ACLOptions{ACLType: ACLTypeNFSv4, ACLMode: ACLModePassthrough, ACLInherit: ACLInheritPassthroughX},
+			want:     map[string]string{"acltype": "nfsv4", "aclmode": "passthrough", "aclinherit": "passthrough-x"},
+		},
+		"solaris aclinherit": {
+			platform: &solarisPlatform,
+			opts:     ACLOptions{ACLInherit: ACLInheritRestricted},
+			want:     map[string]string{"aclinherit": "restricted"},
+		},
+		"empty":                 {platform: &linuxPlatform, err: true},
+		"invalid acltype":       {platform: &linuxPlatform, opts: ACLOptions{ACLType: "posixish"}, err: true},
+		"nfsv4 on linux":        {platform: &linuxPlatform, opts: ACLOptions{ACLType: ACLTypeNFSv4}, err: true},
+		"posix on freebsd":      {platform: &freebsdPlatform, opts: ACLOptions{ACLType: ACLTypePOSIX}, err: true},
+		"acltype on solaris":    {platform: &solarisPlatform, opts: ACLOptions{ACLType: ACLTypeNFSv4}, err: true},
+		"aclmode on linux":      {platform: &linuxPlatform, opts: ACLOptions{ACLMode: ACLModeDiscard}, err: true},
+		"aclinherit with posix": {platform: &freebsdPlatform, opts: ACLOptions{ACLType: ACLTypeOff, ACLInherit: ACLInheritNoallow}, err: true},
+		"posix without xattrs":  {platform: &linuxPlatform, opts: ACLOptions{ACLType: ACLTypePOSIX, Xattr: XattrOff}, err: true},
+		"sa on illumos":         {platform: &solarisPlatform, opts: ACLOptions{Xattr: XattrSA}, err: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := test.opts.properties(test.platform)
+			if test.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(test.want, got) {
+				t.Fatalf("wanted: %v, got: %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestParseACLOptions(t *testing.T) {
+	got, err := parseACLOptions([][]string{
+		{"acltype", "posixacl"},
+		{"aclmode", "discard"},
+		{"aclinherit", "restricted"},
+		{"xattr", "sa"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := &ACLOptions{ACLType: ACLTypePOSIX, ACLMode: ACLModeDiscard, ACLInherit: ACLInheritRestricted, Xattr: XattrSA}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %+v, got: %+v", want, got)
+	}
+}
@@ -0,0 +1,266 @@
+package zfs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Permission is a delegated permission as used by `zfs allow`.
+// Besides the constants below, property names (e.g. "compression") and permission set names (e.g. "@backup") are valid permissions.
+type Permission string
+
+// Commonly delegated permissions.
+const (
+	PermissionAllow      Permission = "allow"
+	PermissionBookmark   Permission = "bookmark"
+	PermissionClone      Permission = "clone"
+	PermissionCreate     Permission = "create"
+	PermissionDestroy    Permission = "destroy"
+	PermissionDiff       Permission = "diff"
+	PermissionHold       Permission = "hold"
+	PermissionLoadKey    Permission = "load-key"
+	PermissionChangeKey  Permission = "change-key"
+	PermissionMount      Permission = "mount"
+	PermissionPromote    Permission = "promote"
+	PermissionReceive    Permission = "receive"
+	PermissionRelease    Permission = "release"
+	PermissionRename     Permission = "rename"
+	PermissionRollback   Permission = "rollback"
+	PermissionSend       Permission = "send"
+	PermissionShare      Permission = "share"
+	PermissionSnapshot   Permission = "snapshot"
+	PermissionUserprop   Permission = "userprop"
+	PermissionUserquota  Permission = "userquota"
+	PermissionGroupquota Permission = "groupquota"
+	PermissionUserused   Permission = "userused"
+	PermissionGroupused  Permission = "groupused"
+)
+
+// DelegationTarget is the kind of principal that permissions are delegated to.
+type DelegationTarget string
+
+// Delegation targets.
+const (
+	TargetUser     DelegationTarget = "user"
+	TargetGroup    DelegationTarget = "group"
+	TargetEveryone DelegationTarget = "everyone"
+)
+
+// DelegationScope controls whether delegated permissions apply to the dataset itself, its descendents, or both.
+type DelegationScope int
+
+// Delegation scopes.
+const (
+	ScopeLocalDescendent DelegationScope = iota
+	ScopeLocal
+	ScopeDescendent
+)
+
+// Delegation grants a set of permissions to a user, a group or everyone.
+type Delegation struct {
+	Target DelegationTarget `json:"target"`
+	// Name is the user or group name, it is empty for TargetEveryone.
+	Name        string          `json:"name"`
+	Scope       DelegationScope `json:"scope"`
+	Permissions []Permission    `json:"permissions"`
+}
+
+// PermissionTable is the set of delegated permissions defined on a single dataset.
+type PermissionTable struct {
+	Dataset string `json:"dataset"`
+	// Sets maps the permission set names, including the leading "@", to their permissions.
+	Sets map[string][]Permission `json:"sets"`
+	// CreateTime are the permissions granted to the creator of a descendent dataset.
+	CreateTime  []Permission `json:"create_time"`
+	Delegations []Delegation `json:"delegations"`
+}
+
+func joinPermissions(perms []Permission) (string, error) {
+	if len(perms) == 0 {
+		return "", errors.New("no permissions given")
+	}
+	s := make([]string, len(perms))
+	for i, p := range perms {
+		if p == "" || strings.ContainsAny(string(p), ", \t") {
+			return "", fmt.Errorf("invalid permission %q", p)
+		}
+		s[i] = string(p)
+	}
+	return strings.Join(s, ","), nil
+}
+
+func (d Delegation) args(allowEmpty bool) ([]string, error) {
+	var args []string
+	switch d.Scope {
+	case ScopeLocalDescendent:
+	case ScopeLocal:
+		args = append(args, "-l")
+	case ScopeDescendent:
+		args = append(args, "-d")
+	default:
+		return nil, fmt.Errorf("invalid delegation scope %d", d.Scope)
+	}
+
+	switch d.Target {
+	case TargetUser, TargetGroup:
+		if d.Name == "" {
+			return nil, fmt.Errorf("a %s delegation requires a Name", d.Target)
+		}
+		args = append(args, "-"+string(d.Target[0]), d.Name)
+	case TargetEveryone:
+		if d.Name != "" {
+			return nil, errors.New("an everyone delegation cannot have a Name")
+		}
+		args = append(args, "-e")
+	default:
+		return nil, fmt.Errorf("invalid delegation target %q", d.Target)
+	}
+
+	if allowEmpty && len(d.Permissions) == 0 {
+		return args, nil
+	}
+	perms, err := joinPermissions(d.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	return append(args, perms), nil
+}
+
+// Allow delegates permissions on the receiving dataset.
+func (d *Dataset) Allow(delegation Delegation) error {
+	args, err := delegation.args(false)
+	if err != nil {
+		return err
+	}
+	args = append([]string{"allow"}, args...)
+	return zfs(append(args, d.Name)...)
+}
+
+// AllowCreateTime sets the permissions granted to the creator of a descendent dataset.
+func (d *Dataset) AllowCreateTime(perms ...Permission) error {
+	p, err := joinPermissions(perms)
+	if err != nil {
+		return err
+	}
+	return zfs("allow", "-c", p, d.Name)
+}
+
+// DefinePermissionSet defines or extends a named permission set on the receiving dataset.
+// The name must start with "@".
+func (d *Dataset) DefinePermissionSet(name string, perms ...Permission) error {
+	if len(name) < 2 || name[0] != '@' {
+		return fmt.Errorf("invalid permission set name %q", name)
+	}
+	p, err := joinPermissions(perms)
+	if err != nil {
+		return err
+	}
+	return zfs("allow", "-s", name, p, d.Name)
+}
+
+// Unallow revokes delegated permissions on the receiving dataset.
+// Leaving Permissions empty revokes all permissions of the target.
+// Optionally, the permissions are revoked on all descendents as well.
+func (d *Dataset) Unallow(delegation Delegation, recursive bool) error {
+	args, err := delegation.args(true)
+	if err != nil {
+		return err
+	}
+	if recursive {
+		args = append([]string{"-r"}, args...)
+	}
+	args = append([]string{"unallow"}, args...)
+	return zfs(append(args, d.Name)...)
+}
+
+// Permissions returns the delegated permissions that apply to the receiving dataset.
+// The first table is that of the dataset itself, followed by those inherited from its ancestors.
+func (d *Dataset) Permissions() ([]PermissionTable, error) {
+	out, err := zfsOutput("allow", d.Name)
+	if err != nil {
+		return nil, err
+	}
+	return parsePermissions(out)
+}
+
+func splitPermissions(s string) []Permission {
+	var perms []Permission
+	for _, p := range strings.Split(s, ",") {
+		if p != "" {
+			perms = append(perms, Permission(p))
+		}
+	}
+	return perms
+}
+
+// parsePermissions parses the output of `zfs allow <dataset>`.
+func parsePermissions(lines [][]string) ([]PermissionTable, error) {
+	var tables []PermissionTable
+	var section string
+	for _, fields := range lines {
+		line := strings.TrimSpace(strings.Join(fields, "\t"))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "---- Permissions on ") {
+			name := strings.TrimPrefix(line, "---- Permissions on ")
+			// the name may itself contain spaces and dashes, only the trailing " ----" is cut off
+			name = strings.TrimSuffix(strings.TrimRight(name, "-"), " ")
+			tables = append(tables, PermissionTable{Dataset: name})
+			section = ""
+			continue
+		}
+		if len(tables) == 0 {
+			return nil, fmt.Errorf("unexpected line before permission table: %q", line)
+		}
+		t := &tables[len(tables)-1]
+
+		if strings.HasSuffix(line, ":") {
+			section = strings.TrimSuffix(line, ":")
+			continue
+		}
+
+		f := strings.Fields(line)
+		var scope DelegationScope
+		switch section {
+		case "Permission sets":
+			if len(f) != 2 {
+				return nil, fmt.Errorf("unexpected permission set line: %q", line)
+			}
+			if t.Sets == nil {
+				t.Sets = map[string][]Permission{}
+			}
+			t.Sets[f[0]] = splitPermissions(f[1])
+			continue
+		case "Create time permissions":
+			if len(f) != 1 {
+				return nil, fmt.Errorf("unexpected create time permission line: %q", line)
+			}
+			t.CreateTime = splitPermissions(f[0])
+			continue
+		case "Local permissions":
+			scope = ScopeLocal
+		case "Descendent permissions":
+			scope = ScopeDescendent
+		case "Local+Descendent permissions":
+			scope = ScopeLocalDescendent
+		default:
+			return nil, fmt.Errorf("unexpected permission section %q", section)
+		}
+
+		del := Delegation{Target: DelegationTarget(f[0]), Scope: scope}
+		switch {
+		case del.Target == TargetEveryone && len(f) == 2:
+			del.Permissions = splitPermissions(f[1])
+		case (del.Target == TargetUser || del.Target == TargetGroup) && len(f) == 3:
+			del.Name = f[1]
+			del.Permissions = splitPermissions(f[2])
+		default:
+			return nil, fmt.Errorf("unexpected delegation line: %q", line)
+		}
+		t.Delegations = append(t.Delegations, del)
+	}
+	return tables, nil
+}
@@ -0,0 +1,129 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDelegationArgs(t *testing.T) {
+	tests := []struct {
+		name       string
+		delegation Delegation
+		allowEmpty bool
+		want       []string
+		err        bool
+	}{
+		{
+			name:       "user local+descendent",
+			delegation: Delegation{Target: TargetUser, Name: "alice", Permissions: []Permission{PermissionCreate, PermissionMount}},
+			want:       []string{"-u", "alice", "create,mount"},
+		},
+		{
+			name:       "group local",
+			delegation: Delegation{Target: TargetGroup, Name: "staff", Scope: ScopeLocal, Permissions: []Permission{"@backup"}},
+			want:       []string{"-l", "-g", "staff", "@backup"},
+		},
+		{
+			name:       "everyone descendent",
+			delegation: Delegation{Target: TargetEveryone, Scope: ScopeDescendent, Permissions: []Permission{"compression"}},
+			want:       []string{"-d", "-e", "compression"},
+		},
+		{
+			name:       "unallow everything",
+			delegation: Delegation{Target: TargetUser, Name: "alice"},
+			allowEmpty: true,
+			want:       []string{"-u", "alice"},
+		},
+		{
+			name:       "no permissions",
+			delegation: Delegation{Target: TargetUser, Name: "alice"},
+			err:        true,
+		},
+		{
+			name:       "missing name",
+			delegation: Delegation{Target: TargetUser, Permissions: []Permission{PermissionMount}},
+			err:        true,
+		},
+		{
+			name:       "everyone with name",
+			delegation: Delegation{Target: TargetEveryone, Name: "alice", Permissions: []Permission{PermissionMount}},
+			err:        true,
+		},
+		{
+			name:       "invalid permission",
+			delegation: Delegation{Target: TargetEveryone, Permissions: []Permission{"create,mount"}},
+			err:        true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.delegation.args(test.allowEmpty)
+			if test.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(test.want, got) {
+				t.Fatalf("wanted: %v, got: %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestParsePermissions(t *testing.T) {
+	out := [][]string{
+		{"---- Permissions on tank/home ----------------------------------------"},
+		{"Permission sets:"},
+		{"", "@backup send,snapshot,hold"},
+		{"Create time permissions:"},
+		{"", "destroy,mount"},
+		{"Local permissions:"},
+		{"", "user alice create,mount"},
+		{"Descendent permissions:"},
+		{"", "group staff snapshot"},
+		{"Local+Descendent permissions:"},
+		{"", "everyone @backup"},
+		{"---- Permissions on tank ---------------------------------------------"},
+		{"Local+Descendent permissions:"},
+		{"", "user bob destroy"},
+	}
+	got, err := parsePermissions(out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []PermissionTable{
+		{
+			Dataset:    "tank/home",
+			Sets:       map[string][]Permission{"@backup": {PermissionSend, PermissionSnapshot, PermissionHold}},
+			CreateTime: []Permission{PermissionDestroy, PermissionMount},
+			Delegations: []Delegation{
+				{Target: TargetUser, Name: "alice", Scope: ScopeLocal, Permissions: []Permission{PermissionCreate, PermissionMount}},
+				{Target: TargetGroup, Name: "staff", Scope: ScopeDescendent, Permissions: []Permission{PermissionSnapshot}},
+				{Target: TargetEveryone, Scope: ScopeLocalDescendent, Permissions: []Permission{"@backup"}},
+			},
+		},
+		{
+			Dataset: "tank",
+			Delegations: []Delegation{
+				{Target: TargetUser, Name: "bob", Scope: ScopeLocalDescendent, Permissions: []Permission{PermissionDestroy}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %+v, got: %+v", want, got)
+	}
+
+	got, err = parsePermissions(nil)
+	if err != nil || got != nil {
+		t.Fatalf("wanted no tables, got: %v, %v", got, err)
+	}
+
+	if _, err := parsePermissions([][]string{{"Local permissions:"}}); err == nil {
+		t.Fatal("expected an error for a section without a table")
+	}
+}
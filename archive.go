@@ -0,0 +1,167 @@
+package zfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ErrStreamCorrupt is returned by VerifyStreamFile when a stream file does not match its manifest.
+var ErrStreamCorrupt = errors.New("stream file does not match its manifest")
+
+// StreamManifest describes a send stream written to a file by SendToFile.
+type StreamManifest struct {
+	// Snapshot is the sent snapshot, and GUID its GUID.
+	Snapshot string `json:"snapshot"`
+	GUID     uint64 `json:"guid,string"`
+	// IncrementalBase is the full name of the snapshot or bookmark an incremental stream was generated from,
+	// and BaseGUID its GUID. The stream can only be received by a dataset holding a snapshot with that GUID.
+	IncrementalBase string `json:"incremental_base,omitempty"`
+	BaseGUID        uint64 `json:"base_guid,string,omitempty"`
+	// Size is the size of the stream file in bytes, and SHA256 the hex encoded SHA-256 digest of its contents.
+	Size    uint64    `json:"size"`
+	SHA256  string    `json:"sha256"`
+	Created time.Time `json:"created"`
+}
+
+// ManifestPath returns the path of the manifest of the stream file at path.
+func ManifestPath(path string) string {
+	return path + ".manifest.json"
+}
+
+// SendToFile writes the send stream of this snapshot with opts to the file at path, and its manifest
+// to ManifestPath(path), e.g. to archive backups as files. VerifyStreamFile detects if the file was changed since.
+// The stream is written to a temporary file first, which is renamed to path once it is complete and synced.
+// An existing manifest is removed before, so VerifyStreamFile never checks a new stream against an old manifest.
+// The send is aborted when ctx is cancelled, in which case the returned error wraps ctx.Err().
+func (d *Dataset) SendToFile(ctx context.Context, path string, opts SendOptions) (*StreamManifest, error) {
+	if d.Type != DatasetSnapshot {
+		return nil, errors.New("can only send snapshots")
+	}
+
+	m := &StreamManifest{Snapshot: d.Name, GUID: d.GUID}
+	if m.GUID == 0 {
+		guid, err := getProperty(d.Name, "guid")
+		if err != nil {
+			return nil, err
+		}
+		if m.GUID, err = strconv.ParseUint(guid, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	if opts.IncrementalBase != "" {
+		m.IncrementalBase = resolveIncrementalBase(d.Name, opts.IncrementalBase)
+		guid, err := getProperty(m.IncrementalBase, "guid")
+		if err != nil {
+			return nil, err
+		}
+		if m.BaseGUID, err = strconv.ParseUint(guid, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+
+	m.Created = time.Now().UTC()
+	err := writeStreamFile(path, m, func(w io.Writer) error {
+		return d.SendTo(ctx, w, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// writeStreamFile writes the stream send writes to the file at path, recording its size and digest in m,
+// and writes m to ManifestPath(path). A manifest left from an earlier stream at path is removed before
+// the new stream replaces it, so the old manifest is never paired with the new stream.
+func writeStreamFile(path string, m *StreamManifest, send func(w io.Writer) error) error {
+	tmp := path + ".partial"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	h := sha256.New()
+	c := &countingWriter{w: io.MultiWriter(f, h)}
+	if err := send(c); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	m.Size = c.n
+	m.SHA256 = hex.EncodeToString(h.Sum(nil))
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	manifest := ManifestPath(path)
+	if err := ioutil.WriteFile(manifest+".partial", append(b, '\n'), 0o600); err != nil {
+		return err
+	}
+	defer os.Remove(manifest + ".partial")
+	if err := os.Remove(manifest); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return os.Rename(manifest+".partial", manifest)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n uint64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += uint64(n)
+	return n, err
+}
+
+// VerifyStreamFile checks the stream file at path against its manifest, written by SendToFile,
+// and returns the manifest. ErrStreamCorrupt is returned if the file's size or digest do not match.
+// The stream itself is not parsed; receiving it, or DumpStream, detects streams that were corrupt to begin with.
+func VerifyStreamFile(path string) (*StreamManifest, error) {
+	b, err := ioutil.ReadFile(ManifestPath(path))
+	if err != nil {
+		return nil, err
+	}
+	m := &StreamManifest{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("invalid stream manifest: %w", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(n) != m.Size {
+		return m, fmt.Errorf("%w: %s is %d bytes, expected %d", ErrStreamCorrupt, path, n, m.Size)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != m.SHA256 {
+		return m, fmt.Errorf("%w: %s has SHA-256 %s, expected %s", ErrStreamCorrupt, path, sum, m.SHA256)
+	}
+	return m, nil
+}
@@ -0,0 +1,80 @@
+package zfs
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stream-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tank-fs@snap.zfs")
+
+	m := &StreamManifest{Snapshot: "tank/fs@snap", GUID: 42, IncrementalBase: "tank/fs@base", BaseGUID: 41, Created: time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)}
+	err = writeStreamFile(path, m, func(w io.Writer) error {
+		_, err := io.WriteString(w, "stream")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.Size != 6 || m.SHA256 != "dca83e717b1f64eb141057a7415a330ad1361f51703efa2e4776f40047898a04" {
+		t.Fatalf("unexpected manifest: %+v", m)
+	}
+
+	got, err := VerifyStreamFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(m, got) {
+		t.Fatalf("wanted: %+v, got: %+v", m, got)
+	}
+	// GUIDs are strings, as they exceed the integers JSON consumers such as JavaScript represent exactly
+	if b, err := ioutil.ReadFile(ManifestPath(path)); err != nil || !strings.Contains(string(b), `"guid": "42"`) || !strings.Contains(string(b), `"base_guid": "41"`) {
+		t.Fatalf("wanted: GUIDs as strings, got: %s %v", b, err)
+	}
+
+	// a failed send leaves neither the stream nor a manifest behind
+	failed := filepath.Join(dir, "failed.zfs")
+	errSend := errors.New("send failed")
+	if err := writeStreamFile(failed, &StreamManifest{}, func(io.Writer) error { return errSend }); err != errSend {
+		t.Fatalf("wanted: %v, got: %v", errSend, err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "failed*")); len(files) != 0 {
+		t.Fatalf("wanted no files, got: %v", files)
+	}
+
+	// rewriting a stream file replaces its manifest as well
+	err = writeStreamFile(path, &StreamManifest{Snapshot: "tank/fs@snap2"}, func(w io.Writer) error {
+		_, err := io.WriteString(w, "stream2")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, err := VerifyStreamFile(path); err != nil || got.Snapshot != "tank/fs@snap2" || got.Size != 7 {
+		t.Fatalf("wanted: the manifest of tank/fs@snap2, got: %+v %v", got, err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.partial")); len(files) != 0 {
+		t.Fatalf("wanted no partial files, got: %v", files)
+	}
+
+	for _, content := range []string{"streAm", "stream!"} {
+		if err := ioutil.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := VerifyStreamFile(path); !errors.Is(err, ErrStreamCorrupt) {
+			t.Fatalf("wanted: %v, got: %v", ErrStreamCorrupt, err)
+		}
+	}
+}
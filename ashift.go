@@ -0,0 +1,196 @@
+package zfs
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Bounds of the ashift pool property.
+const (
+	minAshift = 9
+	maxAshift = 16
+)
+
+// SectorSize is the logical and physical sector size of a block device, in bytes.
+type SectorSize struct {
+	Device   string `json:"device"`
+	Logical  uint64 `json:"logical"`
+	Physical uint64 `json:"physical"`
+}
+
+// Emulated reports whether the device exposes smaller logical sectors than its physical ones, as 512e drives do.
+// Such devices work with a smaller ashift, but every write then turns into a read-modify-write.
+func (s SectorSize) Emulated() bool {
+	return s.Physical > s.Logical
+}
+
+// Ashift returns the ashift matching the physical sector size of the device.
+func (s SectorSize) Ashift() uint {
+	return sizeToAshift(s.Physical)
+}
+
+func sizeToAshift(size uint64) uint {
+	ashift := uint(minAshift)
+	for ashift < maxAshift && uint64(1)<<ashift < size {
+		ashift++
+	}
+	return ashift
+}
+
+// DetectSectorSize returns the sector sizes of the given block device or partition.
+func DetectSectorSize(device string) (*SectorSize, error) {
+	if currentPlatform.sectorSize == nil {
+		return nil, fmt.Errorf("detecting sector sizes is not supported on %s", currentPlatform.name)
+	}
+	logical, physical, err := currentPlatform.sectorSize(device)
+	if err != nil {
+		return nil, err
+	}
+	if physical < logical {
+		physical = logical
+	}
+	return &SectorSize{Device: device, Logical: logical, Physical: physical}, nil
+}
+
+func detectSectorSizes(devices []string) ([]SectorSize, error) {
+	if len(devices) == 0 {
+		return nil, errors.New("no devices given")
+	}
+	sizes := make([]SectorSize, len(devices))
+	for i, dev := range devices {
+		s, err := DetectSectorSize(dev)
+		if err != nil {
+			return nil, err
+		}
+		sizes[i] = *s
+	}
+	return sizes, nil
+}
+
+// RecommendAshift returns the ashift a new vdev made of the given devices should use,
+// which is the one matching the largest physical sector size among them.
+func RecommendAshift(devices ...string) (uint, error) {
+	sizes, err := detectSectorSizes(devices)
+	if err != nil {
+		return 0, err
+	}
+	return recommendAshift(sizes), nil
+}
+
+func recommendAshift(sizes []SectorSize) uint {
+	ashift := uint(minAshift)
+	for _, s := range sizes {
+		if a := s.Ashift(); a > ashift {
+			ashift = a
+		}
+	}
+	return ashift
+}
+
+// ValidateAshift checks that ashift suits all of the given devices before they are used in a new vdev.
+// It fails if ashift is below a device's logical sector size, which ZFS refuses,
+// or below its physical sector size, which badly hurts the performance of 512e drives.
+func ValidateAshift(ashift uint, devices ...string) error {
+	sizes, err := detectSectorSizes(devices)
+	if err != nil {
+		return err
+	}
+	return validateAshift(ashift, sizes)
+}
+
+func validateAshift(ashift uint, sizes []SectorSize) error {
+	if ashift < minAshift || ashift > maxAshift {
+		return fmt.Errorf("ashift must be between %d and %d, got %d", minAshift, maxAshift, ashift)
+	}
+	for _, s := range sizes {
+		if a := sizeToAshift(s.Logical); ashift < a {
+			return fmt.Errorf("ashift %d is below the %d byte logical sectors of %s, which requires ashift %d", ashift, s.Logical, s.Device, a)
+		}
+		if a := s.Ashift(); ashift < a {
+			if s.Emulated() {
+				return fmt.Errorf("ashift %d is below the %d byte physical sectors of %s (%d byte emulated), use ashift %d", ashift, s.Physical, s.Device, s.Logical, a)
+			}
+			return fmt.Errorf("ashift %d is below the %d byte physical sectors of %s, use ashift %d", ashift, s.Physical, s.Device, a)
+		}
+	}
+	return nil
+}
+
+// sysfsSectorSize reads the sector sizes of a Linux block device from sysfs.
+func sysfsSectorSize(device string) (logical, physical uint64, err error) {
+	dev, err := filepath.EvalSymlinks(device)
+	if err != nil {
+		return 0, 0, err
+	}
+	return readSysfsSectorSize("/sys", filepath.Base(dev))
+}
+
+func readSysfsSectorSize(sysfs, name string) (logical, physical uint64, err error) {
+	dir, err := filepath.EvalSymlinks(filepath.Join(sysfs, "class", "block", name))
+	if err != nil {
+		return 0, 0, err
+	}
+	// partitions share the queue of their parent disk
+	queue := filepath.Join(dir, "queue")
+	if _, err := ioutil.ReadDir(queue); err != nil {
+		queue = filepath.Join(filepath.Dir(dir), "queue")
+	}
+
+	read := func(file string) (uint64, error) {
+		b, err := ioutil.ReadFile(filepath.Join(queue, file))
+		if err != nil {
+			return 0, err
+		}
+		return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	}
+	if logical, err = read("logical_block_size"); err != nil {
+		return 0, 0, err
+	}
+	if physical, err = read("physical_block_size"); err != nil {
+		return 0, 0, err
+	}
+	return logical, physical, nil
+}
+
+// diskinfoSectorSize reads the sector sizes of a FreeBSD block device using diskinfo(8).
+func diskinfoSectorSize(device string) (logical, physical uint64, err error) {
+	c := command{Command: "diskinfo"}
+	out, err := c.Run("-v", device)
+	if err != nil {
+		return 0, 0, err
+	}
+	return parseDiskinfo(out)
+}
+
+// parseDiskinfo parses the output of `diskinfo -v`, where the stripe size is the physical sector size if set.
+func parseDiskinfo(lines [][]string) (logical, physical uint64, err error) {
+	for _, line := range lines {
+		fields := strings.Fields(strings.Join(line, " "))
+		if len(fields) < 3 || fields[1] != "#" {
+			continue
+		}
+		var val *uint64
+		switch fields[2] {
+		case "sectorsize":
+			val = &logical
+		case "stripesize":
+			val = &physical
+		default:
+			continue
+		}
+		if *val, err = strconv.ParseUint(fields[0], 10, 64); err != nil {
+			return 0, 0, err
+		}
+	}
+	if logical == 0 {
+		return 0, 0, errors.New("sectorsize not found in diskinfo output")
+	}
+	if physical == 0 {
+		physical = logical
+	}
+	return logical, physical, nil
+}
@@ -0,0 +1,105 @@
+package zfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecommendAshift(t *testing.T) {
+	tests := []struct {
+		name  string
+		sizes []SectorSize
+		want  uint
+	}{
+		{name: "512n", sizes: []SectorSize{{Device: "sda", Logical: 512, Physical: 512}}, want: 9},
+		{name: "512e", sizes: []SectorSize{{Device: "sda", Logical: 512, Physical: 4096}}, want: 12},
+		{name: "mixed", sizes: []SectorSize{{Device: "sda", Logical: 512, Physical: 512}, {Device: "nvme0n1", Logical: 4096, Physical: 4096}}, want: 12},
+		{name: "huge", sizes: []SectorSize{{Device: "sda", Logical: 4096, Physical: 1 << 20}}, want: 16},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := recommendAshift(test.sizes); got != test.want {
+				t.Fatalf("wanted: %v, got: %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateAshift(t *testing.T) {
+	e512 := []SectorSize{{Device: "sda", Logical: 512, Physical: 4096}}
+	n4k := []SectorSize{{Device: "nvme0n1", Logical: 4096, Physical: 4096}}
+
+	if err := validateAshift(12, e512); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := validateAshift(13, n4k); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := validateAshift(9, e512); err == nil {
+		t.Fatal("expected an error for a 512e drive with ashift 9")
+	}
+	if err := validateAshift(9, n4k); err == nil {
+		t.Fatal("expected an error for ashift below the logical sector size")
+	}
+	if err := validateAshift(17, n4k); err == nil {
+		t.Fatal("expected an error for ashift out of range")
+	}
+}
+
+func TestReadSysfsSectorSize(t *testing.T) {
+	sysfs, err := ioutil.TempDir("", "sysfs-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(sysfs)
+
+	disk := filepath.Join(sysfs, "devices", "pci0000:00", "sda")
+	for _, dir := range []string{filepath.Join(disk, "queue"), filepath.Join(disk, "sda1"), filepath.Join(sysfs, "class", "block")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for file, val := range map[string]string{"logical_block_size": "512\n", "physical_block_size": "4096\n"} {
+		if err := ioutil.WriteFile(filepath.Join(disk, "queue", file), []byte(val), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"sda", "sda/sda1"} {
+		if err := os.Symlink(filepath.Join(sysfs, "devices", "pci0000:00", name), filepath.Join(sysfs, "class", "block", filepath.Base(name))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"sda", "sda1"} {
+		logical, physical, err := readSysfsSectorSize(sysfs, name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if logical != 512 || physical != 4096 {
+			t.Fatalf("wanted: 512/4096, got: %d/%d", logical, physical)
+		}
+	}
+}
+
+func TestParseDiskinfo(t *testing.T) {
+	out := splitOutput("/dev/ada0\n" +
+		"\t512         \t# sectorsize\n" +
+		"\t500107862016\t# mediasize in bytes (466G)\n" +
+		"\t976773168   \t# mediasize in sectors\n" +
+		"\t4096        \t# stripesize\n" +
+		"\t0           \t# stripeoffset\n")
+	logical, physical, err := parseDiskinfo(out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if logical != 512 || physical != 4096 {
+		t.Fatalf("wanted: 512/4096, got: %d/%d", logical, physical)
+	}
+
+	if _, _, err := parseDiskinfo(splitOutput("/dev/ada0\n")); err == nil {
+		t.Fatal("expected an error without a sectorsize")
+	}
+}
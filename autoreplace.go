@@ -0,0 +1,205 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SparePolicy picks the device that replaces a failed disk of a pool.
+// Returning an empty device leaves the disk alone, e.g. when no spare is left.
+type SparePolicy func(pool string, failed *ZpoolVdev) (string, error)
+
+// SpareDevices returns a SparePolicy that hands out the given devices in order, each at most once.
+func SpareDevices(devices ...string) SparePolicy {
+	var mu sync.Mutex
+	devices = append([]string(nil), devices...)
+	return func(string, *ZpoolVdev) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(devices) == 0 {
+			return "", nil
+		}
+		dev := devices[0]
+		devices = devices[1:]
+		return dev, nil
+	}
+}
+
+// ReplaceStage is the stage an automatic replacement has reached.
+type ReplaceStage int
+
+// Replacement stages.
+const (
+	// ReplaceStarted is reported once the failed disk was offlined and `zpool replace` started resilvering.
+	ReplaceStarted ReplaceStage = iota
+	// ReplaceResilvered is reported once resilvering finished and the failed disk left the pool.
+	ReplaceResilvered
+	// ReplaceFailed is reported if picking a spare, offlining or replacing failed. The disk is not retried.
+	ReplaceFailed
+)
+
+// ReplaceEvent reports the progress of an automatic replacement.
+type ReplaceEvent struct {
+	Pool string
+	// Device is the name of the failed disk, and GUID its vdev GUID.
+	Device string
+	GUID   string
+	// Replacement is the device replacing it, empty if the spare policy failed.
+	Replacement string
+	Stage       ReplaceStage
+	Err         error
+}
+
+// failedStates are the states of leaf vdevs that are replaced.
+var failedStates = map[string]bool{
+	ZpoolFaulted: true,
+	ZpoolUnavail: true,
+	ZpoolRemoved: true,
+}
+
+// AutoReplacer watches a pool for failed disks and replaces them with devices picked by a SparePolicy,
+// the core of a self-healing storage agent.
+type AutoReplacer struct {
+	Pool  string
+	Spare SparePolicy
+	// Interval is the time between checks of the pool's status.
+	Interval time.Duration
+	// OnEvent, if set, is called as replacements progress.
+	OnEvent func(ReplaceEvent)
+	// OnError, if set, is called with the errors retrieving the pool's status, which are otherwise discarded.
+	OnError func(err error)
+
+	// status and zpool are replaced in tests.
+	status func() (*ZpoolStatus, error)
+	zpool  func(ctx context.Context, arg ...string) error
+}
+
+// Run checks the pool every interval and replaces failed disks until ctx is cancelled, and returns ctx.Err().
+// Errors retrieving the pool's status, e.g. while the pool is busy, are passed to OnError and the pool is checked
+// again after the next interval.
+func (a *AutoReplacer) Run(ctx context.Context) error {
+	if a.Spare == nil {
+		return errors.New("a SparePolicy is required")
+	}
+	if a.Interval <= 0 {
+		return errors.New("a positive Interval is required")
+	}
+	status := a.status
+	if status == nil {
+		status = func() (*ZpoolStatus, error) {
+			return GetZpoolStatusWithOptions(a.Pool, ZpoolStatusOptions{Parsable: true})
+		}
+	}
+	run := a.zpool
+	if run == nil {
+		run = func(ctx context.Context, arg ...string) error {
+			c := command{Command: "zpool", Ctx: ctx}
+			_, err := c.Run(arg...)
+			return err
+		}
+	}
+
+	// handled maps the GUIDs of failed disks to their last event
+	handled := map[string]ReplaceEvent{}
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+	for {
+		s, err := status()
+		if err == nil {
+			a.check(ctx, s, handled, run)
+		} else if a.OnError != nil {
+			a.OnError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *AutoReplacer) check(ctx context.Context, s *ZpoolStatus, handled map[string]ReplaceEvent, run func(context.Context, ...string) error) {
+	current := map[string]*ZpoolVdev{}
+	var failed []*ZpoolVdev
+	for _, class := range vdevClasses {
+		// spares and cache devices cannot be replaced, only removed
+		if class == VdevClassSpare || class == VdevClassCache {
+			continue
+		}
+		for _, root := range s.classRoots(class) {
+			walkLeafVdevs(root, false, func(v *ZpoolVdev, replacing bool) {
+				current[v.GUID] = v
+				if failedStates[v.State] && !replacing {
+					failed = append(failed, v)
+				}
+			})
+		}
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i].Name < failed[j].Name })
+
+	// replacements are done once the failed disk has left the pool
+	guids := make([]string, 0, len(handled))
+	for guid := range handled {
+		guids = append(guids, guid)
+	}
+	sort.Strings(guids)
+	for _, guid := range guids {
+		ev := handled[guid]
+		if _, ok := current[guid]; ok || ev.Stage != ReplaceStarted {
+			continue
+		}
+		ev.Stage = ReplaceResilvered
+		a.report(ev)
+		delete(handled, guid)
+	}
+
+	for _, v := range failed {
+		if _, ok := handled[v.GUID]; ok {
+			continue
+		}
+		ev := ReplaceEvent{Pool: a.Pool, Device: v.Name, GUID: v.GUID}
+		dev, err := a.Spare(a.Pool, v)
+		if err == nil && dev == "" {
+			continue
+		}
+		ev.Replacement = dev
+		if err == nil {
+			err = run(ctx, "offline", a.Pool, v.GUID)
+		}
+		if err == nil {
+			err = run(ctx, "replace", a.Pool, v.GUID, dev)
+		}
+		if err != nil {
+			ev.Stage, ev.Err = ReplaceFailed, err
+		} else {
+			ev.Stage = ReplaceStarted
+		}
+		handled[v.GUID] = ev
+		a.report(ev)
+	}
+}
+
+func (a *AutoReplacer) report(ev ReplaceEvent) {
+	if a.OnEvent != nil {
+		a.OnEvent(ev)
+	}
+}
+
+// walkLeafVdevs calls fn for each leaf vdev below v, along with whether it is part of an ongoing replacement
+// or a hot spare taking over.
+func walkLeafVdevs(v *ZpoolVdev, replacing bool, fn func(v *ZpoolVdev, replacing bool)) {
+	if len(v.Vdevs) == 0 {
+		if v.VdevType != "root" {
+			fn(v, replacing)
+		}
+		return
+	}
+	replacing = replacing || v.VdevType == "replacing" || v.VdevType == "spare"
+	for _, child := range v.Children() {
+		walkLeafVdevs(child, replacing, fn)
+	}
+}
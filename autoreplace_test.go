@@ -0,0 +1,127 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mirrorStatus(children ...*ZpoolVdev) *ZpoolStatus {
+	mirror := &ZpoolVdev{Name: "mirror-0", VdevType: "mirror", Vdevs: map[string]*ZpoolVdev{}}
+	for _, c := range children {
+		mirror.Vdevs[c.Name] = c
+	}
+	return &ZpoolStatus{Name: "tank", Vdevs: map[string]*ZpoolVdev{
+		"tank": {Name: "tank", VdevType: "root", Vdevs: map[string]*ZpoolVdev{"mirror-0": mirror}},
+	}}
+}
+
+func TestAutoReplacerCheck(t *testing.T) {
+	sda := &ZpoolVdev{Name: "sda", VdevType: "disk", GUID: "1", State: ZpoolOnline}
+	sdb := &ZpoolVdev{Name: "sdb", VdevType: "disk", GUID: "2", State: ZpoolFaulted}
+	sdc := &ZpoolVdev{Name: "sdc", VdevType: "disk", GUID: "3", State: ZpoolOnline}
+
+	var events []ReplaceEvent
+	var commands []string
+	a := &AutoReplacer{
+		Pool:    "tank",
+		Spare:   SpareDevices("sdc"),
+		OnEvent: func(ev ReplaceEvent) { events = append(events, ev) },
+	}
+	run := func(_ context.Context, arg ...string) error {
+		commands = append(commands, strings.Join(arg, " "))
+		return nil
+	}
+	handled := map[string]ReplaceEvent{}
+
+	a.check(context.Background(), mirrorStatus(sda, sdb), handled, run)
+	wantCommands := []string{"offline tank 2", "replace tank 2 sdc"}
+	if !reflect.DeepEqual(wantCommands, commands) {
+		t.Fatalf("wanted: %v, got: %v", wantCommands, commands)
+	}
+
+	// resilvering, the failed disk is part of a replacing vdev
+	replacing := &ZpoolVdev{Name: "replacing-1", VdevType: "replacing", Vdevs: map[string]*ZpoolVdev{"sdb": sdb, "sdc": sdc}}
+	a.check(context.Background(), mirrorStatus(sda, replacing), handled, run)
+	if len(commands) != 2 {
+		t.Fatalf("wanted no further commands, got: %v", commands)
+	}
+
+	a.check(context.Background(), mirrorStatus(sda, sdc), handled, run)
+	wantEvents := []ReplaceEvent{
+		{Pool: "tank", Device: "sdb", GUID: "2", Replacement: "sdc", Stage: ReplaceStarted},
+		{Pool: "tank", Device: "sdb", GUID: "2", Replacement: "sdc", Stage: ReplaceResilvered},
+	}
+	if !reflect.DeepEqual(wantEvents, events) {
+		t.Fatalf("wanted: %+v, got: %+v", wantEvents, events)
+	}
+	if len(handled) != 0 {
+		t.Fatalf("wanted no pending replacements, got: %v", handled)
+	}
+}
+
+func TestAutoReplacerCheckFailure(t *testing.T) {
+	sdb := &ZpoolVdev{Name: "sdb", VdevType: "disk", GUID: "2", State: ZpoolUnavail}
+	sdd := &ZpoolVdev{Name: "sdd", VdevType: "disk", GUID: "4", State: ZpoolRemoved}
+
+	var events []ReplaceEvent
+	a := &AutoReplacer{
+		Pool:    "tank",
+		Spare:   SpareDevices("sdc"),
+		OnEvent: func(ev ReplaceEvent) { events = append(events, ev) },
+	}
+	errReplace := errors.New("replace failed")
+	calls := 0
+	run := func(_ context.Context, arg ...string) error {
+		calls++
+		if arg[0] == "replace" {
+			return errReplace
+		}
+		return nil
+	}
+	handled := map[string]ReplaceEvent{}
+
+	// sdb fails to be replaced and sdd finds no spare left; neither is retried
+	for i := 0; i < 2; i++ {
+		a.check(context.Background(), mirrorStatus(sdb, sdd), handled, run)
+	}
+	if calls != 2 {
+		t.Fatalf("wanted 2 zpool calls, got: %d", calls)
+	}
+	want := []ReplaceEvent{{Pool: "tank", Device: "sdb", GUID: "2", Replacement: "sdc", Stage: ReplaceFailed, Err: errReplace}}
+	if !reflect.DeepEqual(want, events) {
+		t.Fatalf("wanted: %+v, got: %+v", want, events)
+	}
+}
+
+func TestAutoReplacerRunKeepsPolling(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errBusy := errors.New("pool is busy")
+	polls := 0
+	var errs []error
+	a := &AutoReplacer{
+		Pool:     "tank",
+		Spare:    SpareDevices(),
+		Interval: time.Millisecond,
+		OnError:  func(err error) { errs = append(errs, err) },
+		status: func() (*ZpoolStatus, error) {
+			polls++
+			if polls == 1 {
+				return nil, errBusy
+			}
+			cancel()
+			return mirrorStatus(), nil
+		},
+		zpool: func(context.Context, ...string) error { return nil },
+	}
+	if err := a.Run(ctx); err != context.Canceled {
+		t.Fatalf("wanted: %v, got: %v", context.Canceled, err)
+	}
+	if polls != 2 || !reflect.DeepEqual(errs, []error{errBusy}) {
+		t.Fatalf("wanted: 2 polls and %v, got: %d polls and %v", errBusy, polls, errs)
+	}
+}
@@ -0,0 +1,42 @@
+package zfs
+
+import (
+	"errors"
+	"strings"
+)
+
+// Bookmark creates a bookmark of the receiving snapshot with the specified name, and returns it.
+// A bookmark keeps the point in time of the snapshot, so it can be used as an incremental send base
+// (see SendOptions.IncrementalBase) after the snapshot itself has been destroyed.
+// An error will be returned if the receiving dataset is not of snapshot type.
+func (d *Dataset) Bookmark(name string) (*Dataset, error) {
+	if d.Type != DatasetSnapshot {
+		return nil, errors.New("can only bookmark snapshots")
+	}
+
+	bookmark := strings.SplitN(d.Name, "@", 2)[0] + "#" + name
+	if err := zfs("bookmark", d.Name, bookmark); err != nil {
+		return nil, err
+	}
+
+	bookmarks, err := listDatasetsWithOptional(func(props []string) []string {
+		return []string{"list", "-Hp", "-t", DatasetBookmark, "-o", strings.Join(props, ","), bookmark}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bookmarks[0], nil
+}
+
+// ListBookmarks returns the bookmarks of the named dataset, ordered from oldest to newest.
+func ListBookmarks(dataset string) ([]*Dataset, error) {
+	return listSortedByCreation(dataset, DatasetBookmark)
+}
+
+// DestroyBookmark destroys the named bookmark, e.g. "pool/fs#bookmark".
+func DestroyBookmark(name string) error {
+	if !strings.Contains(name, "#") {
+		return errors.New("can only destroy bookmarks")
+	}
+	return zfs("destroy", name)
+}
@@ -0,0 +1,117 @@
+package bootenv
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	zfs "github.com/mistifyio/go-zfs/v3"
+)
+
+// backend is the subset of the zfs package and the system tools used by the manager, replaced in tests.
+type backend interface {
+	Get(name string) (*zfs.Dataset, error)
+	// Children returns the file systems directly below name.
+	Children(name string) ([]*zfs.Dataset, error)
+	// Descendants returns the file system name and all file systems below it, parents first.
+	Descendants(name string) ([]*zfs.Dataset, error)
+	// Snapshot recursively snapshots name.
+	Snapshot(name, snapshot string) error
+	Clone(snapshot, dest string, props map[string]string) error
+	Promote(name string) error
+	// Destroy recursively destroys the file system or snapshot name.
+	Destroy(name string) error
+	// RootDataset returns the file system mounted at /.
+	RootDataset() (string, error)
+	// BootFS returns the bootfs property of pool, empty if unset.
+	BootFS(pool string) (string, error)
+	SetBootFS(pool, dataset string) error
+}
+
+type localBackend struct{}
+
+func (localBackend) Get(name string) (*zfs.Dataset, error) { return zfs.GetDataset(name) }
+
+func (localBackend) Children(name string) ([]*zfs.Dataset, error) {
+	all, err := (&zfs.Dataset{Name: name}).Children(1)
+	if err != nil {
+		return nil, err
+	}
+	return filesystems(all), nil
+}
+
+func (localBackend) Descendants(name string) ([]*zfs.Dataset, error) {
+	ds, err := zfs.GetDataset(name)
+	if err != nil {
+		return nil, err
+	}
+	all, err := ds.Children(0)
+	if err != nil {
+		return nil, err
+	}
+	return append([]*zfs.Dataset{ds}, filesystems(all)...), nil
+}
+
+func filesystems(datasets []*zfs.Dataset) []*zfs.Dataset {
+	var fs []*zfs.Dataset
+	for _, ds := range datasets {
+		if ds.Type == zfs.DatasetFilesystem {
+			fs = append(fs, ds)
+		}
+	}
+	return fs
+}
+
+func (localBackend) Snapshot(name, snapshot string) error {
+	_, err := (&zfs.Dataset{Name: name}).Snapshot(snapshot, true)
+	return err
+}
+
+func (localBackend) Clone(snapshot, dest string, props map[string]string) error {
+	_, err := (&zfs.Dataset{Name: snapshot, Type: zfs.DatasetSnapshot}).Clone(dest, props)
+	return err
+}
+
+func (localBackend) Promote(name string) error {
+	return (&zfs.Dataset{Name: name}).Promote()
+}
+
+func (localBackend) Destroy(name string) error {
+	return (&zfs.Dataset{Name: name}).Destroy(zfs.DestroyRecursive)
+}
+
+func (localBackend) RootDataset() (string, error) {
+	ds, err := zfs.DatasetForPath("/")
+	if errors.Is(err, zfs.ErrNotOnDataset) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return ds.Name, nil
+}
+
+func (localBackend) BootFS(pool string) (string, error) {
+	out, err := run("zpool", "get", "-H", "-o", "value", "bootfs", pool)
+	if err != nil || out == "-" {
+		return "", err
+	}
+	return out, nil
+}
+
+func (localBackend) SetBootFS(pool, dataset string) error {
+	_, err := run("zpool", "set", "bootfs="+dataset, pool)
+	return err
+}
+
+func run(name string, arg ...string) (string, error) {
+	cmd := exec.Command(name, arg...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s %s: %v: %s", name, strings.Join(arg, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,260 @@
+// Package bootenv manages boot environments: bootable clones of the root file system, which allow upgrading
+// a system and falling back to its previous state if the upgrade goes wrong.
+//
+// Boot environments follow the layout used by bectl(8) on FreeBSD and by zsys on Ubuntu. Each one is a file system
+// directly below a common parent, e.g. "zroot/ROOT/default", with canmount=noauto and mountpoint=/, and the
+// pool's bootfs property selects the one booted next. File systems below a boot environment, e.g.
+// "zroot/ROOT/default/usr", are part of it, and are snapshotted and cloned along with it.
+package bootenv
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	zfs "github.com/mistifyio/go-zfs/v3"
+)
+
+// ErrNotFound is returned for boot environments that do not exist.
+var ErrNotFound = errors.New("boot environment not found")
+
+// snapshotTimeFormat names the snapshots boot environments are created from, as bectl does.
+const snapshotTimeFormat = "2006-01-02-15:04:05"
+
+// BootEnvironment is a boot environment.
+type BootEnvironment struct {
+	// Name is the name of the boot environment, e.g. "default".
+	Name string `json:"name"`
+	// Dataset is its root file system, e.g. "zroot/ROOT/default".
+	Dataset string `json:"dataset"`
+	// Origin is the snapshot the boot environment was cloned from, empty once it was activated.
+	Origin string `json:"origin,omitempty"`
+	// Active is true for the boot environment the system is running from.
+	Active bool `json:"active"`
+	// NextBoot is true for the boot environment the system boots from next.
+	NextBoot bool      `json:"next_boot"`
+	Used     uint64    `json:"used"`
+	Creation time.Time `json:"creation"`
+}
+
+// Manager manages the boot environments below Root.
+type Manager struct {
+	// Root is the parent of the boot environments, e.g. "zroot/ROOT" or "rpool/ROOT".
+	Root string
+
+	// backend and now are replaced in tests.
+	backend backend
+	now     func() time.Time
+}
+
+func (m *Manager) be() backend {
+	if m.backend == nil {
+		return localBackend{}
+	}
+	return m.backend
+}
+
+func (m *Manager) pool() string {
+	return strings.SplitN(m.Root, "/", 2)[0]
+}
+
+func (m *Manager) dataset(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, "/@#") {
+		return "", fmt.Errorf("%w: invalid boot environment name %q", zfs.ErrInvalidName, name)
+	}
+	ds := m.Root + "/" + name
+	return ds, zfs.ValidateDatasetName(ds)
+}
+
+// List returns the boot environments, ordered by name.
+func (m *Manager) List() ([]*BootEnvironment, error) {
+	children, err := m.be().Children(m.Root)
+	if err != nil {
+		return nil, err
+	}
+	root, bootfs, err := m.state()
+	if err != nil {
+		return nil, err
+	}
+	envs := make([]*BootEnvironment, 0, len(children))
+	for _, ds := range children {
+		envs = append(envs, m.environment(ds, root, bootfs))
+	}
+	sort.Slice(envs, func(i, j int) bool { return envs[i].Name < envs[j].Name })
+	return envs, nil
+}
+
+// Get returns the named boot environment.
+func (m *Manager) Get(name string) (*BootEnvironment, error) {
+	ds, err := m.dataset(name)
+	if err != nil {
+		return nil, err
+	}
+	v, err := m.be().Get(ds)
+	if errors.Is(err, zfs.ErrDatasetNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	root, bootfs, err := m.state()
+	if err != nil {
+		return nil, err
+	}
+	return m.environment(v, root, bootfs), nil
+}
+
+// Current returns the boot environment the system is running from.
+func (m *Manager) Current() (*BootEnvironment, error) {
+	root, err := m.be().RootDataset()
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimPrefix(root, m.Root+"/")
+	if root == "" || name == root || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("the root file system is not a boot environment below %s", m.Root)
+	}
+	return m.Get(name)
+}
+
+// state returns the root file system and the bootfs property of the pool.
+func (m *Manager) state() (string, string, error) {
+	root, err := m.be().RootDataset()
+	if err != nil {
+		return "", "", err
+	}
+	bootfs, err := m.be().BootFS(m.pool())
+	return root, bootfs, err
+}
+
+func (m *Manager) environment(ds *zfs.Dataset, root, bootfs string) *BootEnvironment {
+	return &BootEnvironment{
+		Name:     strings.TrimPrefix(ds.Name, m.Root+"/"),
+		Dataset:  ds.Name,
+		Origin:   ds.Origin,
+		Active:   ds.Name == root,
+		NextBoot: ds.Name == bootfs,
+		Used:     ds.Used,
+		Creation: ds.Creation,
+	}
+}
+
+// Create creates the named boot environment as a clone of source, which is the name of a boot environment,
+// a snapshot of one, e.g. "default@2024-01-02-15:04:05", or empty for the one the system is running from.
+// Unless source is a snapshot, a snapshot of it is taken first, named after the current time.
+// The new boot environment is not activated. If cloning fails, anything created is destroyed again.
+func (m *Manager) Create(name, source string) (*BootEnvironment, error) {
+	dest, err := m.dataset(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.be().Get(dest); err == nil {
+		return nil, fmt.Errorf("%w: boot environment %s", zfs.ErrDatasetExists, name)
+	} else if !errors.Is(err, zfs.ErrDatasetNotFound) {
+		return nil, err
+	}
+
+	sourceName, snapshot := source, ""
+	if i := strings.IndexByte(source, '@'); i >= 0 {
+		sourceName, snapshot = source[:i], source[i+1:]
+	}
+	if sourceName == "" {
+		current, err := m.Current()
+		if err != nil {
+			return nil, err
+		}
+		sourceName = current.Name
+	}
+	src, err := m.dataset(sourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	var undo []string
+	rollback := func() {
+		for i := len(undo) - 1; i >= 0; i-- {
+			_ = m.be().Destroy(undo[i])
+		}
+	}
+	if snapshot == "" {
+		now := m.now
+		if now == nil {
+			now = time.Now
+		}
+		snapshot = now().UTC().Format(snapshotTimeFormat)
+		if err := m.be().Snapshot(src, snapshot); err != nil {
+			return nil, err
+		}
+		undo = append(undo, src+"@"+snapshot)
+	}
+
+	datasets, err := m.be().Descendants(src)
+	if err != nil {
+		rollback()
+		return nil, err
+	}
+	for i, ds := range datasets {
+		props := map[string]string{"canmount": "noauto"}
+		if i == 0 {
+			props["mountpoint"] = "/"
+		}
+		clone := dest + strings.TrimPrefix(ds.Name, src)
+		if err := m.be().Clone(ds.Name+"@"+snapshot, clone, props); err != nil {
+			rollback()
+			return nil, err
+		}
+		if i == 0 {
+			undo = append(undo, clone)
+		}
+	}
+	return m.Get(name)
+}
+
+// Activate makes the named boot environment the one booted next. Its file systems are promoted,
+// so that it no longer depends on the boot environment it was cloned from, which can then be destroyed.
+func (m *Manager) Activate(name string) error {
+	ds, err := m.dataset(name)
+	if err != nil {
+		return err
+	}
+	datasets, err := m.be().Descendants(ds)
+	if errors.Is(err, zfs.ErrDatasetNotFound) {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return err
+	}
+	for _, d := range datasets {
+		if d.Origin == "" {
+			continue
+		}
+		if err := m.be().Promote(d.Name); err != nil {
+			return err
+		}
+	}
+	return m.be().SetBootFS(m.pool(), ds)
+}
+
+// Destroy destroys the named boot environment, which must be neither running nor booted next.
+// If destroyOrigin is set, the snapshot it was cloned from is destroyed as well.
+func (m *Manager) Destroy(name string, destroyOrigin bool) error {
+	env, err := m.Get(name)
+	if err != nil {
+		return err
+	}
+	if env.Active {
+		return fmt.Errorf("boot environment %s is running", name)
+	}
+	if env.NextBoot {
+		return fmt.Errorf("boot environment %s is activated for the next boot", name)
+	}
+	if err := m.be().Destroy(env.Dataset); err != nil {
+		return err
+	}
+	if destroyOrigin && env.Origin != "" {
+		return m.be().Destroy(env.Origin)
+	}
+	return nil
+}
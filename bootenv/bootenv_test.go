@@ -0,0 +1,265 @@
+package bootenv
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	zfs "github.com/mistifyio/go-zfs/v3"
+)
+
+// fakeBackend keeps file systems in memory and records all calls changing them.
+type fakeBackend struct {
+	datasets map[string]*zfs.Dataset
+	root     string
+	bootfs   string
+	calls    []string
+	// fail fails the calls starting with it
+	fail string
+}
+
+func newFakeBackend(names ...string) *fakeBackend {
+	b := &fakeBackend{datasets: map[string]*zfs.Dataset{}}
+	for _, name := range names {
+		b.datasets[name] = &zfs.Dataset{Name: name, Type: zfs.DatasetFilesystem}
+	}
+	return b
+}
+
+func (b *fakeBackend) record(format string, args ...interface{}) error {
+	call := fmt.Sprintf(format, args...)
+	b.calls = append(b.calls, call)
+	if b.fail != "" && strings.HasPrefix(call, b.fail) {
+		return errors.New("failed")
+	}
+	return nil
+}
+
+func notFound(name string) error {
+	return &zfs.Error{Err: errors.New("exit status 1"), Stderr: "cannot open '" + name + "': dataset does not exist"}
+}
+
+func (b *fakeBackend) Get(name string) (*zfs.Dataset, error) {
+	ds, ok := b.datasets[name]
+	if !ok {
+		return nil, notFound(name)
+	}
+	return ds, nil
+}
+
+func (b *fakeBackend) list(name string, depth int) []*zfs.Dataset {
+	var names []string
+	for n := range b.datasets {
+		if n == name || (strings.HasPrefix(n, name+"/") && (depth == 0 || strings.Count(n[len(name):], "/") <= depth)) {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	var datasets []*zfs.Dataset
+	for _, n := range names {
+		if !strings.Contains(n, "@") {
+			datasets = append(datasets, b.datasets[n])
+		}
+	}
+	return datasets
+}
+
+func (b *fakeBackend) Children(name string) ([]*zfs.Dataset, error) {
+	return b.list(name, 1)[1:], nil
+}
+
+func (b *fakeBackend) Descendants(name string) ([]*zfs.Dataset, error) {
+	if _, ok := b.datasets[name]; !ok {
+		return nil, notFound(name)
+	}
+	return b.list(name, 0), nil
+}
+
+func (b *fakeBackend) Snapshot(name, snapshot string) error {
+	if err := b.record("snapshot -r %s@%s", name, snapshot); err != nil {
+		return err
+	}
+	for _, ds := range b.list(name, 0) {
+		b.datasets[ds.Name+"@"+snapshot] = &zfs.Dataset{Name: ds.Name + "@" + snapshot, Type: zfs.DatasetSnapshot}
+	}
+	return nil
+}
+
+func (b *fakeBackend) Clone(snapshot, dest string, props map[string]string) error {
+	var kv []string
+	for k, v := range props {
+		kv = append(kv, k+"="+v)
+	}
+	sort.Strings(kv)
+	if err := b.record("clone %s %s %s", snapshot, dest, strings.Join(kv, ",")); err != nil {
+		return err
+	}
+	b.datasets[dest] = &zfs.Dataset{Name: dest, Type: zfs.DatasetFilesystem, Origin: snapshot}
+	return nil
+}
+
+func (b *fakeBackend) Promote(name string) error {
+	if err := b.record("promote %s", name); err != nil {
+		return err
+	}
+	b.datasets[name].Origin = ""
+	return nil
+}
+
+func (b *fakeBackend) Destroy(name string) error {
+	if err := b.record("destroy -r %s", name); err != nil {
+		return err
+	}
+	ds, snap := name, ""
+	if i := strings.IndexByte(name, '@'); i >= 0 {
+		ds, snap = name[:i], name[i:]
+	}
+	for n := range b.datasets {
+		if (n == ds || strings.HasPrefix(n, ds+"/") || strings.HasPrefix(n, ds+"@")) && strings.HasSuffix(n, snap) {
+			delete(b.datasets, n)
+		}
+	}
+	return nil
+}
+
+func (b *fakeBackend) RootDataset() (string, error) { return b.root, nil }
+
+func (b *fakeBackend) BootFS(pool string) (string, error) { return b.bootfs, nil }
+
+func (b *fakeBackend) SetBootFS(pool, dataset string) error {
+	b.bootfs = dataset
+	return b.record("set bootfs=%s %s", dataset, pool)
+}
+
+func newTestManager(b *fakeBackend) *Manager {
+	return &Manager{
+		Root:    "zroot/ROOT",
+		backend: b,
+		now:     func() time.Time { return time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC) },
+	}
+}
+
+func TestCreateActivateDestroy(t *testing.T) {
+	b := newFakeBackend("zroot/ROOT", "zroot/ROOT/default", "zroot/ROOT/default/usr")
+	b.root, b.bootfs = "zroot/ROOT/default", "zroot/ROOT/default"
+	m := newTestManager(b)
+
+	current, err := m.Current()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if current.Name != "default" || !current.Active || !current.NextBoot {
+		t.Fatalf("wanted: active default, got: %+v", current)
+	}
+
+	env, err := m.Create("upgrade", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"snapshot -r zroot/ROOT/default@2024-01-02-15:04:05",
+		"clone zroot/ROOT/default@2024-01-02-15:04:05 zroot/ROOT/upgrade canmount=noauto,mountpoint=/",
+		"clone zroot/ROOT/default/usr@2024-01-02-15:04:05 zroot/ROOT/upgrade/usr canmount=noauto",
+	}
+	if !reflect.DeepEqual(want, b.calls) {
+		t.Fatalf("wanted: %v, got: %v", want, b.calls)
+	}
+	if env.Origin != "zroot/ROOT/default@2024-01-02-15:04:05" || env.Active || env.NextBoot {
+		t.Fatalf("wanted: inactive clone, got: %+v", env)
+	}
+	if _, err := m.Create("upgrade", ""); !errors.Is(err, zfs.ErrDatasetExists) {
+		t.Fatalf("wanted: %v, got: %v", zfs.ErrDatasetExists, err)
+	}
+
+	b.calls = nil
+	if err := m.Activate("upgrade"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = []string{"promote zroot/ROOT/upgrade", "promote zroot/ROOT/upgrade/usr", "set bootfs=zroot/ROOT/upgrade zroot"}
+	if !reflect.DeepEqual(want, b.calls) {
+		t.Fatalf("wanted: %v, got: %v", want, b.calls)
+	}
+
+	envs, err := m.List()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, e := range envs {
+		got = append(got, fmt.Sprintf("%s %v %v", e.Name, e.Active, e.NextBoot))
+	}
+	if want := []string{"default true false", "upgrade false true"}; !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %v, got: %v", want, got)
+	}
+
+	// the running and the next boot environment are kept
+	for _, name := range []string{"default", "upgrade"} {
+		if err := m.Destroy(name, false); err == nil {
+			t.Fatalf("expected an error destroying %s", name)
+		}
+	}
+	if err := m.Destroy("missing", false); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("wanted: %v, got: %v", ErrNotFound, err)
+	}
+}
+
+func TestCreateFromSnapshot(t *testing.T) {
+	b := newFakeBackend("zroot/ROOT", "zroot/ROOT/default", "zroot/ROOT/old")
+	b.datasets["zroot/ROOT/default@before"] = &zfs.Dataset{Name: "zroot/ROOT/default@before", Type: zfs.DatasetSnapshot}
+	b.root = "zroot/ROOT/default"
+	m := newTestManager(b)
+
+	if _, err := m.Create("rescue", "default@before"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"clone zroot/ROOT/default@before zroot/ROOT/rescue canmount=noauto,mountpoint=/"}
+	if !reflect.DeepEqual(want, b.calls) {
+		t.Fatalf("wanted: %v, got: %v", want, b.calls)
+	}
+
+	b.calls = nil
+	if err := m.Destroy("rescue", true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = []string{"destroy -r zroot/ROOT/rescue", "destroy -r zroot/ROOT/default@before"}
+	if !reflect.DeepEqual(want, b.calls) {
+		t.Fatalf("wanted: %v, got: %v", want, b.calls)
+	}
+
+	for _, name := range []string{"", "a/b", "a@b"} {
+		if _, err := m.Create(name, ""); !errors.Is(err, zfs.ErrInvalidName) {
+			t.Fatalf("wanted: %v, got: %v", zfs.ErrInvalidName, err)
+		}
+	}
+}
+
+func TestCreateUndo(t *testing.T) {
+	b := newFakeBackend("zroot/ROOT", "zroot/ROOT/default", "zroot/ROOT/default/var")
+	b.root = "zroot/ROOT/default"
+	b.fail = "clone zroot/ROOT/default/var@"
+	m := newTestManager(b)
+
+	if _, err := m.Create("upgrade", "default"); err == nil {
+		t.Fatal("expected an error")
+	}
+	want := []string{
+		"snapshot -r zroot/ROOT/default@2024-01-02-15:04:05",
+		"clone zroot/ROOT/default@2024-01-02-15:04:05 zroot/ROOT/upgrade canmount=noauto,mountpoint=/",
+		"clone zroot/ROOT/default/var@2024-01-02-15:04:05 zroot/ROOT/upgrade/var canmount=noauto",
+		"destroy -r zroot/ROOT/upgrade",
+		"destroy -r zroot/ROOT/default@2024-01-02-15:04:05",
+	}
+	if !reflect.DeepEqual(want, b.calls) {
+		t.Fatalf("wanted: %v, got: %v", want, b.calls)
+	}
+
+	// a root file system outside of the boot environments
+	b.root = "zroot/data"
+	if _, err := m.Current(); err == nil {
+		t.Fatal("expected an error")
+	}
+}
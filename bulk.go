@@ -0,0 +1,104 @@
+package zfs
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// BulkItemError is the failure of a bulk operation on a single dataset.
+type BulkItemError struct {
+	Name string
+	Err  error
+}
+
+// BulkError collects the failures of a bulk operation, in the order the datasets were given.
+type BulkError struct {
+	Errors []BulkItemError
+}
+
+func (e *BulkError) Error() string {
+	s := make([]string, len(e.Errors))
+	for i, item := range e.Errors {
+		s[i] = item.Name + ": " + item.Err.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+// bulk runs fn for each name on up to workers goroutines, runtime.NumCPU() if workers is not positive.
+// Names not yet started when ctx is cancelled fail with ctx.Err().
+// It returns a *BulkError if any of them failed.
+func bulk(ctx context.Context, names []string, workers int, fn func(ctx context.Context, name string) error) error {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	errs := make([]error, len(names))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(names); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := ctx.Err(); err != nil {
+					errs[i] = err
+					continue
+				}
+				errs[i] = fn(ctx, names[i])
+			}
+		}()
+	}
+	for i := range names {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var bulkErr BulkError
+	for i, err := range errs {
+		if err != nil {
+			bulkErr.Errors = append(bulkErr.Errors, BulkItemError{Name: names[i], Err: err})
+		}
+	}
+	if len(bulkErr.Errors) > 0 {
+		return &bulkErr
+	}
+	return nil
+}
+
+func zfsContext(ctx context.Context, arg ...string) error {
+	c := command{Command: "zfs", Ctx: ctx}
+	_, err := c.Run(arg...)
+	return err
+}
+
+// BulkSnapshot snapshots each of the datasets with the given snapshot name, on up to workers concurrent zfs processes.
+// The snapshots are not atomic across datasets; use a recursive snapshot of a common parent for that.
+// Failures of individual datasets are returned in a *BulkError.
+func BulkSnapshot(ctx context.Context, datasets []string, name string, recursive bool, workers int) error {
+	return bulk(ctx, datasets, workers, func(ctx context.Context, ds string) error {
+		args := []string{"snapshot"}
+		if recursive {
+			args = append(args, "-r")
+		}
+		return zfsContext(ctx, append(args, ds+"@"+name)...)
+	})
+}
+
+// BulkDestroy destroys each of the named datasets, on up to workers concurrent zfs processes.
+// Failures of individual datasets are returned in a *BulkError.
+func BulkDestroy(ctx context.Context, names []string, flags DestroyFlag, workers int) error {
+	return bulk(ctx, names, workers, func(ctx context.Context, name string) error {
+		return zfsContext(ctx, append(destroyArgs(flags), name)...)
+	})
+}
+
+// BulkSetProperty sets a property on each of the datasets, on up to workers concurrent zfs processes.
+// Failures of individual datasets are returned in a *BulkError.
+func BulkSetProperty(ctx context.Context, datasets []string, key, val string, workers int) error {
+	return bulk(ctx, datasets, workers, func(ctx context.Context, ds string) error {
+		return zfsContext(ctx, "set", key+"="+val, ds)
+	})
+}
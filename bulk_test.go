@@ -0,0 +1,66 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestBulk(t *testing.T) {
+	names := []string{"tank/a", "tank/b", "tank/c", "tank/d", "tank/e"}
+	errFailed := errors.New("failed")
+
+	var mu sync.Mutex
+	var running, maxRunning int
+	var done []string
+	err := bulk(context.Background(), names, 2, func(_ context.Context, name string) error {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		defer func() {
+			mu.Lock()
+			running--
+			done = append(done, name)
+			mu.Unlock()
+		}()
+		if name == "tank/b" || name == "tank/d" {
+			return errFailed
+		}
+		return nil
+	})
+
+	var bulkErr *BulkError
+	if !errors.As(err, &bulkErr) {
+		t.Fatalf("wanted a *BulkError, got: %v", err)
+	}
+	want := []BulkItemError{{Name: "tank/b", Err: errFailed}, {Name: "tank/d", Err: errFailed}}
+	if !reflect.DeepEqual(want, bulkErr.Errors) {
+		t.Fatalf("wanted: %v, got: %v", want, bulkErr.Errors)
+	}
+	if err.Error() != "tank/b: failed; tank/d: failed" {
+		t.Fatalf("unexpected error message: %v", err)
+	}
+	if len(done) != len(names) || maxRunning > 2 {
+		t.Fatalf("wanted %d items on at most 2 workers, got %d items on %d", len(names), len(done), maxRunning)
+	}
+
+	if err := bulk(context.Background(), names, 0, func(context.Context, string) error { return nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = bulk(ctx, names, 1, func(context.Context, string) error {
+		t.Fatal("no item should run after cancellation")
+		return nil
+	})
+	if !errors.As(err, &bulkErr) || len(bulkErr.Errors) != len(names) || !errors.Is(bulkErr.Errors[0].Err, context.Canceled) {
+		t.Fatalf("wanted all items canceled, got: %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package zfs
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// PropertyCache is a read-through cache of datasets, pools and properties whose entries expire after a TTL,
+// reducing the number of commands run by e.g. dashboards polling frequently.
+// Mutations made through the cache invalidate the entries they affect; use Invalidate after changes made otherwise.
+// A PropertyCache is safe for concurrent use. The zero value is ready to use, but its TTL of zero caches nothing.
+type PropertyCache struct {
+	ttl time.Duration
+
+	mu         sync.Mutex
+	datasets   map[string]cachedDataset
+	pools      map[string]cachedZpool
+	properties map[[2]string]cachedProperty
+
+	// now and the loaders are replaced in tests, the defaults are used if nil.
+	now         func() time.Time
+	getDataset  func(name string) (*Dataset, error)
+	getZpool    func(name string) (*Zpool, error)
+	getProperty func(name, key string) (string, error)
+}
+
+type cachedDataset struct {
+	ds      Dataset
+	expires time.Time
+}
+
+type cachedZpool struct {
+	z       Zpool
+	expires time.Time
+}
+
+type cachedProperty struct {
+	val     string
+	expires time.Time
+}
+
+// NewPropertyCache returns a cache whose entries are refreshed once they are older than ttl.
+func NewPropertyCache(ttl time.Duration) *PropertyCache {
+	return &PropertyCache{ttl: ttl}
+}
+
+func (c *PropertyCache) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+// GetDataset returns the named dataset, as GetDataset does, from the cache if possible.
+func (c *PropertyCache) GetDataset(name string) (*Dataset, error) {
+	c.mu.Lock()
+	e, ok := c.datasets[name]
+	c.mu.Unlock()
+	if ok && c.clock().Before(e.expires) {
+		ds := e.ds
+		return &ds, nil
+	}
+
+	load := c.getDataset
+	if load == nil {
+		load = GetDataset
+	}
+	ds, err := load(name)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.datasets == nil {
+		c.datasets = map[string]cachedDataset{}
+	}
+	c.datasets[name] = cachedDataset{ds: *ds, expires: c.clock().Add(c.ttl)}
+	c.mu.Unlock()
+	return ds, nil
+}
+
+// GetZpool returns the named pool, as GetZpool does, from the cache if possible.
+func (c *PropertyCache) GetZpool(name string) (*Zpool, error) {
+	c.mu.Lock()
+	e, ok := c.pools[name]
+	c.mu.Unlock()
+	if ok && c.clock().Before(e.expires) {
+		z := e.z
+		return &z, nil
+	}
+
+	load := c.getZpool
+	if load == nil {
+		load = GetZpool
+	}
+	z, err := load(name)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.pools == nil {
+		c.pools = map[string]cachedZpool{}
+	}
+	c.pools[name] = cachedZpool{z: *z, expires: c.clock().Add(c.ttl)}
+	c.mu.Unlock()
+	return z, nil
+}
+
+// GetProperty returns a property of the named dataset, as Dataset.GetProperty does, from the cache if possible.
+func (c *PropertyCache) GetProperty(name, key string) (string, error) {
+	k := [2]string{name, key}
+	c.mu.Lock()
+	e, ok := c.properties[k]
+	c.mu.Unlock()
+	if ok && c.clock().Before(e.expires) {
+		return e.val, nil
+	}
+
+	load := c.getProperty
+	if load == nil {
+		load = func(name, key string) (string, error) { return (&Dataset{Name: name}).GetProperty(key) }
+	}
+	val, err := load(name, key)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	if c.properties == nil {
+		c.properties = map[[2]string]cachedProperty{}
+	}
+	c.properties[k] = cachedProperty{val: val, expires: c.clock().Add(c.ttl)}
+	c.mu.Unlock()
+	return val, nil
+}
+
+// Invalidate drops the cached entries affected by a change to the named dataset: the dataset itself,
+// its descendants and snapshots, its ancestors, whose space accounting includes it, and its pool.
+func (c *PropertyCache) Invalidate(name string) {
+	affected := func(other string) bool {
+		return other == name || isDescendant(other, name) || isDescendant(name, other)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for n := range c.datasets {
+		if affected(n) {
+			delete(c.datasets, n)
+		}
+	}
+	for k := range c.properties {
+		if affected(k[0]) {
+			delete(c.properties, k)
+		}
+	}
+	delete(c.pools, poolOf(name))
+}
+
+// InvalidateAll drops all cached entries.
+func (c *PropertyCache) InvalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.datasets = nil
+	c.pools = nil
+	c.properties = nil
+}
+
+// isDescendant reports whether name is a descendant, snapshot or bookmark of ancestor.
+func isDescendant(name, ancestor string) bool {
+	if !strings.HasPrefix(name, ancestor) || len(name) == len(ancestor) {
+		return false
+	}
+	switch name[len(ancestor)] {
+	case '/', '@', '#':
+		return true
+	}
+	return false
+}
+
+// SetProperty sets a property of the named dataset and invalidates the affected entries.
+func (c *PropertyCache) SetProperty(name, key, val string) error {
+	defer c.Invalidate(name)
+	return (&Dataset{Name: name}).SetProperty(key, val)
+}
+
+// Snapshot snapshots the named dataset, as Dataset.Snapshot does, and invalidates the affected entries.
+func (c *PropertyCache) Snapshot(name, snapshot string, recursive bool) (*Dataset, error) {
+	defer c.Invalidate(name)
+	return (&Dataset{Name: name}).Snapshot(snapshot, recursive)
+}
+
+// Destroy destroys the named dataset, as Dataset.Destroy does, and invalidates the affected entries.
+func (c *PropertyCache) Destroy(name string, flags DestroyFlag) error {
+	defer c.Invalidate(name)
+	return (&Dataset{Name: name}).Destroy(flags)
+}
+
+// Rename renames the named dataset, as Dataset.Rename does, and invalidates the entries of both names.
+func (c *PropertyCache) Rename(name, newName string, createParent, recursiveRenameSnapshots bool) (*Dataset, error) {
+	defer c.Invalidate(newName)
+	defer c.Invalidate(name)
+	return (&Dataset{Name: name}).Rename(newName, createParent, recursiveRenameSnapshots)
+}
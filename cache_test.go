@@ -0,0 +1,115 @@
+package zfs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPropertyCache(t *testing.T) {
+	now := time.Unix(1650000000, 0)
+	loads := map[string]int{}
+
+	c := NewPropertyCache(time.Minute)
+	c.now = func() time.Time { return now }
+	c.getDataset = func(name string) (*Dataset, error) {
+		loads[name]++
+		return &Dataset{Name: name, Used: uint64(loads[name])}, nil
+	}
+	c.getZpool = func(name string) (*Zpool, error) {
+		loads["pool "+name]++
+		return &Zpool{Name: name}, nil
+	}
+	c.getProperty = func(name, key string) (string, error) {
+		loads[name+" "+key]++
+		return "lz4", nil
+	}
+
+	for i := 0; i < 3; i++ {
+		ds, err := c.GetDataset("test/fs")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ds.Used != 1 {
+			t.Fatalf("wanted: %d, got: %d", 1, ds.Used)
+		}
+		// changes to returned datasets do not leak into the cache
+		ds.Used = 100
+	}
+	if _, err := c.GetZpool("test"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.GetProperty("test/fs/child", "compression"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// entries expire after the TTL
+	now = now.Add(time.Minute)
+	if ds, _ := c.GetDataset("test/fs"); ds.Used != 2 {
+		t.Fatalf("wanted: %d, got: %d", 2, ds.Used)
+	}
+	if _, err := c.GetDataset("test/other"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// invalidation affects descendants, ancestors and the pool, but not siblings
+	c.Invalidate("test/fs")
+	c.GetDataset("test/fs")
+	c.GetZpool("test")
+	c.GetProperty("test/fs/child", "compression")
+	c.GetDataset("test/other")
+	want := map[string]int{
+		"test/fs":                   3,
+		"pool test":                 2,
+		"test/fs/child compression": 2,
+		"test/other":                1,
+	}
+	for k, n := range want {
+		if loads[k] != n {
+			t.Fatalf("wanted: %d loads of %s, got: %d", n, k, loads[k])
+		}
+	}
+
+	c.InvalidateAll()
+	c.GetDataset("test/other")
+	if loads["test/other"] != 2 {
+		t.Fatalf("wanted: %d, got: %d", 2, loads["test/other"])
+	}
+}
+
+func TestPropertyCacheZeroValue(t *testing.T) {
+	loads := 0
+	c := &PropertyCache{getDataset: func(name string) (*Dataset, error) {
+		loads++
+		return &Dataset{Name: name}, nil
+	}}
+	c.Invalidate("test/fs")
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetDataset("test/fs"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// with a TTL of zero, entries expire right away
+	if loads != 2 {
+		t.Fatalf("wanted: %d, got: %d", 2, loads)
+	}
+	c.InvalidateAll()
+}
+
+func TestIsDescendant(t *testing.T) {
+	tests := []struct {
+		name, ancestor string
+		want           bool
+	}{
+		{"test/fs/child", "test/fs", true},
+		{"test/fs@snap", "test/fs", true},
+		{"test/fs#mark", "test/fs", true},
+		{"test/fs", "test/fs", false},
+		{"test/fs2", "test/fs", false},
+		{"test", "test/fs", false},
+	}
+	for _, tt := range tests {
+		if got := isDescendant(tt.name, tt.ancestor); got != tt.want {
+			t.Fatalf("%s below %s: wanted: %v, got: %v", tt.name, tt.ancestor, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"time"
+)
+
+// Capacity returns the percentage of the pool's size that is allocated.
+func (z *Zpool) Capacity() uint64 {
+	if z.Size == 0 {
+		return 0
+	}
+	return percentOf(z.Allocated, z.Size)
+}
+
+// percentOf returns part in whole percent of whole, rounded down, without overflowing for large values.
+// whole must not be zero.
+func percentOf(part, whole uint64) uint64 {
+	hi, lo := bits.Mul64(part, 100)
+	if hi >= whole {
+		// part exceeds whole many times over, and the quotient would not fit
+		return math.MaxUint64
+	}
+	quo, _ := bits.Div64(hi, lo, whole)
+	return quo
+}
+
+// PoolMetric is a pool property, in percent, that a CapacityThreshold applies to.
+type PoolMetric string
+
+// Pool metrics.
+const (
+	MetricCapacity      PoolMetric = "capacity"
+	MetricFragmentation PoolMetric = "fragmentation"
+)
+
+func (m PoolMetric) value(z *Zpool) (uint64, error) {
+	switch m {
+	case MetricCapacity:
+		return z.Capacity(), nil
+	case MetricFragmentation:
+		return z.Fragmentation, nil
+	}
+	return 0, fmt.Errorf("invalid pool metric %q", m)
+}
+
+// CapacityThreshold is a limit on a pool metric.
+// It is exceeded once the metric reaches Percent, and cleared once the metric drops below Percent minus Hysteresis,
+// so a pool hovering around the threshold does not raise a flood of alerts.
+type CapacityThreshold struct {
+	Metric     PoolMetric `json:"metric"`
+	Percent    uint64     `json:"percent"`
+	Hysteresis uint64     `json:"hysteresis"`
+}
+
+// CapacityAlert reports a pool crossing a CapacityThreshold.
+type CapacityAlert struct {
+	Pool      string            `json:"pool"`
+	Threshold CapacityThreshold `json:"threshold"`
+	// Value is the metric's current value in percent.
+	Value uint64 `json:"value"`
+	// Exceeded is true when the threshold was exceeded, and false when it was cleared again.
+	Exceeded bool `json:"exceeded"`
+}
+
+// CapacityWatcher polls the capacity and fragmentation of pools and alerts when thresholds are crossed,
+// e.g. at 80% and 90% capacity, well before a pool hits the performance cliff of a nearly full pool.
+type CapacityWatcher struct {
+	// Pools are the names of the pools to watch, all pools are watched if empty.
+	Pools      []string
+	Thresholds []CapacityThreshold
+	// Interval is the time between polls.
+	Interval time.Duration
+	// OnAlert is called for every threshold crossed.
+	OnAlert func(CapacityAlert)
+
+	// state tracks the thresholds currently exceeded, by pool name.
+	state thresholdState
+	// list is replaced in tests.
+	list func() ([]*Zpool, error)
+}
+
+// Run polls the pools every interval until ctx is cancelled, and returns ctx.Err().
+// Thresholds already exceeded on the first poll are alerted right away.
+// Errors retrieving the pools are returned immediately.
+func (w *CapacityWatcher) Run(ctx context.Context) error {
+	if w.OnAlert == nil {
+		return errors.New("an OnAlert callback is required")
+	}
+	if w.Interval <= 0 {
+		return errors.New("a positive Interval is required")
+	}
+	for _, t := range w.Thresholds {
+		if _, err := t.Metric.value(&Zpool{}); err != nil {
+			return err
+		}
+		if err := checkHysteresis(t.Percent, t.Hysteresis); err != nil {
+			return err
+		}
+	}
+	list := w.list
+	if list == nil {
+		list = w.listPools
+	}
+
+	return pollEvery(ctx, w.Interval, func() error {
+		pools, err := list()
+		if err != nil {
+			return err
+		}
+		for _, z := range pools {
+			w.check(z)
+		}
+		return nil
+	})
+}
+
+func (w *CapacityWatcher) listPools() ([]*Zpool, error) {
+	if len(w.Pools) == 0 {
+		return ListZpools()
+	}
+	pools := make([]*Zpool, 0, len(w.Pools))
+	for _, name := range w.Pools {
+		z, err := GetZpool(name)
+		if err != nil {
+			return nil, err
+		}
+		pools = append(pools, z)
+	}
+	return pools, nil
+}
+
+func (w *CapacityWatcher) check(z *Zpool) {
+	for i, t := range w.Thresholds {
+		val, err := t.Metric.value(z)
+		if err != nil {
+			continue
+		}
+		if exceeded, changed := w.state.update(z.Name, i, val, t.Percent, t.Hysteresis); changed {
+			w.OnAlert(CapacityAlert{Pool: z.Name, Threshold: t, Value: val, Exceeded: exceeded})
+		}
+	}
+}
+
+// thresholdState tracks which thresholds of the watched items are exceeded, by item and threshold index.
+type thresholdState map[interface{}]map[int]bool
+
+// update records value against the threshold percent of item, and reports whether the threshold is exceeded
+// and whether that changed. A threshold is exceeded once value reaches percent,
+// and cleared once value drops below percent minus hysteresis.
+func (s *thresholdState) update(item interface{}, i int, value, percent, hysteresis uint64) (exceeded, changed bool) {
+	if *s == nil {
+		*s = thresholdState{}
+	}
+	state := (*s)[item]
+	if state == nil {
+		state = map[int]bool{}
+		(*s)[item] = state
+	}
+	switch {
+	case !state[i] && value >= percent:
+		state[i] = true
+	case state[i] && value+hysteresis < percent:
+		state[i] = false
+	default:
+		return state[i], false
+	}
+	return state[i], true
+}
+
+func checkHysteresis(percent, hysteresis uint64) error {
+	if hysteresis > percent {
+		return fmt.Errorf("hysteresis %d exceeds threshold %d", hysteresis, percent)
+	}
+	return nil
+}
+
+// pollEvery calls poll right away and then every interval until ctx is cancelled, and returns ctx.Err().
+// Errors of poll are returned immediately.
+func pollEvery(ctx context.Context, interval time.Duration, poll func() error) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := poll(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
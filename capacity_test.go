@@ -0,0 +1,60 @@
+package zfs
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestCapacityWatcherCheck(t *testing.T) {
+	warn := CapacityThreshold{Metric: MetricCapacity, Percent: 80, Hysteresis: 5}
+	crit := CapacityThreshold{Metric: MetricCapacity, Percent: 90, Hysteresis: 5}
+	frag := CapacityThreshold{Metric: MetricFragmentation, Percent: 50}
+
+	var alerts []CapacityAlert
+	w := &CapacityWatcher{
+		Thresholds: []CapacityThreshold{warn, crit, frag},
+		OnAlert:    func(a CapacityAlert) { alerts = append(alerts, a) },
+	}
+
+	// capacity in percent, with fragmentation following along
+	for _, capacity := range []uint64{50, 81, 79, 91, 86, 84, 74} {
+		w.check(&Zpool{Name: "tank", Size: 100, Allocated: capacity, Fragmentation: capacity / 2})
+	}
+
+	want := []CapacityAlert{
+		{Pool: "tank", Threshold: warn, Value: 81, Exceeded: true},
+		{Pool: "tank", Threshold: crit, Value: 91, Exceeded: true},
+		{Pool: "tank", Threshold: crit, Value: 84, Exceeded: false},
+		{Pool: "tank", Threshold: warn, Value: 74, Exceeded: false},
+	}
+	if !reflect.DeepEqual(want, alerts) {
+		t.Fatalf("wanted: %+v, got: %+v", want, alerts)
+	}
+
+	// pools are tracked separately
+	alerts = nil
+	w.check(&Zpool{Name: "other", Size: 200, Allocated: 120, Fragmentation: 60})
+	want = []CapacityAlert{{Pool: "other", Threshold: frag, Value: 60, Exceeded: true}}
+	if !reflect.DeepEqual(want, alerts) {
+		t.Fatalf("wanted: %+v, got: %+v", want, alerts)
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	tests := map[string]struct {
+		z    Zpool
+		want uint64
+	}{
+		"empty pool": {z: Zpool{}, want: 0},
+		"small pool": {z: Zpool{Allocated: 80, Size: 100}, want: 80},
+		"rounded":    {z: Zpool{Allocated: 1999, Size: 10000}, want: 19},
+		"huge pool":  {z: Zpool{Allocated: 1 << 62, Size: 1<<63 + 1<<62}, want: 33},
+		"full":       {z: Zpool{Allocated: math.MaxUint64, Size: math.MaxUint64}, want: 100},
+	}
+	for name, test := range tests {
+		if got := test.z.Capacity(); got != test.want {
+			t.Fatalf("%s: wanted: %d, got: %d", name, test.want, got)
+		}
+	}
+}
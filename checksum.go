@@ -0,0 +1,148 @@
+package zfs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChecksumAlgorithm is a value of the checksum property, which selects how the integrity of data is verified.
+type ChecksumAlgorithm string
+
+// Checksum algorithms.
+const (
+	// ChecksumOn selects the default algorithm, fletcher4.
+	ChecksumOn        ChecksumAlgorithm = "on"
+	ChecksumOff       ChecksumAlgorithm = "off"
+	ChecksumFletcher2 ChecksumAlgorithm = "fletcher2"
+	ChecksumFletcher4 ChecksumAlgorithm = "fletcher4"
+	ChecksumSHA256    ChecksumAlgorithm = "sha256"
+	ChecksumSHA512    ChecksumAlgorithm = "sha512"
+	ChecksumSkein     ChecksumAlgorithm = "skein"
+	ChecksumEdonR     ChecksumAlgorithm = "edonr"
+	ChecksumBLAKE3    ChecksumAlgorithm = "blake3"
+)
+
+// checksumSince is the first OpenZFS release supporting each algorithm, if later than the earliest one.
+var checksumSince = map[ChecksumAlgorithm]Version{
+	ChecksumSHA512: {0, 7, 0},
+	ChecksumSkein:  {0, 7, 0},
+	ChecksumEdonR:  {0, 7, 0},
+	ChecksumBLAKE3: {2, 2, 0},
+}
+
+// dedupChecksums are the algorithms strong enough to deduplicate blocks by.
+var dedupChecksums = map[ChecksumAlgorithm]bool{
+	ChecksumSHA256: true,
+	ChecksumSHA512: true,
+	ChecksumSkein:  true,
+	ChecksumEdonR:  true,
+	ChecksumBLAKE3: true,
+}
+
+// check returns an error if a is not a known algorithm, or is not supported by release v.
+// The release is not checked if v is nil.
+func (a ChecksumAlgorithm) check(v *Version) error {
+	switch a {
+	case ChecksumOn, ChecksumOff, ChecksumFletcher2, ChecksumFletcher4, ChecksumSHA256:
+		return nil
+	}
+	since, ok := checksumSince[a]
+	if !ok {
+		return fmt.Errorf("invalid checksum algorithm %q", a)
+	}
+	if v != nil && !v.AtLeast(since) {
+		return fmt.Errorf("checksum algorithm %s requires OpenZFS %s, running %s", a, since, v)
+	}
+	return nil
+}
+
+// detectedVersion returns the detected release, or nil if it cannot be detected, in which case zfs itself
+// has to reject algorithms it does not support.
+func detectedVersion() *Version {
+	v, err := DetectVersion()
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// SetChecksum sets the checksum algorithm of the receiving dataset, which applies to newly written blocks.
+// Algorithms not supported by the detected OpenZFS release are refused, see DetectVersion.
+// Pools need the corresponding feature, e.g. feature@blake3, to be enabled as well.
+func (d *Dataset) SetChecksum(a ChecksumAlgorithm) error {
+	if err := a.check(detectedVersion()); err != nil {
+		return err
+	}
+	return d.SetProperty("checksum", string(a))
+}
+
+// Checksum returns the checksum algorithm of the receiving dataset.
+func (d *Dataset) Checksum() (ChecksumAlgorithm, error) {
+	val, err := d.GetProperty("checksum")
+	return ChecksumAlgorithm(val), err
+}
+
+// DedupMode is a value of the dedup property, e.g. "off", "on", "sha512" or "skein,verify".
+type DedupMode string
+
+// Deduplication modes not naming an algorithm. DedupOn and DedupVerify use sha256.
+const (
+	DedupOff    DedupMode = "off"
+	DedupOn     DedupMode = "on"
+	DedupVerify DedupMode = "verify"
+)
+
+// NewDedupMode returns the mode deduplicating blocks by checksum algorithm a,
+// comparing the contents of blocks with matching checksums if verify is set.
+func NewDedupMode(a ChecksumAlgorithm, verify bool) DedupMode {
+	if verify {
+		return DedupMode(string(a) + ",verify")
+	}
+	return DedupMode(a)
+}
+
+// Algorithm returns the checksum algorithm of the mode, empty if deduplication is off.
+func (m DedupMode) Algorithm() ChecksumAlgorithm {
+	switch m {
+	case DedupOff:
+		return ""
+	case DedupOn, DedupVerify:
+		return ChecksumSHA256
+	}
+	return ChecksumAlgorithm(strings.TrimSuffix(string(m), ",verify"))
+}
+
+// Verify reports whether the mode compares the contents of blocks with matching checksums.
+func (m DedupMode) Verify() bool {
+	return m == DedupVerify || strings.HasSuffix(string(m), ",verify")
+}
+
+// check returns an error if m is not a valid mode, or its algorithm is not supported by release v.
+func (m DedupMode) check(v *Version) error {
+	a := m.Algorithm()
+	if a == "" {
+		return nil
+	}
+	if !dedupChecksums[a] {
+		return fmt.Errorf("invalid dedup mode %q", m)
+	}
+	if a == ChecksumEdonR && !m.Verify() {
+		return fmt.Errorf("dedup with %s requires verify", a)
+	}
+	return a.check(v)
+}
+
+// SetDedup sets the deduplication mode of the receiving dataset, which applies to newly written blocks.
+// Modes using algorithms not supported by the detected OpenZFS release are refused, see DetectVersion.
+func (d *Dataset) SetDedup(m DedupMode) error {
+	if err := m.check(detectedVersion()); err != nil {
+		return err
+	}
+	return d.SetProperty("dedup", string(m))
+}
+
+// Dedup returns the deduplication mode of the receiving dataset.
+func (d *Dataset) Dedup() (DedupMode, error) {
+	val, err := d.GetProperty("dedup")
+	return DedupMode(val), err
+}
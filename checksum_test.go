@@ -0,0 +1,53 @@
+package zfs
+
+import "testing"
+
+func TestChecksumAndDedupCheck(t *testing.T) {
+	v21, v22 := &Version{2, 1, 14}, &Version{2, 2, 0}
+	checksums := []struct {
+		alg     ChecksumAlgorithm
+		version *Version
+		ok      bool
+	}{
+		{ChecksumOn, v21, true},
+		{ChecksumSHA512, v21, true},
+		{ChecksumBLAKE3, v21, false},
+		{ChecksumBLAKE3, v22, true},
+		{ChecksumBLAKE3, nil, true},
+		{ChecksumAlgorithm("md5"), nil, false},
+	}
+	for _, test := range checksums {
+		if err := test.alg.check(test.version); (err == nil) != test.ok {
+			t.Fatalf("%s on %v: wanted ok: %v, got: %v", test.alg, test.version, test.ok, err)
+		}
+	}
+
+	modes := []struct {
+		mode    DedupMode
+		alg     ChecksumAlgorithm
+		verify  bool
+		version *Version
+		ok      bool
+	}{
+		{DedupOff, "", false, v21, true},
+		{DedupOn, ChecksumSHA256, false, v21, true},
+		{DedupVerify, ChecksumSHA256, true, v21, true},
+		{NewDedupMode(ChecksumSkein, true), ChecksumSkein, true, v21, true},
+		{NewDedupMode(ChecksumEdonR, false), ChecksumEdonR, false, v21, false},
+		{NewDedupMode(ChecksumEdonR, true), ChecksumEdonR, true, v21, true},
+		{NewDedupMode(ChecksumBLAKE3, false), ChecksumBLAKE3, false, v21, false},
+		{NewDedupMode(ChecksumBLAKE3, true), ChecksumBLAKE3, true, v22, true},
+		{NewDedupMode(ChecksumFletcher4, false), ChecksumFletcher4, false, v22, false},
+	}
+	for _, test := range modes {
+		if alg := test.mode.Algorithm(); alg != test.alg {
+			t.Fatalf("wanted: %v, got: %v", test.alg, alg)
+		}
+		if verify := test.mode.Verify(); verify != test.verify {
+			t.Fatalf("wanted: %v, got: %v", test.verify, verify)
+		}
+		if err := test.mode.check(test.version); (err == nil) != test.ok {
+			t.Fatalf("%s on %v: wanted ok: %v, got: %v", test.mode, test.version, test.ok, err)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package zfs
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OriginGraph is the origin to clone relationship graph of the datasets in a pool.
+type OriginGraph struct {
+	// Origins maps each clone to its origin snapshot.
+	Origins map[string]string `json:"origins"`
+	// Clones maps each origin snapshot to its clones, ordered by name.
+	Clones map[string][]string `json:"clones"`
+	// createtxg holds the creation txg of the origin snapshots.
+	createtxg map[string]uint64
+}
+
+// CloneGraph builds the origin to clone relationship graph of all datasets in the pool (or below the given dataset),
+// using their origin properties.
+func CloneGraph(pool string) (*OriginGraph, error) {
+	out, err := zfsOutput("list", "-Hp", "-r", "-t", "filesystem,volume,snapshot", "-o", "name,origin,createtxg", pool)
+	if err != nil {
+		return nil, err
+	}
+	return parseOriginGraph(out)
+}
+
+func parseOriginGraph(lines [][]string) (*OriginGraph, error) {
+	g := &OriginGraph{
+		Origins:   map[string]string{},
+		Clones:    map[string][]string{},
+		createtxg: map[string]uint64{},
+	}
+
+	txgs := make(map[string]uint64, len(lines))
+	for _, line := range lines {
+		if len(line) != 3 {
+			return nil, errors.New("output does not match what is expected on this platform")
+		}
+		txg, err := strconv.ParseUint(line[2], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		txgs[line[0]] = txg
+
+		if line[1] != "-" && line[1] != "" {
+			g.Origins[line[0]] = line[1]
+			g.Clones[line[1]] = append(g.Clones[line[1]], line[0])
+		}
+	}
+
+	for origin, clones := range g.Clones {
+		sort.Strings(clones)
+		g.createtxg[origin] = txgs[origin]
+	}
+	return g, nil
+}
+
+// isWithin reports whether name is dataset itself, or one of its descendents or snapshots.
+func isWithin(name, dataset string) bool {
+	return name == dataset || strings.HasPrefix(name, dataset+"/") || strings.HasPrefix(name, dataset+"@")
+}
+
+// PromotionOrder returns the clones that have to be promoted (see Dataset.Promote), in order,
+// before dataset and its descendents can be destroyed without destroying clones outside of it.
+//
+// Promoting a clone moves its origin snapshot, and all earlier snapshots of the origin's file system, to the clone.
+// For each file system, promoting the clone of the newest origin snapshot is therefore sufficient.
+func (g *OriginGraph) PromotionOrder(dataset string) []string {
+	newest := map[string]string{} // file system -> newest origin snapshot with clones outside of dataset
+	for origin, clones := range g.Clones {
+		if !isWithin(origin, dataset) {
+			continue
+		}
+		external := false
+		for _, c := range clones {
+			if !isWithin(c, dataset) {
+				external = true
+				break
+			}
+		}
+		if !external {
+			continue
+		}
+
+		fs := strings.SplitN(origin, "@", 2)[0]
+		if cur, ok := newest[fs]; !ok || g.createtxg[origin] > g.createtxg[cur] {
+			newest[fs] = origin
+		}
+	}
+
+	filesystems := make([]string, 0, len(newest))
+	for fs := range newest {
+		filesystems = append(filesystems, fs)
+	}
+	sort.Strings(filesystems)
+
+	order := make([]string, 0, len(filesystems))
+	for _, fs := range filesystems {
+		for _, c := range g.Clones[newest[fs]] {
+			if !isWithin(c, dataset) {
+				order = append(order, c)
+				break
+			}
+		}
+	}
+	return order
+}
+
+// OriginChain returns the origin snapshots the named dataset was cloned from, nearest first: its origin,
+// the origin of that snapshot's file system, and so on up to a file system that is not a clone.
+// For a snapshot, the chain starts with the origin of its file system.
+func (g *OriginGraph) OriginChain(dataset string) []string {
+	chain, _ := originChain(dataset, func(fs string) (string, error) { return g.Origins[fs], nil })
+	return chain
+}
+
+// OriginChain returns the origin snapshots the receiving dataset was cloned from, nearest first,
+// up to a file system that is not a clone. It is empty if the dataset is not a clone.
+// For a snapshot, the chain starts with the origin of its file system.
+func (d *Dataset) OriginChain() ([]string, error) {
+	return originChain(d.Name, func(fs string) (string, error) {
+		out, err := zfsOutput("get", "-H", "-o", "value", "origin", fs)
+		if err != nil {
+			return "", err
+		}
+		if len(out) != 1 || len(out[0]) != 1 {
+			return "", errors.New("output does not match what is expected on this platform")
+		}
+		if out[0][0] == "-" {
+			return "", nil
+		}
+		return out[0][0], nil
+	})
+}
+
+// originChain follows the origins from the file system of dataset, where origin returns the origin snapshot
+// of a file system, empty if it is not a clone.
+func originChain(dataset string, origin func(fs string) (string, error)) ([]string, error) {
+	var chain []string
+	fs := strings.SplitN(dataset, "@", 2)[0]
+	seen := map[string]bool{}
+	for !seen[fs] {
+		seen[fs] = true
+		o, err := origin(fs)
+		if err != nil {
+			return nil, err
+		}
+		if o == "" {
+			break
+		}
+		chain = append(chain, o)
+		fs = strings.SplitN(o, "@", 2)[0]
+	}
+	return chain, nil
+}
+
+// Dependents returns the clones of the receiving snapshot, ordered by name.
+// The snapshot can only be destroyed along with them, or once they were promoted (see Dataset.Promote).
+func (d *Dataset) Dependents() ([]string, error) {
+	if d.Type != DatasetSnapshot {
+		return nil, errors.New("can only list dependents of snapshots")
+	}
+	out, err := zfsOutput("get", "-H", "-o", "value", "clones", d.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) != 1 || len(out[0]) != 1 {
+		return nil, errors.New("output does not match what is expected on this platform")
+	}
+	var clones []string
+	if out[0][0] != "" && out[0][0] != "-" {
+		clones = splitList(out[0][0])
+	}
+	sort.Strings(clones)
+	return clones, nil
+}
@@ -0,0 +1,67 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOriginGraph(t *testing.T) {
+	g, err := parseOriginGraph([][]string{
+		{"test", "-", "1"},
+		{"test/fs", "-", "10"},
+		{"test/fs@a", "-", "11"},
+		{"test/fs@b", "-", "12"},
+		{"test/fs@c", "-", "13"},
+		{"test/fs/child", "-", "14"},
+		{"test/fs/child@x", "-", "15"},
+		{"test/clone-a", "test/fs@a", "20"},
+		{"test/clone-b", "test/fs@b", "21"},
+		{"test/fs/inner-clone", "test/fs@c", "22"},
+		{"test/child-clone", "test/fs/child@x", "23"},
+		{"test/unrelated-clone", "test/other@x", "24"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := g.Origins["test/clone-b"]; got != "test/fs@b" {
+		t.Fatalf("wanted origin test/fs@b, got: %q", got)
+	}
+	if got := g.Clones["test/fs@a"]; !reflect.DeepEqual([]string{"test/clone-a"}, got) {
+		t.Fatalf("wanted clones [test/clone-a], got: %v", got)
+	}
+
+	// test/fs@c is only cloned within test/fs, so promoting test/clone-b covers test/fs
+	want := []string{"test/clone-b", "test/child-clone"}
+	if got := g.PromotionOrder("test/fs"); !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %v, got: %v", want, got)
+	}
+
+	if got := g.PromotionOrder("test/clone-a"); len(got) != 0 {
+		t.Fatalf("wanted no promotions, got: %v", got)
+	}
+}
+
+func TestOriginChain(t *testing.T) {
+	g, err := parseOriginGraph([][]string{
+		{"test/base", "-", "1"},
+		{"test/base@gold", "-", "2"},
+		{"test/golden", "test/base@gold", "3"},
+		{"test/golden@v1", "-", "4"},
+		{"test/vm", "test/golden@v1", "5"},
+		{"test/vm@daily", "-", "6"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"test/golden@v1", "test/base@gold"}
+	for _, name := range []string{"test/vm", "test/vm@daily"} {
+		if got := g.OriginChain(name); !reflect.DeepEqual(want, got) {
+			t.Fatalf("wanted: %v, got: %v", want, got)
+		}
+	}
+	if got := g.OriginChain("test/base"); len(got) != 0 {
+		t.Fatalf("wanted no origins, got: %v", got)
+	}
+}
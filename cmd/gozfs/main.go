@@ -0,0 +1,372 @@
+// Command gozfs is a reference command line interface built on the zfs package.
+// It prints its results as JSON, and doubles as an example of using the library.
+//
+// Usage:
+//
+//	gozfs pools
+//	gozfs status POOL
+//	gozfs list [-t TYPE] [-names] [FILTER]
+//	gozfs get DATASET [PROPERTY...]
+//	gozfs snapshot [-r] DATASET@NAME
+//	gozfs destroy [-r] [-n] NAME
+//	gozfs send [-i BASE] [-I] [-w] [-c] [-R] SNAPSHOT > STREAM
+//	gozfs receive [-F] [-u] [-s] TARGET < STREAM
+//	gozfs replicate [-i BASE] [-I] [-w] [-c] [-R] [-F] [-u] [-gzip] SNAPSHOT TARGET
+//	gozfs watch [-interval DURATION] [-capacity PERCENT,...] [POOL...]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	zfs "github.com/mistifyio/go-zfs/v3"
+)
+
+// errUsage is returned for invalid command lines, after the usage has been printed.
+var errUsage = errors.New("invalid usage")
+
+// env holds the streams of a command, replaced in tests.
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+type cmd struct {
+	usage string
+	run   func(ctx context.Context, e env, fs *flag.FlagSet, args []string) error
+}
+
+var commands = map[string]cmd{
+	"pools":     {"", runPools},
+	"status":    {"POOL", runStatus},
+	"list":      {"[-t TYPE] [-names] [FILTER]", runList},
+	"get":       {"DATASET [PROPERTY...]", runGet},
+	"snapshot":  {"[-r] DATASET@NAME", runSnapshot},
+	"destroy":   {"[-r] [-n] NAME", runDestroy},
+	"send":      {"[-i BASE] [-I] [-w] [-c] [-R] SNAPSHOT", runSend},
+	"receive":   {"[-F] [-u] [-s] TARGET", runReceive},
+	"replicate": {"[-i BASE] [-I] [-w] [-c] [-R] [-F] [-u] [-gzip] SNAPSHOT TARGET", runReplicate},
+	"watch":     {"[-interval DURATION] [-capacity PERCENT,...] [POOL...]", runWatch},
+}
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		cancel()
+	}()
+
+	err := run(ctx, env{os.Stdin, os.Stdout, os.Stderr}, os.Args[1:])
+	cancel()
+	switch {
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "gozfs:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, e env, args []string) error {
+	if len(args) == 0 {
+		usage(e.stderr)
+		return errUsage
+	}
+	c, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(e.stderr, "gozfs: unknown command %q\n", args[0])
+		usage(e.stderr)
+		return errUsage
+	}
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "usage: gozfs %s %s\n", args[0], c.usage)
+		fs.PrintDefaults()
+	}
+	return c.run(ctx, e, fs, args[1:])
+}
+
+func usage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "usage: gozfs COMMAND [ARGS]")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s %s\n", name, commands[name].usage)
+	}
+}
+
+// parse parses the command's flags and checks that between min and max arguments remain, max < 0 meaning no limit.
+func parse(fs *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, errUsage
+	}
+	if n := fs.NArg(); n < min || (max >= 0 && n > max) {
+		fs.Usage()
+		return nil, errUsage
+	}
+	return fs.Args(), nil
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func runPools(ctx context.Context, e env, fs *flag.FlagSet, args []string) error {
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	pools, err := zfs.ListZpools()
+	if err != nil {
+		return err
+	}
+	return printJSON(e.stdout, pools)
+}
+
+func runStatus(ctx context.Context, e env, fs *flag.FlagSet, args []string) error {
+	args, err := parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	status, err := zfs.GetZpoolStatusWithOptions(args[0], zfs.ZpoolStatusOptions{Parsable: true})
+	if err != nil {
+		return err
+	}
+	return printJSON(e.stdout, status)
+}
+
+func runList(ctx context.Context, e env, fs *flag.FlagSet, args []string) error {
+	types := fs.String("t", "", "comma separated dataset `types` to list, e.g. filesystem,snapshot")
+	names := fs.Bool("names", false, "list names only")
+	args, err := parse(fs, args, 0, 1)
+	if err != nil {
+		return err
+	}
+	opts := zfs.ListOptions{NamesOnly: *names}
+	if *types != "" {
+		opts.Types = strings.Split(*types, ",")
+	}
+	filter := ""
+	if len(args) > 0 {
+		filter = args[0]
+	}
+	datasets, err := zfs.ListDatasets(filter, opts)
+	if err != nil {
+		return err
+	}
+	if *names {
+		list := make([]string, len(datasets))
+		for i, ds := range datasets {
+			list[i] = ds.Name
+		}
+		return printJSON(e.stdout, list)
+	}
+	return printJSON(e.stdout, datasets)
+}
+
+func runGet(ctx context.Context, e env, fs *flag.FlagSet, args []string) error {
+	args, err := parse(fs, args, 1, -1)
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		ds, err := zfs.GetDataset(args[0])
+		if err != nil {
+			return err
+		}
+		return printJSON(e.stdout, ds)
+	}
+	ds := &zfs.Dataset{Name: args[0]}
+	props := make(map[string]string, len(args)-1)
+	for _, prop := range args[1:] {
+		if props[prop], err = ds.GetProperty(prop); err != nil {
+			return err
+		}
+	}
+	return printJSON(e.stdout, props)
+}
+
+func runSnapshot(ctx context.Context, e env, fs *flag.FlagSet, args []string) error {
+	recursive := fs.Bool("r", false, "snapshot descendent datasets as well")
+	args, err := parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	if err := zfs.ValidateSnapshotName(args[0]); err != nil {
+		return err
+	}
+	i := strings.IndexByte(args[0], '@')
+	snap, err := (&zfs.Dataset{Name: args[0][:i]}).Snapshot(args[0][i+1:], *recursive)
+	if err != nil {
+		return err
+	}
+	return printJSON(e.stdout, snap)
+}
+
+func runDestroy(ctx context.Context, e env, fs *flag.FlagSet, args []string) error {
+	recursive := fs.Bool("r", false, "destroy descendents as well")
+	dryRun := fs.Bool("n", false, "only print what would be destroyed")
+	args, err := parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	flags := zfs.DestroyDefault
+	if *recursive {
+		flags = zfs.DestroyRecursive
+	}
+	ds := &zfs.Dataset{Name: args[0]}
+	preview, err := ds.DestroyPreview(flags)
+	if err != nil {
+		return err
+	}
+	if !*dryRun {
+		if err := ds.Destroy(flags); err != nil {
+			return err
+		}
+	}
+	return printJSON(e.stdout, preview)
+}
+
+// sendFlags registers the flags shared by send and replicate.
+func sendFlags(fs *flag.FlagSet) *zfs.SendOptions {
+	opts := &zfs.SendOptions{}
+	fs.StringVar(&opts.IncrementalBase, "i", "", "send incrementally from the `base` snapshot or bookmark")
+	fs.BoolVar(&opts.Intermediate, "I", false, "include intermediate snapshots of an incremental send")
+	fs.BoolVar(&opts.Raw, "w", false, "send raw, still encrypted data")
+	fs.BoolVar(&opts.Compressed, "c", false, "send compressed blocks as stored on disk")
+	fs.BoolVar(&opts.Replicate, "R", false, "send a replication stream of all descendents")
+	return opts
+}
+
+// receiveFlags registers the flags shared by receive and replicate.
+func receiveFlags(fs *flag.FlagSet) *zfs.ReceiveOptions {
+	opts := &zfs.ReceiveOptions{}
+	fs.BoolVar(&opts.Force, "F", false, "roll back the target before receiving")
+	fs.BoolVar(&opts.NoMount, "u", false, "do not mount the received file system")
+	return opts
+}
+
+// progress returns a progress callback printing JSON lines to w.
+func progress(w io.Writer) zfs.ProgressFunc {
+	enc := json.NewEncoder(w)
+	return func(p zfs.Progress) {
+		_ = enc.Encode(p)
+	}
+}
+
+func runSend(ctx context.Context, e env, fs *flag.FlagSet, args []string) error {
+	opts := sendFlags(fs)
+	args, err := parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	snap, err := snapshot(args[0])
+	if err != nil {
+		return err
+	}
+	// the stream goes to stdout, progress to stderr
+	opts.Progress = progress(e.stderr)
+	return snap.SendTo(ctx, e.stdout, *opts)
+}
+
+// snapshot returns the named snapshot, as a dataset that can be sent.
+func snapshot(name string) (*zfs.Dataset, error) {
+	if err := zfs.ValidateSnapshotName(name); err != nil {
+		return nil, err
+	}
+	return &zfs.Dataset{Name: name, Type: zfs.DatasetSnapshot}, nil
+}
+
+func runReceive(ctx context.Context, e env, fs *flag.FlagSet, args []string) error {
+	opts := receiveFlags(fs)
+	fs.BoolVar(&opts.Resumable, "s", false, "save the partially received state on interruption")
+	args, err := parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	ds, err := zfs.ReceiveFrom(ctx, e.stdin, args[0], *opts)
+	if err != nil {
+		return err
+	}
+	return printJSON(e.stdout, ds)
+}
+
+func runReplicate(ctx context.Context, e env, fs *flag.FlagSet, args []string) error {
+	send := sendFlags(fs)
+	receive := receiveFlags(fs)
+	gzip := fs.Bool("gzip", false, "compress the stream in transit")
+	args, err := parse(fs, args, 2, 2)
+	if err != nil {
+		return err
+	}
+	snap, err := snapshot(args[0])
+	if err != nil {
+		return err
+	}
+	send.Progress = progress(e.stderr)
+	opts := zfs.ReplicateOptions{Send: *send, Receive: *receive}
+	if *gzip {
+		opts.Transforms = []zfs.StreamTransform{zfs.GzipTransform{}}
+	}
+	ds, err := zfs.Replicate(ctx, snap, args[1], opts)
+	if err != nil {
+		return err
+	}
+	return printJSON(e.stdout, ds)
+}
+
+func runWatch(ctx context.Context, e env, fs *flag.FlagSet, args []string) error {
+	interval := fs.Duration("interval", time.Minute, "time between polls")
+	capacity := fs.String("capacity", "80,90", "comma separated capacity `thresholds` in percent")
+	pools, err := parse(fs, args, 0, -1)
+	if err != nil {
+		return err
+	}
+	thresholds, err := parseThresholds(*capacity)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(e.stdout)
+	w := &zfs.CapacityWatcher{
+		Pools:      pools,
+		Thresholds: thresholds,
+		Interval:   *interval,
+		OnAlert:    func(a zfs.CapacityAlert) { _ = enc.Encode(a) },
+	}
+	if err := w.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+// parseThresholds parses a comma separated list of capacity thresholds, e.g. "80,90".
+func parseThresholds(s string) ([]zfs.CapacityThreshold, error) {
+	var thresholds []zfs.CapacityThreshold
+	for _, p := range strings.Split(s, ",") {
+		percent, err := strconv.ParseUint(strings.TrimSpace(p), 10, 64)
+		if err != nil || percent == 0 || percent > 100 {
+			return nil, fmt.Errorf("invalid capacity threshold %q", p)
+		}
+		thresholds = append(thresholds, zfs.CapacityThreshold{Metric: zfs.MetricCapacity, Percent: percent})
+	}
+	return thresholds, nil
+}
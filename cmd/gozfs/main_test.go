@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	zfs "github.com/mistifyio/go-zfs/v3"
+)
+
+func TestRunUsage(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		err    error
+		stderr string
+	}{
+		{"no command", nil, errUsage, "usage: gozfs COMMAND"},
+		{"unknown command", []string{"frobnicate"}, errUsage, `unknown command "frobnicate"`},
+		{"missing argument", []string{"status"}, errUsage, "usage: gozfs status POOL"},
+		{"extra argument", []string{"pools", "tank"}, errUsage, "usage: gozfs pools"},
+		{"unknown flag", []string{"list", "-x"}, errUsage, "flag provided but not defined"},
+		{"invalid snapshot", []string{"snapshot", "tank/fs"}, zfs.ErrInvalidName, ""},
+		{"send of a file system", []string{"send", "tank/fs"}, zfs.ErrInvalidName, ""},
+		{"replicate of a file system", []string{"replicate", "tank/fs", "backup/fs"}, zfs.ErrInvalidName, ""},
+		{"invalid threshold", []string{"watch", "-capacity", "80,x"}, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			err := run(context.Background(), env{strings.NewReader(""), &stdout, &stderr}, tt.args)
+			if err == nil {
+				t.Fatal("wanted an error, got nil")
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("wanted: %v, got: %v", tt.err, err)
+			}
+			if !strings.Contains(stderr.String(), tt.stderr) {
+				t.Fatalf("wanted stderr containing %q, got: %q", tt.stderr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	snap, err := snapshot("tank/fs@snap")
+	if err != nil || snap.Name != "tank/fs@snap" || snap.Type != zfs.DatasetSnapshot {
+		t.Fatalf("wanted: a snapshot, got: %+v %v", snap, err)
+	}
+	if _, err := snapshot("tank/fs"); !errors.Is(err, zfs.ErrInvalidName) {
+		t.Fatalf("wanted: %v, got: %v", zfs.ErrInvalidName, err)
+	}
+}
+
+func TestParseThresholds(t *testing.T) {
+	got, err := parseThresholds("80, 90")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []zfs.CapacityThreshold{
+		{Metric: zfs.MetricCapacity, Percent: 80},
+		{Metric: zfs.MetricCapacity, Percent: 90},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %v, got: %v", want, got)
+	}
+
+	for _, s := range []string{"", "0", "101", "80,,90", "high"} {
+		if _, err := parseThresholds(s); err == nil {
+			t.Fatalf("%q: wanted an error, got nil", s)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package zfs
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrNoCommonSnapshot is returned by CommonSnapshot when source and target share no snapshot.
+var ErrNoCommonSnapshot = errors.New("no common snapshot")
+
+// CommonSnapshot returns the newest snapshot or bookmark of the source dataset that also exists as a snapshot of the target dataset,
+// for use as the IncrementalBase of the next send. Snapshots are matched by GUID, so renamed snapshots are found as well.
+// A snapshot is preferred over a bookmark of the same snapshot, as only snapshots can be the base of an intermediate stream.
+// ErrNoCommonSnapshot is returned if there is none, in which case a full send is required.
+func CommonSnapshot(source, target string) (*Dataset, error) {
+	sourceSnaps, err := listSortedByCreation(source, DatasetSnapshot+","+DatasetBookmark)
+	if err != nil {
+		return nil, err
+	}
+	targetSnaps, err := listSortedByCreation(target, DatasetSnapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	if common := newestCommon(sourceSnaps, targetSnaps); common != nil {
+		return common, nil
+	}
+	return nil, ErrNoCommonSnapshot
+}
+
+// newestCommon returns the newest of source whose GUID is also found in target, or nil.
+// source must be ordered from oldest to newest.
+func newestCommon(source, target []*Dataset) *Dataset {
+	guids := make(map[uint64]bool, len(target))
+	for _, t := range target {
+		guids[t.GUID] = true
+	}
+
+	var common *Dataset
+	for i := len(source) - 1; i >= 0; i-- {
+		s := source[i]
+		if !guids[s.GUID] {
+			continue
+		}
+		if common == nil {
+			common = s
+		} else if s.GUID != common.GUID {
+			break
+		}
+		if s.Type == DatasetSnapshot {
+			return s
+		}
+	}
+	return common
+}
+
+// ReplicaReport is the result of comparing the snapshots of a source dataset with those of its replica.
+// Snapshots are listed by their short name (the part after "@"), ordered from oldest to newest.
+type ReplicaReport struct {
+	// Common are the snapshots found on both sides, matched by GUID.
+	Common []string `json:"common"`
+	// Missing are the source snapshots not found on the target, neither by GUID nor by name.
+	Missing []string `json:"missing"`
+	// Extra are the target snapshots not found on the source.
+	Extra []string `json:"extra"`
+	// Diverged are the target snapshots named like a source snapshot, but with a different GUID.
+	Diverged []string `json:"diverged"`
+}
+
+// InSync reports whether the target holds exactly the snapshots of the source.
+func (r *ReplicaReport) InSync() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Diverged) == 0
+}
+
+// VerifyReplica compares the snapshots of the source dataset with those of the target dataset by GUID,
+// reporting missing, extra and diverged snapshots on the target.
+func VerifyReplica(source, target string) (*ReplicaReport, error) {
+	sourceSnaps, err := listSortedByCreation(source, DatasetSnapshot)
+	if err != nil {
+		return nil, err
+	}
+	targetSnaps, err := listSortedByCreation(target, DatasetSnapshot)
+	if err != nil {
+		return nil, err
+	}
+	return compareReplica(sourceSnaps, targetSnaps), nil
+}
+
+// snapshotShortName returns the part of a snapshot name after "@".
+func snapshotShortName(name string) string {
+	if i := strings.LastIndex(name, "@"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+func compareReplica(source, target []*Dataset) *ReplicaReport {
+	r := &ReplicaReport{}
+
+	targetGUIDs := make(map[uint64]bool, len(target))
+	targetNames := make(map[string]bool, len(target))
+	for _, t := range target {
+		targetGUIDs[t.GUID] = true
+		targetNames[snapshotShortName(t.Name)] = true
+	}
+	sourceGUIDs := make(map[uint64]bool, len(source))
+	sourceNames := make(map[string]bool, len(source))
+	for _, s := range source {
+		sourceGUIDs[s.GUID] = true
+		sourceNames[snapshotShortName(s.Name)] = true
+
+		switch name := snapshotShortName(s.Name); {
+		case targetGUIDs[s.GUID]:
+			r.Common = append(r.Common, name)
+		case !targetNames[name]:
+			// a diverged snapshot is reported as such below
+			r.Missing = append(r.Missing, name)
+		}
+	}
+
+	for _, t := range target {
+		if sourceGUIDs[t.GUID] {
+			continue
+		}
+		name := snapshotShortName(t.Name)
+		if sourceNames[name] {
+			r.Diverged = append(r.Diverged, name)
+		} else {
+			r.Extra = append(r.Extra, name)
+		}
+	}
+	return r
+}
+
+// SubtreeCommonSnapshot is the result of MostRecentCommonSnapshot.
+type SubtreeCommonSnapshot struct {
+	// Snapshot is the short name (the part after "@") of the newest snapshot common to all datasets,
+	// empty if there is none.
+	Snapshot string `json:"snapshot"`
+	// Datasets maps the datasets found below both source and target, by their name relative to the source
+	// ("" for the source itself, "child/grandchild" for a descendant), to their own newest common snapshot,
+	// empty for those sharing none.
+	Datasets map[string]string `json:"datasets"`
+}
+
+// MostRecentCommonSnapshot returns the newest snapshot name that the source dataset and each of its descendants
+// share with the corresponding dataset below target, for use as the IncrementalBase of a recursive (Replicate) send.
+// Snapshots are matched by GUID, as in CommonSnapshot. Descendants only found below source are ignored,
+// as a replication stream creates them on the target.
+// ErrNoCommonSnapshot is returned along with the result if there is no such name; its Datasets tell which
+// datasets lack a common snapshot, or have diverged.
+func MostRecentCommonSnapshot(source, target string) (*SubtreeCommonSnapshot, error) {
+	types := DatasetFilesystem + "," + DatasetVolume + "," + DatasetSnapshot
+	list := func(name string) ([]*Dataset, error) {
+		return listDatasetsWithOptional(func(props []string) []string {
+			return []string{"list", "-Hp", "-r", "-t", types, "-o", strings.Join(props, ","), name}
+		})
+	}
+	sourceDatasets, err := list(source)
+	if err != nil {
+		return nil, err
+	}
+	targetDatasets, err := list(target)
+	if err != nil {
+		return nil, err
+	}
+
+	c := subtreeCommon(source, sourceDatasets, target, targetDatasets)
+	if c.Snapshot == "" {
+		return c, ErrNoCommonSnapshot
+	}
+	return c, nil
+}
+
+// subtree groups the snapshots below root by the name of their dataset relative to root,
+// with an entry for every dataset, ordered from oldest to newest.
+func subtree(root string, datasets []*Dataset) map[string][]*Dataset {
+	snapshots := map[string][]*Dataset{}
+	for _, ds := range datasets {
+		fs := strings.SplitN(ds.Name, "@", 2)[0]
+		rel := strings.TrimPrefix(strings.TrimPrefix(fs, root), "/")
+		if ds.Type == DatasetSnapshot {
+			snapshots[rel] = append(snapshots[rel], ds)
+		} else if _, ok := snapshots[rel]; !ok {
+			snapshots[rel] = nil
+		}
+	}
+	for _, s := range snapshots {
+		sortByCreation(s)
+	}
+	return snapshots
+}
+
+func subtreeCommon(source string, sourceDatasets []*Dataset, target string, targetDatasets []*Dataset) *SubtreeCommonSnapshot {
+	c := &SubtreeCommonSnapshot{Datasets: map[string]string{}}
+	sourceSnaps := subtree(source, sourceDatasets)
+	targetSnaps := subtree(target, targetDatasets)
+
+	// common holds the short names of the snapshots each dataset shares with the target
+	common := map[string]map[string]bool{}
+	for rel, snaps := range sourceSnaps {
+		replicated, ok := targetSnaps[rel]
+		if !ok {
+			continue
+		}
+		guids := make(map[uint64]bool, len(replicated))
+		for _, t := range replicated {
+			guids[t.GUID] = true
+		}
+		common[rel] = map[string]bool{}
+		c.Datasets[rel] = ""
+		for _, s := range snaps {
+			if guids[s.GUID] {
+				common[rel][snapshotShortName(s.Name)] = true
+				c.Datasets[rel] = snapshotShortName(s.Name)
+			}
+		}
+	}
+
+	root := sourceSnaps[""]
+	for i := len(root) - 1; i >= 0; i-- {
+		name := snapshotShortName(root[i].Name)
+		shared := true
+		for _, names := range common {
+			if !names[name] {
+				shared = false
+				break
+			}
+		}
+		if shared {
+			c.Snapshot = name
+			break
+		}
+	}
+	return c
+}
@@ -0,0 +1,151 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewestCommon(t *testing.T) {
+	source := []*Dataset{
+		{Name: "src@a", Type: DatasetSnapshot, GUID: 1},
+		{Name: "src@b", Type: DatasetSnapshot, GUID: 2},
+		{Name: "src#c", Type: DatasetBookmark, GUID: 3},
+		{Name: "src@c", Type: DatasetSnapshot, GUID: 3},
+		{Name: "src#d", Type: DatasetBookmark, GUID: 4},
+		{Name: "src@e", Type: DatasetSnapshot, GUID: 5},
+	}
+
+	for name, test := range map[string]struct {
+		target []*Dataset
+		want   string
+	}{
+		"snapshot preferred over bookmark": {
+			target: []*Dataset{{GUID: 1}, {GUID: 3}},
+			want:   "src@c",
+		},
+		"bookmark": {
+			target: []*Dataset{{GUID: 2}, {GUID: 4}},
+			want:   "src#d",
+		},
+		"renamed snapshot": {
+			target: []*Dataset{{Name: "dst@renamed", GUID: 2}},
+			want:   "src@b",
+		},
+		"none": {
+			target: []*Dataset{{GUID: 42}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got := newestCommon(source, test.target)
+			if test.want == "" {
+				if got != nil {
+					t.Fatalf("wanted no common snapshot, got: %s", got.Name)
+				}
+				return
+			}
+			if got == nil || got.Name != test.want {
+				t.Fatalf("wanted: %s, got: %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestCompareReplica(t *testing.T) {
+	source := []*Dataset{
+		{Name: "src@a", GUID: 1},
+		{Name: "src@b", GUID: 2},
+		{Name: "src@c", GUID: 3},
+		{Name: "src@d", GUID: 4},
+	}
+	tests := map[string]struct {
+		target []*Dataset
+		want   *ReplicaReport
+	}{
+		"missing, extra and diverged": {
+			target: []*Dataset{
+				{Name: "dst@a", GUID: 1},
+				{Name: "dst@c", GUID: 3},
+				{Name: "dst@d", GUID: 40},
+				{Name: "dst@local", GUID: 50},
+			},
+			want: &ReplicaReport{
+				Common:   []string{"a", "c"},
+				Missing:  []string{"b"},
+				Extra:    []string{"local"},
+				Diverged: []string{"d"},
+			},
+		},
+		"only diverged": {
+			target: []*Dataset{
+				{Name: "dst@a", GUID: 1},
+				{Name: "dst@b", GUID: 20},
+				{Name: "dst@c", GUID: 3},
+				{Name: "dst@d", GUID: 4},
+			},
+			want: &ReplicaReport{
+				Common:   []string{"a", "c", "d"},
+				Diverged: []string{"b"},
+			},
+		},
+		"in sync": {
+			target: source,
+			want:   &ReplicaReport{Common: []string{"a", "b", "c", "d"}},
+		},
+	}
+	for name, test := range tests {
+		got := compareReplica(source, test.target)
+		if !reflect.DeepEqual(test.want, got) {
+			t.Fatalf("%s: wanted: %+v, got: %+v", name, test.want, got)
+		}
+		if got.InSync() != (name == "in sync") {
+			t.Fatalf("%s: wanted in sync: %v, got: %v", name, name == "in sync", got.InSync())
+		}
+	}
+}
+
+func TestSubtreeCommon(t *testing.T) {
+	source := []*Dataset{
+		{Name: "tank/src", Type: DatasetFilesystem},
+		{Name: "tank/src@a", Type: DatasetSnapshot, GUID: 1, Createtxg: 1},
+		{Name: "tank/src@b", Type: DatasetSnapshot, GUID: 2, Createtxg: 2},
+		{Name: "tank/src@c", Type: DatasetSnapshot, GUID: 3, Createtxg: 3},
+		{Name: "tank/src/db", Type: DatasetFilesystem},
+		{Name: "tank/src/db@a", Type: DatasetSnapshot, GUID: 11, Createtxg: 1},
+		{Name: "tank/src/db@b", Type: DatasetSnapshot, GUID: 12, Createtxg: 2},
+		{Name: "tank/src/db@c", Type: DatasetSnapshot, GUID: 13, Createtxg: 3},
+		{Name: "tank/src/new", Type: DatasetFilesystem},
+		{Name: "tank/src/new@c", Type: DatasetSnapshot, GUID: 23, Createtxg: 3},
+	}
+	target := []*Dataset{
+		{Name: "backup/dst", Type: DatasetFilesystem},
+		{Name: "backup/dst@a", Type: DatasetSnapshot, GUID: 1},
+		{Name: "backup/dst@b", Type: DatasetSnapshot, GUID: 2},
+		{Name: "backup/dst@c", Type: DatasetSnapshot, GUID: 3},
+		{Name: "backup/dst/db", Type: DatasetFilesystem},
+		{Name: "backup/dst/db@a", Type: DatasetSnapshot, GUID: 11},
+		{Name: "backup/dst/db@b", Type: DatasetSnapshot, GUID: 12},
+	}
+
+	// tank/src/db lacks c on the target, and tank/src/new is not replicated yet
+	got := subtreeCommon("tank/src", source, "backup/dst", target)
+	want := &SubtreeCommonSnapshot{Snapshot: "b", Datasets: map[string]string{"": "c", "db": "b"}}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %+v, got: %+v", want, got)
+	}
+
+	// a snapshot of the same name, but with a different GUID, has diverged
+	target[6].GUID = 99
+	got = subtreeCommon("tank/src", source, "backup/dst", target)
+	want = &SubtreeCommonSnapshot{Snapshot: "a", Datasets: map[string]string{"": "c", "db": "a"}}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %+v, got: %+v", want, got)
+	}
+
+	// a dataset without snapshots on the target shares none
+	target = target[:5]
+	got = subtreeCommon("tank/src", source, "backup/dst", target)
+	want = &SubtreeCommonSnapshot{Datasets: map[string]string{"": "c", "db": ""}}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %+v, got: %+v", want, got)
+	}
+}
@@ -0,0 +1,87 @@
+package zfs
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+// NoCountLimit is the FilesystemLimit or SnapshotLimit of datasets whose number of descendants is not limited.
+const NoCountLimit = math.MaxUint64
+
+// CountLimits are the numbers of file systems and snapshots below a dataset, and the limits on them.
+// Limits apply to the dataset and all of its descendants, but not to the root user of the global zone
+// or host; they restrict delegated administration, e.g. tenants allowed to create snapshots.
+//
+// Counts are only tracked, and reported non-zero, once a limit is set on the dataset or one of its
+// ancestors. This requires the pool's filesystem_limits feature to be enabled.
+type CountLimits struct {
+	Dataset string `json:"dataset"`
+	// FilesystemCount is the number of file systems and volumes below the dataset.
+	FilesystemCount uint64 `json:"filesystem_count"`
+	FilesystemLimit uint64 `json:"filesystem_limit"`
+	// SnapshotCount is the number of snapshots of the dataset and its descendants.
+	SnapshotCount uint64 `json:"snapshot_count"`
+	SnapshotLimit uint64 `json:"snapshot_limit"`
+}
+
+const countLimitProperties = "name,filesystem_count,filesystem_limit,snapshot_count,snapshot_limit"
+
+// CountLimits returns the counts and limits of the file systems and snapshots below the receiving dataset.
+func (d *Dataset) CountLimits() (*CountLimits, error) {
+	out, err := zfsOutput("list", "-Hp", "-o", countLimitProperties, d.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) != 1 {
+		return nil, errors.New("output does not match what is expected on this platform")
+	}
+	return parseCountLimits(out[0])
+}
+
+// example input for parseCountLimits
+// tank/tenant	12	100	340	none
+
+func parseCountLimits(line []string) (*CountLimits, error) {
+	if len(line) != 5 {
+		return nil, errors.New("output does not match what is expected on this platform")
+	}
+	c := &CountLimits{Dataset: line[0]}
+	if err := setUint(&c.FilesystemCount, line[1]); err != nil {
+		return nil, err
+	}
+	if err := setCountLimit(&c.FilesystemLimit, line[2]); err != nil {
+		return nil, err
+	}
+	if err := setUint(&c.SnapshotCount, line[3]); err != nil {
+		return nil, err
+	}
+	return c, setCountLimit(&c.SnapshotLimit, line[4])
+}
+
+func setCountLimit(field *uint64, val string) error {
+	if val == "none" || val == "-" {
+		*field = NoCountLimit
+		return nil
+	}
+	return setUint(field, val)
+}
+
+func formatCountLimit(limit uint64) string {
+	if limit == NoCountLimit {
+		return "none"
+	}
+	return strconv.FormatUint(limit, 10)
+}
+
+// SetFilesystemLimit limits the number of file systems and volumes that can be created below the receiving dataset,
+// NoCountLimit removes the limit.
+func (d *Dataset) SetFilesystemLimit(limit uint64) error {
+	return d.SetProperty("filesystem_limit", formatCountLimit(limit))
+}
+
+// SetSnapshotLimit limits the number of snapshots that can be taken of the receiving dataset and its descendants,
+// NoCountLimit removes the limit.
+func (d *Dataset) SetSnapshotLimit(limit uint64) error {
+	return d.SetProperty("snapshot_limit", formatCountLimit(limit))
+}
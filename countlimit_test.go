@@ -0,0 +1,36 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCountLimits(t *testing.T) {
+	tests := []struct {
+		out  string
+		want *CountLimits
+	}{
+		{"tank/tenant\t12\t100\t340\tnone\n", &CountLimits{Dataset: "tank/tenant", FilesystemCount: 12, FilesystemLimit: 100, SnapshotCount: 340, SnapshotLimit: NoCountLimit}},
+		{"tank/other\t-\tnone\t-\tnone\n", &CountLimits{Dataset: "tank/other", FilesystemLimit: NoCountLimit, SnapshotLimit: NoCountLimit}},
+		{"tank/frozen\t0\t0\t5\t5\n", &CountLimits{Dataset: "tank/frozen", SnapshotCount: 5, SnapshotLimit: 5}},
+	}
+	for _, test := range tests {
+		got, err := parseCountLimits(splitOutput(test.out)[0])
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(test.want, got) {
+			t.Fatalf("wanted: %+v, got: %+v", test.want, got)
+		}
+	}
+
+	if _, err := parseCountLimits([]string{"tank", "1"}); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	for limit, want := range map[uint64]string{NoCountLimit: "none", 0: "0", 100: "100"} {
+		if got := formatCountLimit(limit); got != want {
+			t.Fatalf("wanted: %v, got: %v", want, got)
+		}
+	}
+}
@@ -0,0 +1,425 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInsufficientCapacity is returned by Provisioner when the parent dataset has too little space left.
+var ErrInsufficientCapacity = errors.New("insufficient capacity")
+
+// defaultVolBlockSize is the volblocksize zvol sizes are rounded up to unless another one is requested.
+// It is the default of current OpenZFS releases and a multiple of that of older ones.
+const defaultVolBlockSize = 16 << 10
+
+// cloneSnapshotPrefix prefixes the snapshots taken of a source volume to clone it.
+const cloneSnapshotPrefix = "clone-"
+
+// VolumeRequest describes a volume to be created by Provisioner.CreateVolume.
+type VolumeRequest struct {
+	// Name is the name of the volume below the provisioner's parent dataset.
+	Name string
+	// Size is the volsize of a zvol, or the refquota of a file system. It may be zero for file systems only.
+	// Zvol sizes are rounded up to a multiple of their volblocksize.
+	Size uint64
+	// Filesystem creates a file system instead of a zvol.
+	Filesystem bool
+	// Properties are set on the new volume.
+	Properties map[string]string
+	// SourceSnapshot restores the volume from a snapshot, given relative to the parent dataset as "volume@snapshot".
+	SourceSnapshot string
+	// SourceVolume clones another volume below the parent dataset.
+	// The snapshot taken for that is destroyed together with the clone by DeleteVolume.
+	SourceVolume string
+}
+
+// Provisioner implements the idempotent volume operations of storage orchestrators such as Kubernetes CSI drivers
+// on the datasets below Parent.
+//
+// Every operation may be retried with the same arguments: creating an existing volume or snapshot returns it,
+// and deleting a missing one succeeds. Errors can be told apart with errors.Is: ErrDatasetExists is returned
+// for an existing volume not matching the request, ErrDatasetNotFound for missing volumes and sources,
+// and ErrInsufficientCapacity when the parent dataset has too little space left.
+type Provisioner struct {
+	// Parent is the dataset volumes are created below, e.g. "tank/k8s".
+	Parent string
+	// Sparse creates volumes without reserving their size, and skips the capacity checks.
+	Sparse bool
+	// RetryInterval is the time between attempts to destroy a busy volume, one second if zero.
+	RetryInterval time.Duration
+
+	// get, getProperty and run are replaced in tests.
+	get         func(name string) (*Dataset, error)
+	getProperty func(name, key string) (string, error)
+	run         func(ctx context.Context, arg ...string) error
+}
+
+func (p *Provisioner) dataset(name string) (*Dataset, error) {
+	if p.get != nil {
+		return p.get(name)
+	}
+	return GetDataset(name)
+}
+
+func (p *Provisioner) property(name, key string) (string, error) {
+	if p.getProperty != nil {
+		return p.getProperty(name, key)
+	}
+	return getProperty(name, key)
+}
+
+func (p *Provisioner) zfs(ctx context.Context, arg ...string) error {
+	if p.run != nil {
+		return p.run(ctx, arg...)
+	}
+	return zfsContext(ctx, arg...)
+}
+
+// fullName returns the name of a volume or snapshot below the parent dataset.
+func (p *Provisioner) fullName(name string) string {
+	return p.Parent + "/" + name
+}
+
+// CreateVolume creates a zvol or file system as requested, optionally restored from a snapshot or cloned
+// from another volume. If the volume exists already, it is returned as long as it satisfies the request.
+func (p *Provisioner) CreateVolume(ctx context.Context, req VolumeRequest) (*Dataset, error) {
+	name := p.fullName(req.Name)
+	if err := ValidateDatasetName(name); err != nil {
+		return nil, err
+	}
+	if req.SourceSnapshot != "" && req.SourceVolume != "" {
+		return nil, errors.New("only one of SourceSnapshot and SourceVolume may be set")
+	}
+	size := req.Size
+	if !req.Filesystem {
+		blockSize, err := volBlockSize(req.Properties["volblocksize"])
+		if err != nil {
+			return nil, err
+		}
+		size = roundUp(size, blockSize)
+	}
+
+	ds, err := p.dataset(name)
+	if err == nil {
+		if err := p.satisfies(ds, req, size); err != nil {
+			return nil, err
+		}
+		return ds, nil
+	}
+	if !errors.Is(err, ErrDatasetNotFound) {
+		return nil, err
+	}
+
+	if req.SourceSnapshot != "" || req.SourceVolume != "" {
+		return p.clone(ctx, name, req, size)
+	}
+	if size == 0 && !req.Filesystem {
+		return nil, errors.New("zvols require a size")
+	}
+	if err := p.checkCapacity(size); err != nil {
+		return nil, err
+	}
+
+	props := copyProps(req.Properties)
+	args := []string{"create"}
+	if req.Filesystem {
+		if size > 0 {
+			props["refquota"] = strconv.FormatUint(size, 10)
+			if !p.Sparse {
+				props["refreservation"] = strconv.FormatUint(size, 10)
+			}
+		}
+	} else {
+		if p.Sparse {
+			args = append(args, "-s")
+		}
+		args = append(args, "-V", strconv.FormatUint(size, 10))
+	}
+	args = append(args, propsSlice(props)...)
+	if err := p.zfs(ctx, append(args, name)...); err != nil {
+		return nil, err
+	}
+	return p.dataset(name)
+}
+
+// satisfies checks that an existing volume matches a request to create it.
+func (p *Provisioner) satisfies(ds *Dataset, req VolumeRequest, size uint64) error {
+	want := DatasetVolume
+	if req.Filesystem {
+		want = DatasetFilesystem
+	}
+	if ds.Type != want {
+		return fmt.Errorf("%w: %s is a %s", ErrDatasetExists, ds.Name, ds.Type)
+	}
+
+	if origin := p.origin(req); origin != ds.Origin && (origin != "" || ds.Origin != "") {
+		return fmt.Errorf("%w: %s is not a clone of %s", ErrDatasetExists, ds.Name, origin)
+	}
+
+	current := ds.Volsize
+	if req.Filesystem {
+		var err error
+		if current, err = p.refquota(ds.Name); err != nil {
+			return err
+		}
+		// a file system without a refquota is only bounded by its parent
+		if current == 0 {
+			return nil
+		}
+	}
+	if current < size {
+		return fmt.Errorf("%w: %s has a size of %d, smaller than %d", ErrDatasetExists, ds.Name, current, size)
+	}
+	return nil
+}
+
+// origin returns the snapshot the volume of a request is cloned from, empty if it is not a clone.
+func (p *Provisioner) origin(req VolumeRequest) string {
+	switch {
+	case req.SourceSnapshot != "":
+		return p.fullName(req.SourceSnapshot)
+	case req.SourceVolume != "":
+		return p.fullName(req.SourceVolume) + "@" + cloneSnapshotPrefix + req.Name
+	}
+	return ""
+}
+
+// clone creates the volume of a request with a source.
+func (p *Provisioner) clone(ctx context.Context, name string, req VolumeRequest, size uint64) (*Dataset, error) {
+	origin := p.origin(req)
+	if req.SourceVolume != "" {
+		if err := p.zfs(ctx, "snapshot", origin); err != nil && !errors.Is(err, ErrDatasetExists) {
+			return nil, err
+		}
+	}
+	src, err := p.dataset(origin)
+	if err != nil {
+		return nil, err
+	}
+
+	props := copyProps(req.Properties)
+	if req.Filesystem {
+		if size > 0 {
+			props["refquota"] = strconv.FormatUint(size, 10)
+			if !p.Sparse {
+				props["refreservation"] = strconv.FormatUint(size, 10)
+			}
+		}
+	} else {
+		if size == 0 {
+			size = src.Volsize
+		}
+		if size < src.Volsize {
+			return nil, fmt.Errorf("cannot create %s with a size of %d from %s with a size of %d", name, size, origin, src.Volsize)
+		}
+		if !p.Sparse {
+			// clones do not inherit the reservation of their origin
+			props["refreservation"] = "auto"
+		}
+	}
+	if !p.Sparse {
+		if err := p.checkCapacity(size - src.Volsize); err != nil {
+			return nil, err
+		}
+	}
+
+	args := append([]string{"clone"}, propsSlice(props)...)
+	if err := p.zfs(ctx, append(args, origin, name)...); err != nil {
+		return nil, err
+	}
+	if !req.Filesystem && size > src.Volsize {
+		if err := p.zfs(ctx, "set", "volsize="+strconv.FormatUint(size, 10), name); err != nil {
+			return nil, err
+		}
+	}
+	return p.dataset(name)
+}
+
+// ExpandVolume grows a volume to at least size bytes, and returns it. Volumes which are large enough already are left alone.
+func (p *Provisioner) ExpandVolume(ctx context.Context, volume string, size uint64) (*Dataset, error) {
+	name := p.fullName(volume)
+	ds, err := p.dataset(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var props []string
+	switch ds.Type {
+	case DatasetVolume:
+		bs, err := p.property(name, "volblocksize")
+		if err != nil {
+			return nil, err
+		}
+		blockSize, err := volBlockSize(bs)
+		if err != nil {
+			return nil, err
+		}
+		size = roundUp(size, blockSize)
+		if ds.Volsize >= size {
+			return ds, nil
+		}
+		if err := p.checkCapacity(size - ds.Volsize); err != nil {
+			return nil, err
+		}
+		props = []string{"volsize=" + strconv.FormatUint(size, 10)}
+	case DatasetFilesystem:
+		current, err := p.refquota(name)
+		if err != nil {
+			return nil, err
+		}
+		if current == 0 || current >= size {
+			return ds, nil
+		}
+		if err := p.checkCapacity(size - current); err != nil {
+			return nil, err
+		}
+		props = []string{"refquota=" + strconv.FormatUint(size, 10)}
+		if !p.Sparse {
+			props = append(props, "refreservation="+strconv.FormatUint(size, 10))
+		}
+	default:
+		return nil, fmt.Errorf("cannot expand %s %s", ds.Type, name)
+	}
+
+	args := append([]string{"set"}, props...)
+	if err := p.zfs(ctx, append(args, name)...); err != nil {
+		return nil, err
+	}
+	return p.dataset(name)
+}
+
+// CreateSnapshot takes a snapshot of a volume, or returns the existing one of the same name.
+func (p *Provisioner) CreateSnapshot(ctx context.Context, volume, snapshot string) (*Dataset, error) {
+	name := p.fullName(volume) + "@" + snapshot
+	if err := ValidateSnapshotName(name); err != nil {
+		return nil, err
+	}
+	if err := p.zfs(ctx, "snapshot", name); err != nil && !errors.Is(err, ErrDatasetExists) {
+		return nil, err
+	}
+	return p.dataset(name)
+}
+
+// DeleteSnapshot destroys a snapshot given as "volume@snapshot". Snapshots with clones are destroyed once their last clone is.
+func (p *Provisioner) DeleteSnapshot(ctx context.Context, snapshot string) error {
+	return p.destroy(ctx, "-d", p.fullName(snapshot))
+}
+
+// DeleteVolume destroys a volume, retrying while it is busy until ctx is done.
+// Volumes with snapshots or clones are not destroyed, an error matching ErrDestroyBlocked is returned instead.
+func (p *Provisioner) DeleteVolume(ctx context.Context, volume string) error {
+	name := p.fullName(volume)
+	ds, err := p.dataset(name)
+	if errors.Is(err, ErrDatasetNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := p.destroy(ctx, name); err != nil {
+		return err
+	}
+	// snapshots taken to clone a source volume are only used by that clone
+	if dataset, snap := splitSnapshotName(ds.Origin); strings.HasPrefix(dataset, p.Parent+"/") && snap == cloneSnapshotPrefix+volume {
+		return p.destroy(ctx, "-d", ds.Origin)
+	}
+	return nil
+}
+
+// destroy runs zfs destroy, retrying while the dataset is busy. Datasets which do not exist are ignored.
+func (p *Provisioner) destroy(ctx context.Context, arg ...string) error {
+	interval := p.RetryInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	for {
+		err := p.zfs(ctx, append([]string{"destroy"}, arg...)...)
+		var e *Error
+		switch {
+		case err == nil, errors.Is(err, ErrDatasetNotFound):
+			return nil
+		case errors.As(err, &e) && (strings.Contains(e.Stderr, "has children") || strings.Contains(e.Stderr, "has dependent clones")):
+			return fmt.Errorf("%w: %v", ErrDestroyBlocked, err)
+		case !errors.Is(err, ErrDatasetBusy):
+			return err
+		}
+
+		t := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+// checkCapacity fails with ErrInsufficientCapacity unless the parent dataset has size bytes available.
+func (p *Provisioner) checkCapacity(size uint64) error {
+	if p.Sparse || size == 0 {
+		return nil
+	}
+	parent, err := p.dataset(p.Parent)
+	if err != nil {
+		return err
+	}
+	if parent.Avail < size {
+		return fmt.Errorf("%w: %s has %d bytes available, %d requested", ErrInsufficientCapacity, p.Parent, parent.Avail, size)
+	}
+	return nil
+}
+
+func (p *Provisioner) refquota(name string) (uint64, error) {
+	v, err := p.property(name, "refquota")
+	if err != nil {
+		return 0, err
+	}
+	var quota uint64
+	if v != "none" {
+		if err := setUint(&quota, v); err != nil {
+			return 0, err
+		}
+	}
+	return quota, nil
+}
+
+// volBlockSize parses a volblocksize given in bytes or with a K or M suffix, returning the default if empty.
+func volBlockSize(s string) (uint64, error) {
+	if s == "" || s == "-" {
+		return defaultVolBlockSize, nil
+	}
+	shift := uint(0)
+	switch strings.ToUpper(s[len(s)-1:]) {
+	case "K":
+		shift = 10
+	case "M":
+		shift = 20
+	}
+	if shift > 0 {
+		s = s[:len(s)-1]
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || v == 0 {
+		return 0, fmt.Errorf("invalid volblocksize %q", s)
+	}
+	return v << shift, nil
+}
+
+func roundUp(size, multiple uint64) uint64 {
+	if r := size % multiple; r != 0 {
+		size += multiple - r
+	}
+	return size
+}
+
+func copyProps(props map[string]string) map[string]string {
+	c := make(map[string]string, len(props)+2)
+	for k, v := range props {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,350 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeProvisioner returns a Provisioner below "tank/k8s" with 1 GiB available, whose zfs commands act on datasets held in memory.
+func fakeProvisioner() (*Provisioner, map[string]*Dataset, *[]string) {
+	datasets := map[string]*Dataset{
+		"tank/k8s": {Name: "tank/k8s", Type: DatasetFilesystem, Avail: 1 << 30},
+	}
+	refquotas := map[string]string{}
+	var calls []string
+
+	notFound := func(name string) error {
+		return &Error{Err: errors.New("exit status 1"), Stderr: "cannot open '" + name + "': dataset does not exist"}
+	}
+	p := &Provisioner{Parent: "tank/k8s", RetryInterval: time.Millisecond}
+	p.get = func(name string) (*Dataset, error) {
+		ds, ok := datasets[name]
+		if !ok {
+			return nil, notFound(name)
+		}
+		return ds, nil
+	}
+	p.getProperty = func(name, key string) (string, error) {
+		if _, ok := datasets[name]; !ok {
+			return "", notFound(name)
+		}
+		switch key {
+		case "refquota":
+			if q, ok := refquotas[name]; ok {
+				return q, nil
+			}
+			return "0", nil
+		case "volblocksize":
+			return "16384", nil
+		}
+		return "-", nil
+	}
+	p.run = func(ctx context.Context, arg ...string) error {
+		name := arg[len(arg)-1]
+		// properties are recorded in order, as propsSlice follows the order of a map
+		props := map[string]string{}
+		var opts []string
+		for i, a := range arg {
+			if a == "-o" {
+				kv := strings.SplitN(arg[i+1], "=", 2)
+				props[kv[0]] = kv[1]
+				opts = append(opts, arg[i+1])
+			}
+		}
+		sort.Strings(opts)
+		call := append([]string{}, arg...)
+		for i, j := 0, 0; i < len(call); i++ {
+			if call[i] == "-o" {
+				call[i+1] = opts[j]
+				j++
+			}
+		}
+		calls = append(calls, strings.Join(call, " "))
+		switch arg[0] {
+		case "create", "clone", "snapshot":
+			if _, ok := datasets[name]; ok {
+				return &Error{Err: errors.New("exit status 1"), Stderr: "cannot create '" + name + "': dataset already exists"}
+			}
+			ds := &Dataset{Name: name, Type: DatasetFilesystem}
+			switch {
+			case arg[0] == "snapshot":
+				dataset, _ := splitSnapshotName(name)
+				ds.Type, ds.Volsize = DatasetSnapshot, datasets[dataset].Volsize
+			case arg[0] == "clone":
+				src := datasets[arg[len(arg)-2]]
+				ds.Origin, ds.Volsize = src.Name, src.Volsize
+				if src.Volsize > 0 {
+					ds.Type = DatasetVolume
+				}
+			default:
+				for i, a := range arg {
+					if a == "-V" {
+						ds.Type = DatasetVolume
+						ds.Volsize, _ = strconv.ParseUint(arg[i+1], 10, 64)
+					}
+				}
+			}
+			if q, ok := props["refquota"]; ok {
+				refquotas[name] = q
+			}
+			datasets[name] = ds
+		case "set":
+			for _, kv := range arg[1 : len(arg)-1] {
+				kv := strings.SplitN(kv, "=", 2)
+				switch kv[0] {
+				case "volsize":
+					datasets[name].Volsize, _ = strconv.ParseUint(kv[1], 10, 64)
+				case "refquota":
+					refquotas[name] = kv[1]
+				}
+			}
+		case "destroy":
+			if _, ok := datasets[name]; !ok && strings.Contains(name, "@") {
+				return &Error{Err: errors.New("exit status 1"), Stderr: "could not find any snapshots to destroy; check snapshot names."}
+			}
+			if _, ok := datasets[name]; !ok {
+				return notFound(name)
+			}
+			delete(datasets, name)
+		}
+		return nil
+	}
+	return p, datasets, &calls
+}
+
+func TestProvisionerCreateVolume(t *testing.T) {
+	ctx := context.Background()
+	p, datasets, calls := fakeProvisioner()
+
+	ds, err := p.CreateVolume(ctx, VolumeRequest{Name: "pvc-1", Size: 1000, Properties: map[string]string{"compression": "lz4"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Volsize != 16384 {
+		t.Fatalf("wanted: %v, got: %v", 16384, ds.Volsize)
+	}
+	// retrying the request succeeds, a larger size conflicts
+	if _, err := p.CreateVolume(ctx, VolumeRequest{Name: "pvc-1", Size: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.CreateVolume(ctx, VolumeRequest{Name: "pvc-1", Size: 1 << 20}); !errors.Is(err, ErrDatasetExists) {
+		t.Fatalf("wanted: %v, got: %v", ErrDatasetExists, err)
+	}
+	if _, err := p.CreateVolume(ctx, VolumeRequest{Name: "pvc-1", Filesystem: true}); !errors.Is(err, ErrDatasetExists) {
+		t.Fatalf("wanted: %v, got: %v", ErrDatasetExists, err)
+	}
+
+	if _, err := p.CreateVolume(ctx, VolumeRequest{Name: "pvc-2", Size: 1 << 20, Filesystem: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.CreateVolume(ctx, VolumeRequest{Name: "pvc-2", Size: 2 << 20, Filesystem: true}); !errors.Is(err, ErrDatasetExists) {
+		t.Fatalf("wanted: %v, got: %v", ErrDatasetExists, err)
+	}
+	if _, err := p.CreateVolume(ctx, VolumeRequest{Name: "pvc-3", Size: 2 << 30}); !errors.Is(err, ErrInsufficientCapacity) {
+		t.Fatalf("wanted: %v, got: %v", ErrInsufficientCapacity, err)
+	}
+	if _, err := p.CreateVolume(ctx, VolumeRequest{Name: "pvc-3", SourceSnapshot: "pvc-1@missing"}); !errors.Is(err, ErrDatasetNotFound) {
+		t.Fatalf("wanted: %v, got: %v", ErrDatasetNotFound, err)
+	}
+	if _, err := p.CreateVolume(ctx, VolumeRequest{Name: "a@b", Size: 1}); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("wanted: %v, got: %v", ErrInvalidName, err)
+	}
+
+	want := []string{
+		"create -V 16384 -o compression=lz4 tank/k8s/pvc-1",
+		"create -o refquota=1048576 -o refreservation=1048576 tank/k8s/pvc-2",
+	}
+	if !reflect.DeepEqual(*calls, want) {
+		t.Fatalf("wanted: %v, got: %v", want, *calls)
+	}
+	if len(datasets) != 3 {
+		t.Fatalf("wanted: 3 datasets, got: %v", datasets)
+	}
+
+	p.Sparse = true
+	*calls = nil
+	if _, err := p.CreateVolume(ctx, VolumeRequest{Name: "thin", Size: 2 << 30, Properties: map[string]string{"volblocksize": "64K"}}); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"create -s -V 2147483648 -o volblocksize=64K tank/k8s/thin"}
+	if !reflect.DeepEqual(*calls, want) {
+		t.Fatalf("wanted: %v, got: %v", want, *calls)
+	}
+}
+
+func TestProvisionerClone(t *testing.T) {
+	ctx := context.Background()
+	p, _, calls := fakeProvisioner()
+
+	if _, err := p.CreateVolume(ctx, VolumeRequest{Name: "src", Size: 1 << 20}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.CreateSnapshot(ctx, "src", "snap"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.CreateSnapshot(ctx, "src", "snap"); err != nil {
+		t.Fatal(err)
+	}
+	ds, err := p.CreateVolume(ctx, VolumeRequest{Name: "restored", Size: 2 << 20, SourceSnapshot: "src@snap"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Origin != "tank/k8s/src@snap" || ds.Volsize != 2<<20 {
+		t.Fatalf("wanted: clone of tank/k8s/src@snap, got: %+v", ds)
+	}
+	if _, err := p.CreateVolume(ctx, VolumeRequest{Name: "restored", Size: 2 << 20, SourceVolume: "src"}); !errors.Is(err, ErrDatasetExists) {
+		t.Fatalf("wanted: %v, got: %v", ErrDatasetExists, err)
+	}
+	if _, err := p.CreateVolume(ctx, VolumeRequest{Name: "small", Size: 1 << 10, SourceSnapshot: "src@snap"}); err == nil {
+		t.Fatal("wanted: error for a clone smaller than its source, got: nil")
+	}
+	if _, err := p.CreateVolume(ctx, VolumeRequest{Name: "copy", SourceVolume: "src"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.DeleteVolume(ctx, "copy"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"create -V 1048576 tank/k8s/src",
+		"snapshot tank/k8s/src@snap",
+		"snapshot tank/k8s/src@snap",
+		"clone -o refreservation=auto tank/k8s/src@snap tank/k8s/restored",
+		"set volsize=2097152 tank/k8s/restored",
+		"snapshot tank/k8s/src@clone-copy",
+		"clone -o refreservation=auto tank/k8s/src@clone-copy tank/k8s/copy",
+		"destroy tank/k8s/copy",
+		"destroy -d tank/k8s/src@clone-copy",
+	}
+	if !reflect.DeepEqual(*calls, want) {
+		t.Fatalf("wanted: %v, got: %v", want, *calls)
+	}
+}
+
+func TestProvisionerExpandVolume(t *testing.T) {
+	ctx := context.Background()
+	p, _, calls := fakeProvisioner()
+
+	if _, err := p.CreateVolume(ctx, VolumeRequest{Name: "vol", Size: 1 << 20}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.CreateVolume(ctx, VolumeRequest{Name: "fs", Size: 1 << 20, Filesystem: true}); err != nil {
+		t.Fatal(err)
+	}
+	*calls = nil
+
+	ds, err := p.ExpandVolume(ctx, "vol", 3<<20+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Volsize != 3<<20+16384 {
+		t.Fatalf("wanted: %v, got: %v", 3<<20+16384, ds.Volsize)
+	}
+	if _, err := p.ExpandVolume(ctx, "vol", 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.ExpandVolume(ctx, "fs", 2<<20); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.ExpandVolume(ctx, "vol", 2<<30); !errors.Is(err, ErrInsufficientCapacity) {
+		t.Fatalf("wanted: %v, got: %v", ErrInsufficientCapacity, err)
+	}
+	if _, err := p.ExpandVolume(ctx, "missing", 1); !errors.Is(err, ErrDatasetNotFound) {
+		t.Fatalf("wanted: %v, got: %v", ErrDatasetNotFound, err)
+	}
+
+	want := []string{
+		"set volsize=3162112 tank/k8s/vol",
+		"set refquota=2097152 refreservation=2097152 tank/k8s/fs",
+	}
+	if !reflect.DeepEqual(*calls, want) {
+		t.Fatalf("wanted: %v, got: %v", want, *calls)
+	}
+}
+
+func TestProvisionerDelete(t *testing.T) {
+	ctx := context.Background()
+	p, _, calls := fakeProvisioner()
+	run := p.run
+
+	busy := 2
+	p.run = func(ctx context.Context, arg ...string) error {
+		if arg[0] == "destroy" && arg[len(arg)-1] == "tank/k8s/busy" && busy > 0 {
+			busy--
+			*calls = append(*calls, strings.Join(arg, " "))
+			return &Error{Err: errors.New("exit status 1"), Stderr: "cannot destroy 'tank/k8s/busy': dataset is busy"}
+		}
+		if arg[0] == "destroy" && arg[len(arg)-1] == "tank/k8s/parent" {
+			return &Error{Err: errors.New("exit status 1"), Stderr: "cannot destroy 'tank/k8s/parent': volume has children"}
+		}
+		return run(ctx, arg...)
+	}
+	for _, name := range []string{"busy", "parent"} {
+		if _, err := p.CreateVolume(ctx, VolumeRequest{Name: name, Size: 1 << 20}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	*calls = nil
+
+	if err := p.DeleteVolume(ctx, "busy"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.DeleteVolume(ctx, "busy"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.DeleteVolume(ctx, "parent"); !errors.Is(err, ErrDestroyBlocked) {
+		t.Fatalf("wanted: %v, got: %v", ErrDestroyBlocked, err)
+	}
+	if err := p.DeleteSnapshot(ctx, "parent@gone"); err != nil {
+		t.Fatal(err)
+	}
+
+	busy = 1
+	if _, err := p.CreateVolume(ctx, VolumeRequest{Name: "busy", Size: 1 << 20}); err != nil {
+		t.Fatal(err)
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := p.DeleteVolume(cancelled, "busy"); !errors.Is(err, ErrDatasetBusy) {
+		t.Fatalf("wanted: %v, got: %v", ErrDatasetBusy, err)
+	}
+
+	want := []string{
+		"destroy tank/k8s/busy",
+		"destroy tank/k8s/busy",
+		"destroy tank/k8s/busy",
+		"destroy -d tank/k8s/parent@gone",
+		"create -V 1048576 tank/k8s/busy",
+		"destroy tank/k8s/busy",
+	}
+	if !reflect.DeepEqual(*calls, want) {
+		t.Fatalf("wanted: %v, got: %v", want, *calls)
+	}
+}
+
+func TestVolBlockSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want uint64
+	}{
+		{"", defaultVolBlockSize},
+		{"8192", 8192},
+		{"64K", 64 << 10},
+		{"1M", 1 << 20},
+	}
+	for _, test := range tests {
+		got, err := volBlockSize(test.in)
+		if err != nil || got != test.want {
+			t.Fatalf("wanted: %v, got: %v %v", test.want, got, err)
+		}
+	}
+	if _, err := volBlockSize("huge"); err == nil {
+		t.Fatal("wanted: error, got: nil")
+	}
+}
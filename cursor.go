@@ -0,0 +1,144 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// cursorPrefix prefixes the bookmark names of replication cursors.
+const cursorPrefix = "repl-"
+
+// ReplicationCursor is a bookmark on a source file system marking the snapshot last replicated to a target,
+// e.g. "tank/data#repl-backup". Using it as the incremental base of the next replication means that
+// source snapshots can be pruned freely, as the base of the next incremental stream is kept by the bookmark.
+type ReplicationCursor struct {
+	// Dataset is the source file system or volume.
+	Dataset string
+	// Name is the name of the bookmark.
+	Name string
+
+	// zfs is replaced in tests.
+	zfs func(arg ...string) error
+}
+
+// NewReplicationCursor returns the cursor of dataset for the named target, which may be any string identifying it,
+// e.g. "backup" or "host:pool/fs". The bookmark is named "repl-" followed by the target, sanitized into a valid name.
+func NewReplicationCursor(dataset, target string) *ReplicationCursor {
+	// leave room for the suffix of the bookmark used while advancing
+	max := MaxDatasetNameLen - len(dataset) - len("#"+cursorPrefix+"-next")
+	return &ReplicationCursor{Dataset: dataset, Name: cursorPrefix + SanitizeNameComponent(target, max)}
+}
+
+func (c *ReplicationCursor) run(arg ...string) error {
+	if c.zfs != nil {
+		return c.zfs(arg...)
+	}
+	return zfs(arg...)
+}
+
+// Bookmark returns the full name of the cursor's bookmark.
+func (c *ReplicationCursor) Bookmark() string {
+	return c.Dataset + "#" + c.Name
+}
+
+// next is the bookmark holding the new position while the cursor is advanced.
+func (c *ReplicationCursor) next() string {
+	return c.Bookmark() + "-next"
+}
+
+// Base returns the bookmark to use as the incremental base of the next replication,
+// or an empty string if the cursor was never advanced, in which case a full send is required.
+// If advancing the cursor was interrupted, the new position is returned, as it was only advanced after a replication succeeded.
+func (c *ReplicationCursor) Base() (string, error) {
+	for _, name := range []string{c.next(), c.Bookmark()} {
+		ok, err := c.exists(name)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return name, nil
+		}
+	}
+	return "", nil
+}
+
+func (c *ReplicationCursor) exists(bookmark string) (bool, error) {
+	err := c.run("list", "-H", "-t", DatasetBookmark, "-o", "name", bookmark)
+	if errors.Is(err, ErrDatasetNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Advance moves the cursor to the given snapshot of its dataset, after it was replicated successfully.
+// The cursor is never left without a position: the new bookmark is created before the old one is destroyed.
+// If an earlier advance was interrupted after destroying the old bookmark, its -next bookmark is the position,
+// and it is kept until the cursor's bookmark was recreated.
+func (c *ReplicationCursor) Advance(snapshot string) error {
+	if dataset, snap := splitSnapshotName(snapshot); dataset != c.Dataset || snap == "" {
+		return fmt.Errorf("%s is not a snapshot of %s", snapshot, c.Dataset)
+	}
+	ok, err := c.exists(c.Bookmark())
+	if err != nil {
+		return err
+	}
+	steps := [][]string{
+		{"bookmark", snapshot, c.Bookmark()},
+		{"destroy", c.next()},
+	}
+	if ok {
+		steps = append([][]string{
+			{"destroy", c.next()},
+			{"bookmark", snapshot, c.next()},
+			{"destroy", c.Bookmark()},
+		}, steps...)
+	}
+	for _, step := range steps {
+		if err := c.run(step...); err != nil && !(step[0] == "destroy" && errors.Is(err, ErrDatasetNotFound)) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Replicate replicates the snapshot to target as Replicate does, incrementally from the cursor if it has a position,
+// and advances the cursor once the snapshot was received. opts.Send.IncrementalBase must not be set.
+func (c *ReplicationCursor) Replicate(ctx context.Context, snapshot *Dataset, target string, opts ReplicateOptions) (*Dataset, error) {
+	if err := c.prepare(snapshot, &opts); err != nil {
+		return nil, err
+	}
+	ds, err := Replicate(ctx, snapshot, target, opts)
+	if err != nil {
+		return nil, err
+	}
+	return ds, c.Advance(snapshot.Name)
+}
+
+// ReplicateRemote replicates the snapshot to target on the host reached through runner as ReplicateRemote does,
+// incrementally from the cursor if it has a position, and advances the cursor once the snapshot was received.
+// opts.Send.IncrementalBase must not be set.
+func (c *ReplicationCursor) ReplicateRemote(ctx context.Context, snapshot *Dataset, runner Runner, target string, opts ReplicateOptions) error {
+	if err := c.prepare(snapshot, &opts); err != nil {
+		return err
+	}
+	if err := ReplicateRemote(ctx, snapshot, runner, target, opts); err != nil {
+		return err
+	}
+	return c.Advance(snapshot.Name)
+}
+
+func (c *ReplicationCursor) prepare(snapshot *Dataset, opts *ReplicateOptions) error {
+	if opts.Send.IncrementalBase != "" {
+		return errors.New("the incremental base is taken from the cursor")
+	}
+	if dataset, _ := splitSnapshotName(snapshot.Name); dataset != c.Dataset {
+		return fmt.Errorf("%s is not a snapshot of %s", snapshot.Name, c.Dataset)
+	}
+	base, err := c.Base()
+	if err != nil {
+		return err
+	}
+	opts.Send.IncrementalBase = base
+	return nil
+}
@@ -0,0 +1,122 @@
+package zfs
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeCursor returns a cursor whose zfs commands act on the given bookmarks and record the calls.
+func fakeCursor(bookmarks map[string]bool, calls *[]string) *ReplicationCursor {
+	c := NewReplicationCursor("tank/data", "backup@host:pool/data")
+	c.zfs = func(arg ...string) error {
+		*calls = append(*calls, strings.Join(arg, " "))
+		name := arg[len(arg)-1]
+		switch arg[0] {
+		case "list", "destroy":
+			if !bookmarks[name] && arg[0] == "list" {
+				return &Error{Err: errors.New("exit status 1"), Stderr: "cannot open '" + name + "': dataset does not exist"}
+			}
+			if !bookmarks[name] {
+				return &Error{Err: errors.New("exit status 1"), Stderr: "bookmark '" + name + "' does not exist."}
+			}
+			if arg[0] == "destroy" {
+				delete(bookmarks, name)
+			}
+		case "bookmark":
+			bookmarks[name] = true
+		}
+		return nil
+	}
+	return c
+}
+
+func TestReplicationCursor(t *testing.T) {
+	bookmarks := map[string]bool{}
+	var calls []string
+	c := fakeCursor(bookmarks, &calls)
+
+	if got := c.Bookmark(); got != "tank/data#repl-backup_host:pool_data" {
+		t.Fatalf("wanted: %v, got: %v", "tank/data#repl-backup_host:pool_data", got)
+	}
+	if base, err := c.Base(); err != nil || base != "" {
+		t.Fatalf("wanted: no base, got: %v %v", base, err)
+	}
+
+	if err := c.Advance("tank/data@s1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Advance("tank/data@s2"); err != nil {
+		t.Fatal(err)
+	}
+	if base, err := c.Base(); err != nil || base != c.Bookmark() {
+		t.Fatalf("wanted: %v, got: %v %v", c.Bookmark(), base, err)
+	}
+	if !reflect.DeepEqual(bookmarks, map[string]bool{c.Bookmark(): true}) {
+		t.Fatalf("wanted: only %v, got: %v", c.Bookmark(), bookmarks)
+	}
+
+	// an interrupted advance leaves the new position in the -next bookmark
+	bookmarks[c.Bookmark()+"-next"] = true
+	if base, err := c.Base(); err != nil || base != c.Bookmark()+"-next" {
+		t.Fatalf("wanted: %v, got: %v %v", c.Bookmark()+"-next", base, err)
+	}
+
+	if err := c.Advance("tank/other@s3"); err == nil {
+		t.Fatal("wanted: error for a snapshot of another dataset, got: nil")
+	}
+	if err := c.prepare(&Dataset{Name: "tank/data@s3"}, &ReplicateOptions{Send: SendOptions{IncrementalBase: "@s2"}}); err == nil {
+		t.Fatal("wanted: error for an explicit incremental base, got: nil")
+	}
+	opts := ReplicateOptions{}
+	if err := c.prepare(&Dataset{Name: "tank/data@s3"}, &opts); err != nil || opts.Send.IncrementalBase != c.Bookmark()+"-next" {
+		t.Fatalf("wanted: %v, got: %v %v", c.Bookmark()+"-next", opts.Send.IncrementalBase, err)
+	}
+
+	want := []string{
+		// the first advance creates the bookmark directly
+		"list -H -t bookmark -o name tank/data#repl-backup_host:pool_data",
+		"bookmark tank/data@s1 tank/data#repl-backup_host:pool_data",
+		"destroy tank/data#repl-backup_host:pool_data-next",
+		// later ones go through -next
+		"list -H -t bookmark -o name tank/data#repl-backup_host:pool_data",
+		"destroy tank/data#repl-backup_host:pool_data-next",
+		"bookmark tank/data@s2 tank/data#repl-backup_host:pool_data-next",
+		"destroy tank/data#repl-backup_host:pool_data",
+		"bookmark tank/data@s2 tank/data#repl-backup_host:pool_data",
+		"destroy tank/data#repl-backup_host:pool_data-next",
+	}
+	if !reflect.DeepEqual(calls[2:11], want) {
+		t.Fatalf("wanted: %v, got: %v", want, calls[2:11])
+	}
+}
+
+func TestReplicationCursorInterrupted(t *testing.T) {
+	// an advance was interrupted after destroying the cursor's bookmark
+	bookmarks := map[string]bool{"tank/data#repl-backup_host:pool_data-next": true}
+	var calls []string
+	c := fakeCursor(bookmarks, &calls)
+	fake := c.zfs
+	c.zfs = func(arg ...string) error {
+		if arg[0] == "bookmark" {
+			return errors.New("exit status 1")
+		}
+		return fake(arg...)
+	}
+
+	if err := c.Advance("tank/data@s3"); err == nil {
+		t.Fatal("wanted: error, got: nil")
+	}
+	if base, err := c.Base(); err != nil || base != c.next() {
+		t.Fatalf("wanted: %v, got: %v %v", c.next(), base, err)
+	}
+
+	c.zfs = fake
+	if err := c.Advance("tank/data@s3"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(bookmarks, map[string]bool{c.Bookmark(): true}) {
+		t.Fatalf("wanted: only %v, got: %v", c.Bookmark(), bookmarks)
+	}
+}
@@ -0,0 +1,322 @@
+package zfs
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrDestroyBlocked is returned by DestroySafe when the dataset cannot be destroyed without force.
+var ErrDestroyBlocked = errors.New("dataset destroy is blocked")
+
+// DestroyBlockers lists what prevents a dataset from being destroyed on its own.
+type DestroyBlockers struct {
+	// Children are the descendent datasets and snapshots, which require a recursive destroy.
+	Children []string `json:"children"`
+	// Clones are the clones of the dataset's snapshots, which require destroying dependents as well.
+	Clones []string `json:"clones"`
+	// Holds maps held snapshots to their hold tags, which must be released first.
+	Holds map[string][]string `json:"holds"`
+}
+
+// Blocked reports whether anything blocks the destroy.
+func (b *DestroyBlockers) Blocked() bool {
+	return len(b.Children) > 0 || len(b.Clones) > 0 || len(b.Holds) > 0
+}
+
+// DestroyBlockers inspects the receiving dataset for children, dependent clones and held snapshots.
+func (d *Dataset) DestroyBlockers() (*DestroyBlockers, error) {
+	b := &DestroyBlockers{Holds: map[string][]string{}}
+
+	out, err := zfsOutput("list", "-H", "-r", "-t", "all", "-o", "name", d.Name)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range out {
+		if line[0] != d.Name {
+			b.Children = append(b.Children, line[0])
+		}
+	}
+
+	out, err = zfsOutput("get", "-Hp", "-r", "-t", DatasetSnapshot, "-o", "name,property,value", "clones,userrefs", d.Name)
+	if err != nil {
+		return nil, err
+	}
+	var held []string
+	for _, line := range out {
+		if len(line) != 3 {
+			return nil, errors.New("output does not match what is expected on this platform")
+		}
+		switch line[1] {
+		case "clones":
+			if line[2] != "" && line[2] != "-" {
+				b.Clones = append(b.Clones, splitList(line[2])...)
+			}
+		case "userrefs":
+			if n, err := strconv.ParseUint(line[2], 10, 64); err == nil && n > 0 {
+				held = append(held, line[0])
+			}
+		}
+	}
+
+	if len(held) > 0 {
+		holds, err := listHolds(held...)
+		if err != nil {
+			return nil, err
+		}
+		b.Holds = holds
+	}
+	return b, nil
+}
+
+// DestroySafe destroys the receiving dataset only if nothing blocks it, returning ErrDestroyBlocked along with the blockers otherwise.
+// With force set, the blockers are cleared instead: holds are released, and the dataset is destroyed
+// together with its children and dependent clones.
+func (d *Dataset) DestroySafe(force bool) (*DestroyBlockers, error) {
+	b, err := d.DestroyBlockers()
+	if err != nil {
+		return nil, err
+	}
+	if !b.Blocked() {
+		return b, d.Destroy(DestroyDefault)
+	}
+	if !force {
+		return b, ErrDestroyBlocked
+	}
+
+	for snapshot, tags := range b.Holds {
+		for _, tag := range tags {
+			if err := zfs("release", tag, snapshot); err != nil {
+				return b, err
+			}
+		}
+	}
+	return b, d.Destroy(DestroyRecursive | DestroyRecursiveClones)
+}
+
+// DestroyPreview describes what a destroy would do, as reported by `zfs destroy -nvp`.
+type DestroyPreview struct {
+	// Datasets are the datasets and snapshots that would be destroyed.
+	Datasets []string `json:"datasets"`
+	// Reclaim is the space in bytes that would be freed, as estimated for snapshots.
+	Reclaim uint64 `json:"reclaim"`
+}
+
+// DestroyPreview reports which datasets Destroy would destroy with the given flags, and how much space would be reclaimed,
+// without destroying anything.
+func (d *Dataset) DestroyPreview(flags DestroyFlag) (*DestroyPreview, error) {
+	args := append(destroyArgs(flags), "-nvp", d.Name)
+	out, err := zfsOutput(args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseDestroyPreview(out)
+}
+
+// example input for parseDestroyPreview
+// destroy test/fs@a
+// destroy test/fs@b
+// reclaim 1048576
+
+func parseDestroyPreview(lines [][]string) (*DestroyPreview, error) {
+	p := &DestroyPreview{}
+	for _, line := range lines {
+		if len(line) != 2 {
+			return nil, errors.New("output does not match what is expected on this platform")
+		}
+		switch line[0] {
+		case "destroy":
+			p.Datasets = append(p.Datasets, line[1])
+		case "reclaim":
+			if err := setUint(&p.Reclaim, line[1]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return p, nil
+}
+
+// listHolds returns the hold tags of the given snapshots, keyed by snapshot name.
+func listHolds(snapshots ...string) (map[string][]string, error) {
+	out, err := zfsOutput(append([]string{"holds", "-H"}, snapshots...)...)
+	if err != nil {
+		return nil, err
+	}
+	return parseHolds(out)
+}
+
+// example input for parseHolds
+// test/fs@snap    keep    Thu Apr 14 10:00 2022
+// test/fs@snap    backup  Thu Apr 14 10:01 2022
+
+func parseHolds(lines [][]string) (map[string][]string, error) {
+	holds := map[string][]string{}
+	for _, line := range lines {
+		if len(line) < 2 {
+			return nil, errors.New("output does not match what is expected on this platform")
+		}
+		holds[line[0]] = append(holds[line[0]], line[1])
+	}
+	return holds, nil
+}
+
+// splitList splits a comma separated property value, such as clones.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// maxDestroyArgLen bounds the length of a single batched `zfs destroy` argument,
+// well below the per-argument limit of Linux.
+const maxDestroyArgLen = 64 << 10
+
+// DestroySnapshots destroys the given snapshots with as few `zfs destroy` invocations as possible.
+// Snapshots of the same dataset are destroyed together, using ranges (pool/fs@a%c) for runs of
+// consecutive snapshots and comma lists (pool/fs@a,c) otherwise. With DestroyRecursive, only comma lists are used,
+// as a range would also destroy the snapshots within it that only descendants have.
+func DestroySnapshots(snapshots []*Dataset, flags DestroyFlag) error {
+	var all []*Dataset
+	seen := map[string]bool{}
+	for _, s := range snapshots {
+		ds, _ := splitSnapshotName(s.Name)
+		if seen[ds] {
+			continue
+		}
+		seen[ds] = true
+		sorted, err := listSortedByCreation(ds, DatasetSnapshot)
+		if err != nil {
+			return err
+		}
+		all = append(all, sorted...)
+	}
+	_, err := destroySnapshotBatches(all, snapshots, flags)
+	return err
+}
+
+// destroySnapshotBatches destroys the snapshots in destroy, where all holds every snapshot of their datasets
+// in any order, and returns the snapshots destroyed before an error occurred.
+func destroySnapshotBatches(all, destroy []*Dataset, flags DestroyFlag) ([]*Dataset, error) {
+	var done []*Dataset
+	// under -r, a range applies in every descendant and would catch their own snapshots within it as well
+	ranges := flags&DestroyRecursive == 0
+	for _, b := range snapshotBatches(all, destroy, maxDestroyArgLen, ranges) {
+		if err := zfs(append(destroyArgs(flags), b.arg)...); err != nil {
+			return done, err
+		}
+		done = append(done, b.snapshots...)
+	}
+	return done, nil
+}
+
+// snapshotBatch is a single `zfs destroy` argument and the snapshots it names.
+type snapshotBatch struct {
+	arg       string
+	snapshots []*Dataset
+}
+
+// snapshotBatches groups the snapshots in destroy by dataset into arguments of at most maxLen bytes where possible.
+// Runs of two or more snapshots that are adjacent in all, which holds every snapshot of the datasets,
+// become ranges if ranges is set. Adjacency follows createtxg, the order zfs applies ranges in, rather than the order
+// of all, as creation times have a resolution of seconds and follow the wall clock. Without the createtxg of every
+// snapshot, no ranges are used. Snapshots missing from all are listed individually, as a range could span snapshots
+// not to be destroyed.
+func snapshotBatches(all, destroy []*Dataset, maxLen int, ranges bool) []snapshotBatch {
+	all = append([]*Dataset(nil), all...)
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Createtxg < all[j].Createtxg })
+	for _, s := range all {
+		if s.Createtxg == 0 {
+			ranges = false
+		}
+	}
+
+	selected := make(map[string]bool, len(destroy))
+	var datasets []string
+	bySet := map[string][]*Dataset{}
+	for _, s := range destroy {
+		if selected[s.Name] {
+			continue
+		}
+		selected[s.Name] = true
+		ds, _ := splitSnapshotName(s.Name)
+		if _, ok := bySet[ds]; !ok {
+			datasets = append(datasets, ds)
+		}
+		bySet[ds] = append(bySet[ds], s)
+	}
+
+	var batches []snapshotBatch
+	for _, ds := range datasets {
+		// items are the comma separated parts of the argument, each a snapshot or a range
+		type item struct {
+			text      string
+			snapshots []*Dataset
+		}
+		var items []item
+		var run []*Dataset
+		flush := func() {
+			switch {
+			case len(run) == 0:
+				return
+			case len(run) == 1 || !ranges:
+				for _, s := range run {
+					_, snap := splitSnapshotName(s.Name)
+					items = append(items, item{snap, []*Dataset{s}})
+				}
+			default:
+				_, first := splitSnapshotName(run[0].Name)
+				_, last := splitSnapshotName(run[len(run)-1].Name)
+				items = append(items, item{first + "%" + last, run})
+			}
+			run = nil
+		}
+		listed := map[string]bool{}
+		for _, s := range all {
+			if d, _ := splitSnapshotName(s.Name); d != ds {
+				continue
+			}
+			listed[s.Name] = true
+			if selected[s.Name] {
+				run = append(run, s)
+			} else {
+				flush()
+			}
+		}
+		flush()
+		for _, s := range bySet[ds] {
+			if !listed[s.Name] {
+				run = append(run, s)
+				flush()
+			}
+		}
+
+		var b snapshotBatch
+		for _, it := range items {
+			if b.arg != "" && len(b.arg)+1+len(it.text) > maxLen {
+				batches = append(batches, b)
+				b = snapshotBatch{}
+			}
+			if b.arg == "" {
+				b.arg = ds + "@" + it.text
+			} else {
+				b.arg += "," + it.text
+			}
+			b.snapshots = append(b.snapshots, it.snapshots...)
+		}
+		batches = append(batches, b)
+	}
+	return batches
+}
+
+// splitSnapshotName splits a snapshot name into its dataset and snapshot parts.
+func splitSnapshotName(name string) (dataset, snapshot string) {
+	if i := strings.Index(name, "@"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return name, ""
+}
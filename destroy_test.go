@@ -0,0 +1,114 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHolds(t *testing.T) {
+	got, err := parseHolds([][]string{
+		{"test/fs@snap", "keep", "Thu Apr 14 10:00 2022"},
+		{"test/fs@snap", "backup", "Thu Apr 14 10:01 2022"},
+		{"test/fs@other", "keep", "Thu Apr 14 10:02 2022"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string][]string{
+		"test/fs@snap":  {"keep", "backup"},
+		"test/fs@other": {"keep"},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %v, got: %v", want, got)
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	want := []string{"test/clone1", "test/clone2"}
+	if got := splitList("test/clone1,test/clone2"); !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %v, got: %v", want, got)
+	}
+	if got := splitList(""); got != nil {
+		t.Fatalf("wanted nil, got: %v", got)
+	}
+}
+
+func TestParseDestroyPreview(t *testing.T) {
+	got, err := parseDestroyPreview([][]string{
+		{"destroy", "test/fs@a"},
+		{"destroy", "test/fs@b"},
+		{"reclaim", "1048576"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &DestroyPreview{Datasets: []string{"test/fs@a", "test/fs@b"}, Reclaim: 1048576}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %+v, got: %+v", want, got)
+	}
+}
+
+func TestSnapshotBatches(t *testing.T) {
+	snaps := func(names ...string) []*Dataset {
+		ds := make([]*Dataset, len(names))
+		for i, name := range names {
+			ds[i] = &Dataset{Name: name, Createtxg: uint64(i + 1)}
+		}
+		return ds
+	}
+	all := snaps("test/fs@a", "test/fs@b", "test/fs@c", "test/fs@d", "test/fs@e", "test/fs@f", "test/other@a", "test/other@b",
+		"test/parent@a", "test/parent@c")
+
+	tests := []struct {
+		name      string
+		destroy   []*Dataset
+		maxLen    int
+		recursive bool
+		want      []string
+	}{
+		{"none", nil, 100, false, nil},
+		{"single", snaps("test/fs@b"), 100, false, []string{"test/fs@b"}},
+		{"range", snaps("test/fs@a", "test/fs@b", "test/fs@c"), 100, false, []string{"test/fs@a%c"}},
+		{"mixed", snaps("test/fs@a", "test/fs@b", "test/fs@d", "test/fs@f"), 100, false, []string{"test/fs@a%b,d,f"}},
+		{"datasets", snaps("test/other@b", "test/fs@e", "test/other@a"), 100, false, []string{"test/other@a%b", "test/fs@e"}},
+		{"unlisted", snaps("test/fs@x", "test/fs@c", "test/fs@d"), 100, false, []string{"test/fs@c%d,x"}},
+		{"split", snaps("test/fs@a", "test/fs@c", "test/fs@e"), 12, false, []string{"test/fs@a,c", "test/fs@e"}},
+		{"recursive", snaps("test/fs@a", "test/fs@b", "test/fs@c"), 100, true, []string{"test/fs@a,b,c"}},
+		// test/parent@a%c would destroy a test/parent/child@b, which only the child has, under -r
+		{"recursive child-only snapshot", snaps("test/parent@a", "test/parent@c"), 100, true, []string{"test/parent@a,c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			count := 0
+			for _, b := range snapshotBatches(all, tt.destroy, tt.maxLen, !tt.recursive) {
+				got = append(got, b.arg)
+				count += len(b.snapshots)
+			}
+			if !reflect.DeepEqual(tt.want, got) {
+				t.Fatalf("wanted: %v, got: %v", tt.want, got)
+			}
+			if count != len(tt.destroy) {
+				t.Fatalf("wanted: %d snapshots, got: %d", len(tt.destroy), count)
+			}
+		})
+	}
+
+	// @c was created in between @a and @b by createtxg, but has the older creation time after a clock step
+	byCreation := []*Dataset{
+		{Name: "test/fs@a", Createtxg: 10},
+		{Name: "test/fs@b", Createtxg: 30},
+		{Name: "test/fs@c", Createtxg: 20},
+	}
+	destroy := []*Dataset{byCreation[0], byCreation[1]}
+	if got := snapshotBatches(byCreation, destroy, 100, true); len(got) != 1 || got[0].arg != "test/fs@a,b" {
+		t.Fatalf("wanted: %v, got: %v", "test/fs@a,b", got)
+	}
+	// without createtxg, the order cannot be trusted
+	unordered := []*Dataset{{Name: "test/fs@a"}, {Name: "test/fs@b"}}
+	if got := snapshotBatches(unordered, unordered, 100, true); len(got) != 1 || got[0].arg != "test/fs@a,b" {
+		t.Fatalf("wanted: %v, got: %v", "test/fs@a,b", got)
+	}
+}
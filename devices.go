@@ -0,0 +1,121 @@
+package zfs
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrNoStableDevicePath is returned by StableDevicePath when a device has no persistent link.
+var ErrNoStableDevicePath = errors.New("no stable device path found")
+
+// vdevKeywords are the words of a vdev specification that are not devices.
+var vdevKeywords = map[string]bool{
+	"mirror":  true,
+	"spare":   true,
+	"log":     true,
+	"cache":   true,
+	"special": true,
+	"dedup":   true,
+}
+
+// valueFlags are the flags of `zpool create` and `zpool add` taking a separate value, e.g. -m none.
+var valueFlags = map[string]bool{
+	"-o": true,
+	"-O": true,
+	"-m": true,
+	"-R": true,
+	"-t": true,
+}
+
+// isVdevDevice reports whether arg of a vdev specification is a device, rather than a keyword or flag.
+func isVdevDevice(arg string) bool {
+	if vdevKeywords[arg] || strings.HasPrefix(arg, "raidz") || strings.HasPrefix(arg, "draid") {
+		return false
+	}
+	return !strings.HasPrefix(arg, "-") && !strings.Contains(arg, "=")
+}
+
+// StableDevicePath returns a persistent link to device, e.g. /dev/disk/by-id/ata-... for /dev/sda or sda,
+// so that a pool keeps finding its devices when the kernel renumbers them.
+// Links in the platform's most stable naming scheme are preferred.
+func StableDevicePath(device string) (string, error) {
+	return stableDevicePath(currentPlatform, device)
+}
+
+func stableDevicePath(p *platform, device string) (string, error) {
+	target, err := resolveDevice(p, device)
+	if err != nil {
+		return "", err
+	}
+	for _, dir := range p.deviceDirs {
+		if dir == p.deviceRoot {
+			continue
+		}
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			// naming schemes without devices do not exist
+			continue
+		}
+		names := make([]string, 0, len(entries))
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			link := filepath.Join(dir, name)
+			if resolved, err := filepath.EvalSymlinks(link); err == nil && resolved == target {
+				return link, nil
+			}
+		}
+	}
+	return "", ErrNoStableDevicePath
+}
+
+// KernelDevicePath resolves a device name or persistent link to the kernel's device node, e.g. /dev/sda.
+func KernelDevicePath(path string) (string, error) {
+	return resolveDevice(currentPlatform, path)
+}
+
+func resolveDevice(p *platform, device string) (string, error) {
+	if !filepath.IsAbs(device) {
+		device = filepath.Join(p.deviceRoot, device)
+	}
+	return filepath.EvalSymlinks(device)
+}
+
+// StableVdevArgs translates the devices in a vdev specification, as passed to CreateZpool, to stable device paths.
+// Keywords such as "mirror" or "log" and flags along with their values are kept,
+// as are files and devices without a persistent link.
+func StableVdevArgs(args ...string) ([]string, error) {
+	return stableVdevArgs(currentPlatform, args)
+}
+
+func stableVdevArgs(p *platform, args []string) ([]string, error) {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		out[i] = arg
+		if !isVdevDevice(arg) || i > 0 && valueFlags[args[i-1]] {
+			continue
+		}
+		target, err := resolveDevice(p, arg)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(target, p.deviceRoot+"/") {
+			// files and devices outside of the device directory are used as is
+			continue
+		}
+		path, err := stableDevicePath(p, arg)
+		if errors.Is(err, ErrNoStableDevicePath) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out[i] = path
+	}
+	return out, nil
+}
@@ -0,0 +1,103 @@
+package zfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func setupDeviceDirs(t *testing.T) (*platform, func()) {
+	t.Helper()
+	tmp, err := ioutil.TempDir("", "dev-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmp, err = filepath.EvalSymlinks(tmp); err != nil {
+		t.Fatal(err)
+	}
+	dev := filepath.Join(tmp, "dev")
+	byID := filepath.Join(dev, "disk", "by-id")
+	if err := os.MkdirAll(byID, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"sda", "sdb", "vda"} {
+		if err := ioutil.WriteFile(filepath.Join(dev, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		"wwn-0x5000c500a1b2c3d4":  "../../sda",
+		"ata-ST4000NM0035_ZC1234": "../../sda",
+		"ata-ST4000NM0035_ZC5678": "../../sdb",
+	} {
+		if err := os.Symlink(target, filepath.Join(byID, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := &platform{
+		name:       "test",
+		deviceDirs: []string{filepath.Join(dev, "disk", "by-vdev"), byID, dev},
+		deviceRoot: dev,
+	}
+	return p, func() { os.RemoveAll(tmp) }
+}
+
+func TestStableDevicePath(t *testing.T) {
+	p, cleanUp := setupDeviceDirs(t)
+	defer cleanUp()
+
+	byID := p.deviceDirs[1]
+	for _, dev := range []string{"sda", filepath.Join(p.deviceRoot, "sda"), filepath.Join(byID, "wwn-0x5000c500a1b2c3d4")} {
+		got, err := stableDevicePath(p, dev)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := filepath.Join(byID, "ata-ST4000NM0035_ZC1234"); got != want {
+			t.Fatalf("wanted: %v, got: %v", want, got)
+		}
+	}
+
+	if _, err := stableDevicePath(p, "vda"); err != ErrNoStableDevicePath {
+		t.Fatalf("wanted: %v, got: %v", ErrNoStableDevicePath, err)
+	}
+	if _, err := stableDevicePath(p, "sdz"); err == nil {
+		t.Fatal("expected an error for a missing device")
+	}
+
+	got, err := resolveDevice(p, filepath.Join(byID, "ata-ST4000NM0035_ZC5678"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := filepath.Join(p.deviceRoot, "sdb"); got != want {
+		t.Fatalf("wanted: %v, got: %v", want, got)
+	}
+}
+
+func TestStableVdevArgs(t *testing.T) {
+	p, cleanUp := setupDeviceDirs(t)
+	defer cleanUp()
+
+	file, err := ioutil.TempFile("", "vdev-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	got, err := stableVdevArgs(p, []string{"-f", "-m", "none", "-o", "ashift=12", "-R", "sda", "mirror", "sda", "sdb", "log", "vda", "cache", file.Name()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	byID := p.deviceDirs[1]
+	want := []string{
+		"-f", "-m", "none", "-o", "ashift=12", "-R", "sda", "mirror",
+		filepath.Join(byID, "ata-ST4000NM0035_ZC1234"),
+		filepath.Join(byID, "ata-ST4000NM0035_ZC5678"),
+		"log", "vda", "cache", file.Name(),
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %v, got: %v", want, got)
+	}
+}
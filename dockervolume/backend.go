@@ -0,0 +1,110 @@
+package dockervolume
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	zfs "github.com/mistifyio/go-zfs/v3"
+)
+
+// backend is the subset of the zfs package and the system tools used by the driver, replaced in tests.
+type backend interface {
+	Get(name string) (*zfs.Dataset, error)
+	Children(name string) ([]*zfs.Dataset, error)
+	CreateFilesystem(name string, props map[string]string) error
+	CreateVolume(name string, size uint64, props map[string]string) error
+	Destroy(name string) error
+	Mount(name string) error
+	Unmount(name string) error
+	WaitForDevice(name string) (string, error)
+	Format(device, fsType string) error
+	MountDevice(device, mountpoint, fsType string) error
+	UnmountDevice(mountpoint string) error
+}
+
+// deviceTimeout is how long to wait for the device node of a new zvol to appear.
+const deviceTimeout = 10 * time.Second
+
+type localBackend struct{}
+
+func (localBackend) Get(name string) (*zfs.Dataset, error) { return zfs.GetDataset(name) }
+
+// Children returns the file systems and volumes directly below name.
+func (localBackend) Children(name string) ([]*zfs.Dataset, error) {
+	all, err := (&zfs.Dataset{Name: name}).Children(1)
+	if err != nil {
+		return nil, err
+	}
+	var children []*zfs.Dataset
+	for _, ds := range all {
+		if ds.Type == zfs.DatasetFilesystem || ds.Type == zfs.DatasetVolume {
+			children = append(children, ds)
+		}
+	}
+	return children, nil
+}
+
+func (localBackend) CreateFilesystem(name string, props map[string]string) error {
+	_, err := zfs.CreateFilesystem(name, props)
+	return err
+}
+
+func (localBackend) CreateVolume(name string, size uint64, props map[string]string) error {
+	_, err := zfs.CreateVolume(name, size, props)
+	return err
+}
+
+func (localBackend) Destroy(name string) error {
+	return (&zfs.Dataset{Name: name}).Destroy(zfs.DestroyDefault)
+}
+
+func (localBackend) Mount(name string) error {
+	ds, err := zfs.GetDataset(name)
+	if err != nil {
+		return err
+	}
+	if mounted, err := ds.GetProperty("mounted"); err == nil && mounted == "yes" {
+		return nil
+	}
+	_, err = ds.Mount(false, nil)
+	return err
+}
+
+func (localBackend) Unmount(name string) error {
+	_, err := (&zfs.Dataset{Name: name, Type: zfs.DatasetFilesystem}).Unmount(false)
+	return err
+}
+
+// WaitForDevice waits for udev to create the device node of the named zvol, and returns its path.
+func (localBackend) WaitForDevice(name string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), deviceTimeout)
+	defer cancel()
+	return zfs.WaitForZvolDevice(ctx, name)
+}
+
+func (localBackend) Format(device, fsType string) error {
+	return run("mkfs."+fsType, device)
+}
+
+func (localBackend) MountDevice(device, mountpoint, fsType string) error {
+	if err := os.MkdirAll(mountpoint, 0o755); err != nil {
+		return err
+	}
+	return run("mount", "-t", fsType, device, mountpoint)
+}
+
+func (localBackend) UnmountDevice(mountpoint string) error {
+	return run("umount", mountpoint)
+}
+
+func run(name string, arg ...string) error {
+	out, err := exec.Command(name, arg...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %v: %s", name, strings.Join(arg, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,344 @@
+// Package dockervolume implements the Docker volume plugin protocol on top of ZFS,
+// backing each Docker volume with a file system or a formatted zvol below a parent dataset.
+//
+// Volume options given to `docker volume create -o` select how a volume is created:
+//
+//	type=filesystem|volume  a file system (the default) or a zvol
+//	size=10G                the size of a zvol, or the refquota of a file system
+//	template=NAME           a named set of properties from Driver.Templates
+//	any other key           a ZFS property, e.g. compression=zstd
+//
+// The protocol is described at https://docs.docker.com/engine/extend/plugins_volume/.
+package dockervolume
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	zfs "github.com/mistifyio/go-zfs/v3"
+)
+
+// Volume option keys with a special meaning.
+const (
+	OptType     = "type"
+	OptSize     = "size"
+	OptTemplate = "template"
+)
+
+// ErrVolumeNotFound is returned for volumes that do not exist below the driver's parent dataset.
+var ErrVolumeNotFound = errors.New("volume not found")
+
+// Volume describes a Docker volume.
+type Volume struct {
+	Name       string                 `json:"Name"`
+	Mountpoint string                 `json:"Mountpoint,omitempty"`
+	Status     map[string]interface{} `json:"Status,omitempty"`
+}
+
+// Driver manages Docker volumes as datasets below Parent. A Driver is safe for concurrent use.
+type Driver struct {
+	// Parent is the dataset volumes are created below, e.g. "tank/docker".
+	Parent string
+	// Defaults are the properties all volumes are created with, overridden by templates and volume options.
+	Defaults map[string]string
+	// Templates are named sets of properties, selected with the template volume option.
+	Templates map[string]map[string]string
+	// ZvolMountRoot is the directory zvol-backed volumes are mounted below, they cannot be created if empty.
+	ZvolMountRoot string
+	// ZvolFSType is the file system zvols are formatted with, "ext4" if empty.
+	ZvolFSType string
+
+	mu sync.Mutex
+	// mounts holds the IDs of the active mounts of each volume, which is unmounted once the last one is gone.
+	mounts map[string]map[string]bool
+
+	// backend is replaced in tests.
+	backend backend
+}
+
+func (d *Driver) dataset(name string) (string, error) {
+	ds := d.Parent + "/" + name
+	if strings.Contains(name, "/") {
+		return "", fmt.Errorf("%w: volume names must not contain '/'", zfs.ErrInvalidName)
+	}
+	return ds, zfs.ValidateDatasetName(ds)
+}
+
+func (d *Driver) be() backend {
+	if d.backend == nil {
+		return localBackend{}
+	}
+	return d.backend
+}
+
+func (d *Driver) fsType() string {
+	if d.ZvolFSType == "" {
+		return "ext4"
+	}
+	return d.ZvolFSType
+}
+
+// properties merges the defaults, the selected template and the volume options into the properties to create a volume with.
+func (d *Driver) properties(opts map[string]string) (map[string]string, error) {
+	props := map[string]string{}
+	for k, v := range d.Defaults {
+		props[k] = v
+	}
+	if name, ok := opts[OptTemplate]; ok {
+		tmpl, ok := d.Templates[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown template %q", name)
+		}
+		for k, v := range tmpl {
+			props[k] = v
+		}
+	}
+	for k, v := range opts {
+		switch k {
+		case OptType, OptSize, OptTemplate:
+		default:
+			props[k] = v
+		}
+	}
+	return props, nil
+}
+
+// Create creates the named volume with the given options.
+func (d *Driver) Create(name string, opts map[string]string) error {
+	ds, err := d.dataset(name)
+	if err != nil {
+		return err
+	}
+	props, err := d.properties(opts)
+	if err != nil {
+		return err
+	}
+	var size uint64
+	if s, ok := opts[OptSize]; ok {
+		if size, err = ParseSize(s); err != nil {
+			return err
+		}
+	}
+
+	switch opts[OptType] {
+	case "", zfs.DatasetFilesystem:
+		if size > 0 {
+			props["refquota"] = strconv.FormatUint(size, 10)
+		}
+		// mounted on demand only
+		props["canmount"] = "noauto"
+		return d.be().CreateFilesystem(ds, props)
+	case zfs.DatasetVolume:
+		if d.ZvolMountRoot == "" {
+			return errors.New("zvol volumes are not enabled")
+		}
+		if size == 0 {
+			return errors.New("zvol volumes require a size")
+		}
+		if err := d.be().CreateVolume(ds, size, props); err != nil {
+			return err
+		}
+		device, err := d.be().WaitForDevice(ds)
+		if err == nil {
+			err = d.be().Format(device, d.fsType())
+		}
+		if err != nil {
+			_ = d.be().Destroy(ds)
+			return err
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown volume type %q", opts[OptType])
+	}
+}
+
+// Remove destroys the named volume, which must not be mounted.
+func (d *Driver) Remove(name string) error {
+	ds, err := d.dataset(name)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.mounts[name]) > 0 {
+		return fmt.Errorf("volume %s is in use", name)
+	}
+	if _, err := d.get(ds); err != nil {
+		return err
+	}
+	return d.be().Destroy(ds)
+}
+
+// Mount mounts the named volume for the mount request id, and returns its mount point.
+// The volume is mounted on the first request only.
+func (d *Driver) Mount(name, id string) (string, error) {
+	ds, err := d.dataset(name)
+	if err != nil {
+		return "", err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	v, err := d.get(ds)
+	if err != nil {
+		return "", err
+	}
+
+	var mountpoint string
+	if v.Type == zfs.DatasetVolume {
+		mountpoint = filepath.Join(d.ZvolMountRoot, name)
+		if len(d.mounts[name]) == 0 {
+			var device string
+			device, err = zfs.ZvolDevicePath(ds)
+			if err == nil {
+				err = d.be().MountDevice(device, mountpoint, d.fsType())
+			}
+		}
+	} else {
+		if len(d.mounts[name]) == 0 {
+			err = d.be().Mount(ds)
+		}
+		mountpoint = v.Mountpoint
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if d.mounts == nil {
+		d.mounts = map[string]map[string]bool{}
+	}
+	if d.mounts[name] == nil {
+		d.mounts[name] = map[string]bool{}
+	}
+	d.mounts[name][id] = true
+	return mountpoint, nil
+}
+
+// Unmount releases the mount request id of the named volume, which is unmounted once no request is left.
+func (d *Driver) Unmount(name, id string) error {
+	ds, err := d.dataset(name)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.mounts[name][id] {
+		return nil
+	}
+	if len(d.mounts[name]) == 1 {
+		v, err := d.get(ds)
+		if err != nil {
+			return err
+		}
+		if v.Type == zfs.DatasetVolume {
+			err = d.be().UnmountDevice(filepath.Join(d.ZvolMountRoot, name))
+		} else {
+			err = d.be().Unmount(ds)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	delete(d.mounts[name], id)
+	return nil
+}
+
+// Path returns the mount point of the named volume, which is empty unless it is mounted.
+func (d *Driver) Path(name string) (string, error) {
+	v, err := d.Get(name)
+	if err != nil {
+		return "", err
+	}
+	return v.Mountpoint, nil
+}
+
+// Get describes the named volume.
+func (d *Driver) Get(name string) (*Volume, error) {
+	ds, err := d.dataset(name)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	v, err := d.get(ds)
+	if err != nil {
+		return nil, err
+	}
+	return d.volume(v), nil
+}
+
+// List describes all volumes.
+func (d *Driver) List() ([]*Volume, error) {
+	datasets, err := d.be().Children(d.Parent)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	sort.Slice(datasets, func(i, j int) bool { return datasets[i].Name < datasets[j].Name })
+	volumes := make([]*Volume, 0, len(datasets))
+	for _, ds := range datasets {
+		volumes = append(volumes, d.volume(ds))
+	}
+	return volumes, nil
+}
+
+func (d *Driver) get(ds string) (*zfs.Dataset, error) {
+	v, err := d.be().Get(ds)
+	var zfsErr *zfs.Error
+	if errors.As(err, &zfsErr) && strings.Contains(zfsErr.Stderr, "does not exist") {
+		return nil, ErrVolumeNotFound
+	}
+	return v, err
+}
+
+// volume describes a dataset, whose mount point is only reported while Docker has it mounted.
+func (d *Driver) volume(ds *zfs.Dataset) *Volume {
+	name := strings.TrimPrefix(ds.Name, d.Parent+"/")
+	v := &Volume{
+		Name: name,
+		Status: map[string]interface{}{
+			"dataset":   ds.Name,
+			"type":      ds.Type,
+			"used":      ds.Used,
+			"available": ds.Avail,
+		},
+	}
+	if len(d.mounts[name]) > 0 {
+		if ds.Type == zfs.DatasetVolume {
+			v.Mountpoint = filepath.Join(d.ZvolMountRoot, name)
+		} else {
+			v.Mountpoint = ds.Mountpoint
+		}
+	}
+	return v
+}
+
+// ParseSize parses a size such as "10G", "1.5T" or "512MiB" into bytes, using binary units as ZFS does.
+// Fractional sizes are rounded down to whole bytes.
+func ParseSize(s string) (uint64, error) {
+	t := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B"), "I")
+	shift := uint(0)
+	if n := len(t); n > 0 {
+		if i := strings.IndexByte("KMGTPE", t[n-1]); i >= 0 {
+			shift = uint(i+1) * 10
+			t = t[:n-1]
+		}
+	}
+	// big.Rat parses decimals exactly, but also accepts signs, fractions and exponents
+	v, ok := new(big.Rat).SetString(t)
+	if !ok || strings.ContainsAny(t, "+-/E") {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	v.Mul(v, new(big.Rat).SetInt(new(big.Int).Lsh(big.NewInt(1), shift)))
+	size := new(big.Int).Quo(v.Num(), v.Denom())
+	if !size.IsUint64() {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return size.Uint64(), nil
+}
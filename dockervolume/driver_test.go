@@ -0,0 +1,264 @@
+package dockervolume
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	zfs "github.com/mistifyio/go-zfs/v3"
+)
+
+// fakeBackend keeps datasets in memory and records all calls changing them.
+type fakeBackend struct {
+	datasets map[string]*zfs.Dataset
+	calls    []string
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{datasets: map[string]*zfs.Dataset{}}
+}
+
+func (b *fakeBackend) record(format string, args ...interface{}) {
+	b.calls = append(b.calls, fmt.Sprintf(format, args...))
+}
+
+func (b *fakeBackend) Get(name string) (*zfs.Dataset, error) {
+	ds, ok := b.datasets[name]
+	if !ok {
+		return nil, &zfs.Error{Err: errors.New("exit status 1"), Stderr: "cannot open '" + name + "': dataset does not exist"}
+	}
+	return ds, nil
+}
+
+func (b *fakeBackend) Children(name string) ([]*zfs.Dataset, error) {
+	var children []*zfs.Dataset
+	for _, ds := range b.datasets {
+		children = append(children, ds)
+	}
+	return children, nil
+}
+
+func sortedProps(props map[string]string) string {
+	var kv []string
+	for k, v := range props {
+		kv = append(kv, k+"="+v)
+	}
+	sort.Strings(kv)
+	return strings.Join(kv, ",")
+}
+
+func (b *fakeBackend) CreateFilesystem(name string, props map[string]string) error {
+	b.record("create %s %s", name, sortedProps(props))
+	b.datasets[name] = &zfs.Dataset{Name: name, Type: zfs.DatasetFilesystem, Mountpoint: "/" + name}
+	return nil
+}
+
+func (b *fakeBackend) CreateVolume(name string, size uint64, props map[string]string) error {
+	b.record("create -V %d %s %s", size, name, sortedProps(props))
+	b.datasets[name] = &zfs.Dataset{Name: name, Type: zfs.DatasetVolume, Mountpoint: "-"}
+	return nil
+}
+
+func (b *fakeBackend) Destroy(name string) error {
+	b.record("destroy %s", name)
+	delete(b.datasets, name)
+	return nil
+}
+
+func (b *fakeBackend) Mount(name string) error   { b.record("mount %s", name); return nil }
+func (b *fakeBackend) Unmount(name string) error { b.record("umount %s", name); return nil }
+func (b *fakeBackend) WaitForDevice(name string) (string, error) {
+	return zfs.ZvolDevicePath(name)
+}
+
+func (b *fakeBackend) Format(device, fsType string) error {
+	b.record("mkfs.%s %s", fsType, device)
+	return nil
+}
+
+func (b *fakeBackend) MountDevice(device, mountpoint, fsType string) error {
+	b.record("mount -t %s %s %s", fsType, device, mountpoint)
+	return nil
+}
+
+func (b *fakeBackend) UnmountDevice(mountpoint string) error {
+	b.record("umount %s", mountpoint)
+	return nil
+}
+
+func newTestDriver() (*Driver, *fakeBackend) {
+	b := newFakeBackend()
+	return &Driver{
+		Parent:        "tank/docker",
+		Defaults:      map[string]string{"compression": "lz4"},
+		Templates:     map[string]map[string]string{"db": {"recordsize": "16K", "compression": "off"}},
+		ZvolMountRoot: "/var/lib/zvols",
+		backend:       b,
+	}, b
+}
+
+func TestCreate(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  map[string]string
+		calls []string
+		err   string
+	}{
+		{name: "web", calls: []string{"create tank/docker/web canmount=noauto,compression=lz4"}},
+		{
+			name:  "db",
+			opts:  map[string]string{"template": "db", "size": "10G", "atime": "off"},
+			calls: []string{"create tank/docker/db atime=off,canmount=noauto,compression=off,recordsize=16K,refquota=10737418240"},
+		},
+		{
+			name:  "vm",
+			opts:  map[string]string{"type": "volume", "size": "1M", "compression": "zstd"},
+			calls: []string{"create -V 1048576 tank/docker/vm compression=zstd", "mkfs.ext4 /dev/zvol/tank/docker/vm"},
+		},
+		{name: "vm", opts: map[string]string{"type": "volume"}, err: "zvol volumes require a size"},
+		{name: "x", opts: map[string]string{"template": "nope"}, err: `unknown template "nope"`},
+		{name: "x", opts: map[string]string{"type": "snapshot"}, err: `unknown volume type "snapshot"`},
+		{name: "x", opts: map[string]string{"size": "lots"}, err: `invalid size "lots"`},
+		{name: "a/b", err: "invalid name"},
+		{name: "a@b", err: "invalid name"},
+	}
+	for _, test := range tests {
+		d, b := newTestDriver()
+		err := d.Create(test.name, test.opts)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("wanted: %v, got: %v", test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(b.calls, test.calls) {
+			t.Fatalf("wanted: %v, got: %v", test.calls, b.calls)
+		}
+	}
+}
+
+func TestMountRefcount(t *testing.T) {
+	d, b := newTestDriver()
+	if err := d.Create("web", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Create("vm", map[string]string{"type": "volume", "size": "1G"}); err != nil {
+		t.Fatal(err)
+	}
+	b.calls = nil
+
+	for _, id := range []string{"a", "b"} {
+		mp, err := d.Mount("web", id)
+		if err != nil || mp != "/tank/docker/web" {
+			t.Fatalf("wanted: /tank/docker/web, got: %v %v", mp, err)
+		}
+	}
+	if mp, err := d.Mount("vm", "a"); err != nil || mp != "/var/lib/zvols/vm" {
+		t.Fatalf("wanted: /var/lib/zvols/vm, got: %v %v", mp, err)
+	}
+	if err := d.Remove("web"); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Fatalf("wanted: in use, got: %v", err)
+	}
+	if p, _ := d.Path("web"); p != "/tank/docker/web" {
+		t.Fatalf("wanted: /tank/docker/web, got: %v", p)
+	}
+	for _, u := range [][2]string{{"web", "a"}, {"web", "a"}, {"web", "b"}, {"vm", "a"}} {
+		if err := d.Unmount(u[0], u[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p, _ := d.Path("web"); p != "" {
+		t.Fatalf("wanted: unmounted, got: %v", p)
+	}
+	if err := d.Remove("web"); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := []string{
+		"mount tank/docker/web",
+		"mount -t ext4 /dev/zvol/tank/docker/vm /var/lib/zvols/vm",
+		"umount tank/docker/web",
+		"umount /var/lib/zvols/vm",
+		"destroy tank/docker/web",
+	}
+	if !reflect.DeepEqual(b.calls, calls) {
+		t.Fatalf("wanted: %v, got: %v", calls, b.calls)
+	}
+	if _, err := d.Get("web"); !errors.Is(err, ErrVolumeNotFound) {
+		t.Fatalf("wanted: %v, got: %v", ErrVolumeNotFound, err)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want uint64
+	}{
+		{"512", 512},
+		{"1k", 1 << 10},
+		{"10G", 10 << 30},
+		{"1.5M", 3 << 19},
+		{"2TiB", 2 << 40},
+		{"1PB", 1 << 50},
+		{"0.1K", 102},
+		{"9007199254740993", 1<<53 + 1},
+	}
+	for _, test := range tests {
+		got, err := ParseSize(test.in)
+		if err != nil || got != test.want {
+			t.Fatalf("wanted: %v, got: %v %v", test.want, got, err)
+		}
+	}
+	for _, in := range []string{"", "G", "-1G", "1X", "16E", "1e3", "+1", "1/2"} {
+		if _, err := ParseSize(in); err == nil {
+			t.Fatalf("wanted: error for %q, got: nil", in)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	d, _ := newTestDriver()
+	srv := httptest.NewServer(d.Handler())
+	defer srv.Close()
+
+	tests := []struct {
+		path, body string
+		code       int
+		want       string
+	}{
+		{"/Plugin.Activate", "", http.StatusOK, `{"Err":"","Implements":["VolumeDriver"]}`},
+		{"/VolumeDriver.Capabilities", "{}", http.StatusOK, `{"Err":"","Capabilities":{"Scope":"local"}}`},
+		{"/VolumeDriver.Create", `{"Name":"web","Opts":{"atime":"off"}}`, http.StatusOK, `{"Err":""}`},
+		{"/VolumeDriver.Create", `{"Name":"web","Opts":{"template":"x"}}`, http.StatusInternalServerError, `{"Err":"unknown template \"x\""}`},
+		{"/VolumeDriver.Mount", `{"Name":"web","ID":"c1"}`, http.StatusOK, `{"Err":"","Mountpoint":"/tank/docker/web"}`},
+		{"/VolumeDriver.Path", `{"Name":"web"}`, http.StatusOK, `{"Err":"","Mountpoint":"/tank/docker/web"}`},
+		{"/VolumeDriver.Get", `{"Name":"missing"}`, http.StatusInternalServerError, `{"Err":"volume not found"}`},
+		{"/VolumeDriver.Unmount", `{"Name":"web","ID":"c1"}`, http.StatusOK, `{"Err":""}`},
+		{"/VolumeDriver.Remove", `{"Name":"web"}`, http.StatusOK, `{"Err":""}`},
+		{"/VolumeDriver.List", "{}", http.StatusOK, `{"Err":""}`},
+		{"/VolumeDriver.Bogus", "{}", http.StatusNotFound, "404 page not found"},
+	}
+	for _, test := range tests {
+		resp, err := http.Post(srv.URL+test.path, ContentType, strings.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(string(body)); resp.StatusCode != test.code || got != test.want {
+			t.Fatalf("%s: wanted: %d %s, got: %d %s", test.path, test.code, test.want, resp.StatusCode, got)
+		}
+	}
+}
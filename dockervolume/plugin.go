@@ -0,0 +1,87 @@
+package dockervolume
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ContentType is the media type of plugin protocol requests and responses.
+const ContentType = "application/vnd.docker.plugins.v1.2+json"
+
+type request struct {
+	Name string            `json:"Name"`
+	ID   string            `json:"ID"`
+	Opts map[string]string `json:"Opts"`
+}
+
+type response struct {
+	Err          string        `json:"Err"`
+	Mountpoint   string        `json:"Mountpoint,omitempty"`
+	Volume       *Volume       `json:"Volume,omitempty"`
+	Volumes      []*Volume     `json:"Volumes,omitempty"`
+	Capabilities *capabilities `json:"Capabilities,omitempty"`
+	Implements   []string      `json:"Implements,omitempty"`
+}
+
+type capabilities struct {
+	Scope string `json:"Scope"`
+}
+
+// Handler returns the handler serving the plugin protocol for the driver,
+// usually on a unix socket in /run/docker/plugins.
+func (d *Driver) Handler() http.Handler {
+	return http.HandlerFunc(d.serveHTTP)
+}
+
+func (d *Driver) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	var req request
+	// some calls have no body at all
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && r.ContentLength > 0 {
+		d.reply(w, &response{}, err)
+		return
+	}
+
+	var (
+		resp response
+		err  error
+	)
+	switch strings.TrimPrefix(r.URL.Path, "/") {
+	case "Plugin.Activate":
+		resp.Implements = []string{"VolumeDriver"}
+	case "VolumeDriver.Create":
+		err = d.Create(req.Name, req.Opts)
+	case "VolumeDriver.Remove":
+		err = d.Remove(req.Name)
+	case "VolumeDriver.Mount":
+		resp.Mountpoint, err = d.Mount(req.Name, req.ID)
+	case "VolumeDriver.Unmount":
+		err = d.Unmount(req.Name, req.ID)
+	case "VolumeDriver.Path":
+		resp.Mountpoint, err = d.Path(req.Name)
+	case "VolumeDriver.Get":
+		resp.Volume, err = d.Get(req.Name)
+	case "VolumeDriver.List":
+		resp.Volumes, err = d.List()
+	case "VolumeDriver.Capabilities":
+		resp.Capabilities = &capabilities{Scope: "local"}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	d.reply(w, &resp, err)
+}
+
+func (d *Driver) reply(w http.ResponseWriter, resp *response, err error) {
+	w.Header().Set("Content-Type", ContentType)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		resp = &response{Err: err.Error()}
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
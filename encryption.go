@@ -0,0 +1,374 @@
+package zfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// KeyFormat is the format of the key of an encrypted dataset.
+type KeyFormat string
+
+// Key formats.
+const (
+	KeyFormatPassphrase KeyFormat = "passphrase"
+	KeyFormatHex        KeyFormat = "hex"
+	KeyFormatRaw        KeyFormat = "raw"
+)
+
+// KeyLocationPrompt is the key location that reads the key from standard input.
+const KeyLocationPrompt = "prompt"
+
+// minPBKDF2Iters is the smallest number of PBKDF2 iterations accepted by ZFS.
+const minPBKDF2Iters = 100000
+
+// encryptionAlgorithms are the valid values of the encryption property for new datasets.
+var encryptionAlgorithms = map[string]bool{
+	"on":          true,
+	"aes-128-ccm": true,
+	"aes-192-ccm": true,
+	"aes-256-ccm": true,
+	"aes-128-gcm": true,
+	"aes-192-gcm": true,
+	"aes-256-gcm": true,
+}
+
+// EncryptionOptions are the encryption properties of a new encrypted dataset.
+//
+// More information regarding encryption can be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html#encryption
+type EncryptionOptions struct {
+	// Encryption is the encryption algorithm, e.g. "aes-256-gcm". "on" selects the default algorithm and is used if empty.
+	Encryption string
+	// KeyFormat is the format of the key, it is required.
+	KeyFormat KeyFormat
+	// KeyLocation is where the key is loaded from: "prompt" (the default), "file:///absolute/path" or an https:// URL.
+	KeyLocation string
+	// PBKDF2Iters is the number of PBKDF2 iterations used to derive a key from a passphrase, the ZFS default is used if 0.
+	PBKDF2Iters uint64
+}
+
+// Properties validates the options and returns them as ZFS properties.
+func (o EncryptionOptions) Properties() (map[string]string, error) {
+	encryption := o.Encryption
+	if encryption == "" {
+		encryption = "on"
+	}
+	if !encryptionAlgorithms[encryption] {
+		return nil, fmt.Errorf("invalid encryption algorithm %q", o.Encryption)
+	}
+
+	switch o.KeyFormat {
+	case KeyFormatPassphrase, KeyFormatHex, KeyFormatRaw:
+	case "":
+		return nil, errors.New("a key format is required for encryption")
+	default:
+		return nil, fmt.Errorf("invalid key format %q", o.KeyFormat)
+	}
+
+	location := o.KeyLocation
+	if location == "" {
+		location = KeyLocationPrompt
+	}
+	if err := validateKeyLocation(location); err != nil {
+		return nil, err
+	}
+
+	props := map[string]string{
+		"encryption":  encryption,
+		"keyformat":   string(o.KeyFormat),
+		"keylocation": location,
+	}
+	if o.PBKDF2Iters != 0 {
+		if o.KeyFormat != KeyFormatPassphrase {
+			return nil, errors.New("PBKDF2 iterations only apply to passphrase keys")
+		}
+		if o.PBKDF2Iters < minPBKDF2Iters {
+			return nil, fmt.Errorf("at least %d PBKDF2 iterations are required", minPBKDF2Iters)
+		}
+		props["pbkdf2iters"] = strconv.FormatUint(o.PBKDF2Iters, 10)
+	}
+	return props, nil
+}
+
+func validateKeyLocation(location string) error {
+	switch {
+	case location == KeyLocationPrompt:
+	case strings.HasPrefix(location, "file:///"):
+	case strings.HasPrefix(location, "https://"), strings.HasPrefix(location, "http://"):
+	default:
+		return fmt.Errorf("invalid key location %q: must be prompt, a file:// URI with an absolute path or an http(s):// URL", location)
+	}
+	return nil
+}
+
+// CreateEncryptedFilesystem creates a new encrypted ZFS filesystem with the specified name, properties and encryption options.
+// If the key location is "prompt", the key is read from key, which is otherwise unused and may be nil.
+//
+// A full list of available ZFS properties may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html.
+func CreateEncryptedFilesystem(name string, properties map[string]string, enc EncryptionOptions, key io.Reader) (*Dataset, error) {
+	encProps, err := enc.Properties()
+	if err != nil {
+		return nil, err
+	}
+	if encProps["keylocation"] == KeyLocationPrompt && key == nil {
+		return nil, errors.New("a key is required when the key location is prompt")
+	}
+
+	all := make(map[string]string, len(properties)+len(encProps))
+	for k, v := range properties {
+		all[k] = v
+	}
+	for k, v := range encProps {
+		all[k] = v
+	}
+
+	args := append([]string{"create"}, sortedPropsSlice(all)...)
+	args = append(args, name)
+	c := command{Command: "zfs"}
+	if encProps["keylocation"] == KeyLocationPrompt {
+		c.Stdin = key
+	}
+	if _, err := c.Run(args...); err != nil {
+		return nil, err
+	}
+	return GetDataset(name)
+}
+
+// LoadKeyOptions controls how `zfs load-key` loads encryption keys.
+type LoadKeyOptions struct {
+	// Recursive loads the keys of all encryption roots below the dataset as well (-r).
+	Recursive bool
+	// KeyLocation overrides the keylocation property of the dataset for this load (-L).
+	KeyLocation string
+	// Key, if set, supplies the key on standard input, so no key file is needed on disk.
+	// It implies a KeyLocation of "prompt".
+	Key io.Reader
+	// DryRun only verifies that the key is correct, without loading it (-n).
+	DryRun bool
+}
+
+func (o LoadKeyOptions) args() ([]string, error) {
+	var args []string
+	if o.Recursive {
+		args = append(args, "-r")
+	}
+	if o.DryRun {
+		args = append(args, "-n")
+	}
+
+	location := o.KeyLocation
+	if o.Key != nil {
+		if location != "" && location != KeyLocationPrompt {
+			return nil, errors.New("a Key can only be supplied with the prompt key location")
+		}
+		location = KeyLocationPrompt
+	}
+	if location != "" {
+		if err := validateKeyLocation(location); err != nil {
+			return nil, err
+		}
+		args = append(args, "-L", location)
+	}
+	return args, nil
+}
+
+func loadKey(opts LoadKeyOptions, target ...string) error {
+	flags, err := opts.args()
+	if err != nil {
+		return err
+	}
+	args := append([]string{"load-key"}, flags...)
+	args = append(args, target...)
+
+	c := command{Command: "zfs", Stdin: opts.Key}
+	_, err = c.Run(args...)
+	return err
+}
+
+// LoadKey loads the encryption key of the receiving dataset, which must be an encryption root.
+func (d *Dataset) LoadKey(opts LoadKeyOptions) error {
+	return loadKey(opts, d.Name)
+}
+
+// LoadAllKeys loads the encryption keys of all encryption roots on the system (-a).
+// Supplying a Key is only useful if all of them share it.
+func LoadAllKeys(opts LoadKeyOptions) error {
+	if opts.Recursive {
+		return errors.New("option Recursive does not apply when loading all keys")
+	}
+	return loadKey(opts, "-a")
+}
+
+// UnloadKey unloads the encryption key of the receiving dataset, which must be unmounted.
+// Optionally, the keys of all encryption roots below the dataset are unloaded as well.
+func (d *Dataset) UnloadKey(recursive bool) error {
+	args := []string{"unload-key"}
+	if recursive {
+		args = append(args, "-r")
+	}
+	args = append(args, d.Name)
+	return zfs(args...)
+}
+
+// UnloadAllKeys unloads the encryption keys of all encryption roots on the system.
+func UnloadAllKeys() error {
+	return zfs("unload-key", "-a")
+}
+
+// ChangeKeyOptions controls how `zfs change-key` changes the key of an encryption root.
+type ChangeKeyOptions struct {
+	// LoadKey loads the current key first, if it is not loaded already (-l).
+	LoadKey bool
+	// Inherit makes the dataset inherit the key of its parent, so it is no longer an encryption root (-i).
+	// It cannot be combined with the new key options.
+	Inherit bool
+	// KeyFormat, KeyLocation and PBKDF2Iters set the new key's properties, unchanged ones are left empty.
+	KeyFormat   KeyFormat
+	KeyLocation string
+	PBKDF2Iters uint64
+	// Key, if set, supplies the new key on standard input when the (new) key location is "prompt".
+	Key io.Reader
+}
+
+func (o ChangeKeyOptions) args() ([]string, error) {
+	var args []string
+	if o.LoadKey {
+		args = append(args, "-l")
+	}
+	if o.Inherit {
+		if o.KeyFormat != "" || o.KeyLocation != "" || o.PBKDF2Iters != 0 || o.Key != nil {
+			return nil, errors.New("a new key cannot be set when inheriting the parent's key")
+		}
+		return append(args, "-i"), nil
+	}
+
+	props := map[string]string{}
+	switch o.KeyFormat {
+	case KeyFormatPassphrase, KeyFormatHex, KeyFormatRaw:
+		props["keyformat"] = string(o.KeyFormat)
+	case "":
+	default:
+		return nil, fmt.Errorf("invalid key format %q", o.KeyFormat)
+	}
+	if o.KeyLocation != "" {
+		if err := validateKeyLocation(o.KeyLocation); err != nil {
+			return nil, err
+		}
+		props["keylocation"] = o.KeyLocation
+	}
+	if o.PBKDF2Iters != 0 {
+		if o.PBKDF2Iters < minPBKDF2Iters {
+			return nil, fmt.Errorf("at least %d PBKDF2 iterations are required", minPBKDF2Iters)
+		}
+		props["pbkdf2iters"] = strconv.FormatUint(o.PBKDF2Iters, 10)
+	}
+	return append(args, sortedPropsSlice(props)...), nil
+}
+
+// ChangeKey changes the encryption key of the receiving dataset, e.g. to rotate it, or makes it inherit its parent's key.
+// The key must be loaded, see ChangeKeyOptions.LoadKey.
+func (d *Dataset) ChangeKey(opts ChangeKeyOptions) error {
+	flags, err := opts.args()
+	if err != nil {
+		return err
+	}
+	args := append([]string{"change-key"}, flags...)
+	args = append(args, d.Name)
+
+	c := command{Command: "zfs", Stdin: opts.Key}
+	_, err = c.Run(args...)
+	return err
+}
+
+// KeyStatus is the status of the key of an encrypted dataset.
+type KeyStatus string
+
+// Key statuses.
+const (
+	KeyStatusNone        KeyStatus = ""
+	KeyStatusAvailable   KeyStatus = "available"
+	KeyStatusUnavailable KeyStatus = "unavailable"
+)
+
+// EncryptionStatus holds the encryption properties of a dataset.
+// All fields are empty for unencrypted datasets.
+type EncryptionStatus struct {
+	Encryption     string    `json:"encryption"`
+	KeyStatus      KeyStatus `json:"key_status"`
+	KeyFormat      KeyFormat `json:"key_format"`
+	KeyLocation    string    `json:"key_location"`
+	EncryptionRoot string    `json:"encryption_root"`
+}
+
+// Encrypted reports whether the dataset is encrypted.
+func (s *EncryptionStatus) Encrypted() bool {
+	return s.Encryption != "" && s.Encryption != "off"
+}
+
+// Locked reports whether the dataset is encrypted and its key is not loaded.
+func (s *EncryptionStatus) Locked() bool {
+	return s.KeyStatus == KeyStatusUnavailable
+}
+
+// EncryptionStatus returns the encryption properties of the receiving dataset.
+func (d *Dataset) EncryptionStatus() (*EncryptionStatus, error) {
+	out, err := zfsOutput("get", "-Hp", "-o", "property,value", "encryption,keystatus,keyformat,keylocation,encryptionroot", d.Name)
+	if err != nil {
+		return nil, err
+	}
+	return parseEncryptionStatus(out)
+}
+
+func parseEncryptionStatus(lines [][]string) (*EncryptionStatus, error) {
+	s := &EncryptionStatus{}
+	for _, line := range lines {
+		if len(line) != 2 {
+			return nil, errors.New("output does not match what is expected on this platform")
+		}
+		var val string
+		setString(&val, line[1])
+		switch line[0] {
+		case "encryption":
+			s.Encryption = val
+		case "keystatus":
+			s.KeyStatus = KeyStatus(val)
+		case "keyformat":
+			if val != "none" {
+				s.KeyFormat = KeyFormat(val)
+			}
+		case "keylocation":
+			if val != "none" {
+				s.KeyLocation = val
+			}
+		case "encryptionroot":
+			s.EncryptionRoot = val
+		}
+	}
+	return s, nil
+}
+
+// ListLockedDatasets returns the encrypted filesystems and volumes whose keys are not loaded.
+func ListLockedDatasets() ([]*Dataset, error) {
+	out, err := zfsOutput("get", "-Hp", "-t", "filesystem,volume", "-o", "name,value", "keystatus")
+	if err != nil {
+		return nil, err
+	}
+
+	var locked []string
+	for _, line := range out {
+		if len(line) == 2 && KeyStatus(line[1]) == KeyStatusUnavailable {
+			locked = append(locked, line[0])
+		}
+	}
+	if len(locked) == 0 {
+		return nil, nil
+	}
+
+	return listDatasetsWithOptional(func(props []string) []string {
+		return append([]string{"list", "-Hp", "-o", strings.Join(props, ",")}, locked...)
+	})
+}
@@ -0,0 +1,163 @@
+package zfs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncryptionOptionsProperties(t *testing.T) {
+	got, err := EncryptionOptions{KeyFormat: KeyFormatPassphrase, PBKDF2Iters: 350000}.Properties()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"encryption":  "on",
+		"keyformat":   "passphrase",
+		"keylocation": "prompt",
+		"pbkdf2iters": "350000",
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %v, got: %v", want, got)
+	}
+
+	got, err = EncryptionOptions{Encryption: "aes-256-gcm", KeyFormat: KeyFormatRaw, KeyLocation: "file:///etc/zfs/key"}.Properties()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got["encryption"] != "aes-256-gcm" || got["keylocation"] != "file:///etc/zfs/key" {
+		t.Fatalf("unexpected properties: %v", got)
+	}
+
+	for name, opts := range map[string]EncryptionOptions{
+		"no key format":          {},
+		"invalid algorithm":      {Encryption: "rot13", KeyFormat: KeyFormatHex},
+		"invalid key format":     {KeyFormat: "base64"},
+		"relative key file":      {KeyFormat: KeyFormatRaw, KeyLocation: "file://key"},
+		"bare path":              {KeyFormat: KeyFormatRaw, KeyLocation: "/etc/zfs/key"},
+		"iterations for raw key": {KeyFormat: KeyFormatRaw, PBKDF2Iters: 350000},
+		"too few iterations":     {KeyFormat: KeyFormatPassphrase, PBKDF2Iters: 1000},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := opts.Properties(); err == nil {
+				t.Fatal("wanted error, got nil")
+			}
+		})
+	}
+}
+
+func TestLoadKeyOptionsArgs(t *testing.T) {
+	for name, test := range map[string]struct {
+		opts LoadKeyOptions
+		want []string
+	}{
+		"zero value": {
+			opts: LoadKeyOptions{},
+			want: nil,
+		},
+		"key from reader": {
+			opts: LoadKeyOptions{Recursive: true, Key: strings.NewReader("secret")},
+			want: []string{"-r", "-L", "prompt"},
+		},
+		"key file": {
+			opts: LoadKeyOptions{DryRun: true, KeyLocation: "file:///run/keys/tank"},
+			want: []string{"-n", "-L", "file:///run/keys/tank"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, err := test.opts.args()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(test.want, got) {
+				t.Fatalf("wanted: %v, got: %v", test.want, got)
+			}
+		})
+	}
+
+	if _, err := (LoadKeyOptions{Key: strings.NewReader("secret"), KeyLocation: "file:///run/keys/tank"}).args(); err == nil {
+		t.Fatal("wanted error for key with file location, got nil")
+	}
+}
+
+func TestChangeKeyOptionsArgs(t *testing.T) {
+	for name, test := range map[string]struct {
+		opts ChangeKeyOptions
+		want []string
+	}{
+		"inherit": {
+			opts: ChangeKeyOptions{LoadKey: true, Inherit: true},
+			want: []string{"-l", "-i"},
+		},
+		"new key": {
+			opts: ChangeKeyOptions{KeyFormat: KeyFormatRaw, KeyLocation: "file:///run/keys/new"},
+			want: []string{"-o", "keyformat=raw", "-o", "keylocation=file:///run/keys/new"},
+		},
+		"new passphrase": {
+			opts: ChangeKeyOptions{KeyLocation: "prompt", PBKDF2Iters: 500000, Key: strings.NewReader("new passphrase")},
+			want: []string{"-o", "keylocation=prompt", "-o", "pbkdf2iters=500000"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, err := test.opts.args()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(test.want, got) {
+				t.Fatalf("wanted: %v, got: %v", test.want, got)
+			}
+		})
+	}
+
+	for name, opts := range map[string]ChangeKeyOptions{
+		"inherit with new key": {Inherit: true, KeyFormat: KeyFormatHex},
+		"invalid key location": {KeyLocation: "/run/keys/new"},
+		"too few iterations":   {PBKDF2Iters: 10},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := opts.args(); err == nil {
+				t.Fatal("wanted error, got nil")
+			}
+		})
+	}
+}
+
+func TestParseEncryptionStatus(t *testing.T) {
+	got, err := parseEncryptionStatus([][]string{
+		{"encryption", "aes-256-gcm"},
+		{"keystatus", "unavailable"},
+		{"keyformat", "passphrase"},
+		{"keylocation", "prompt"},
+		{"encryptionroot", "test/encrypted"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := &EncryptionStatus{
+		Encryption:     "aes-256-gcm",
+		KeyStatus:      KeyStatusUnavailable,
+		KeyFormat:      KeyFormatPassphrase,
+		KeyLocation:    "prompt",
+		EncryptionRoot: "test/encrypted",
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %+v, got: %+v", want, got)
+	}
+	if !got.Encrypted() || !got.Locked() {
+		t.Fatal("wanted encrypted and locked")
+	}
+
+	got, err = parseEncryptionStatus([][]string{
+		{"encryption", "off"},
+		{"keystatus", "-"},
+		{"keyformat", "none"},
+		{"keylocation", "none"},
+		{"encryptionroot", "-"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(&EncryptionStatus{Encryption: "off"}, got) || got.Encrypted() || got.Locked() {
+		t.Fatalf("wanted unencrypted status, got: %+v", got)
+	}
+}
@@ -1,7 +1,19 @@
 package zfs
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+)
+
+// Typed errors matched by errors.Is against the *Error returned when a command fails.
+var (
+	// ErrDatasetNotFound matches failures because a dataset, snapshot or bookmark does not exist.
+	ErrDatasetNotFound = errors.New("dataset does not exist")
+	// ErrDatasetExists matches failures because a dataset, snapshot or bookmark already exists.
+	ErrDatasetExists = errors.New("dataset already exists")
+	// ErrDatasetBusy matches failures because a dataset is in use, e.g. mounted by a process or an open zvol.
+	ErrDatasetBusy = errors.New("dataset is busy")
 )
 
 // Error is an error which is returned when the `zfs` or `zpool` shell
@@ -16,3 +28,32 @@ type Error struct {
 func (e Error) Error() string {
 	return fmt.Sprintf("%s: %q => %s", e.Err, e.Debug, e.Stderr)
 }
+
+// Unwrap returns the underlying error, so that errors.Is and errors.As can
+// inspect it, e.g. to detect a cancelled context.
+func (e Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the error against ErrDatasetNotFound, ErrDatasetExists and ErrDatasetBusy,
+// judging by the messages zfs writes to stderr.
+func (e Error) Is(target error) bool {
+	switch target {
+	case ErrDatasetNotFound:
+		return isDatasetNotExist(e.Stderr)
+	case ErrDatasetExists:
+		return strings.Contains(e.Stderr, "already exists")
+	case ErrDatasetBusy:
+		return strings.Contains(e.Stderr, "dataset is busy")
+	}
+	return false
+}
+
+// isDatasetNotExist reports whether the stderr output of a zfs command says that the dataset does not exist.
+// zfs destroy reports missing snapshots as "could not find any snapshots to destroy; check snapshot names.",
+// and a missing bookmark as "bookmark 'tank/fs#mark' does not exist.".
+func isDatasetNotExist(stderr string) bool {
+	return strings.Contains(stderr, "dataset does not exist") ||
+		strings.Contains(stderr, "could not find any snapshots to destroy") ||
+		strings.Contains(stderr, "bookmark '") && strings.Contains(stderr, "' does not exist")
+}
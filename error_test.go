@@ -1,6 +1,7 @@
 package zfs
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"testing"
@@ -35,3 +36,34 @@ func TestError(t *testing.T) {
 		}
 	}
 }
+
+func TestErrorUnwrap(t *testing.T) {
+	var err error = &Error{Err: context.Canceled, Debug: "/sbin/zfs send test@snap"}
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("errors.Is: wanted context.Canceled in %v", err)
+	}
+}
+
+func TestErrorIs(t *testing.T) {
+	tests := []struct {
+		stderr string
+		target error
+	}{
+		{"cannot open 'tank/x': dataset does not exist", ErrDatasetNotFound},
+		{"bookmark 'tank/x#mark' does not exist.", ErrDatasetNotFound},
+		{"could not find any snapshots to destroy; check snapshot names.", ErrDatasetNotFound},
+		{"cannot create 'tank/x': dataset already exists", ErrDatasetExists},
+		{"cannot create snapshot 'tank/x@s': dataset already exists", ErrDatasetExists},
+		{"cannot destroy 'tank/x': dataset is busy", ErrDatasetBusy},
+		{"cannot unmount '/tank/x': pool or dataset is busy", ErrDatasetBusy},
+		{"cannot open 'tank/x': permission denied", nil},
+	}
+	for _, test := range tests {
+		var err error = fmt.Errorf("wrapped: %w", &Error{Err: errors.New("exit status 1"), Stderr: test.stderr})
+		for _, target := range []error{ErrDatasetNotFound, ErrDatasetExists, ErrDatasetBusy} {
+			if got := errors.Is(err, target); got != (target == test.target) {
+				t.Fatalf("%q: wanted: %v, got: %v for %v", test.stderr, target == test.target, got, target)
+			}
+		}
+	}
+}
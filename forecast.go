@@ -0,0 +1,146 @@
+package zfs
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrInsufficientSamples is returned by forecasts with fewer than two samples taken at different times.
+var ErrInsufficientSamples = errors.New("at least two samples are required for a forecast")
+
+// defaultMaxCapacitySamples is the number of samples a CapacityTracker keeps per pool if MaxSamples is 0.
+const defaultMaxCapacitySamples = 1024
+
+// CapacitySample is the allocation of a pool at a point in time.
+type CapacitySample struct {
+	Time      time.Time `json:"time"`
+	Allocated uint64    `json:"allocated"`
+	Size      uint64    `json:"size"`
+}
+
+// CapacityTracker records samples of pool allocation over time, to forecast when pools fill up.
+// It is safe for concurrent use.
+type CapacityTracker struct {
+	// MaxSamples is the number of samples kept per pool, the oldest are dropped first.
+	MaxSamples int
+
+	mu      sync.Mutex
+	samples map[string][]CapacitySample
+}
+
+// Record adds a sample of the pool's current allocation, taken at t.
+func (c *CapacityTracker) Record(z *Zpool, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.samples == nil {
+		c.samples = map[string][]CapacitySample{}
+	}
+	max := c.MaxSamples
+	if max <= 0 {
+		max = defaultMaxCapacitySamples
+	}
+	s := append(c.samples[z.Name], CapacitySample{Time: t, Allocated: z.Allocated, Size: z.Size})
+	if len(s) > max {
+		s = append([]CapacitySample(nil), s[len(s)-max:]...)
+	}
+	c.samples[z.Name] = s
+}
+
+// Samples returns the samples recorded for the pool, oldest first.
+func (c *CapacityTracker) Samples(pool string) []CapacitySample {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]CapacitySample(nil), c.samples[pool]...)
+}
+
+// CapacityForecast projects when a pool reaches a target utilization.
+// A zero time means the pool is not projected to reach it, because its allocation is not growing.
+type CapacityForecast struct {
+	Pool string `json:"pool"`
+	// Target is the utilization in percent the forecast is for.
+	Target uint64 `json:"target"`
+	// Linear is the projection from a linear fit, suited to steady growth.
+	Linear time.Time `json:"linear"`
+	// Exponential is the projection from an exponential fit, suited to compounding growth.
+	Exponential time.Time `json:"exponential"`
+	// Samples is the number of samples the forecast is based on.
+	Samples int `json:"samples"`
+}
+
+// Forecast projects when the pool reaches target percent utilization from the recorded samples.
+func (c *CapacityTracker) Forecast(pool string, target uint64) (*CapacityForecast, error) {
+	return forecastCapacity(pool, c.Samples(pool), target)
+}
+
+// CapacityForecast records the current allocation of the receiving pool in the tracker,
+// and projects when the pool reaches target percent utilization.
+// It is typically called periodically with a fresh Zpool from GetZpool.
+func (z *Zpool) CapacityForecast(tracker *CapacityTracker, target uint64) (*CapacityForecast, error) {
+	tracker.Record(z, time.Now())
+	return tracker.Forecast(z.Name, target)
+}
+
+func forecastCapacity(pool string, samples []CapacitySample, target uint64) (*CapacityForecast, error) {
+	if target == 0 || target > 100 {
+		return nil, fmt.Errorf("target must be between 1 and 100 percent, got %d", target)
+	}
+	if len(samples) < 2 || !samples[0].Time.Before(samples[len(samples)-1].Time) {
+		return nil, ErrInsufficientSamples
+	}
+
+	last := samples[len(samples)-1]
+	f := &CapacityForecast{Pool: pool, Target: target, Samples: len(samples)}
+	goal := float64(last.Size) * float64(target) / 100
+	if float64(last.Allocated) >= goal {
+		f.Linear, f.Exponential = last.Time, last.Time
+		return f, nil
+	}
+
+	start := samples[0].Time
+	x := make([]float64, len(samples))
+	y := make([]float64, len(samples))
+	logY := make([]float64, 0, len(samples))
+	for i, s := range samples {
+		x[i] = s.Time.Sub(start).Seconds()
+		y[i] = float64(s.Allocated)
+		if s.Allocated > 0 {
+			logY = append(logY, math.Log(y[i]))
+		}
+	}
+
+	f.Linear = project(start, x, y, goal)
+	if len(logY) == len(y) {
+		f.Exponential = project(start, x, logY, math.Log(goal))
+	}
+	return f, nil
+}
+
+// project fits y = a + b*x by least squares and returns the time at which y reaches goal,
+// or the zero time if y is not growing.
+func project(start time.Time, x, y []float64, goal float64) time.Time {
+	n := float64(len(x))
+	var sx, sy, sxx, sxy float64
+	for i := range x {
+		sx += x[i]
+		sy += y[i]
+		sxx += x[i] * x[i]
+		sxy += x[i] * y[i]
+	}
+	den := n*sxx - sx*sx
+	if den == 0 {
+		return time.Time{}
+	}
+	b := (n*sxy - sx*sy) / den
+	if b <= 0 {
+		return time.Time{}
+	}
+	a := (sy - b*sx) / n
+	secs := (goal - a) / b
+	if secs > float64(math.MaxInt64/int64(time.Second)) {
+		return time.Time{}
+	}
+	return start.Add(time.Duration(secs * float64(time.Second)))
+}
@@ -0,0 +1,68 @@
+package zfs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCapacityTrackerForecast(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	tracker := &CapacityTracker{MaxSamples: 10}
+
+	if _, err := tracker.Forecast("tank", 80); err != ErrInsufficientSamples {
+		t.Fatalf("wanted: %v, got: %v", ErrInsufficientSamples, err)
+	}
+
+	// 10 GB per day on a 1000 GB pool, starting at 100 GB
+	for i := 0; i < 15; i++ {
+		tracker.Record(&Zpool{Name: "tank", Size: 1000e9, Allocated: uint64(100e9 + i*10e9)}, start.Add(time.Duration(i)*day))
+	}
+	if n := len(tracker.Samples("tank")); n != 10 {
+		t.Fatalf("wanted 10 samples, got: %d", n)
+	}
+
+	f, err := tracker.Forecast("tank", 80)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 800 GB is reached after 70 days
+	if want := start.Add(70 * day); f.Linear.Before(want.Add(-time.Minute)) || f.Linear.After(want.Add(time.Minute)) {
+		t.Fatalf("wanted: %v, got: %v", want, f.Linear)
+	}
+	// the exponential fit of linear growth is sooner than the linear fit
+	if f.Exponential.IsZero() || !f.Exponential.Before(f.Linear) {
+		t.Fatalf("wanted an exponential projection before %v, got: %v", f.Linear, f.Exponential)
+	}
+	if f.Samples != 10 || f.Target != 80 {
+		t.Fatalf("unexpected forecast: %+v", f)
+	}
+
+	// already past the target
+	f, err = tracker.Forecast("tank", 20)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if last := start.Add(14 * day); !f.Linear.Equal(last) || !f.Exponential.Equal(last) {
+		t.Fatalf("wanted: %v, got: %+v", last, f)
+	}
+
+	if _, err := tracker.Forecast("tank", 101); err == nil {
+		t.Fatal("expected an error for a target above 100 percent")
+	}
+}
+
+func TestForecastCapacityShrinking(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	samples := []CapacitySample{
+		{Time: start, Allocated: 500, Size: 1000},
+		{Time: start.Add(time.Hour), Allocated: 400, Size: 1000},
+	}
+	f, err := forecastCapacity("tank", samples, 90)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !f.Linear.IsZero() || !f.Exponential.IsZero() {
+		t.Fatalf("wanted no projection for a shrinking pool, got: %+v", f)
+	}
+}
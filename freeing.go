@@ -0,0 +1,69 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// FreeingProgress reports how far the pool has come in freeing the space of destroyed datasets in the background.
+type FreeingProgress struct {
+	// Initial is the amount of space in bytes that was left to free when monitoring started.
+	Initial uint64 `json:"initial"`
+	// Remaining is the amount of space in bytes that is still to be freed.
+	Remaining uint64 `json:"remaining"`
+	// Done is set on the final report, once nothing is left to free.
+	Done bool `json:"done"`
+}
+
+// Freed returns the amount of space in bytes freed since monitoring started.
+func (p FreeingProgress) Freed() uint64 {
+	if p.Remaining > p.Initial {
+		return 0
+	}
+	return p.Initial - p.Remaining
+}
+
+// MonitorFreeing polls the pool's freeing property every interval and reports the progress to fn,
+// until the space of destroyed datasets has been freed or ctx is cancelled.
+// It is typically started after destroying a large dataset or snapshot. interval must be positive.
+func (z *Zpool) MonitorFreeing(ctx context.Context, interval time.Duration, fn func(FreeingProgress)) error {
+	return monitorFreeing(ctx, interval, func() (uint64, error) {
+		pool, err := GetZpool(z.Name)
+		if err != nil {
+			return 0, err
+		}
+		return pool.Freeing, nil
+	}, fn)
+}
+
+func monitorFreeing(ctx context.Context, interval time.Duration, freeing func() (uint64, error), fn func(FreeingProgress)) error {
+	if interval <= 0 {
+		return errors.New("a positive interval is required")
+	}
+	remaining, err := freeing()
+	if err != nil {
+		return err
+	}
+	p := FreeingProgress{Initial: remaining, Remaining: remaining}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		p.Done = p.Remaining == 0
+		fn(p)
+		if p.Done {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		if p.Remaining, err = freeing(); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMonitorFreeing(t *testing.T) {
+	samples := []uint64{300, 200, 50, 0}
+	freeing := func() (uint64, error) {
+		v := samples[0]
+		samples = samples[1:]
+		return v, nil
+	}
+
+	var reports []FreeingProgress
+	err := monitorFreeing(context.Background(), time.Millisecond, freeing, func(p FreeingProgress) {
+		reports = append(reports, p)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(reports) != 4 {
+		t.Fatalf("wanted 4 reports, got: %+v", reports)
+	}
+	if last := reports[3]; !last.Done || last.Freed() != 300 {
+		t.Fatalf("wanted final report with 300 bytes freed, got: %+v", last)
+	}
+	if reports[1].Freed() != 100 || reports[1].Done {
+		t.Fatalf("wanted 100 bytes freed, got: %+v", reports[1])
+	}
+}
+
+func TestMonitorFreeingCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	err := monitorFreeing(ctx, time.Hour, func() (uint64, error) { return 1, nil }, func(FreeingProgress) {
+		cancel()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("wanted context.Canceled, got: %v", err)
+	}
+}
+
+func TestMonitorFreeingInterval(t *testing.T) {
+	called := false
+	err := monitorFreeing(context.Background(), 0, func() (uint64, error) { called = true; return 1, nil }, func(FreeingProgress) {})
+	if err == nil || called {
+		t.Fatalf("wanted: an error before polling, got: %v (polled: %v)", err, called)
+	}
+}
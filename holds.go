@@ -0,0 +1,76 @@
+package zfs
+
+import (
+	"errors"
+	"strconv"
+)
+
+// maxHoldsArgLen bounds the total length of the snapshot names passed to a single `zfs holds`, well below ARG_MAX.
+const maxHoldsArgLen = 128 << 10
+
+// ListHolds returns the hold tags of all held snapshots in the pool, or below any other dataset, keyed by snapshot name,
+// to find what keeps snapshots, and the space they pin, from being destroyed.
+// Held snapshots are found by their userrefs in a single pass, so only they are passed to `zfs holds`,
+// in as few invocations as their names fit into.
+func ListHolds(pool string) (map[string][]string, error) {
+	out, err := zfsOutput("get", "-Hp", "-r", "-t", DatasetSnapshot, "-o", "name,value", "userrefs", pool)
+	if err != nil {
+		return nil, err
+	}
+	held, err := heldSnapshots(out)
+	if err != nil {
+		return nil, err
+	}
+
+	holds := map[string][]string{}
+	for _, batch := range batchArgs(held, maxHoldsArgLen) {
+		h, err := listHolds(batch...)
+		if err != nil {
+			return nil, err
+		}
+		for snap, tags := range h {
+			holds[snap] = append(holds[snap], tags...)
+		}
+	}
+	return holds, nil
+}
+
+// example input for heldSnapshots
+// test/fs@a    0
+// test/fs@b    2
+
+func heldSnapshots(lines [][]string) ([]string, error) {
+	var held []string
+	for _, line := range lines {
+		if len(line) != 2 {
+			return nil, errors.New("output does not match what is expected on this platform")
+		}
+		refs, err := strconv.ParseUint(line[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if refs > 0 {
+			held = append(held, line[0])
+		}
+	}
+	return held, nil
+}
+
+// batchArgs splits args into batches whose total length stays within maxLen, each holding at least one argument.
+func batchArgs(args []string, maxLen int) [][]string {
+	var batches [][]string
+	var batch []string
+	n := 0
+	for _, a := range args {
+		if len(batch) > 0 && n+len(a)+1 > maxLen {
+			batches = append(batches, batch)
+			batch, n = nil, 0
+		}
+		batch = append(batch, a)
+		n += len(a) + 1
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
@@ -0,0 +1,43 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHeldSnapshots(t *testing.T) {
+	held, err := heldSnapshots([][]string{
+		{"test/fs@a", "0"},
+		{"test/fs@b", "2"},
+		{"test/fs with space@c", "1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"test/fs@b", "test/fs with space@c"}
+	if !reflect.DeepEqual(held, want) {
+		t.Fatalf("wanted: %v, got: %v", want, held)
+	}
+
+	if _, err := heldSnapshots([][]string{{"test/fs@a", "-"}}); err == nil {
+		t.Fatal("wanted: error, got: nil")
+	}
+}
+
+func TestBatchArgs(t *testing.T) {
+	tests := []struct {
+		args   []string
+		maxLen int
+		want   [][]string
+	}{
+		{nil, 10, nil},
+		{[]string{"aaa", "bbb", "ccc"}, 8, [][]string{{"aaa", "bbb"}, {"ccc"}}},
+		{[]string{"aaa", "bbb", "ccc"}, 100, [][]string{{"aaa", "bbb", "ccc"}}},
+		{[]string{"toolong", "a"}, 4, [][]string{{"toolong"}, {"a"}}},
+	}
+	for _, test := range tests {
+		if got := batchArgs(test.args, test.maxLen); !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("wanted: %v, got: %v", test.want, got)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// HookFailurePolicy decides how a failing pre-snapshot hook affects the snapshot.
+type HookFailurePolicy int
+
+// Hook failure policies.
+const (
+	// HookAbort skips the snapshot when the pre-snapshot hook fails. It is the default.
+	HookAbort HookFailurePolicy = iota
+	// HookContinue takes the snapshot anyway when the pre-snapshot hook fails, e.g. for best-effort flushes.
+	HookContinue
+)
+
+// SnapshotHook is run around snapshot creation, e.g. to call fsfreeze, flush a database or pause a VM,
+// so that application-consistent snapshots can be taken.
+type SnapshotHook struct {
+	// Name identifies the hook in errors.
+	Name string
+	// Pre, if set, is called before the snapshot is taken.
+	Pre func(ctx context.Context, dataset string) error
+	// Post, if set, is called after the snapshot attempt if Pre succeeded (or is unset), even if the snapshot failed.
+	Post func(ctx context.Context, dataset string) error
+	// Timeout bounds each of Pre and Post, no timeout is applied if 0.
+	Timeout time.Duration
+	// OnFailure decides whether the snapshot is taken if Pre fails.
+	OnFailure HookFailurePolicy
+}
+
+// CommandHook returns a SnapshotHook running the pre and post commands on the local host, either of which may be empty.
+// The dataset name is appended to the arguments of both commands.
+func CommandHook(name string, pre, post []string, timeout time.Duration) SnapshotHook {
+	run := func(argv []string) func(context.Context, string) error {
+		if len(argv) == 0 {
+			return nil
+		}
+		return func(ctx context.Context, dataset string) error {
+			args := append(append([]string{}, argv[1:]...), dataset)
+			c := command{Command: argv[0], Ctx: ctx}
+			_, err := c.Run(args...)
+			return err
+		}
+	}
+	return SnapshotHook{Name: name, Pre: run(pre), Post: run(post), Timeout: timeout}
+}
+
+func (h SnapshotHook) call(ctx context.Context, fn func(context.Context, string) error, dataset string) error {
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+	return fn(ctx, dataset)
+}
+
+// SnapshotWithHooks is like Snapshot, but runs the Pre functions of hooks in order before taking the snapshot,
+// and the Post functions in reverse order afterwards.
+// If a Pre function fails under HookAbort, no snapshot is taken and only the hooks already prepared are run post.
+func (d *Dataset) SnapshotWithHooks(ctx context.Context, name string, recursive bool, hooks ...SnapshotHook) (*Dataset, error) {
+	var snap *Dataset
+	err := runWithHooks(ctx, d.Name, hooks, func() error {
+		var err error
+		snap, err = d.Snapshot(name, recursive)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// runWithHooks runs take between the Pre and Post functions of hooks for dataset.
+func runWithHooks(ctx context.Context, dataset string, hooks []SnapshotHook, take func() error) error {
+	var prepared []SnapshotHook
+	var err error
+	for _, h := range hooks {
+		if h.Pre != nil {
+			if preErr := h.call(ctx, h.Pre, dataset); preErr != nil {
+				if h.OnFailure == HookAbort {
+					err = fmt.Errorf("pre-snapshot hook %s of %s failed: %w", h.Name, dataset, preErr)
+					break
+				}
+				// the hook did not prepare anything, so there is nothing to undo
+				continue
+			}
+		}
+		prepared = append(prepared, h)
+	}
+
+	if err == nil {
+		err = take()
+	}
+
+	for i := len(prepared) - 1; i >= 0; i-- {
+		h := prepared[i]
+		if h.Post == nil {
+			continue
+		}
+		if postErr := h.call(ctx, h.Post, dataset); postErr != nil && err == nil {
+			err = fmt.Errorf("post-snapshot hook %s of %s failed: %w", h.Name, dataset, postErr)
+		}
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRunWithHooks(t *testing.T) {
+	errHook := errors.New("hook failed")
+
+	for name, test := range map[string]struct {
+		hooks   []func(*[]string) SnapshotHook
+		want    []string
+		wantErr bool
+	}{
+		"pre and post in order": {
+			hooks: []func(*[]string) SnapshotHook{
+				recordingHook("a", nil, HookAbort),
+				recordingHook("b", nil, HookAbort),
+			},
+			want: []string{"pre a", "pre b", "snapshot", "post b", "post a"},
+		},
+		"abort": {
+			hooks: []func(*[]string) SnapshotHook{
+				recordingHook("a", nil, HookAbort),
+				recordingHook("b", errHook, HookAbort),
+				recordingHook("c", nil, HookAbort),
+			},
+			want:    []string{"pre a", "pre b", "post a"},
+			wantErr: true,
+		},
+		"continue": {
+			hooks: []func(*[]string) SnapshotHook{
+				recordingHook("a", errHook, HookContinue),
+				recordingHook("b", nil, HookAbort),
+			},
+			want: []string{"pre a", "pre b", "snapshot", "post b"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var calls []string
+			hooks := make([]SnapshotHook, len(test.hooks))
+			for i, h := range test.hooks {
+				hooks[i] = h(&calls)
+			}
+
+			err := runWithHooks(context.Background(), "test/fs", hooks, func() error {
+				calls = append(calls, "snapshot")
+				return nil
+			})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("wanted error: %v, got: %v", test.wantErr, err)
+			}
+			if !reflect.DeepEqual(test.want, calls) {
+				t.Fatalf("wanted: %v, got: %v", test.want, calls)
+			}
+		})
+	}
+}
+
+func recordingHook(name string, preErr error, policy HookFailurePolicy) func(*[]string) SnapshotHook {
+	return func(calls *[]string) SnapshotHook {
+		return SnapshotHook{
+			Name: name,
+			Pre: func(context.Context, string) error {
+				*calls = append(*calls, "pre "+name)
+				return preErr
+			},
+			Post: func(context.Context, string) error {
+				*calls = append(*calls, "post "+name)
+				return nil
+			},
+			OnFailure: policy,
+		}
+	}
+}
+
+func TestHookTimeout(t *testing.T) {
+	hook := SnapshotHook{
+		Name: "slow",
+		Pre: func(ctx context.Context, _ string) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		Timeout: time.Millisecond,
+	}
+	err := runWithHooks(context.Background(), "test/fs", []SnapshotHook{hook}, func() error {
+		t.Fatal("snapshot taken despite failing hook")
+		return nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wanted context.DeadlineExceeded, got: %v", err)
+	}
+}
+
+func TestCommandHook(t *testing.T) {
+	hook := CommandHook("test", []string{"true"}, []string{"false"}, time.Second)
+	err := runWithHooks(context.Background(), "test/fs", []SnapshotHook{hook}, func() error { return nil })
+	if err == nil {
+		t.Fatal("wanted error from failing post command, got nil")
+	}
+}
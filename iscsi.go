@@ -0,0 +1,287 @@
+package zfs
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// User properties marking zvols for export over iSCSI.
+const (
+	// ISCSIExportProperty is "on" for zvols to be exported.
+	ISCSIExportProperty = "iscsi:export"
+	// ISCSITargetProperty is the IQN of the target exporting the zvol, derived from the zvol name if unset.
+	// Zvols sharing a target are exported as its LUNs.
+	ISCSITargetProperty = "iscsi:target"
+	// ISCSILUNProperty is the LUN of the zvol within its target, the next free one if unset.
+	ISCSILUNProperty = "iscsi:lun"
+	// ISCSIInitiatorsProperty is a comma separated list of the initiator IQNs allowed to access the target.
+	// Without any, all initiators are allowed.
+	ISCSIInitiatorsProperty = "iscsi:initiators"
+)
+
+var iscsiProperties = []string{ISCSIExportProperty, ISCSITargetProperty, ISCSILUNProperty, ISCSIInitiatorsProperty}
+
+// ISCSIExportOptions controls how SetISCSIExport marks a zvol for export.
+type ISCSIExportOptions struct {
+	// Target is the IQN of the target, see ISCSITargetProperty.
+	Target string
+	// LUN is the LUN within the target, the next free one if nil.
+	LUN *int
+	// Initiators are the initiator IQNs allowed to access the target, see ISCSIInitiatorsProperty.
+	Initiators []string
+}
+
+// SetISCSIExport marks the receiving zvol for export over iSCSI.
+// Exports are not set up by this, see TargetcliScript and CtldConfig for that.
+func (d *Dataset) SetISCSIExport(opts ISCSIExportOptions) error {
+	if d.Type != "" && d.Type != DatasetVolume {
+		return errors.New("can only export volumes over iSCSI")
+	}
+	return zfs(append(iscsiExportArgs(opts), d.Name)...)
+}
+
+// iscsiExportArgs returns the zfs set arguments for opts, without the zvol name.
+func iscsiExportArgs(opts ISCSIExportOptions) []string {
+	args := []string{"set", ISCSIExportProperty + "=on"}
+	if opts.Target != "" {
+		args = append(args, ISCSITargetProperty+"="+opts.Target)
+	}
+	if opts.LUN != nil {
+		args = append(args, ISCSILUNProperty+"="+strconv.Itoa(*opts.LUN))
+	}
+	if len(opts.Initiators) > 0 {
+		args = append(args, ISCSIInitiatorsProperty+"="+strings.Join(opts.Initiators, ","))
+	}
+	return args
+}
+
+// ClearISCSIExport removes the iSCSI export properties from the receiving zvol.
+func (d *Dataset) ClearISCSIExport() error {
+	for _, prop := range iscsiProperties {
+		if err := zfs("inherit", prop, d.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ISCSIExport is a zvol marked for export over iSCSI.
+type ISCSIExport struct {
+	Volume string `json:"volume"`
+	// Device is the block device node of the zvol.
+	Device     string   `json:"device"`
+	Target     string   `json:"target"`
+	LUN        int      `json:"lun"`
+	Initiators []string `json:"initiators,omitempty"`
+	Size       uint64   `json:"size"`
+}
+
+// ListISCSIExports returns the zvols marked for export below filter, or in all pools if filter is empty,
+// ordered by target and LUN. Targets of zvols without ISCSITargetProperty are named baseIQN:<zvol name>,
+// e.g. "iqn.2005-10.org.freenas.ctl:tank.vm.disk0" for the base IQN "iqn.2005-10.org.freenas.ctl".
+func ListISCSIExports(filter, baseIQN string) ([]*ISCSIExport, error) {
+	args := []string{"get", "-Hp", "-r", "-t", DatasetVolume, "-o", "name,property,value",
+		strings.Join(append([]string{"volsize"}, iscsiProperties...), ",")}
+	if filter != "" {
+		args = append(args, filter)
+	}
+	out, err := zfsOutput(args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseISCSIExports(out, baseIQN)
+}
+
+func parseISCSIExports(lines [][]string, baseIQN string) ([]*ISCSIExport, error) {
+	var exports []*ISCSIExport
+	// props holds the properties of each zvol, in the order they are listed
+	props := map[string]map[string]string{}
+	var names []string
+	for _, line := range lines {
+		if len(line) != 3 {
+			return nil, errors.New("output does not match what is expected on this platform")
+		}
+		if props[line[0]] == nil {
+			props[line[0]] = map[string]string{}
+			names = append(names, line[0])
+		}
+		if line[2] != "-" {
+			props[line[0]][line[1]] = line[2]
+		}
+	}
+
+	// LUNs are unique within a target, those not set explicitly take the lowest free ones
+	used := map[string]map[int]bool{}
+	var auto []*ISCSIExport
+	for _, name := range names {
+		p := props[name]
+		if p[ISCSIExportProperty] != "on" {
+			continue
+		}
+		e := &ISCSIExport{Volume: name, Target: p[ISCSITargetProperty], LUN: -1}
+		if e.Target == "" {
+			if baseIQN == "" {
+				return nil, fmt.Errorf("%s has no %s and no base IQN is given", name, ISCSITargetProperty)
+			}
+			e.Target = baseIQN + ":" + iqnName(name)
+		}
+		if v, ok := p[ISCSILUNProperty]; ok {
+			lun, err := strconv.Atoi(v)
+			if err != nil || lun < 0 {
+				return nil, fmt.Errorf("invalid %s of %s: %q", ISCSILUNProperty, name, v)
+			}
+			if used[e.Target][lun] {
+				return nil, fmt.Errorf("LUN %d of target %s is used more than once", lun, e.Target)
+			}
+			e.LUN = lun
+		}
+		if v := p[ISCSIInitiatorsProperty]; v != "" {
+			e.Initiators = splitList(v)
+		}
+		if err := setUint(&e.Size, p["volsize"]); err != nil {
+			return nil, err
+		}
+		dev, err := ZvolDevicePath(name)
+		if err != nil {
+			return nil, err
+		}
+		e.Device = dev
+
+		if used[e.Target] == nil {
+			used[e.Target] = map[int]bool{}
+		}
+		if e.LUN >= 0 {
+			used[e.Target][e.LUN] = true
+		} else {
+			auto = append(auto, e)
+		}
+		exports = append(exports, e)
+	}
+	for _, e := range auto {
+		for e.LUN = 0; used[e.Target][e.LUN]; e.LUN++ {
+		}
+		used[e.Target][e.LUN] = true
+	}
+
+	sort.Slice(exports, func(i, j int) bool {
+		if exports[i].Target != exports[j].Target {
+			return exports[i].Target < exports[j].Target
+		}
+		return exports[i].LUN < exports[j].LUN
+	})
+	return exports, nil
+}
+
+// iqnName turns a dataset name into the unique part of an IQN, which allows lowercase letters, digits, '-', '.' and ':'.
+func iqnName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.', r == ':':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		case r == '/':
+			return '.'
+		}
+		return '-'
+	}, name)
+}
+
+// iscsiTarget is a target along with its LUNs.
+type iscsiTarget struct {
+	iqn        string
+	luns       []*ISCSIExport
+	initiators []string
+}
+
+// groupISCSITargets groups exports by target. The initiators of a target are those allowed by any of its LUNs.
+func groupISCSITargets(exports []*ISCSIExport) []*iscsiTarget {
+	var targets []*iscsiTarget
+	byIQN := map[string]*iscsiTarget{}
+	for _, e := range exports {
+		t := byIQN[e.Target]
+		if t == nil {
+			t = &iscsiTarget{iqn: e.Target}
+			byIQN[e.Target] = t
+			targets = append(targets, t)
+		}
+		t.luns = append(t.luns, e)
+		for _, i := range e.Initiators {
+			if !containsString(t.initiators, i) {
+				t.initiators = append(t.initiators, i)
+			}
+		}
+	}
+	return targets
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// TargetcliScript returns the targetcli commands exporting the given zvols through the Linux LIO target,
+// as returned by ListISCSIExports. Each zvol becomes a block backstore named after it.
+// Targets without initiators accept all of them, without authentication.
+func TargetcliScript(exports []*ISCSIExport) string {
+	var b strings.Builder
+	for _, e := range exports {
+		fmt.Fprintf(&b, "/backstores/block create name=%s dev=%s\n", backstoreName(e.Volume), e.Device)
+	}
+	for _, t := range groupISCSITargets(exports) {
+		tpg := "/iscsi/" + t.iqn + "/tpg1"
+		fmt.Fprintf(&b, "/iscsi create %s\n", t.iqn)
+		for _, e := range t.luns {
+			fmt.Fprintf(&b, "%s/luns create /backstores/block/%s lun=%d\n", tpg, backstoreName(e.Volume), e.LUN)
+		}
+		if len(t.initiators) == 0 {
+			fmt.Fprintf(&b, "%s set attribute authentication=0 generate_node_acls=1 demo_mode_write_protect=0\n", tpg)
+		}
+		for _, i := range t.initiators {
+			fmt.Fprintf(&b, "%s/acls create %s\n", tpg, i)
+		}
+	}
+	b.WriteString("saveconfig\n")
+	return b.String()
+}
+
+// backstoreName turns a zvol name into a LIO backstore name.
+func backstoreName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == ' ' || r == '@' || r == ':' {
+			return '-'
+		}
+		return r
+	}, name)
+}
+
+// CtldConfig returns the ctl.conf(5) targets exporting the given zvols through the FreeBSD ctld(8),
+// as returned by ListISCSIExports. The targets use the named portal group, which must be defined elsewhere.
+// Targets without initiators accept all of them, without authentication.
+func CtldConfig(exports []*ISCSIExport, portalGroup string) string {
+	var b strings.Builder
+	for n, t := range groupISCSITargets(exports) {
+		authGroup := "no-authentication"
+		if len(t.initiators) > 0 {
+			authGroup = "ag" + strconv.Itoa(n)
+			fmt.Fprintf(&b, "auth-group %s {\n\tauth-type none\n", authGroup)
+			for _, i := range t.initiators {
+				fmt.Fprintf(&b, "\tinitiator-name %q\n", i)
+			}
+			b.WriteString("}\n\n")
+		}
+		fmt.Fprintf(&b, "target %q {\n\tauth-group %s\n\tportal-group %s\n", t.iqn, authGroup, portalGroup)
+		for _, e := range t.luns {
+			fmt.Fprintf(&b, "\tlun %d {\n\t\tpath %q\n\t}\n", e.LUN, e.Device)
+		}
+		b.WriteString("}\n\n")
+	}
+	return b.String()
+}
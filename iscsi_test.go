@@ -0,0 +1,135 @@
+package zfs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseISCSIExports(t *testing.T) {
+	if currentPlatform.zvolDir == "" {
+		t.Skip("zvol device nodes are not supported on", currentPlatform.name)
+	}
+	lines := [][]string{
+		{"tank/vm/Web_1", "volsize", "1073741824"},
+		{"tank/vm/Web_1", "iscsi:export", "on"},
+		{"tank/vm/Web_1", "iscsi:target", "-"},
+		{"tank/vm/Web_1", "iscsi:lun", "-"},
+		{"tank/vm/Web_1", "iscsi:initiators", "-"},
+		{"tank/vm/db", "volsize", "2048"},
+		{"tank/vm/db", "iscsi:export", "on"},
+		{"tank/vm/db", "iscsi:target", "iqn.2024-01.com.example:db"},
+		{"tank/vm/db", "iscsi:lun", "-"},
+		{"tank/vm/db", "iscsi:initiators", "iqn.a,iqn.b"},
+		{"tank/vm/log", "volsize", "1024"},
+		{"tank/vm/log", "iscsi:export", "on"},
+		{"tank/vm/log", "iscsi:target", "iqn.2024-01.com.example:db"},
+		{"tank/vm/log", "iscsi:lun", "0"},
+		{"tank/vm/log", "iscsi:initiators", "-"},
+		{"tank/vm/off", "volsize", "1024"},
+		{"tank/vm/off", "iscsi:export", "-"},
+	}
+	exports, err := parseISCSIExports(lines, "iqn.2024-01.com.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev := func(name string) string {
+		d, _ := ZvolDevicePath(name)
+		return d
+	}
+	want := []*ISCSIExport{
+		{Volume: "tank/vm/log", Device: dev("tank/vm/log"), Target: "iqn.2024-01.com.example:db", LUN: 0, Size: 1024},
+		{Volume: "tank/vm/db", Device: dev("tank/vm/db"), Target: "iqn.2024-01.com.example:db", LUN: 1, Initiators: []string{"iqn.a", "iqn.b"}, Size: 2048},
+		{Volume: "tank/vm/Web_1", Device: dev("tank/vm/Web_1"), Target: "iqn.2024-01.com.example:tank.vm.web-1", LUN: 0, Size: 1 << 30},
+	}
+	if !reflect.DeepEqual(exports, want) {
+		t.Fatalf("wanted: %+v, got: %+v", want, exports)
+	}
+
+	if _, err := parseISCSIExports(lines, ""); err == nil {
+		t.Fatal("wanted: error for a missing base IQN, got: nil")
+	}
+	dup := append(lines[:0:0], lines...)
+	dup[8] = []string{"tank/vm/db", "iscsi:lun", "0"}
+	if _, err := parseISCSIExports(dup, "iqn.x"); err == nil || !strings.Contains(err.Error(), "more than once") {
+		t.Fatalf("wanted: duplicate LUN error, got: %v", err)
+	}
+}
+
+var testISCSIExports = []*ISCSIExport{
+	{Volume: "tank/db", Device: "/dev/zvol/tank/db", Target: "iqn.x:db", LUN: 0, Initiators: []string{"iqn.a"}},
+	{Volume: "tank/log", Device: "/dev/zvol/tank/log", Target: "iqn.x:db", LUN: 1},
+	{Volume: "tank/web", Device: "/dev/zvol/tank/web", Target: "iqn.x:web", LUN: 0},
+}
+
+func TestISCSIExportArgs(t *testing.T) {
+	zero, two := 0, 2
+	tests := map[string]struct {
+		opts ISCSIExportOptions
+		want []string
+	}{
+		"zero value": {ISCSIExportOptions{}, []string{"set", "iscsi:export=on"}},
+		"lun 0":      {ISCSIExportOptions{LUN: &zero}, []string{"set", "iscsi:export=on", "iscsi:lun=0"}},
+		"all": {
+			ISCSIExportOptions{Target: "iqn.x:db", LUN: &two, Initiators: []string{"iqn.a", "iqn.b"}},
+			[]string{"set", "iscsi:export=on", "iscsi:target=iqn.x:db", "iscsi:lun=2", "iscsi:initiators=iqn.a,iqn.b"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := iscsiExportArgs(test.opts)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("wanted: %v, got: %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestTargetcliScript(t *testing.T) {
+	want := `/backstores/block create name=tank-db dev=/dev/zvol/tank/db
+/backstores/block create name=tank-log dev=/dev/zvol/tank/log
+/backstores/block create name=tank-web dev=/dev/zvol/tank/web
+/iscsi create iqn.x:db
+/iscsi/iqn.x:db/tpg1/luns create /backstores/block/tank-db lun=0
+/iscsi/iqn.x:db/tpg1/luns create /backstores/block/tank-log lun=1
+/iscsi/iqn.x:db/tpg1/acls create iqn.a
+/iscsi create iqn.x:web
+/iscsi/iqn.x:web/tpg1/luns create /backstores/block/tank-web lun=0
+/iscsi/iqn.x:web/tpg1 set attribute authentication=0 generate_node_acls=1 demo_mode_write_protect=0
+saveconfig
+`
+	if got := TargetcliScript(testISCSIExports); got != want {
+		t.Fatalf("wanted: %v, got: %v", want, got)
+	}
+}
+
+func TestCtldConfig(t *testing.T) {
+	want := `auth-group ag0 {
+	auth-type none
+	initiator-name "iqn.a"
+}
+
+target "iqn.x:db" {
+	auth-group ag0
+	portal-group pg0
+	lun 0 {
+		path "/dev/zvol/tank/db"
+	}
+	lun 1 {
+		path "/dev/zvol/tank/log"
+	}
+}
+
+target "iqn.x:web" {
+	auth-group no-authentication
+	portal-group pg0
+	lun 0 {
+		path "/dev/zvol/tank/web"
+	}
+}
+
+`
+	if got := CtldConfig(testISCSIExports, "pg0"); got != want {
+		t.Fatalf("wanted: %v, got: %v", want, got)
+	}
+}
@@ -0,0 +1,55 @@
+//go:build freebsd
+// +build freebsd
+
+package zfs
+
+import "errors"
+
+// Jail attaches the receiving file system to the jail with the given ID or name, so it can be managed from within the jail.
+// The jailed property of the file system must be set (see SetJailed) first.
+func (d *Dataset) Jail(jail string) error {
+	args, err := jailArgs("jail", jail, d.Name)
+	if err != nil {
+		return err
+	}
+	return zfs(args...)
+}
+
+// Unjail detaches the receiving file system from the jail with the given ID or name.
+func (d *Dataset) Unjail(jail string) error {
+	args, err := jailArgs("unjail", jail, d.Name)
+	if err != nil {
+		return err
+	}
+	return zfs(args...)
+}
+
+// jailArgs returns the arguments of `zfs jail` or `zfs unjail`.
+func jailArgs(cmd, jail, name string) ([]string, error) {
+	if jail == "" {
+		return nil, errors.New("a jail ID or name is required")
+	}
+	if err := ValidateDatasetName(name); err != nil {
+		return nil, err
+	}
+	return []string{cmd, jail, name}, nil
+}
+
+// Jailed reports whether the jailed property of the receiving dataset is set.
+func (d *Dataset) Jailed() (bool, error) {
+	val, err := d.GetProperty("jailed")
+	if err != nil {
+		return false, err
+	}
+	return val == "on", nil
+}
+
+// SetJailed sets or clears the jailed property of the receiving dataset.
+// A jailed file system is not mounted on the host and may be attached to a jail.
+func (d *Dataset) SetJailed(jailed bool) error {
+	val := "off"
+	if jailed {
+		val = "on"
+	}
+	return d.SetProperty("jailed", val)
+}
@@ -0,0 +1,33 @@
+//go:build freebsd
+// +build freebsd
+
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJailArgs(t *testing.T) {
+	tests := map[string]struct {
+		cmd, jail, name string
+		want            []string
+	}{
+		"jail by ID":     {cmd: "jail", jail: "3", name: "tank/jails/www", want: []string{"jail", "3", "tank/jails/www"}},
+		"unjail by name": {cmd: "unjail", jail: "www", name: "tank/jails/www", want: []string{"unjail", "www", "tank/jails/www"}},
+		"no jail":        {cmd: "jail", name: "tank/jails/www"},
+		"snapshot":       {cmd: "jail", jail: "www", name: "tank/jails/www@snap"},
+	}
+	for name, test := range tests {
+		got, err := jailArgs(test.cmd, test.jail, test.name)
+		if test.want == nil {
+			if err == nil {
+				t.Fatalf("%s: wanted: error, got: %v", name, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(test.want, got) {
+			t.Fatalf("%s: wanted: %v, got: %v %v", name, test.want, got, err)
+		}
+	}
+}
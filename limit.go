@@ -0,0 +1,62 @@
+package zfs
+
+import (
+	"context"
+	"sync"
+)
+
+// commandLimit is the semaphore limiting concurrent zfs and zpool processes, nil if unlimited.
+var commandLimit struct {
+	mu  sync.Mutex
+	sem chan struct{}
+}
+
+// SetMaxConcurrentCommands limits the number of zfs and zpool processes run at the same time,
+// so that many goroutines do not spawn hundreds of processes contending for the pool configuration lock.
+// Further commands wait for a running one to finish, or for their context to be cancelled.
+// A value of zero or less removes the limit, which is the default.
+//
+// zfs send and zfs receive are not limited: they run as long as their stream does,
+// and a receive waiting for its stream must never keep the matching send from starting.
+func SetMaxConcurrentCommands(n int) {
+	commandLimit.mu.Lock()
+	defer commandLimit.mu.Unlock()
+	if n <= 0 {
+		commandLimit.sem = nil
+		return
+	}
+	commandLimit.sem = make(chan struct{}, n)
+}
+
+// streamingCommands are the zfs subcommands exempt from the limit.
+var streamingCommands = map[string]bool{
+	"send":    true,
+	"receive": true,
+	"recv":    true,
+}
+
+// acquireCommand waits for a slot to run the named command with args, and returns the function releasing it.
+// Commands other than zfs and zpool, and streaming sends and receives, are not limited.
+func acquireCommand(ctx context.Context, name string, args []string) (func(), error) {
+	if name != "zfs" && name != "zpool" || name == "zfs" && len(args) > 0 && streamingCommands[args[0]] {
+		return func() {}, nil
+	}
+	commandLimit.mu.Lock()
+	sem := commandLimit.sem
+	commandLimit.mu.Unlock()
+	if sem == nil {
+		return func() {}, nil
+	}
+
+	release := func() { <-sem }
+	if ctx == nil {
+		sem <- struct{}{}
+		return release, nil
+	}
+	select {
+	case sem <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,80 @@
+package zfs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAcquireCommand(t *testing.T) {
+	SetMaxConcurrentCommands(2)
+	defer SetMaxConcurrentCommands(0)
+
+	first, err := acquireCommand(context.Background(), "zfs", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := acquireCommand(context.Background(), "zpool", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// other commands, and streaming sends and receives, are not limited
+	for _, args := range [][]string{{"zstream", "dump"}, {"zfs", "send", "tank/fs@snap"}, {"zfs", "receive", "tank/copy"}, {"zfs", "recv", "tank/copy"}} {
+		if _, err := acquireCommand(context.Background(), args[0], args[1:]); err != nil {
+			t.Fatalf("%v: unexpected error: %v", args, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := acquireCommand(ctx, "zfs", nil); err != context.DeadlineExceeded {
+		t.Fatalf("wanted: %v, got: %v", context.DeadlineExceeded, err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		release, err := acquireCommand(context.Background(), "zfs", nil)
+		if err == nil {
+			release()
+		}
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired a slot beyond the limit")
+	case <-time.After(10 * time.Millisecond):
+	}
+	first()
+	<-acquired
+	second()
+
+	SetMaxConcurrentCommands(0)
+	if _, err := acquireCommand(context.Background(), "zfs", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestZpoolStatusLimited(t *testing.T) {
+	SetMaxConcurrentCommands(1)
+	defer SetMaxConcurrentCommands(0)
+
+	release, err := acquireCommand(context.Background(), "zpool", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		// the result does not matter, only that zpool waits for the slot
+		_, _ = GetZpoolStatusWithOptions("test", ZpoolStatusOptions{})
+		_, _ = ListPoolStatus(false)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("zpool status ran beyond the limit")
+	case <-time.After(10 * time.Millisecond):
+	}
+	release()
+	<-done
+}
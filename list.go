@@ -0,0 +1,61 @@
+package zfs
+
+import "strings"
+
+// ListOptions controls which datasets ListDatasets returns and which of their properties it loads.
+type ListOptions struct {
+	// Types are the dataset types to list, e.g. DatasetSnapshot. All types are listed if empty.
+	Types []string
+	// NamesOnly loads nothing but the names of the datasets, which is by far the fastest way to list many snapshots.
+	// The properties of a single dataset can be loaded on demand using Refresh.
+	NamesOnly bool
+	// Properties are the properties to load, e.g. "used" and "creation". The name is always loaded.
+	// All properties of Dataset are loaded if empty. Properties without a Dataset field are ignored.
+	Properties []string
+}
+
+// properties returns the columns to list, starting with the name, or nil for all properties of Dataset.
+func (o ListOptions) properties() []string {
+	if o.NamesOnly {
+		return []string{"name"}
+	}
+	if len(o.Properties) == 0 {
+		return nil
+	}
+	props := []string{"name"}
+	for _, p := range o.Properties {
+		if p != "name" {
+			props = append(props, p)
+		}
+	}
+	return props
+}
+
+func (o ListOptions) args(props []string) []string {
+	types := "all"
+	if len(o.Types) > 0 {
+		types = strings.Join(o.Types, ",")
+	}
+	return []string{"list", "-rHp", "-t", types, "-o", strings.Join(props, ",")}
+}
+
+// ListDatasets recursively lists the datasets below filter, or all datasets if filter is empty,
+// loading only the properties selected by opts.
+func ListDatasets(filter string, opts ListOptions) ([]*Dataset, error) {
+	args := func(props []string) []string {
+		args := opts.args(props)
+		if filter != "" {
+			args = append(args, filter)
+		}
+		return args
+	}
+	props := opts.properties()
+	if props == nil {
+		return listDatasetsWithOptional(args)
+	}
+	out, err := zfsOutput(args(props)...)
+	if err != nil {
+		return nil, err
+	}
+	return parseDatasetColumns(out, props)
+}
@@ -0,0 +1,80 @@
+package zfs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListOptionsArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ListOptions
+		want []string
+	}{
+		{"default", ListOptions{}, []string{"list", "-rHp", "-t", "all", "-o", strings.Join(dsPropList, ",")}},
+		{"names", ListOptions{Types: []string{DatasetSnapshot}, NamesOnly: true}, []string{"list", "-rHp", "-t", "snapshot", "-o", "name"}},
+		{"properties", ListOptions{Types: []string{DatasetFilesystem, DatasetVolume}, Properties: []string{"used", "name", "creation"}},
+			[]string{"list", "-rHp", "-t", "filesystem,volume", "-o", "name,used,creation"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			props := tt.opts.properties()
+			if props == nil {
+				props = dsPropList
+			}
+			if got := tt.opts.args(props); !reflect.DeepEqual(tt.want, got) {
+				t.Fatalf("wanted: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseDatasetColumns(t *testing.T) {
+	got, err := parseDatasetColumns([][]string{
+		{"test/fs@a", "1024", "1650000000"},
+		{"test/fs@b", "2048", "1650000060"},
+	}, []string{"name", "used", "creation"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []*Dataset{
+		{Name: "test/fs@a", Used: 1024, Creation: time.Unix(1650000000, 0)},
+		{Name: "test/fs@b", Used: 2048, Creation: time.Unix(1650000060, 0)},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %+v, got: %+v", want, got)
+	}
+
+	if _, err := parseDatasetColumns([][]string{{"test/fs@a"}}, []string{"name", "used"}); err == nil {
+		t.Fatal("expected an error for missing columns")
+	}
+}
+
+func TestDsProps(t *testing.T) {
+	if got := dsProps(0); !reflect.DeepEqual(dsPropList, got) {
+		t.Fatalf("wanted: %v, got: %v", dsPropList, got)
+	}
+	want := append([]string{}, dsPropList...)
+	for _, group := range dsOptionalPropList {
+		want = append(want, group...)
+	}
+	if got := dsProps(len(dsOptionalPropList)); !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %v, got: %v", want, got)
+	}
+
+	// releases without the optional properties leave their fields unset
+	line := make([]string, len(dsPropList))
+	for i := range line {
+		line[i] = "-"
+	}
+	line[0] = "tank/fs"
+	got, err := parseDatasetColumns([][]string{line}, dsProps(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := (&Dataset{Name: "tank/fs"}); !reflect.DeepEqual(want, got[0]) {
+		t.Fatalf("wanted: %+v, got: %+v", want, got[0])
+	}
+}
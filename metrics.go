@@ -0,0 +1,136 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// MetricsSink receives the samples taken by collectors such as SpaceCollector,
+// to be forwarded to a monitoring system like Prometheus or StatsD.
+type MetricsSink interface {
+	// Gauge records the current value of the named metric for the given labels.
+	Gauge(name string, labels map[string]string, value float64)
+}
+
+// Names of the gauges reported by SpaceCollector, labeled with "dataset".
+const (
+	MetricDatasetUsed       = "zfs_dataset_used_bytes"
+	MetricDatasetAvailable  = "zfs_dataset_available_bytes"
+	MetricDatasetReferenced = "zfs_dataset_referenced_bytes"
+	MetricDatasetQuota      = "zfs_dataset_quota_bytes"
+	MetricDatasetRefQuota   = "zfs_dataset_refquota_bytes"
+)
+
+// SpaceUsage is the space accounting of a dataset at a point in time.
+// Quota and RefQuota are zero if unset.
+type SpaceUsage struct {
+	Dataset    string    `json:"dataset"`
+	Used       uint64    `json:"used"`
+	Available  uint64    `json:"available"`
+	Referenced uint64    `json:"referenced"`
+	Quota      uint64    `json:"quota"`
+	RefQuota   uint64    `json:"refquota"`
+	Time       time.Time `json:"time"`
+}
+
+// spaceProperties are the properties listed by SpaceCollector, in column order.
+const spaceProperties = "name,used,available,referenced,quota,refquota"
+
+// SpaceCollector periodically samples the space usage of datasets and reports it to a MetricsSink,
+// e.g. to bill tenants by the space their datasets consume.
+// All datasets are sampled with a single zfs list, however many there are.
+type SpaceCollector struct {
+	// Datasets are the file systems and volumes to sample, all of them if empty.
+	Datasets []string
+	// Recursive samples the descendants of Datasets as well.
+	Recursive bool
+	// Interval is the time between samples.
+	Interval time.Duration
+	// Sink receives the samples as the gauges named by the Metric* constants.
+	Sink MetricsSink
+
+	// list and now are replaced in tests.
+	list func(ctx context.Context, arg ...string) ([][]string, error)
+	now  func() time.Time
+}
+
+// Collect samples the datasets once.
+func (c *SpaceCollector) Collect(ctx context.Context) ([]*SpaceUsage, error) {
+	list := c.list
+	if list == nil {
+		list = func(ctx context.Context, arg ...string) ([][]string, error) {
+			cmd := command{Command: "zfs", Ctx: ctx}
+			return cmd.Run(arg...)
+		}
+	}
+	now := c.now
+	if now == nil {
+		now = time.Now
+	}
+
+	args := []string{"list", "-Hp", "-t", "filesystem,volume", "-o", spaceProperties}
+	if c.Recursive {
+		args = append(args, "-r")
+	}
+	out, err := list(ctx, append(args, c.Datasets...)...)
+	if err != nil {
+		return nil, err
+	}
+	return parseSpaceUsage(out, now())
+}
+
+func parseSpaceUsage(lines [][]string, t time.Time) ([]*SpaceUsage, error) {
+	usage := make([]*SpaceUsage, 0, len(lines))
+	for _, line := range lines {
+		if len(line) != 6 {
+			return nil, errors.New("output does not match what is expected on this platform")
+		}
+		u := &SpaceUsage{Dataset: line[0], Time: t}
+		for i, field := range []*uint64{&u.Used, &u.Available, &u.Referenced, &u.Quota, &u.RefQuota} {
+			if err := setUint(field, line[i+1]); err != nil {
+				return nil, err
+			}
+		}
+		usage = append(usage, u)
+	}
+	return usage, nil
+}
+
+// Run samples the datasets every interval until ctx is cancelled, and returns ctx.Err().
+// Errors sampling the datasets are returned immediately.
+func (c *SpaceCollector) Run(ctx context.Context) error {
+	if c.Sink == nil {
+		return errors.New("a Sink is required")
+	}
+	if c.Interval <= 0 {
+		return errors.New("a positive Interval is required")
+	}
+
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		usage, err := c.Collect(ctx)
+		if err != nil {
+			return err
+		}
+		for _, u := range usage {
+			c.report(u)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *SpaceCollector) report(u *SpaceUsage) {
+	labels := map[string]string{"dataset": u.Dataset}
+	c.Sink.Gauge(MetricDatasetUsed, labels, float64(u.Used))
+	c.Sink.Gauge(MetricDatasetAvailable, labels, float64(u.Available))
+	c.Sink.Gauge(MetricDatasetReferenced, labels, float64(u.Referenced))
+	c.Sink.Gauge(MetricDatasetQuota, labels, float64(u.Quota))
+	c.Sink.Gauge(MetricDatasetRefQuota, labels, float64(u.RefQuota))
+}
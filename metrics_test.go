@@ -0,0 +1,67 @@
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingSink []string
+
+func (s *recordingSink) Gauge(name string, labels map[string]string, value float64) {
+	*s = append(*s, fmt.Sprintf("%s{dataset=%s} %v", name, labels["dataset"], value))
+}
+
+func TestSpaceCollector(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	var args []string
+	sink := &recordingSink{}
+	c := &SpaceCollector{
+		Datasets:  []string{"tank/tenants"},
+		Recursive: true,
+		Interval:  time.Hour,
+		Sink:      sink,
+		list: func(_ context.Context, arg ...string) ([][]string, error) {
+			args = arg
+			return [][]string{
+				{"tank/tenants", "3072", "1000", "96", "0", "0"},
+				{"tank/tenants/a", "2048", "1000", "2048", "4096", "0"},
+				{"tank/tenants/vol", "1024", "1000", "512", "-", "-"},
+			}, nil
+		},
+		now: func() time.Time { return now },
+	}
+
+	usage, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantArgs := "list -Hp -t filesystem,volume -o name,used,available,referenced,quota,refquota -r tank/tenants"
+	if got := strings.Join(args, " "); got != wantArgs {
+		t.Fatalf("wanted: %v, got: %v", wantArgs, got)
+	}
+	want := []*SpaceUsage{
+		{Dataset: "tank/tenants", Used: 3072, Available: 1000, Referenced: 96, Time: now},
+		{Dataset: "tank/tenants/a", Used: 2048, Available: 1000, Referenced: 2048, Quota: 4096, Time: now},
+		{Dataset: "tank/tenants/vol", Used: 1024, Available: 1000, Referenced: 512, Time: now},
+	}
+	if !reflect.DeepEqual(usage, want) {
+		t.Fatalf("wanted: %+v, got: %+v", want, usage)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Run(ctx); err != context.Canceled {
+		t.Fatalf("wanted: %v, got: %v", context.Canceled, err)
+	}
+	if len(*sink) != 15 || (*sink)[5] != "zfs_dataset_used_bytes{dataset=tank/tenants/a} 2048" || (*sink)[8] != "zfs_dataset_quota_bytes{dataset=tank/tenants/a} 4096" {
+		t.Fatalf("wanted: 15 gauges, got: %v", *sink)
+	}
+
+	if _, err := parseSpaceUsage([][]string{{"tank", "1"}}, now); err == nil {
+		t.Fatal("wanted: error for short lines, got: nil")
+	}
+}
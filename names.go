@@ -0,0 +1,154 @@
+package zfs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Limits of dataset names, as enforced by ZFS.
+const (
+	// MaxDatasetNameLen is the maximum length of a full dataset, snapshot or bookmark name.
+	MaxDatasetNameLen = 255
+	// MaxDatasetNesting is the default maximum depth of a dataset below its pool, counting a snapshot as one level.
+	MaxDatasetNesting = 50
+)
+
+// ErrInvalidName is wrapped by the errors of ValidateDatasetName, ValidateSnapshotName and ValidatePoolName.
+var ErrInvalidName = errors.New("invalid name")
+
+// reservedPoolPrefixes are the vdev types pool names may not start with.
+var reservedPoolPrefixes = []string{"mirror", "raidz", "draid", "spare"}
+
+// ValidatePoolName checks name against the ZFS rules for pool names.
+// Besides the rules for dataset name components, pool names must start with a letter,
+// and must not be "log" or start with a vdev type such as "mirror" or "raidz".
+func ValidatePoolName(name string) error {
+	if err := validateComponent(name); err != nil {
+		return nameError(name, err.Error())
+	}
+	return validatePoolComponent(name)
+}
+
+func validatePoolComponent(name string) error {
+	if c := name[0]; !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+		return nameError(name, "pool name must begin with a letter")
+	}
+	if name == "log" {
+		return nameError(name, "pool name is reserved")
+	}
+	for _, prefix := range reservedPoolPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return nameError(name, fmt.Sprintf("pool name must not begin with %q", prefix))
+		}
+	}
+	return nil
+}
+
+// ValidateDatasetName checks name, e.g. "pool/fs/child", against the ZFS naming rules before it is passed to zfs:
+// components may contain letters, digits and the characters "_-:. " only, components must not be empty, "." or "..",
+// and the name must not exceed MaxDatasetNameLen characters or MaxDatasetNesting levels.
+// The returned errors wrap ErrInvalidName.
+func ValidateDatasetName(name string) error {
+	if strings.ContainsAny(name, "@#") {
+		return nameError(name, "dataset name must not contain '@' or '#'")
+	}
+	return validateName(name, 0)
+}
+
+// ValidateSnapshotName checks name, e.g. "pool/fs@snap", against the ZFS naming rules for snapshots:
+// a valid dataset name, followed by a single '@' and a snapshot name obeying the rules of a dataset name component.
+// The returned errors wrap ErrInvalidName.
+func ValidateSnapshotName(name string) error {
+	i := strings.IndexByte(name, '@')
+	if i < 0 {
+		return nameError(name, "snapshot name must contain '@'")
+	}
+	if len(name) > MaxDatasetNameLen {
+		return nameError(name, fmt.Sprintf("name is longer than %d characters", MaxDatasetNameLen))
+	}
+	dataset, snapshot := name[:i], name[i+1:]
+	if strings.ContainsAny(snapshot, "@#/") {
+		return nameError(name, "snapshot name must not contain '@', '#' or '/'")
+	}
+	if strings.ContainsRune(dataset, '#') {
+		return nameError(name, "dataset name must not contain '#'")
+	}
+	if err := validateComponent(snapshot); err != nil {
+		return nameError(name, "snapshot "+err.Error())
+	}
+	return validateName(dataset, 1)
+}
+
+// validateName checks a dataset name, where extra levels are added to its nesting depth, e.g. for a snapshot.
+func validateName(name string, extra int) error {
+	if name == "" {
+		return nameError(name, "name is empty")
+	}
+	if len(name) > MaxDatasetNameLen {
+		return nameError(name, fmt.Sprintf("name is longer than %d characters", MaxDatasetNameLen))
+	}
+	components := strings.Split(name, "/")
+	if len(components)-1+extra >= MaxDatasetNesting {
+		return nameError(name, fmt.Sprintf("name is nested deeper than %d levels", MaxDatasetNesting))
+	}
+	for _, c := range components {
+		if err := validateComponent(c); err != nil {
+			return nameError(name, err.Error())
+		}
+	}
+	return validatePoolComponent(components[0])
+}
+
+// validateComponent checks a single component of a name.
+func validateComponent(c string) error {
+	switch c {
+	case "":
+		return errors.New("component is empty")
+	case ".", "..":
+		return fmt.Errorf("component %q is reserved", c)
+	}
+	for _, r := range c {
+		if !validNameChar(r) {
+			return fmt.Errorf("component %q contains invalid character %q", c, r)
+		}
+	}
+	return nil
+}
+
+func validNameChar(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	}
+	return strings.ContainsRune("_-:. ", r)
+}
+
+func nameError(name, reason string) error {
+	return fmt.Errorf("%w %q: %s", ErrInvalidName, name, reason)
+}
+
+// SanitizeNameComponent turns s into a valid dataset or snapshot name component
+// by replacing invalid characters with '_', e.g. to derive names from user input.
+// The result is at most max bytes long, if max is positive.
+func SanitizeNameComponent(s string, max int) string {
+	var b strings.Builder
+	for _, r := range s {
+		if validNameChar(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	c := b.String()
+	if max > 0 && len(c) > max {
+		c = c[:max]
+	}
+	switch c {
+	case "":
+		c = "_"
+	case ".", "..":
+		c = strings.Repeat("_", len(c))
+	}
+	return c
+}
@@ -0,0 +1,120 @@
+package zfs
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateDatasetName(t *testing.T) {
+	deep := "pool" + strings.Repeat("/a", MaxDatasetNesting)
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"pool", true},
+		{"pool/fs", true},
+		{"pool/fs with spaces/a:b.c_d-e", true},
+		{"pool" + strings.Repeat("/a", MaxDatasetNesting-1), true},
+		{"pool/" + strings.Repeat("a", MaxDatasetNameLen-5), true},
+		{"pool/" + strings.Repeat("a", MaxDatasetNameLen-4), false},
+		{deep, false},
+		{"", false},
+		{"pool/", false},
+		{"/pool", false},
+		{"pool//fs", false},
+		{"pool/.", false},
+		{"pool/..", false},
+		{"pool/fs@snap", false},
+		{"pool/fs#mark", false},
+		{"pool/f%s", false},
+		{"pool/fé", false},
+		{"pool/f$s", false},
+		{"1pool/fs", false},
+		{"mirror1/fs", false},
+		{"log/fs", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateDatasetName(tt.name)
+			if (err == nil) != tt.valid {
+				t.Fatalf("wanted valid: %v, got: %v", tt.valid, err)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidName) {
+				t.Fatalf("wanted: %v, got: %v", ErrInvalidName, err)
+			}
+		})
+	}
+}
+
+func TestValidateSnapshotName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"pool@snap", true},
+		{"pool/fs@snap 2022-04-14 10:00", true},
+		{"pool" + strings.Repeat("/a", MaxDatasetNesting-2) + "@snap", true},
+		{"pool" + strings.Repeat("/a", MaxDatasetNesting-1) + "@snap", false},
+		{"pool/fs@" + strings.Repeat("a", MaxDatasetNameLen-7), false},
+		{"pool/fs", false},
+		{"pool/fs@", false},
+		{"@snap", false},
+		{"pool/fs@a@b", false},
+		{"pool/fs@a/b", false},
+		{"pool/fs@a#b", false},
+		{"pool/fs@..", false},
+		{"pool/fs@a*b", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSnapshotName(tt.name)
+			if (err == nil) != tt.valid {
+				t.Fatalf("wanted valid: %v, got: %v", tt.valid, err)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidName) {
+				t.Fatalf("wanted: %v, got: %v", ErrInvalidName, err)
+			}
+		})
+	}
+}
+
+func TestValidatePoolName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"tank":    true,
+		"tank.01": true,
+		"raidz":   false,
+		"spares":  false,
+		"log":     false,
+		"logs":    true,
+		"_tank":   false,
+		"ta/nk":   false,
+	} {
+		if err := ValidatePoolName(name); (err == nil) != valid {
+			t.Fatalf("%s: wanted valid: %v, got: %v", name, valid, err)
+		}
+	}
+}
+
+func TestSanitizeNameComponent(t *testing.T) {
+	tests := []struct {
+		in   string
+		max  int
+		want string
+	}{
+		{"backup 2022-04-14", 0, "backup 2022-04-14"},
+		{"user@host/path", 0, "user_host_path"},
+		{"café", 0, "caf_"},
+		{"abcdef", 3, "abc"},
+		{"", 0, "_"},
+		{"..", 0, "__"},
+	}
+	for _, tt := range tests {
+		if got := SanitizeNameComponent(tt.in, tt.max); got != tt.want {
+			t.Fatalf("wanted: %q, got: %q", tt.want, got)
+		}
+		if err := validateComponent(SanitizeNameComponent(tt.in, tt.max)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
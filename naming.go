@@ -0,0 +1,94 @@
+package zfs
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// DefaultSnapshotTimeLayout is the time layout used by a SnapshotNaming without one, e.g. "2024-05-01_12:00".
+const DefaultSnapshotTimeLayout = "2006-01-02_15:04"
+
+// SnapshotNaming is a scheme for generating snapshot names from a time stamp and an optional label,
+// e.g. "auto-2024-05-01_12:00-hourly", and for parsing such names back.
+type SnapshotNaming struct {
+	// Prefix starts every name of the scheme, e.g. "auto".
+	Prefix string
+	// Separator separates the prefix, time stamp and label, "-" is used if empty.
+	Separator string
+	// Layout is the Go time layout of the time stamp, DefaultSnapshotTimeLayout is used if empty.
+	Layout string
+	// Location is the time zone of the time stamp, UTC is used if nil.
+	Location *time.Location
+}
+
+// ParsedSnapshotName is the structured data of a snapshot name generated by a SnapshotNaming.
+type ParsedSnapshotName struct {
+	Time  time.Time `json:"time"`
+	Label string    `json:"label"`
+}
+
+func (n SnapshotNaming) separator() string {
+	if n.Separator == "" {
+		return "-"
+	}
+	return n.Separator
+}
+
+func (n SnapshotNaming) layout() string {
+	if n.Layout == "" {
+		return DefaultSnapshotTimeLayout
+	}
+	return n.Layout
+}
+
+func (n SnapshotNaming) location() *time.Location {
+	if n.Location == nil {
+		return time.UTC
+	}
+	return n.Location
+}
+
+// Name returns the snapshot name (the part after "@") for time t and label, which may be empty.
+func (n SnapshotNaming) Name(t time.Time, label string) string {
+	name := n.Prefix + n.separator() + t.In(n.location()).Format(n.layout())
+	if label != "" {
+		name += n.separator() + label
+	}
+	return name
+}
+
+// Parse parses a snapshot name generated by the scheme. The name may include the dataset part.
+func (n SnapshotNaming) Parse(name string) (ParsedSnapshotName, error) {
+	name = snapshotShortName(name)
+	sep := n.separator()
+
+	rest := strings.TrimPrefix(name, n.Prefix+sep)
+	if rest == name {
+		return ParsedSnapshotName{}, errors.New("snapshot name does not start with the naming prefix")
+	}
+
+	// the separator may occur within the time stamp as well, so try the longest time stamp first
+	if t, err := time.ParseInLocation(n.layout(), rest, n.location()); err == nil {
+		return ParsedSnapshotName{Time: t}, nil
+	}
+	for i := strings.LastIndex(rest, sep); i > 0; i = strings.LastIndex(rest[:i], sep) {
+		if t, err := time.ParseInLocation(n.layout(), rest[:i], n.location()); err == nil {
+			return ParsedSnapshotName{Time: t, Label: rest[i+len(sep):]}, nil
+		}
+	}
+	return ParsedSnapshotName{}, errors.New("snapshot name does not contain a time stamp of the naming layout")
+}
+
+// Filter returns the snapshots whose names match the scheme and carry label, or any label if label is empty.
+// It can be used to restrict a RetentionPolicy to snapshots taken automatically.
+func (n SnapshotNaming) Filter(snapshots []*Dataset, label string) []*Dataset {
+	var matched []*Dataset
+	for _, s := range snapshots {
+		p, err := n.Parse(s.Name)
+		if err == nil && (label == "" || p.Label == label) {
+			matched = append(matched, s)
+		}
+	}
+	return matched
+}
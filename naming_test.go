@@ -0,0 +1,65 @@
+package zfs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSnapshotNaming(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for name, test := range map[string]struct {
+		naming SnapshotNaming
+		label  string
+		want   string
+	}{
+		"default": {
+			naming: SnapshotNaming{Prefix: "auto"},
+			want:   "auto-2024-05-01_12:00",
+		},
+		"label": {
+			naming: SnapshotNaming{Prefix: "auto"},
+			label:  "hourly",
+			want:   "auto-2024-05-01_12:00-hourly",
+		},
+		"separator within time stamp": {
+			naming: SnapshotNaming{Prefix: "autosnap", Separator: "_", Layout: "2006-01-02_15:04:05"},
+			label:  "daily",
+			want:   "autosnap_2024-05-01_12:00:00_daily",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got := test.naming.Name(ts, test.label)
+			if got != test.want {
+				t.Fatalf("wanted: %q, got: %q", test.want, got)
+			}
+
+			p, err := test.naming.Parse("test/fs@" + got)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !p.Time.Equal(ts) || p.Label != test.label {
+				t.Fatalf("wanted: %v %q, got: %v %q", ts, test.label, p.Time, p.Label)
+			}
+		})
+	}
+
+	n := SnapshotNaming{Prefix: "auto"}
+	for _, invalid := range []string{"manual-2024-05-01_12:00", "auto-yesterday", "auto-"} {
+		if _, err := n.Parse(invalid); err == nil {
+			t.Errorf("Parse(%q): wanted error, got nil", invalid)
+		}
+	}
+
+	snapshots := []*Dataset{
+		{Name: "test/fs@auto-2024-05-01_12:00-hourly"},
+		{Name: "test/fs@auto-2024-05-01_00:00-daily"},
+		{Name: "test/fs@before-upgrade"},
+	}
+	if got := n.Filter(snapshots, ""); len(got) != 2 {
+		t.Fatalf("wanted 2 snapshots of the scheme, got: %d", len(got))
+	}
+	if got := n.Filter(snapshots, "daily"); len(got) != 1 || got[0] != snapshots[1] {
+		t.Fatalf("wanted the daily snapshot, got: %v", got)
+	}
+}
@@ -0,0 +1,69 @@
+package zfs
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotOnDataset is returned by DatasetForPath when a path does not belong to a mounted ZFS file system.
+var ErrNotOnDataset = errors.New("path is not on a mounted ZFS file system")
+
+// DatasetForPath returns the mounted file system that owns path, i.e. the one with the longest mountpoint containing it.
+// The path is made absolute, and resolved if it exists. File systems with legacy mountpoints are not considered.
+func DatasetForPath(path string) (*Dataset, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	out, err := zfsOutput("list", "-H", "-t", DatasetFilesystem, "-o", "name,mountpoint,mounted")
+	if err != nil {
+		return nil, err
+	}
+	name, err := datasetForPath(out, path)
+	if err != nil {
+		return nil, err
+	}
+	return GetDataset(name)
+}
+
+// example input for datasetForPath
+// test         /test           yes
+// test/fs      /test/fs        yes
+// test/legacy  legacy          no
+
+func datasetForPath(lines [][]string, path string) (string, error) {
+	best, bestLen := "", -1
+	for _, line := range lines {
+		if len(line) != 3 {
+			return "", errors.New("output does not match what is expected on this platform")
+		}
+		name, mountpoint, mounted := line[0], line[1], line[2]
+		if mounted != "yes" || !filepath.IsAbs(mountpoint) {
+			continue
+		}
+		mountpoint = filepath.Clean(mountpoint)
+		if !pathContains(mountpoint, path) {
+			continue
+		}
+		if len(mountpoint) > bestLen {
+			best, bestLen = name, len(mountpoint)
+		}
+	}
+	if best == "" {
+		return "", ErrNotOnDataset
+	}
+	return best, nil
+}
+
+// pathContains reports whether path is dir or below it.
+func pathContains(dir, path string) bool {
+	if dir == "/" || dir == path {
+		return true
+	}
+	return strings.HasPrefix(path, dir+"/")
+}
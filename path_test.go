@@ -0,0 +1,43 @@
+package zfs
+
+import "testing"
+
+func TestDatasetForPath(t *testing.T) {
+	lines := [][]string{
+		{"test", "/test", "yes"},
+		{"test/fs", "/test/fs", "yes"},
+		{"test/unmounted", "/test/fs/unmounted", "no"},
+		{"test/legacy", "legacy", "no"},
+		{"test/none", "none", "no"},
+	}
+	tests := []struct {
+		path string
+		want string
+		err  error
+	}{
+		{"/test", "test", nil},
+		{"/test/file", "test", nil},
+		{"/test/fs", "test/fs", nil},
+		{"/test/fs/dir/file", "test/fs", nil},
+		{"/test/fs2/file", "test", nil},
+		{"/test/fs/unmounted/file", "test/fs", nil},
+		{"/testing", "", ErrNotOnDataset},
+		{"/", "", ErrNotOnDataset},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := datasetForPath(lines, tt.path)
+			if err != tt.err {
+				t.Fatalf("wanted error: %v, got: %v", tt.err, err)
+			}
+			if got != tt.want {
+				t.Fatalf("wanted: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+
+	got, err := datasetForPath([][]string{{"rpool/ROOT", "/", "yes"}}, "/home/user")
+	if err != nil || got != "rpool/ROOT" {
+		t.Fatalf("wanted: %v, got: %v (%v)", "rpool/ROOT", got, err)
+	}
+}
@@ -0,0 +1,83 @@
+package zfs
+
+// platform describes how the operating system the package is built for differs in its ZFS support.
+// The platform in use is currentPlatform, which is selected by build tags.
+type platform struct {
+	// name is the operating system, as in runtime.GOOS.
+	name string
+
+	// deviceDirs are the directories holding persistent device links, most stable naming first.
+	deviceDirs []string
+	// deviceRoot is the directory short device names in `zpool status` output are relative to.
+	deviceRoot string
+	// zvolDir is the directory holding the block device nodes of zvols, by zvol name.
+	zvolDir string
+	// sectorSize returns the logical and physical sector size of a block device, nil if unsupported.
+	sectorSize func(device string) (logical, physical uint64, err error)
+	// sysfs is the mount point of the Linux sysfs, used to find enclosure slots, empty if not available.
+	sysfs string
+	// probeDevice looks for existing data on a device, nil if unsupported.
+	probeDevice func(device string) (*DeviceUsage, error)
+
+	// aclTypes are the supported values of the acltype property, nil if the property does not exist.
+	aclTypes []ACLType
+	// nfsv4ACLs reports whether NFSv4 ACLs, and thus aclmode and aclinherit, are supported.
+	nfsv4ACLs bool
+	// xattrSA reports whether extended attributes can be stored as system attributes.
+	xattrSA bool
+}
+
+var (
+	linuxPlatform = platform{
+		name:        "linux",
+		deviceDirs:  []string{"/dev/disk/by-vdev", "/dev/disk/by-id", "/dev/disk/by-path", "/dev/disk/by-partuuid", "/dev"},
+		deviceRoot:  "/dev",
+		zvolDir:     "/dev/zvol",
+		sectorSize:  sysfsSectorSize,
+		probeDevice: blkidProbe,
+		sysfs:       "/sys",
+		aclTypes:    []ACLType{ACLTypeOff, ACLTypePOSIX},
+		xattrSA:     true,
+	}
+
+	freebsdPlatform = platform{
+		name:        "freebsd",
+		deviceDirs:  []string{"/dev/gpt", "/dev/diskid", "/dev/gptid", "/dev"},
+		deviceRoot:  "/dev",
+		zvolDir:     "/dev/zvol",
+		sectorSize:  diskinfoSectorSize,
+		probeDevice: geomProbe,
+		aclTypes:    []ACLType{ACLTypeOff, ACLTypeNFSv4},
+		nfsv4ACLs:   true,
+		xattrSA:     true,
+	}
+
+	// solarisPlatform covers illumos as well.
+	solarisPlatform = platform{
+		name:       "solaris",
+		deviceDirs: []string{"/dev/dsk"},
+		deviceRoot: "/dev/dsk",
+		zvolDir:    "/dev/zvol/dsk",
+		nfsv4ACLs:  true,
+	}
+
+	darwinPlatform = platform{
+		name:       "darwin",
+		deviceDirs: []string{"/var/run/disk/by-id", "/var/run/disk/by-serial", "/var/run/disk/by-path", "/dev"},
+		deviceRoot: "/dev",
+		zvolDir:    "/var/run/zfs/zvol/dsk",
+		aclTypes:   []ACLType{ACLTypeOff, ACLTypeNFSv4},
+		nfsv4ACLs:  true,
+		xattrSA:    true,
+	}
+)
+
+// supportsACLType reports whether the acltype property can be set to t.
+func (p *platform) supportsACLType(t ACLType) bool {
+	for _, s := range p.aclTypes {
+		if s == t {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,6 @@
+//go:build darwin
+// +build darwin
+
+package zfs
+
+var currentPlatform = &darwinPlatform
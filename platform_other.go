@@ -0,0 +1,13 @@
+//go:build !linux && !freebsd && !solaris && !darwin
+// +build !linux,!freebsd,!solaris,!darwin
+
+package zfs
+
+import "runtime"
+
+// currentPlatform makes no assumptions about operating systems ZFS is not known to run on.
+var currentPlatform = &platform{
+	name:       runtime.GOOS,
+	deviceDirs: []string{"/dev"},
+	deviceRoot: "/dev",
+}
@@ -0,0 +1,12 @@
+//go:build solaris
+// +build solaris
+
+package zfs
+
+import "runtime"
+
+var currentPlatform = func() *platform {
+	p := solarisPlatform
+	p.name = runtime.GOOS
+	return &p
+}()
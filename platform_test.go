@@ -0,0 +1,24 @@
+package zfs
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestCurrentPlatform(t *testing.T) {
+	if currentPlatform.name != runtime.GOOS {
+		t.Fatalf("wanted: %v, got: %v", runtime.GOOS, currentPlatform.name)
+	}
+	if len(currentPlatform.deviceDirs) == 0 || currentPlatform.deviceRoot == "" {
+		t.Fatal("device paths are not set")
+	}
+}
+
+func TestSupportsACLType(t *testing.T) {
+	if !linuxPlatform.supportsACLType(ACLTypePOSIX) || linuxPlatform.supportsACLType(ACLTypeNFSv4) {
+		t.Fatal("wanted POSIX but not NFSv4 ACLs on linux")
+	}
+	if solarisPlatform.supportsACLType(ACLTypeOff) {
+		t.Fatal("wanted no acltype property on solaris")
+	}
+}
@@ -0,0 +1,193 @@
+package zfs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// DeviceUsage describes existing data found on a device that is about to be used in a vdev.
+type DeviceUsage struct {
+	Device string `json:"device"`
+	// Filesystem is the type of the filesystem or other signature found on the device, e.g. "ext4" or "zfs_member".
+	Filesystem string `json:"filesystem"`
+	// Label is the label of the filesystem, which is the pool name for ZFS members.
+	Label string `json:"label"`
+	// PartitionTable is the type of the partition table on the device, e.g. "gpt".
+	PartitionTable string `json:"partition_table"`
+}
+
+// InUse reports whether any existing data was found on the device.
+func (u DeviceUsage) InUse() bool {
+	return u.Filesystem != "" || u.PartitionTable != ""
+}
+
+// ZFSMember reports whether the device carries a ZFS label, i.e. belongs to an existing or destroyed pool.
+func (u DeviceUsage) ZFSMember() bool {
+	return u.Filesystem == "zfs_member" || u.Filesystem == "zfs"
+}
+
+func (u DeviceUsage) String() string {
+	var found []string
+	switch {
+	case u.ZFSMember():
+		found = append(found, fmt.Sprintf("ZFS label of pool %q", u.Label))
+	case u.Filesystem != "":
+		found = append(found, u.Filesystem+" filesystem")
+	}
+	if u.PartitionTable != "" {
+		found = append(found, u.PartitionTable+" partition table")
+	}
+	if len(found) == 0 {
+		return u.Device + ": unused"
+	}
+	return u.Device + ": " + strings.Join(found, ", ")
+}
+
+// DevicesInUseError is returned when pool creation or expansion is refused because devices contain existing data.
+// Force the operation to overwrite the data anyway.
+type DevicesInUseError struct {
+	Devices []DeviceUsage
+}
+
+func (e *DevicesInUseError) Error() string {
+	s := make([]string, len(e.Devices))
+	for i, u := range e.Devices {
+		s[i] = u.String()
+	}
+	return "devices in use: " + strings.Join(s, "; ")
+}
+
+// CheckDevices probes the given devices for existing filesystems, partition tables and ZFS labels.
+// Devices may be given by short name, e.g. "sda", as in a vdev specification. Devices that do not exist fail the check.
+func CheckDevices(devices ...string) ([]DeviceUsage, error) {
+	if currentPlatform.probeDevice == nil {
+		return nil, fmt.Errorf("probing devices is not supported on %s", currentPlatform.name)
+	}
+	usage := make([]DeviceUsage, len(devices))
+	for i, dev := range devices {
+		path, err := resolveDevice(currentPlatform, dev)
+		if err != nil {
+			return nil, err
+		}
+		u, err := currentPlatform.probeDevice(path)
+		if err != nil {
+			return nil, err
+		}
+		u.Device = dev
+		usage[i] = *u
+	}
+	return usage, nil
+}
+
+// checkVdevArgs returns a *DevicesInUseError if any device of the vdev specification contains existing data.
+func checkVdevArgs(args []string) error {
+	var devices []string
+	for _, arg := range args {
+		if isVdevDevice(arg) {
+			devices = append(devices, arg)
+		}
+	}
+	usage, err := CheckDevices(devices...)
+	if err != nil {
+		return err
+	}
+	var inUse []DeviceUsage
+	for _, u := range usage {
+		if u.InUse() {
+			inUse = append(inUse, u)
+		}
+	}
+	if len(inUse) > 0 {
+		return &DevicesInUseError{Devices: inUse}
+	}
+	return nil
+}
+
+// exitCode returns the exit code of a failed command, or -1 if it did not exit normally.
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// blkidProbe probes a device using blkid(8), which exits with status 2 if nothing was found,
+// but also if the device does not exist.
+func blkidProbe(device string) (*DeviceUsage, error) {
+	c := command{Command: "blkid"}
+	out, err := c.Run("-p", "-o", "export", device)
+	if err != nil {
+		if exitCode(err) == 2 {
+			if _, statErr := os.Stat(device); statErr != nil {
+				return nil, statErr
+			}
+			return &DeviceUsage{}, nil
+		}
+		return nil, err
+	}
+	return parseBlkid(out), nil
+}
+
+func parseBlkid(lines [][]string) *DeviceUsage {
+	u := &DeviceUsage{}
+	for _, line := range lines {
+		kv := strings.SplitN(strings.Join(line, "\t"), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "TYPE":
+			u.Filesystem = kv[1]
+		case "LABEL":
+			u.Label = kv[1]
+		case "PTTYPE":
+			u.PartitionTable = kv[1]
+		}
+	}
+	return u
+}
+
+// geomProbe probes a device using fstyp(8) and gpart(8), which both fail if nothing was found.
+func geomProbe(device string) (*DeviceUsage, error) {
+	u := &DeviceUsage{}
+
+	c := command{Command: "fstyp"}
+	out, err := c.Run("-l", device)
+	switch {
+	case err == nil && len(out) > 0:
+		// the label follows the type and may contain spaces
+		f := strings.SplitN(strings.TrimSpace(strings.Join(out[0], " ")), " ", 2)
+		u.Filesystem = f[0]
+		if len(f) > 1 {
+			u.Label = f[1]
+		}
+	case err != nil && exitCode(err) != 1:
+		return nil, err
+	}
+
+	c = command{Command: "gpart"}
+	out, err = c.Run("show", device)
+	switch {
+	case err == nil:
+		u.PartitionTable = parseGpartScheme(out)
+	case exitCode(err) != 1:
+		return nil, err
+	}
+	return u, nil
+}
+
+// parseGpartScheme returns the partitioning scheme from the header line of `gpart show`,
+// e.g. "GPT" from "=>  40  976773088  ada0  GPT  (466G)".
+func parseGpartScheme(lines [][]string) string {
+	for _, line := range lines {
+		f := strings.Fields(strings.Join(line, " "))
+		if len(f) >= 5 && f[0] == "=>" {
+			return strings.ToLower(f[4])
+		}
+	}
+	return ""
+}
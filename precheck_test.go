@@ -0,0 +1,118 @@
+package zfs
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseBlkid(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want *DeviceUsage
+	}{
+		{
+			name: "zfs member",
+			out:  "DEVNAME=/dev/sdb1\nLABEL=tank\nUUID=1234567890\nUUID_SUB=987654321\nVERSION=5000\nTYPE=zfs_member\nUSAGE=filesystem\n",
+			want: &DeviceUsage{Filesystem: "zfs_member", Label: "tank"},
+		},
+		{
+			name: "partitioned disk",
+			out:  "DEVNAME=/dev/sdb\nPTUUID=5c2a1e4b-0000-4000-8000-000000000000\nPTTYPE=gpt\n",
+			want: &DeviceUsage{PartitionTable: "gpt"},
+		},
+		{
+			name: "label with equals sign",
+			out:  "DEVNAME=/dev/sdc\nLABEL=a=b\nTYPE=ext4\n",
+			want: &DeviceUsage{Filesystem: "ext4", Label: "a=b"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := parseBlkid(splitOutput(test.out))
+			if !reflect.DeepEqual(test.want, got) {
+				t.Fatalf("wanted: %+v, got: %+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestParseGpartScheme(t *testing.T) {
+	out := splitOutput("=>       40  976773088  ada0  GPT  (466G)\n" +
+		"         40       1024     1  freebsd-boot  (512K)\n" +
+		"       1064  976772064     2  freebsd-zfs  (466G)\n")
+	if got := parseGpartScheme(out); got != "gpt" {
+		t.Fatalf("wanted: gpt, got: %v", got)
+	}
+}
+
+func TestCheckVdevArgs(t *testing.T) {
+	dev, err := ioutil.TempDir("", "dev-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dev)
+	if dev, err = filepath.EvalSymlinks(dev); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"sda", "sdb", "sdc", "sdd", "sde", "sdf"} {
+		if err := ioutil.WriteFile(filepath.Join(dev, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func(p *platform) { currentPlatform = p }(currentPlatform)
+	currentPlatform = &platform{
+		name:       "test",
+		deviceRoot: dev,
+		probeDevice: func(device string) (*DeviceUsage, error) {
+			// devices are probed by their full path
+			if filepath.Dir(device) != dev {
+				t.Fatalf("unexpected device path: %s", device)
+			}
+			switch filepath.Base(device) {
+			case "sdb":
+				return &DeviceUsage{Filesystem: "zfs_member", Label: "old"}, nil
+			case "sdc":
+				return &DeviceUsage{PartitionTable: "dos"}, nil
+			case "sdf":
+				return nil, errors.New("probe failed")
+			}
+			return &DeviceUsage{}, nil
+		},
+	}
+
+	if err := checkVdevArgs([]string{"mirror", "sda", "sdd", "log", "sde"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = checkVdevArgs([]string{"raidz2", "sda", "sdb", "sdc", "sdd"})
+	var inUse *DevicesInUseError
+	if !errors.As(err, &inUse) {
+		t.Fatalf("wanted a *DevicesInUseError, got: %v", err)
+	}
+	want := []DeviceUsage{
+		{Device: "sdb", Filesystem: "zfs_member", Label: "old"},
+		{Device: "sdc", PartitionTable: "dos"},
+	}
+	if !reflect.DeepEqual(want, inUse.Devices) {
+		t.Fatalf("wanted: %+v, got: %+v", want, inUse.Devices)
+	}
+	if got := err.Error(); got != `devices in use: sdb: ZFS label of pool "old"; sdc: dos partition table` {
+		t.Fatalf("unexpected error message: %v", got)
+	}
+
+	if err := checkVdevArgs([]string{"sdf"}); err == nil || errors.As(err, &inUse) {
+		t.Fatalf("wanted a probe error, got: %v", err)
+	}
+
+	// a device that does not exist is not unused
+	if err := checkVdevArgs([]string{"sdz"}); !os.IsNotExist(err) {
+		t.Fatalf("wanted a not exist error, got: %v", err)
+	}
+}
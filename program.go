@@ -0,0 +1,88 @@
+package zfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ChannelProgramOptions controls how `zfs program` runs a channel program.
+type ChannelProgramOptions struct {
+	// InstructionLimit is the number of Lua instructions the program may execute before it is aborted (-t),
+	// which effectively is its timeout. The ZFS default is used if 0.
+	InstructionLimit uint64
+	// MemoryLimit is the number of bytes the program may allocate (-m). The ZFS default is used if 0.
+	MemoryLimit uint64
+	// ReadOnly runs the program without syncing a transaction group, so it can only read (-n).
+	ReadOnly bool
+}
+
+func (o ChannelProgramOptions) args() []string {
+	args := []string{"-j"}
+	if o.ReadOnly {
+		args = append(args, "-n")
+	}
+	if o.InstructionLimit != 0 {
+		args = append(args, "-t", strconv.FormatUint(o.InstructionLimit, 10))
+	}
+	if o.MemoryLimit != 0 {
+		args = append(args, "-m", strconv.FormatUint(o.MemoryLimit, 10))
+	}
+	return args
+}
+
+// ChannelProgramResult is the value returned by a channel program.
+type ChannelProgramResult struct {
+	// Return is the JSON encoding of the returned value, null if the program returned nothing.
+	Return json.RawMessage `json:"return"`
+}
+
+// Decode decodes the returned value into v, as json.Unmarshal does.
+func (r *ChannelProgramResult) Decode(v interface{}) error {
+	if len(r.Return) == 0 {
+		return json.Unmarshal([]byte("null"), v)
+	}
+	return json.Unmarshal(r.Return, v)
+}
+
+// RunChannelProgram runs the Lua channel program on the receiving pool with the default limits.
+// All of the program's changes happen atomically in a single transaction group.
+//
+// More information regarding channel programs can be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/8/zfs-program.8.html
+func (z *Zpool) RunChannelProgram(lua string, args ...string) (*ChannelProgramResult, error) {
+	return z.RunChannelProgramWithOptions(ChannelProgramOptions{}, lua, args...)
+}
+
+// RunChannelProgramWithOptions runs the Lua channel program on the receiving pool.
+// The args are passed to the program, which receives them in argv.
+func (z *Zpool) RunChannelProgramWithOptions(opts ChannelProgramOptions, lua string, args ...string) (*ChannelProgramResult, error) {
+	return runChannelProgram(z.Name, opts, lua, args...)
+}
+
+func runChannelProgram(pool string, opts ChannelProgramOptions, lua string, args ...string) (*ChannelProgramResult, error) {
+	cli := append([]string{"program"}, opts.args()...)
+	// the program is read from standard input
+	cli = append(cli, pool, "-")
+	cli = append(cli, args...)
+
+	var stdout bytes.Buffer
+	c := command{Command: "zfs", Stdin: strings.NewReader(lua), Stdout: &stdout}
+	if _, err := c.Run(cli...); err != nil {
+		return nil, err
+	}
+	return parseChannelProgramOutput(stdout.Bytes())
+}
+
+func parseChannelProgramOutput(out []byte) (*ChannelProgramResult, error) {
+	r := &ChannelProgramResult{}
+	if len(bytes.TrimSpace(out)) == 0 {
+		return r, nil
+	}
+	if err := json.Unmarshal(out, r); err != nil {
+		return nil, fmt.Errorf("invalid channel program output: %v", err)
+	}
+	return r, nil
+}
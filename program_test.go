@@ -0,0 +1,47 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChannelProgramOptionsArgs(t *testing.T) {
+	got := ChannelProgramOptions{InstructionLimit: 1000, MemoryLimit: 1 << 20, ReadOnly: true}.args()
+	want := []string{"-j", "-n", "-t", "1000", "-m", "1048576"}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %v, got: %v", want, got)
+	}
+	if got := (ChannelProgramOptions{}).args(); !reflect.DeepEqual([]string{"-j"}, got) {
+		t.Fatalf("wanted: [-j], got: %v", got)
+	}
+}
+
+func TestParseChannelProgramOutput(t *testing.T) {
+	r, err := parseChannelProgramOutput([]byte(`{"return": {"destroyed": ["tank/a@1", "tank/a@2"], "count": 2}}` + "\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got struct {
+		Destroyed []string `json:"destroyed"`
+		Count     int      `json:"count"`
+	}
+	if err := r.Decode(&got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual([]string{"tank/a@1", "tank/a@2"}, got.Destroyed) || got.Count != 2 {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+
+	r, err = parseChannelProgramOutput(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var v interface{}
+	if err := r.Decode(&v); err != nil || v != nil {
+		t.Fatalf("wanted a nil result, got: %v, %v", v, err)
+	}
+
+	if _, err := parseChannelProgramOutput([]byte("Channel program execution failed")); err == nil {
+		t.Fatal("expected an error for invalid output")
+	}
+}
@@ -0,0 +1,244 @@
+package zfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// destroyOlderThanProgram destroys the snapshots of a dataset, and optionally its descendents, created before a cutoff.
+// It returns a table mapping the snapshots to 0, or the error code of their destruction.
+const destroyOlderThanProgram = `
+args = ...
+argv = args["argv"]
+root = argv[1]
+cutoff = tonumber(argv[2])
+recursive = argv[3] == "true"
+dryrun = argv[4] == "true"
+
+candidates = {}
+
+function collect(ds)
+	for snap in zfs.list.snapshots(ds) do
+		if zfs.get_prop(snap, "creation") < cutoff then
+			candidates[#candidates + 1] = snap
+		end
+	end
+	if recursive then
+		for child in zfs.list.children(ds) do
+			collect(child)
+		end
+	end
+end
+
+collect(root)
+
+results = {}
+for _, snap in ipairs(candidates) do
+	if dryrun then
+		results[snap] = zfs.check.destroy(snap)
+	else
+		results[snap] = zfs.sync.destroy(snap)
+	end
+end
+return results
+`
+
+// listSnapshotPropertiesProgram returns a table mapping the snapshots of a dataset to tables of the requested properties.
+const listSnapshotPropertiesProgram = `
+args = ...
+argv = args["argv"]
+
+results = {}
+for snap in zfs.list.snapshots(argv[1]) do
+	local props = {}
+	for i = 2, #argv do
+		props[argv[i]] = zfs.get_prop(snap, argv[i])
+	end
+	results[snap] = props
+end
+return results
+`
+
+// snapshotGroupProgram snapshots the datasets in argv[2:] with the name argv[1], if all of them can be snapshotted.
+// It returns a table mapping the datasets that cannot be snapshotted to their error code, empty on success.
+const snapshotGroupProgram = `
+args = ...
+argv = args["argv"]
+name = argv[1]
+
+failed = {}
+for i = 2, #argv do
+	local err = zfs.check.snapshot(argv[i] .. "@" .. name)
+	if err ~= 0 then
+		failed[argv[i]] = err
+	end
+end
+if next(failed) == nil then
+	for i = 2, #argv do
+		local err = zfs.sync.snapshot(argv[i] .. "@" .. name)
+		if err ~= 0 then
+			failed[argv[i]] = err
+		end
+	end
+end
+return failed
+`
+
+// poolOf returns the name of the pool containing the named dataset.
+func poolOf(name string) string {
+	if i := strings.IndexAny(name, "/@#"); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
+
+// decodeNumbers decodes the returned value into v, keeping numbers as json.Number so 64-bit values survive.
+func (r *ChannelProgramResult) decodeNumbers(v interface{}) error {
+	if len(r.Return) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(r.Return))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// DestroySnapshotsOlderThan atomically destroys all snapshots of the dataset created before cutoff,
+// including those of all descendent datasets if recursive is set, in a single channel program.
+// With dryRun, the snapshots are only checked for whether they could be destroyed.
+// It returns the names of the destroyed snapshots, and an error listing the snapshots that could not be destroyed.
+func DestroySnapshotsOlderThan(dataset string, cutoff time.Time, recursive, dryRun bool) ([]string, error) {
+	r, err := runChannelProgram(poolOf(dataset), ChannelProgramOptions{}, destroyOlderThanProgram,
+		dataset, strconv.FormatInt(cutoff.Unix(), 10), strconv.FormatBool(recursive), strconv.FormatBool(dryRun))
+	if err != nil {
+		return nil, err
+	}
+	return parseDestroyResults(r)
+}
+
+func parseDestroyResults(r *ChannelProgramResult) ([]string, error) {
+	var results map[string]int
+	if err := r.decodeNumbers(&results); err != nil {
+		return nil, err
+	}
+	var destroyed, failed []string
+	for snap, code := range results {
+		if code == 0 {
+			destroyed = append(destroyed, snap)
+		} else {
+			failed = append(failed, fmt.Sprintf("%s (error %d)", snap, code))
+		}
+	}
+	sort.Strings(destroyed)
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return destroyed, fmt.Errorf("could not destroy %s", strings.Join(failed, ", "))
+	}
+	return destroyed, nil
+}
+
+// ListSnapshotProperties returns the given properties of all snapshots of the dataset, keyed by snapshot name,
+// using a single read-only channel program instead of a `zfs get` per snapshot.
+func ListSnapshotProperties(dataset string, props ...string) (map[string]map[string]string, error) {
+	if len(props) == 0 {
+		return nil, errors.New("no properties given")
+	}
+	r, err := runChannelProgram(poolOf(dataset), ChannelProgramOptions{ReadOnly: true}, listSnapshotPropertiesProgram,
+		append([]string{dataset}, props...)...)
+	if err != nil {
+		return nil, err
+	}
+	return parseSnapshotProperties(r)
+}
+
+func parseSnapshotProperties(r *ChannelProgramResult) (map[string]map[string]string, error) {
+	var results map[string]map[string]interface{}
+	if err := r.decodeNumbers(&results); err != nil {
+		return nil, err
+	}
+	snapshots := make(map[string]map[string]string, len(results))
+	for snap, props := range results {
+		values := make(map[string]string, len(props))
+		for k, v := range props {
+			values[k] = fmt.Sprint(v)
+		}
+		snapshots[snap] = values
+	}
+	return snapshots, nil
+}
+
+// SnapshotGroup snapshots each of the datasets with the given snapshot name, as one consistent group,
+// and returns the names of the snapshots.
+//
+// The snapshots of the datasets of each pool are taken atomically, in a single channel program that first checks
+// that all of them can be taken, falling back to a single `zfs snapshot` of all of them where channel programs
+// cannot be run, e.g. by delegated users. Pools are snapshotted one after another, so datasets on different pools
+// are not snapshotted at the same instant; applications spanning pools should quiesce writes meanwhile.
+// If any pool fails, the snapshots already taken on the others are destroyed again, so either all datasets
+// are snapshotted, or none.
+func SnapshotGroup(datasets []string, name string) ([]string, error) {
+	var pools []string
+	byPool := map[string][]string{}
+	for _, ds := range datasets {
+		pool := poolOf(ds)
+		if _, ok := byPool[pool]; !ok {
+			pools = append(pools, pool)
+		}
+		byPool[pool] = append(byPool[pool], ds)
+	}
+
+	var taken []string
+	for _, pool := range pools {
+		snapshots, err := snapshotPool(pool, byPool[pool], name)
+		if err != nil {
+			for _, snap := range taken {
+				_ = zfs("destroy", snap)
+			}
+			return nil, err
+		}
+		taken = append(taken, snapshots...)
+	}
+	return taken, nil
+}
+
+// snapshotPool atomically snapshots datasets, all of which are on pool.
+func snapshotPool(pool string, datasets []string, name string) ([]string, error) {
+	snapshots := make([]string, len(datasets))
+	for i, ds := range datasets {
+		snapshots[i] = ds + "@" + name
+	}
+
+	r, err := runChannelProgram(pool, ChannelProgramOptions{}, snapshotGroupProgram, append([]string{name}, datasets...)...)
+	var e *Error
+	if errors.As(err, &e) {
+		// the program could not be run at all
+		return snapshots, zfs(append([]string{"snapshot"}, snapshots...)...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return snapshots, parseSnapshotGroupResults(r)
+}
+
+func parseSnapshotGroupResults(r *ChannelProgramResult) error {
+	var results map[string]int
+	if err := r.decodeNumbers(&results); err != nil {
+		return err
+	}
+	var failed []string
+	for ds, code := range results {
+		if code != 0 {
+			failed = append(failed, fmt.Sprintf("%s (error %d)", ds, code))
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("could not snapshot %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPoolOf(t *testing.T) {
+	for name, want := range map[string]string{
+		"tank":          "tank",
+		"tank/a/b":      "tank",
+		"tank@snap":     "tank",
+		"tank/a#mark":   "tank",
+		"tank/a/b@snap": "tank",
+	} {
+		if got := poolOf(name); got != want {
+			t.Fatalf("wanted: %v, got: %v", want, got)
+		}
+	}
+}
+
+func TestParseDestroyResults(t *testing.T) {
+	r := &ChannelProgramResult{Return: []byte(`{"tank/a@2": 0, "tank/a@1": 0, "tank/a/b@1": 16}`)}
+	destroyed, err := parseDestroyResults(r)
+	if err == nil || err.Error() != "could not destroy tank/a/b@1 (error 16)" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"tank/a@1", "tank/a@2"}; !reflect.DeepEqual(want, destroyed) {
+		t.Fatalf("wanted: %v, got: %v", want, destroyed)
+	}
+
+	destroyed, err = parseDestroyResults(&ChannelProgramResult{Return: []byte(`{}`)})
+	if err != nil || len(destroyed) != 0 {
+		t.Fatalf("wanted nothing destroyed, got: %v, %v", destroyed, err)
+	}
+}
+
+func TestParseSnapshotProperties(t *testing.T) {
+	r := &ChannelProgramResult{Return: []byte(`{
+		"tank/a@1": {"guid": 18446744073709551615, "creation": 1760486400, "type": "snapshot"}
+	}`)}
+	got, err := parseSnapshotProperties(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]map[string]string{
+		"tank/a@1": {"guid": "18446744073709551615", "creation": "1760486400", "type": "snapshot"},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %v, got: %v", want, got)
+	}
+}
+
+func TestParseSnapshotGroupResults(t *testing.T) {
+	r := &ChannelProgramResult{Return: []byte(`{"tank/b": 17, "tank/a": 2}`)}
+	if err := parseSnapshotGroupResults(r); err == nil || err.Error() != "could not snapshot tank/a (error 2), tank/b (error 17)" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := parseSnapshotGroupResults(&ChannelProgramResult{Return: []byte(`{}`)}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package zfs
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultProgressInterval is used when a progress callback is given without an interval.
+const defaultProgressInterval = time.Second
+
+// Progress is a snapshot of the state of a running stream transfer.
+// In JSON, Elapsed and ETA are encoded in nanoseconds.
+type Progress struct {
+	// Bytes is the number of stream bytes transferred so far.
+	Bytes uint64 `json:"bytes"`
+	// Total is the expected size of the stream, or 0 if it is unknown.
+	Total uint64 `json:"total"`
+	// Elapsed is the time since the transfer started.
+	Elapsed time.Duration `json:"elapsed"`
+	// Throughput is the average transfer rate in bytes per second.
+	Throughput float64 `json:"throughput"`
+	// ETA is the estimated remaining time, or 0 if it is unknown.
+	ETA time.Duration `json:"eta"`
+	// Done is set on the final report, once the transfer has finished.
+	Done bool `json:"done"`
+}
+
+// ProgressFunc receives periodic progress reports of a stream transfer.
+// It is called from a separate goroutine and must not block for long.
+type ProgressFunc func(Progress)
+
+// progressTracker counts transferred bytes and periodically reports them to a ProgressFunc.
+type progressTracker struct {
+	n     uint64 // accessed atomically, keep first for alignment
+	total uint64
+	fn    ProgressFunc
+	start time.Time
+	quit  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func startProgress(fn ProgressFunc, interval time.Duration, total uint64) *progressTracker {
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+	p := &progressTracker{
+		total: total,
+		fn:    fn,
+		start: time.Now(),
+		quit:  make(chan struct{}),
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.fn(p.report(false))
+			case <-p.quit:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+func (p *progressTracker) add(n int) {
+	atomic.AddUint64(&p.n, uint64(n))
+}
+
+func (p *progressTracker) report(done bool) Progress {
+	pr := Progress{
+		Bytes:   atomic.LoadUint64(&p.n),
+		Total:   p.total,
+		Elapsed: time.Since(p.start),
+		Done:    done,
+	}
+	if secs := pr.Elapsed.Seconds(); secs > 0 {
+		pr.Throughput = float64(pr.Bytes) / secs
+	}
+	if !done && pr.Total > pr.Bytes && pr.Throughput > 0 {
+		pr.ETA = time.Duration(float64(pr.Total-pr.Bytes) / pr.Throughput * float64(time.Second))
+	}
+	return pr
+}
+
+// stop ends the periodic reports and delivers the final one.
+func (p *progressTracker) stop() {
+	close(p.quit)
+	p.wg.Wait()
+	p.fn(p.report(true))
+}
+
+type progressWriter struct {
+	w io.Writer
+	p *progressTracker
+}
+
+func (pw *progressWriter) Write(b []byte) (int, error) {
+	n, err := pw.w.Write(b)
+	pw.p.add(n)
+	return n, err
+}
+
+type progressReader struct {
+	r io.Reader
+	p *progressTracker
+}
+
+func (pr *progressReader) Read(b []byte) (int, error) {
+	n, err := pr.r.Read(b)
+	pr.p.add(n)
+	return n, err
+}
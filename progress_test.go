@@ -0,0 +1,48 @@
+package zfs
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestProgressWriter(t *testing.T) {
+	var reports []Progress
+	p := startProgress(func(pr Progress) { reports = append(reports, pr) }, time.Hour, 2048)
+
+	var buf bytes.Buffer
+	w := &progressWriter{w: &buf, p: p}
+	if _, err := io.Copy(w, bytes.NewReader(make([]byte, 1024))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pr := p.report(false)
+	if pr.Bytes != 1024 || pr.Total != 2048 {
+		t.Fatalf("wanted 1024 of 2048 bytes, got: %+v", pr)
+	}
+	if pr.ETA <= 0 {
+		t.Fatalf("wanted an ETA, got: %+v", pr)
+	}
+
+	p.stop()
+	if len(reports) != 1 || !reports[0].Done || reports[0].Bytes != 1024 || reports[0].ETA != 0 {
+		t.Fatalf("wanted a single final report, got: %+v", reports)
+	}
+}
+
+func TestProgressReader(t *testing.T) {
+	var final Progress
+	p := startProgress(func(pr Progress) { final = pr }, 0, 0)
+
+	r := &progressReader{r: bytes.NewReader(make([]byte, 4096)), p: p}
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p.stop()
+
+	if !final.Done || final.Bytes != 4096 || final.ETA != 0 {
+		t.Fatalf("wanted final report of 4096 bytes, got: %+v", final)
+	}
+}
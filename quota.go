@@ -0,0 +1,204 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// QuotaKind is the kind of limit a QuotaAlert is about.
+type QuotaKind string
+
+// Quota kinds.
+const (
+	// QuotaKindQuota compares the space used by a file system and its descendants to its quota.
+	QuotaKindQuota QuotaKind = "quota"
+	// QuotaKindRefQuota compares the space referenced by a file system to its refquota.
+	QuotaKindRefQuota QuotaKind = "refquota"
+	// QuotaKindUser compares the space used by a user in a file system to their userquota.
+	QuotaKindUser QuotaKind = "userquota"
+	// QuotaKindGroup compares the space used by a group in a file system to its groupquota.
+	QuotaKindGroup QuotaKind = "groupquota"
+)
+
+// QuotaThreshold is a share of a quota, in percent.
+// It is exceeded once usage reaches Percent, and cleared once usage drops below Percent minus Hysteresis.
+type QuotaThreshold struct {
+	Percent    uint64 `json:"percent"`
+	Hysteresis uint64 `json:"hysteresis"`
+}
+
+// QuotaAlert reports usage crossing a QuotaThreshold.
+type QuotaAlert struct {
+	Dataset string    `json:"dataset"`
+	Kind    QuotaKind `json:"kind"`
+	// Subject is the user or group of userquota and groupquota alerts, empty otherwise.
+	Subject   string         `json:"subject,omitempty"`
+	Used      uint64         `json:"used"`
+	Limit     uint64         `json:"limit"`
+	Threshold QuotaThreshold `json:"threshold"`
+	// Percent is the current usage in percent of Limit.
+	Percent uint64 `json:"percent"`
+	// Exceeded is true when the threshold was exceeded, and false when it was cleared again.
+	Exceeded bool `json:"exceeded"`
+}
+
+// QuotaWatcher polls the usage of file systems against their quotas, and alerts when thresholds are crossed,
+// so that tenants can be warned before their writes start failing.
+type QuotaWatcher struct {
+	// Datasets are the file systems to watch, all of them if empty.
+	Datasets []string
+	// Recursive watches the descendants of Datasets as well.
+	Recursive bool
+	// UserQuotas watches the userquota and groupquota entries of the file systems as well.
+	// This runs `zfs userspace` and `zfs groupspace` for each file system on every poll.
+	UserQuotas bool
+	Thresholds []QuotaThreshold
+	// Interval is the time between polls.
+	Interval time.Duration
+	// OnAlert is called for every threshold crossed.
+	OnAlert func(QuotaAlert)
+
+	// state tracks the thresholds currently exceeded, by quotaKey.
+	state thresholdState
+	// list is replaced in tests.
+	list func(ctx context.Context, arg ...string) ([][]string, error)
+}
+
+// quotaKey identifies a single quota.
+type quotaKey struct {
+	dataset string
+	kind    QuotaKind
+	subject string
+}
+
+// quotaUsage is the usage of a single quota.
+type quotaUsage struct {
+	quotaKey
+	used, limit uint64
+}
+
+// Run polls the quotas every interval until ctx is cancelled, and returns ctx.Err().
+// Thresholds already exceeded on the first poll are alerted right away.
+// Errors retrieving the usage are returned immediately.
+func (w *QuotaWatcher) Run(ctx context.Context) error {
+	if w.OnAlert == nil {
+		return errors.New("an OnAlert callback is required")
+	}
+	if w.Interval <= 0 {
+		return errors.New("a positive Interval is required")
+	}
+	for _, t := range w.Thresholds {
+		if err := checkHysteresis(t.Percent, t.Hysteresis); err != nil {
+			return err
+		}
+	}
+
+	return pollEvery(ctx, w.Interval, func() error {
+		usage, err := w.poll(ctx)
+		if err != nil {
+			return err
+		}
+		for _, u := range usage {
+			w.check(u)
+		}
+		return nil
+	})
+}
+
+// poll returns the usage of all quotas that are set.
+func (w *QuotaWatcher) poll(ctx context.Context) ([]quotaUsage, error) {
+	list := w.list
+	if list == nil {
+		list = func(ctx context.Context, arg ...string) ([][]string, error) {
+			c := command{Command: "zfs", Ctx: ctx}
+			return c.Run(arg...)
+		}
+	}
+
+	args := []string{"list", "-Hp", "-t", DatasetFilesystem, "-o", spaceProperties}
+	if w.Recursive {
+		args = append(args, "-r")
+	}
+	out, err := list(ctx, append(args, w.Datasets...)...)
+	if err != nil {
+		return nil, err
+	}
+	space, err := parseSpaceUsage(out, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	var usage []quotaUsage
+	for _, s := range space {
+		if s.Quota > 0 {
+			usage = append(usage, quotaUsage{quotaKey{s.Dataset, QuotaKindQuota, ""}, s.Used, s.Quota})
+		}
+		if s.RefQuota > 0 {
+			usage = append(usage, quotaUsage{quotaKey{s.Dataset, QuotaKindRefQuota, ""}, s.Referenced, s.RefQuota})
+		}
+		if !w.UserQuotas {
+			continue
+		}
+		for _, kind := range []QuotaKind{QuotaKindUser, QuotaKindGroup} {
+			cmd := "userspace"
+			if kind == QuotaKindGroup {
+				cmd = "groupspace"
+			}
+			out, err := list(ctx, cmd, "-Hp", "-o", "name,used,quota", s.Dataset)
+			if err != nil {
+				return nil, err
+			}
+			entries, err := parseUserspace(out, s.Dataset, kind)
+			if err != nil {
+				return nil, err
+			}
+			usage = append(usage, entries...)
+		}
+	}
+	return usage, nil
+}
+
+// parseUserspace parses the output of `zfs userspace -Hp -o name,used,quota`, skipping entries without a quota.
+func parseUserspace(lines [][]string, dataset string, kind QuotaKind) ([]quotaUsage, error) {
+	var usage []quotaUsage
+	for _, line := range lines {
+		if len(line) != 3 {
+			return nil, errors.New("output does not match what is expected on this platform")
+		}
+		if line[2] == "none" {
+			continue
+		}
+		u := quotaUsage{quotaKey: quotaKey{dataset, kind, line[0]}}
+		if err := setUint(&u.used, line[1]); err != nil {
+			return nil, err
+		}
+		if err := setUint(&u.limit, line[2]); err != nil {
+			return nil, err
+		}
+		if u.limit > 0 {
+			usage = append(usage, u)
+		}
+	}
+	return usage, nil
+}
+
+func (w *QuotaWatcher) check(u quotaUsage) {
+	percent := percentOf(u.used, u.limit)
+	for i, t := range w.Thresholds {
+		exceeded, changed := w.state.update(u.quotaKey, i, percent, t.Percent, t.Hysteresis)
+		if !changed {
+			continue
+		}
+		w.OnAlert(QuotaAlert{
+			Dataset:   u.dataset,
+			Kind:      u.kind,
+			Subject:   u.subject,
+			Used:      u.used,
+			Limit:     u.limit,
+			Threshold: t,
+			Percent:   percent,
+			Exceeded:  exceeded,
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package zfs
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQuotaWatcher(t *testing.T) {
+	warn := QuotaThreshold{Percent: 80, Hysteresis: 5}
+	full := QuotaThreshold{Percent: 95}
+
+	// used of tank/a, its refquota usage, and the usage of alice
+	var used, refer, alice string
+	var calls []string
+	var alerts []QuotaAlert
+	w := &QuotaWatcher{
+		Datasets:   []string{"tank"},
+		Recursive:  true,
+		UserQuotas: true,
+		Thresholds: []QuotaThreshold{warn, full},
+		OnAlert:    func(a QuotaAlert) { alerts = append(alerts, a) },
+		list: func(_ context.Context, arg ...string) ([][]string, error) {
+			calls = append(calls, strings.Join(arg, " "))
+			switch arg[0] {
+			case "list":
+				return [][]string{
+					{"tank", "500", "1000", "100", "0", "0"},
+					{"tank/a", used, "100", refer, "1000", "500"},
+				}, nil
+			case "userspace":
+				if arg[len(arg)-1] == "tank/a" {
+					return [][]string{{"alice", alice, "100"}, {"bob", "5000", "none"}}, nil
+				}
+			}
+			return nil, nil
+		},
+	}
+
+	poll := func(u, r, a string) {
+		used, refer, alice = u, r, a
+		usage, err := w.poll(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, u := range usage {
+			w.check(u)
+		}
+	}
+
+	poll("100", "100", "10")
+	if alerts != nil {
+		t.Fatalf("wanted: no alerts, got: %+v", alerts)
+	}
+	poll("810", "480", "96")
+	poll("790", "460", "96")
+	poll("740", "400", "0")
+
+	want := []QuotaAlert{
+		{Dataset: "tank/a", Kind: QuotaKindQuota, Used: 810, Limit: 1000, Threshold: warn, Percent: 81, Exceeded: true},
+		{Dataset: "tank/a", Kind: QuotaKindRefQuota, Used: 480, Limit: 500, Threshold: warn, Percent: 96, Exceeded: true},
+		{Dataset: "tank/a", Kind: QuotaKindRefQuota, Used: 480, Limit: 500, Threshold: full, Percent: 96, Exceeded: true},
+		{Dataset: "tank/a", Kind: QuotaKindUser, Subject: "alice", Used: 96, Limit: 100, Threshold: warn, Percent: 96, Exceeded: true},
+		{Dataset: "tank/a", Kind: QuotaKindUser, Subject: "alice", Used: 96, Limit: 100, Threshold: full, Percent: 96, Exceeded: true},
+		{Dataset: "tank/a", Kind: QuotaKindRefQuota, Used: 460, Limit: 500, Threshold: full, Percent: 92, Exceeded: false},
+		{Dataset: "tank/a", Kind: QuotaKindQuota, Used: 740, Limit: 1000, Threshold: warn, Percent: 74, Exceeded: false},
+		{Dataset: "tank/a", Kind: QuotaKindUser, Subject: "alice", Used: 0, Limit: 100, Threshold: warn, Percent: 0, Exceeded: false},
+		{Dataset: "tank/a", Kind: QuotaKindUser, Subject: "alice", Used: 0, Limit: 100, Threshold: full, Percent: 0, Exceeded: false},
+	}
+	if !reflect.DeepEqual(want, alerts) {
+		t.Fatalf("wanted: %+v, got: %+v", want, alerts)
+	}
+
+	wantCalls := []string{
+		"list -Hp -t filesystem -o name,used,available,referenced,quota,refquota -r tank",
+		"userspace -Hp -o name,used,quota tank",
+		"groupspace -Hp -o name,used,quota tank",
+		"userspace -Hp -o name,used,quota tank/a",
+		"groupspace -Hp -o name,used,quota tank/a",
+	}
+	if !reflect.DeepEqual(wantCalls, calls[:5]) {
+		t.Fatalf("wanted: %v, got: %v", wantCalls, calls[:5])
+	}
+}
@@ -0,0 +1,99 @@
+package zfs
+
+import (
+	"io"
+	"time"
+)
+
+// tokenBucket limits throughput to rate tokens (bytes) per second, allowing bursts of up to one second worth of tokens.
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	sleep  func(time.Duration)
+}
+
+func newTokenBucket(bytesPerSecond uint64) *tokenBucket {
+	return &tokenBucket{
+		rate:   float64(bytesPerSecond),
+		tokens: float64(bytesPerSecond),
+		last:   time.Now(),
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+}
+
+// burst is the largest number of tokens that can be taken at once.
+func (b *tokenBucket) burst() int {
+	if b.rate < 1 {
+		return 1
+	}
+	return int(b.rate)
+}
+
+// take blocks until n tokens are available and removes them from the bucket.
+func (b *tokenBucket) take(n int) {
+	now := b.now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if burst := float64(b.burst()); b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+
+	b.tokens -= float64(n)
+	if b.tokens < 0 {
+		// the deficit is refilled by the time the sleep ends, which take accounts for on the next call
+		b.sleep(time.Duration(-b.tokens / b.rate * float64(time.Second)))
+	}
+}
+
+type rateLimitedWriter struct {
+	w io.Writer
+	b *tokenBucket
+}
+
+// RateLimitWriter returns a writer that writes to w at no more than bytesPerSecond on average.
+// It can be used to throttle streams, e.g. as the destination of Dataset.SendTo.
+func RateLimitWriter(w io.Writer, bytesPerSecond uint64) io.Writer {
+	return &rateLimitedWriter{w: w, b: newTokenBucket(bytesPerSecond)}
+}
+
+func (rw *rateLimitedWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		chunk := len(p)
+		if burst := rw.b.burst(); chunk > burst {
+			chunk = burst
+		}
+		rw.b.take(chunk)
+
+		n, err := rw.w.Write(p[:chunk])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[chunk:]
+	}
+	return written, nil
+}
+
+type rateLimitedReader struct {
+	r io.Reader
+	b *tokenBucket
+}
+
+// RateLimitReader returns a reader that reads from r at no more than bytesPerSecond on average.
+// It can be used to throttle streams, e.g. as the source of ReceiveFrom.
+func RateLimitReader(r io.Reader, bytesPerSecond uint64) io.Reader {
+	return &rateLimitedReader{r: r, b: newTokenBucket(bytesPerSecond)}
+}
+
+func (rr *rateLimitedReader) Read(p []byte) (int, error) {
+	if burst := rr.b.burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := rr.r.Read(p)
+	rr.b.take(n)
+	return n, err
+}
@@ -0,0 +1,65 @@
+package zfs
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// fakeClock advances only when slept on.
+type fakeClock struct {
+	t     time.Time
+	slept time.Duration
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) sleep(d time.Duration) {
+	c.slept += d
+	c.t = c.t.Add(d)
+}
+
+func newFakeBucket(bytesPerSecond uint64) (*tokenBucket, *fakeClock) {
+	c := &fakeClock{t: time.Unix(0, 0)}
+	b := newTokenBucket(bytesPerSecond)
+	b.last = c.t
+	b.now = c.now
+	b.sleep = c.sleep
+	return b, c
+}
+
+func TestRateLimitWriter(t *testing.T) {
+	b, c := newFakeBucket(1000)
+	var buf bytes.Buffer
+	w := &rateLimitedWriter{w: &buf, b: b}
+
+	// the first second worth of data is a burst, the rest is throttled
+	n, err := w.Write(make([]byte, 5000))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 5000 || buf.Len() != 5000 {
+		t.Fatalf("wanted 5000 bytes written, got: %d (%d)", n, buf.Len())
+	}
+	if c.slept != 4*time.Second {
+		t.Fatalf("wanted 4s of throttling, got: %v", c.slept)
+	}
+}
+
+func TestRateLimitReader(t *testing.T) {
+	b, c := newFakeBucket(1000)
+	r := &rateLimitedReader{r: bytes.NewReader(make([]byte, 3000)), b: b}
+
+	n, err := io.Copy(ioutil.Discard, r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 3000 {
+		t.Fatalf("wanted 3000 bytes read, got: %d", n)
+	}
+	if c.slept != 2*time.Second {
+		t.Fatalf("wanted 2s of throttling, got: %v", c.slept)
+	}
+}
@@ -0,0 +1,157 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// ReceiveOptions controls how `zfs receive` applies a stream.
+// The zero value receives the stream into exactly the named target.
+//
+// The available options are described in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/8/zfs-receive.8.html
+type ReceiveOptions struct {
+	// Force rolls back the target to its most recent snapshot before receiving (-F).
+	Force bool
+	// NoMount prevents the received file system from being mounted (-u).
+	NoMount bool
+	// Resumable saves the partially received state on interruption, so the transfer can be resumed (-s).
+	Resumable bool
+	// DiscardFirstName discards the pool name of the sent snapshot when deriving the target name (-d).
+	DiscardFirstName bool
+	// UseLastName uses only the last element of the sent snapshot's name when deriving the target name (-e).
+	UseLastName bool
+	// Properties are set on the received dataset, overriding any received values (-o).
+	// This avoids racing a `zfs set` after the receive, e.g. for mountpoint, readonly or canmount.
+	Properties map[string]string
+	// ExcludeProperties are not applied from the stream, so the received dataset inherits them instead (-x).
+	ExcludeProperties []string
+
+	// Progress, if set, is called every ProgressInterval (one second by default) while the stream is received,
+	// and once more when it has finished. The total size of the stream is not known.
+	Progress         ProgressFunc
+	ProgressInterval time.Duration
+	// RateLimit, if set, caps the stream to this many bytes per second on average.
+	RateLimit uint64
+}
+
+// args returns the zfs receive command-line arguments for the options.
+func (o ReceiveOptions) args() ([]string, error) {
+	if o.DiscardFirstName && o.UseLastName {
+		return nil, errors.New("DiscardFirstName and UseLastName are mutually exclusive")
+	}
+
+	for _, prop := range o.ExcludeProperties {
+		if _, ok := o.Properties[prop]; ok {
+			return nil, fmt.Errorf("property %s cannot be both overridden and excluded", prop)
+		}
+	}
+
+	var args []string
+	if o.Force {
+		args = append(args, "-F")
+	}
+	if o.NoMount {
+		args = append(args, "-u")
+	}
+	if o.Resumable {
+		args = append(args, "-s")
+	}
+	if o.DiscardFirstName {
+		args = append(args, "-d")
+	}
+	if o.UseLastName {
+		args = append(args, "-e")
+	}
+	args = append(args, sortedPropsSlice(o.Properties)...)
+	for _, prop := range o.ExcludeProperties {
+		args = append(args, "-x", prop)
+	}
+	return args, nil
+}
+
+// ReceiveFrom receives a ZFS stream read from r into the target dataset.
+// The receive is aborted when ctx is cancelled, in which case the returned error wraps ctx.Err().
+// The returned dataset is the target as named, which is the parent file system when DiscardFirstName or UseLastName is set.
+func ReceiveFrom(ctx context.Context, r io.Reader, target string, opts ReceiveOptions) (*Dataset, error) {
+	flags, err := opts.args()
+	if err != nil {
+		return nil, err
+	}
+
+	args := append([]string{"receive"}, flags...)
+	args = append(args, target)
+
+	if opts.RateLimit > 0 {
+		r = RateLimitReader(r, opts.RateLimit)
+	}
+	if opts.Progress != nil {
+		p := startProgress(opts.Progress, opts.ProgressInterval, 0)
+		defer p.stop()
+		r = &progressReader{r: r, p: p}
+	}
+
+	c := command{Command: "zfs", Stdin: r, Ctx: ctx}
+	if _, err := c.Run(args...); err != nil {
+		return nil, err
+	}
+	return GetDataset(target)
+}
+
+// sortedPropsSlice is like propsSlice, but orders the properties by name so the generated arguments are stable.
+func sortedPropsSlice(properties map[string]string) []string {
+	keys := make([]string, 0, len(properties))
+	for k := range properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	args := make([]string, 0, len(properties)*2)
+	for _, k := range keys {
+		args = append(args, "-o", k+"="+properties[k])
+	}
+	return args
+}
+
+// ReceiveResumeToken returns the receive_resume_token of the receiving dataset.
+// The token is only set after a resumable receive (see ReceiveOptions.Resumable) was interrupted,
+// otherwise an empty string is returned.
+func (d *Dataset) ReceiveResumeToken() (string, error) {
+	token, err := getProperty(d.Name, "receive_resume_token")
+	if err != nil {
+		return "", err
+	}
+	if token == "-" {
+		return "", nil
+	}
+	return token, nil
+}
+
+// AbortResumableReceive discards the partially received state of an interrupted resumable receive into dataset.
+func AbortResumableReceive(dataset string) error {
+	return zfs("receive", "-A", dataset)
+}
+
+// ReceivedProperties returns the property values the receiving dataset received from a send stream,
+// e.g. from a replication stream package, regardless of any local overrides.
+func (d *Dataset) ReceivedProperties() (map[string]string, error) {
+	out, err := zfsOutput("get", "-Hp", "-o", "property,received", "all", d.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	props := make(map[string]string, len(out))
+	for _, line := range out {
+		if len(line) != 2 {
+			return nil, errors.New("output does not match what is expected on this platform")
+		}
+		if line[1] != "-" {
+			props[line[0]] = line[1]
+		}
+	}
+	return props, nil
+}
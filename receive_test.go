@@ -0,0 +1,47 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReceiveOptionsArgs(t *testing.T) {
+	for name, test := range map[string]struct {
+		opts ReceiveOptions
+		want []string
+	}{
+		"zero value": {
+			opts: ReceiveOptions{},
+			want: nil,
+		},
+		"flags": {
+			opts: ReceiveOptions{Force: true, NoMount: true, Resumable: true, UseLastName: true},
+			want: []string{"-F", "-u", "-s", "-e"},
+		},
+		"properties": {
+			opts: ReceiveOptions{DiscardFirstName: true, Properties: map[string]string{"readonly": "on", "canmount": "off"}},
+			want: []string{"-d", "-o", "canmount=off", "-o", "readonly=on"},
+		},
+		"excluded properties": {
+			opts: ReceiveOptions{Properties: map[string]string{"readonly": "on"}, ExcludeProperties: []string{"mountpoint", "sharenfs"}},
+			want: []string{"-o", "readonly=on", "-x", "mountpoint", "-x", "sharenfs"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, err := test.opts.args()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(test.want, got) {
+				t.Fatalf("wanted: %v, got: %v", test.want, got)
+			}
+		})
+	}
+
+	if _, err := (ReceiveOptions{DiscardFirstName: true, UseLastName: true}).args(); err == nil {
+		t.Fatal("wanted error for -d with -e, got nil")
+	}
+	if _, err := (ReceiveOptions{Properties: map[string]string{"readonly": "on"}, ExcludeProperties: []string{"readonly"}}).args(); err == nil {
+		t.Fatal("wanted error for overridden and excluded property, got nil")
+	}
+}
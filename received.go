@@ -0,0 +1,66 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+)
+
+// PropertyOverride is a property set locally on a dataset that also has a value received from a replication stream,
+// e.g. one changed on a replica after it was received. The local value shadows the received one,
+// so later changes of the property on the source no longer take effect on the replica.
+type PropertyOverride struct {
+	Dataset  string `json:"dataset"`
+	Property string `json:"property"`
+	Local    string `json:"local"`
+	Received string `json:"received"`
+}
+
+// Differs reports whether the local value differs from the received one.
+func (o PropertyOverride) Differs() bool {
+	return o.Local != o.Received
+}
+
+// PropertyOverrides returns the properties of the receiving dataset, and of its descendants if recursive,
+// whose local values override received values, in the order zfs get lists them.
+func (d *Dataset) PropertyOverrides(recursive bool) ([]PropertyOverride, error) {
+	args := []string{"get", "-Hp", "-s", "local", "-o", "name,property,value,received"}
+	if recursive {
+		args = append(args, "-r")
+	}
+	out, err := zfsOutput(append(args, "all", d.Name)...)
+	if err != nil {
+		return nil, err
+	}
+	return parsePropertyOverrides(out)
+}
+
+func parsePropertyOverrides(lines [][]string) ([]PropertyOverride, error) {
+	var overrides []PropertyOverride
+	for _, line := range lines {
+		if len(line) != 4 {
+			return nil, errors.New("output does not match what is expected on this platform")
+		}
+		if line[3] == "-" {
+			// set locally only
+			continue
+		}
+		overrides = append(overrides, PropertyOverride{Dataset: line[0], Property: line[1], Local: line[2], Received: line[3]})
+	}
+	return overrides, nil
+}
+
+// RevertToReceived reverts each of the overrides to its received value (zfs inherit -S),
+// on up to workers concurrent zfs processes, e.g. to make a replica consistent with its source again.
+// Failures of individual overrides are returned in a *BulkError, named "dataset property".
+func RevertToReceived(ctx context.Context, overrides []PropertyOverride, workers int) error {
+	names := make([]string, len(overrides))
+	byName := make(map[string]PropertyOverride, len(overrides))
+	for i, o := range overrides {
+		names[i] = o.Dataset + " " + o.Property
+		byName[names[i]] = o
+	}
+	return bulk(ctx, names, workers, func(ctx context.Context, name string) error {
+		o := byName[name]
+		return zfsContext(ctx, "inherit", "-S", o.Property, o.Dataset)
+	})
+}
@@ -0,0 +1,30 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePropertyOverrides(t *testing.T) {
+	out := "backup/fs\tcompression\toff\tzstd\n" +
+		"backup/fs\treadonly\ton\t-\n" +
+		"backup/fs/child\trecordsize\t131072\t131072\n"
+	got, err := parsePropertyOverrides(splitOutput(out))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []PropertyOverride{
+		{Dataset: "backup/fs", Property: "compression", Local: "off", Received: "zstd"},
+		{Dataset: "backup/fs/child", Property: "recordsize", Local: "131072", Received: "131072"},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %v, got: %v", want, got)
+	}
+	if !got[0].Differs() || got[1].Differs() {
+		t.Fatalf("wanted: true false, got: %v %v", got[0].Differs(), got[1].Differs())
+	}
+
+	if _, err := parsePropertyOverrides(splitOutput("backup/fs\tcompression\toff\n")); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,169 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrDescendantFailed is the reason a dataset was not destroyed by RecursiveDestroy
+// when destroying one of its descendants failed.
+var ErrDescendantFailed = errors.New("a descendant could not be destroyed")
+
+// DatasetResult is the outcome of a recursive operation on a single dataset.
+type DatasetResult struct {
+	Name string `json:"name"`
+	// Err is the reason the operation failed on the dataset, nil if it succeeded.
+	Err error `json:"-"`
+}
+
+// Succeeded reports whether the operation succeeded on the dataset.
+func (r DatasetResult) Succeeded() bool {
+	return r.Err == nil
+}
+
+// RecursiveResults are the outcomes of a recursive operation, one for each dataset, ordered by name.
+type RecursiveResults []DatasetResult
+
+// Failed returns the results of the datasets the operation failed on.
+func (r RecursiveResults) Failed() []DatasetResult {
+	var failed []DatasetResult
+	for _, res := range r {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Err returns a *BulkError collecting the failures, nil if the operation succeeded on all datasets.
+func (r RecursiveResults) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	e := &BulkError{Errors: make([]BulkItemError, len(failed))}
+	for i, res := range failed {
+		e.Errors[i] = BulkItemError{Name: res.Name, Err: res.Err}
+	}
+	return e
+}
+
+// addFailures adds the failures of a *BulkError returned by bulk to failed.
+func addFailures(failed map[string]error, err error) {
+	var bulkErr *BulkError
+	if errors.As(err, &bulkErr) {
+		for _, item := range bulkErr.Errors {
+			failed[item.Name] = item.Err
+		}
+	}
+}
+
+// recursiveResults returns the results for names given the failed ones, sorted by name.
+func recursiveResults(names []string, failed map[string]error) RecursiveResults {
+	results := make(RecursiveResults, len(names))
+	for i, name := range names {
+		results[i] = DatasetResult{Name: name, Err: failed[name]}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results
+}
+
+// listTree returns the file systems and volumes below root, and root itself.
+func listTree(ctx context.Context, root string) ([]string, error) {
+	if strings.Contains(root, "@") {
+		return nil, fmt.Errorf("%w: %s is a snapshot", ErrInvalidName, root)
+	}
+	c := command{Command: "zfs", Ctx: ctx}
+	out, err := c.Run("list", "-H", "-r", "-t", DatasetFilesystem+","+DatasetVolume, "-o", "name", root)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(out))
+	for _, line := range out {
+		names = append(names, line[0])
+	}
+	return names, nil
+}
+
+// RecursiveSnapshot snapshots root and each of its descendants with the given snapshot name, one dataset at a time,
+// on up to workers concurrent zfs processes, and returns the outcome for each of them.
+// Unlike a recursive Snapshot, the snapshots are not atomic, but failing datasets do not prevent the others
+// from being snapshotted. The error is only non-nil if the datasets could not be listed.
+func RecursiveSnapshot(ctx context.Context, root, name string, workers int) (RecursiveResults, error) {
+	names, err := listTree(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	err = bulk(ctx, names, workers, func(ctx context.Context, ds string) error {
+		return zfsContext(ctx, "snapshot", ds+"@"+name)
+	})
+	failed := map[string]error{}
+	addFailures(failed, err)
+	return recursiveResults(names, failed), nil
+}
+
+// RecursiveSetProperty sets a property on root and each of its descendants, one dataset at a time,
+// on up to workers concurrent zfs processes, and returns the outcome for each of them.
+// Rather than inherited, the property is set locally on each dataset. The error is only non-nil if
+// the datasets could not be listed.
+func RecursiveSetProperty(ctx context.Context, root, key, val string, workers int) (RecursiveResults, error) {
+	names, err := listTree(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	err = bulk(ctx, names, workers, func(ctx context.Context, ds string) error {
+		return zfsContext(ctx, "set", key+"="+val, ds)
+	})
+	failed := map[string]error{}
+	addFailures(failed, err)
+	return recursiveResults(names, failed), nil
+}
+
+// RecursiveDestroy destroys root and each of its descendants along with their snapshots, one dataset at a time,
+// deepest first, on up to workers concurrent zfs processes, and returns the outcome for each of them.
+// Datasets whose descendants could not be destroyed are kept, failing with ErrDescendantFailed.
+// The error is only non-nil if the datasets could not be listed.
+func RecursiveDestroy(ctx context.Context, root string, flags DestroyFlag, workers int) (RecursiveResults, error) {
+	names, err := listTree(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	return destroyTree(ctx, names, workers, func(ctx context.Context, ds string) error {
+		return zfsContext(ctx, append(destroyArgs(flags|DestroyRecursive), ds)...)
+	}), nil
+}
+
+// destroyTree runs destroy for names level by level, deepest first,
+// skipping the datasets whose descendants failed.
+func destroyTree(ctx context.Context, names []string, workers int, destroy func(ctx context.Context, name string) error) RecursiveResults {
+	levels := map[int][]string{}
+	deepest := 0
+	for _, name := range names {
+		depth := strings.Count(name, "/")
+		levels[depth] = append(levels[depth], name)
+		if depth > deepest {
+			deepest = depth
+		}
+	}
+
+	failed := map[string]error{}
+	for depth := deepest; depth >= 0; depth-- {
+		var level []string
+		for _, name := range levels[depth] {
+			for f := range failed {
+				if strings.HasPrefix(f, name+"/") {
+					failed[name] = ErrDescendantFailed
+					break
+				}
+			}
+			if failed[name] == nil {
+				level = append(level, name)
+			}
+		}
+		addFailures(failed, bulk(ctx, level, workers, destroy))
+	}
+	return recursiveResults(names, failed)
+}
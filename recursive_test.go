@@ -0,0 +1,59 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestDestroyTree(t *testing.T) {
+	names := []string{"tank/a", "tank/a/b", "tank/a/b/c", "tank/a/d", "tank/a/e", "tank/a/e/f"}
+	errBusy := errors.New("dataset is busy")
+
+	var mu sync.Mutex
+	var order []string
+	results := destroyTree(context.Background(), names, 2, func(_ context.Context, name string) error {
+		mu.Lock()
+		order = append(order, name)
+		mu.Unlock()
+		if name == "tank/a/b/c" {
+			return errBusy
+		}
+		return nil
+	})
+
+	want := RecursiveResults{
+		{Name: "tank/a", Err: ErrDescendantFailed},
+		{Name: "tank/a/b", Err: ErrDescendantFailed},
+		{Name: "tank/a/b/c", Err: errBusy},
+		{Name: "tank/a/d"},
+		{Name: "tank/a/e"},
+		{Name: "tank/a/e/f"},
+	}
+	if !reflect.DeepEqual(want, results) {
+		t.Fatalf("wanted: %v, got: %v", want, results)
+	}
+
+	// deeper levels are destroyed first, and datasets with failed descendants are not tried
+	if len(order) != 4 || order[len(order)-1] != "tank/a/d" && order[len(order)-1] != "tank/a/e" {
+		t.Fatalf("unexpected destroy order: %v", order)
+	}
+	sort.Strings(order[:2])
+	if !reflect.DeepEqual(order[:2], []string{"tank/a/b/c", "tank/a/e/f"}) {
+		t.Fatalf("unexpected destroy order: %v", order)
+	}
+
+	if got := results.Failed(); len(got) != 3 || got[2].Name != "tank/a/b/c" || got[2].Succeeded() {
+		t.Fatalf("unexpected failures: %v", got)
+	}
+	var bulkErr *BulkError
+	if err := results.Err(); !errors.As(err, &bulkErr) || len(bulkErr.Errors) != 3 {
+		t.Fatalf("wanted a *BulkError with 3 failures, got: %v", err)
+	}
+	if err := want[3:].Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
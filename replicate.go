@@ -0,0 +1,170 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ReplicateOptions controls both ends of a Replicate pipeline.
+type ReplicateOptions struct {
+	Send    SendOptions
+	Receive ReceiveOptions
+	// Transforms are applied in order to the stream between send and receive, e.g. to compress it in transit.
+	Transforms []StreamTransform
+}
+
+// Replicate sends the source snapshot and receives it into the target dataset, as `zfs send | zfs receive` would.
+// The stream is passed between both processes through an in-process pipe.
+// If either end fails, the other is stopped and the error of the end that failed first is returned.
+func Replicate(ctx context.Context, source *Dataset, target string, opts ReplicateOptions) (*Dataset, error) {
+	var ds *Dataset
+	err := pipeline(ctx,
+		func(ctx context.Context, w io.Writer) error {
+			return sendTransformed(w, opts.Transforms, func(w io.Writer) error {
+				return source.SendTo(ctx, w, opts.Send)
+			})
+		},
+		func(ctx context.Context, r io.Reader) error {
+			tr, err := TransformReader(r, opts.Transforms...)
+			if err != nil {
+				return err
+			}
+			defer tr.Close()
+			ds, err = ReceiveFrom(ctx, tr, target, opts.Receive)
+			return err
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+// ReplicateRemote sends the source snapshot and receives it into the target dataset on the host reached through runner,
+// e.g. an SSHRunner. Errors of the remote receive include its stderr output.
+//
+// If opts.Receive.Resumable is set and the target holds the state of an earlier interrupted receive,
+// the transfer is resumed from its receive_resume_token rather than restarted, and opts.Send is ignored.
+//
+// The receive runs remotely, so opts.Receive.Progress and opts.Receive.RateLimit are not applied; use opts.Send instead.
+// Each of opts.Transforms must implement RemoteDecoder, as the stream is decoded by a shell pipeline on the remote host.
+func ReplicateRemote(ctx context.Context, source *Dataset, runner Runner, target string, opts ReplicateOptions) error {
+	flags, err := opts.Receive.args()
+	if err != nil {
+		return err
+	}
+	recvArgs := append([]string{"receive"}, flags...)
+	recvArgs = append(recvArgs, target)
+
+	recvCommand := "zfs"
+	if len(opts.Transforms) > 0 {
+		decode, err := remoteDecodePipeline(opts.Transforms)
+		if err != nil {
+			return err
+		}
+		quoted := []string{shellQuote("zfs")}
+		for _, a := range recvArgs {
+			quoted = append(quoted, shellQuote(a))
+		}
+		recvCommand = "sh"
+		recvArgs = []string{"-c", decode + strings.Join(quoted, " ")}
+	}
+
+	var token string
+	if opts.Receive.Resumable {
+		token, err = remoteResumeToken(ctx, runner, target)
+		if err != nil {
+			return err
+		}
+	}
+
+	return pipeline(ctx,
+		func(ctx context.Context, w io.Writer) error {
+			return sendTransformed(w, opts.Transforms, func(w io.Writer) error {
+				if token != "" {
+					return ResumeSend(ctx, token, w)
+				}
+				return source.SendTo(ctx, w, opts.Send)
+			})
+		},
+		func(ctx context.Context, r io.Reader) error {
+			c := command{Command: recvCommand, Stdin: r, Ctx: ctx, Runner: runner}
+			_, err := c.Run(recvArgs...)
+			return err
+		},
+	)
+}
+
+// remoteResumeToken returns the receive_resume_token of target on the host reached through runner.
+// An empty token is returned if the target does not exist or holds no partially received state.
+func remoteResumeToken(ctx context.Context, runner Runner, target string) (string, error) {
+	c := command{Command: "zfs", Ctx: ctx, Runner: runner}
+	out, err := c.Run("get", "-Hp", "-o", "value", "receive_resume_token", target)
+	if err != nil {
+		var e *Error
+		if errors.As(err, &e) && isDatasetNotExist(e.Stderr) {
+			return "", nil
+		}
+		return "", err
+	}
+	if len(out) == 0 || len(out[0]) == 0 || out[0][0] == "-" {
+		return "", nil
+	}
+	return out[0][0], nil
+}
+
+// sendTransformed runs send with a writer applying transforms to the stream written to w.
+func sendTransformed(w io.Writer, transforms []StreamTransform, send func(io.Writer) error) error {
+	tw, err := TransformWriter(w, transforms...)
+	if err != nil {
+		return err
+	}
+	if err := send(tw); err != nil {
+		tw.Close()
+		return err
+	}
+	return tw.Close()
+}
+
+// pipeline connects send to receive through an in-process pipe and runs both until the stream is consumed.
+// If either end fails, the other is stopped by cancelling its context, and the error of the end that failed first is returned.
+func pipeline(ctx context.Context, send func(context.Context, io.Writer) error, receive func(context.Context, io.Reader) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		once     sync.Once
+		firstErr error
+	)
+	fail := func(side string, err error) {
+		once.Do(func() {
+			firstErr = fmt.Errorf("replication %s failed: %w", side, err)
+			cancel()
+		})
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := send(ctx, pw)
+		if err != nil {
+			fail("send", err)
+		}
+		// a nil error closes the pipe normally, so the receive sees the end of the stream
+		pw.CloseWithError(err)
+	}()
+
+	if err := receive(ctx, pr); err != nil {
+		fail("receive", err)
+	}
+	// unblock the send if the receive stopped reading early
+	pr.CloseWithError(io.ErrClosedPipe)
+	<-done
+
+	return firstErr
+}
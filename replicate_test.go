@@ -0,0 +1,66 @@
+package zfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	var got bytes.Buffer
+	err := pipeline(context.Background(),
+		func(_ context.Context, w io.Writer) error {
+			_, err := w.Write([]byte("stream"))
+			return err
+		},
+		func(_ context.Context, r io.Reader) error {
+			_, err := io.Copy(&got, r)
+			return err
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.String() != "stream" {
+		t.Fatalf("wanted: %q, got: %q", "stream", got.String())
+	}
+}
+
+func TestPipelineSendError(t *testing.T) {
+	errSend := errors.New("send failed")
+	err := pipeline(context.Background(),
+		func(_ context.Context, w io.Writer) error {
+			return errSend
+		},
+		func(_ context.Context, r io.Reader) error {
+			_, err := io.Copy(ioutil.Discard, r)
+			return err
+		},
+	)
+	if !errors.Is(err, errSend) {
+		t.Fatalf("wanted send error, got: %v", err)
+	}
+}
+
+func TestPipelineReceiveError(t *testing.T) {
+	errReceive := errors.New("receive failed")
+	err := pipeline(context.Background(),
+		func(ctx context.Context, w io.Writer) error {
+			// keep writing until the receive side goes away
+			for {
+				if _, err := w.Write(make([]byte, 1024)); err != nil {
+					return err
+				}
+			}
+		},
+		func(_ context.Context, r io.Reader) error {
+			return errReceive
+		},
+	)
+	if !errors.Is(err, errReceive) {
+		t.Fatalf("wanted receive error, got: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package zfs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ResumeToken holds the decoded contents of a receive_resume_token.
+// It describes the interrupted stream and how far its receive got.
+type ResumeToken struct {
+	// ToName is the name of the snapshot being sent.
+	ToName string `json:"to_name"`
+	// ToGUID is the GUID of the snapshot being sent.
+	ToGUID uint64 `json:"to_guid"`
+	// FromGUID is the GUID of the incremental base, or 0 for a full stream.
+	FromGUID uint64 `json:"from_guid"`
+	// Object and Offset locate the position in the stream the send will resume from.
+	Object uint64 `json:"object"`
+	Offset uint64 `json:"offset"`
+	// Bytes is the number of stream bytes that were received before the interruption.
+	Bytes uint64 `json:"bytes"`
+	// The stream flags the original send was started with.
+	EmbedOK      bool `json:"embed_ok"`
+	LargeBlockOK bool `json:"large_block_ok"`
+	CompressOK   bool `json:"compress_ok"`
+	RawOK        bool `json:"raw_ok"`
+}
+
+// DecodeResumeToken decodes a receive_resume_token into its fields.
+// The token is decoded by `zstream token`, falling back to `zfs send -nvt` on systems without zstream.
+func DecodeResumeToken(token string) (*ResumeToken, error) {
+	c := command{Command: "zstream"}
+	out, err := c.Run("token", token)
+	if err != nil {
+		var zfsErr error
+		out, zfsErr = zfsOutput("send", "-nvt", token)
+		if zfsErr != nil {
+			return nil, fmt.Errorf("failed to decode resume token: %w", zfsErr)
+		}
+	}
+	return parseResumeToken(out)
+}
+
+// example input for parseResumeToken
+// resume token contents:
+// nvlist version: 0
+//         object = 0x6
+//         offset = 0x0
+//         bytes = 0x16f0
+//         toguid = 0x5cc1b96d5b2bbfc2
+//         toname = test/fs@snap
+//         embedok
+//         compressok
+
+func parseResumeToken(lines [][]string) (*ResumeToken, error) {
+	t := &ResumeToken{}
+	for _, fields := range lines {
+		// only the indentation is trimmed, names may end in spaces
+		line := strings.TrimLeft(strings.Join(fields, "\t"), " \t")
+		if line == "" || strings.HasSuffix(line, ":") || strings.HasPrefix(line, "nvlist version") {
+			continue
+		}
+
+		kv := strings.SplitN(line, " = ", 2)
+		key := kv[0]
+		var val string
+		if len(kv) == 2 {
+			val = kv[1]
+		}
+
+		var err error
+		switch key {
+		case "toname":
+			t.ToName = val
+		case "toguid":
+			t.ToGUID, err = strconv.ParseUint(val, 0, 64)
+		case "fromguid":
+			t.FromGUID, err = strconv.ParseUint(val, 0, 64)
+		case "object":
+			t.Object, err = strconv.ParseUint(val, 0, 64)
+		case "offset":
+			t.Offset, err = strconv.ParseUint(val, 0, 64)
+		case "bytes":
+			t.Bytes, err = strconv.ParseUint(val, 0, 64)
+		case "embedok":
+			t.EmbedOK = true
+		case "largeblockok":
+			t.LargeBlockOK = true
+		case "compressok":
+			t.CompressOK = true
+		case "rawok":
+			t.RawOK = true
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse resume token field %s: %w", key, err)
+		}
+	}
+
+	if t.ToName == "" {
+		return nil, fmt.Errorf("resume token does not name a snapshot")
+	}
+	return t, nil
+}
@@ -0,0 +1,45 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseResumeToken(t *testing.T) {
+	got, err := parseResumeToken([][]string{
+		{"resume token contents:"},
+		{"nvlist version: 0"},
+		{"", "fromguid = 0x10"},
+		{"", "object = 0x6"},
+		{"", "offset = 0x20000"},
+		{"", "bytes = 0x16f0"},
+		{"", "toguid = 0x5cc1b96d5b2bbfc2"},
+		{"", "toname = test/fs with space@snap"},
+		{"", "embedok"},
+		{"", "compressok = 1"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &ResumeToken{
+		ToName:     "test/fs with space@snap",
+		ToGUID:     0x5cc1b96d5b2bbfc2,
+		FromGUID:   0x10,
+		Object:     6,
+		Offset:     0x20000,
+		Bytes:      0x16f0,
+		EmbedOK:    true,
+		CompressOK: true,
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("parse failure: wanted: %+v, got: %+v", want, got)
+	}
+
+	if _, err := parseResumeToken([][]string{{"", "bytes = 12z"}, {"", "toname = test/fs@snap"}}); err == nil {
+		t.Fatal("wanted error for invalid number, got nil")
+	}
+	if _, err := parseResumeToken([][]string{{"", "bytes = 0x1"}}); err == nil {
+		t.Fatal("wanted error for missing toname, got nil")
+	}
+}
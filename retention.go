@@ -0,0 +1,82 @@
+package zfs
+
+import (
+	"fmt"
+	"time"
+)
+
+// RetentionPolicy decides which snapshots of a dataset to keep.
+// A snapshot is kept if any rule selects it; all other snapshots are pruned.
+//
+// The periodic rules keep the newest snapshot of each of the most recent periods
+// (hours, days, ISO weeks or months, in the local time zone) that contain a snapshot.
+type RetentionPolicy struct {
+	// KeepLast keeps the given number of most recent snapshots.
+	KeepLast int
+	// KeepHourly keeps the newest snapshot of the given number of most recent hours.
+	KeepHourly int
+	// KeepDaily keeps the newest snapshot of the given number of most recent days.
+	KeepDaily int
+	// KeepWeekly keeps the newest snapshot of the given number of most recent weeks.
+	KeepWeekly int
+	// KeepMonthly keeps the newest snapshot of the given number of most recent months.
+	KeepMonthly int
+	// MinAge keeps all snapshots younger than the given duration.
+	MinAge time.Duration
+}
+
+// Apply splits snapshots into those to keep and those to prune at time now.
+// Both results are ordered like snapshots, which are expected to be ordered from oldest to newest.
+func (p RetentionPolicy) Apply(snapshots []*Dataset, now time.Time) (keep, prune []*Dataset) {
+	kept := make(map[*Dataset]bool, len(snapshots))
+
+	loc := now.Location()
+	rules := []struct {
+		count  int
+		bucket func(*Dataset) string
+	}{
+		{p.KeepLast, func(s *Dataset) string { return s.Name }},
+		{p.KeepHourly, func(s *Dataset) string { return s.Creation.In(loc).Format("2006-01-02T15") }},
+		{p.KeepDaily, func(s *Dataset) string { return s.Creation.In(loc).Format("2006-01-02") }},
+		{p.KeepWeekly, func(s *Dataset) string {
+			year, week := s.Creation.In(loc).ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{p.KeepMonthly, func(s *Dataset) string { return s.Creation.In(loc).Format("2006-01") }},
+	}
+	for _, rule := range rules {
+		seen := map[string]bool{}
+		for i := len(snapshots) - 1; i >= 0 && len(seen) < rule.count; i-- {
+			b := rule.bucket(snapshots[i])
+			if !seen[b] {
+				seen[b] = true
+				kept[snapshots[i]] = true
+			}
+		}
+	}
+
+	for _, s := range snapshots {
+		if kept[s] || now.Sub(s.Creation) < p.MinAge {
+			keep = append(keep, s)
+		} else {
+			prune = append(prune, s)
+		}
+	}
+	return keep, prune
+}
+
+// Prune destroys the snapshots of the receiving dataset that the policy does not keep, and returns them.
+// With dryRun set, the snapshots are only returned. Snapshots are destroyed in batches (see DestroySnapshots),
+// and on error only those already destroyed are returned.
+func (d *Dataset) Prune(policy RetentionPolicy, dryRun bool) ([]*Dataset, error) {
+	snapshots, err := d.SnapshotsSorted()
+	if err != nil {
+		return nil, err
+	}
+
+	_, prune := policy.Apply(snapshots, time.Now())
+	if dryRun {
+		return prune, nil
+	}
+	return destroySnapshotBatches(snapshots, prune, DestroyDefault)
+}
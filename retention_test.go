@@ -0,0 +1,63 @@
+package zfs
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func names(datasets []*Dataset) []string {
+	var n []string
+	for _, d := range datasets {
+		n = append(n, d.Name)
+	}
+	return n
+}
+
+func TestRetentionPolicy(t *testing.T) {
+	now := time.Date(2022, 4, 14, 12, 30, 0, 0, time.UTC)
+
+	// hourly snapshots over the last three days, oldest first
+	var snapshots []*Dataset
+	for h := 72; h >= 0; h-- {
+		c := now.Add(-time.Duration(h) * time.Hour)
+		snapshots = append(snapshots, &Dataset{Name: fmt.Sprintf("test/fs@%s", c.Format("2006-01-02_15")), Creation: c})
+	}
+
+	for name, test := range map[string]struct {
+		policy RetentionPolicy
+		keep   []string
+	}{
+		"keep last": {
+			policy: RetentionPolicy{KeepLast: 2},
+			keep:   []string{"test/fs@2022-04-14_11", "test/fs@2022-04-14_12"},
+		},
+		"keep daily": {
+			policy: RetentionPolicy{KeepDaily: 3},
+			keep:   []string{"test/fs@2022-04-12_23", "test/fs@2022-04-13_23", "test/fs@2022-04-14_12"},
+		},
+		"keep last and hourly overlap": {
+			policy: RetentionPolicy{KeepLast: 1, KeepHourly: 2},
+			keep:   []string{"test/fs@2022-04-14_11", "test/fs@2022-04-14_12"},
+		},
+		"keep monthly": {
+			policy: RetentionPolicy{KeepMonthly: 12},
+			keep:   []string{"test/fs@2022-04-14_12"},
+		},
+		"min age": {
+			policy: RetentionPolicy{MinAge: 90 * time.Minute},
+			keep:   []string{"test/fs@2022-04-14_11", "test/fs@2022-04-14_12"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			keep, prune := test.policy.Apply(snapshots, now)
+			if got := names(keep); !reflect.DeepEqual(test.keep, got) {
+				t.Fatalf("wanted to keep: %v, got: %v", test.keep, got)
+			}
+			if len(keep)+len(prune) != len(snapshots) {
+				t.Fatalf("wanted %d snapshots in total, got: %d", len(snapshots), len(keep)+len(prune))
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package zfs
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RollbackImpact lists what rolling back to a snapshot destroys.
+type RollbackImpact struct {
+	Snapshot string `json:"snapshot"`
+	// Snapshots and Bookmarks are those of the snapshot's file system more recent than it,
+	// which are destroyed by Rollback(true), i.e. zfs rollback -r.
+	Snapshots []string `json:"snapshots"`
+	Bookmarks []string `json:"bookmarks"`
+	// Clones are the clones of those snapshots, the datasets below them, and their own clones in turn,
+	// which are destroyed along with them by zfs rollback -R.
+	Clones []string `json:"clones"`
+}
+
+// RequiresDestroy reports whether more recent snapshots or bookmarks have to be destroyed for the rollback.
+func (r *RollbackImpact) RequiresDestroy() bool {
+	return len(r.Snapshots) > 0 || len(r.Bookmarks) > 0
+}
+
+// RequiresDestroyClones reports whether clones have to be destroyed for the rollback (zfs rollback -R).
+func (r *RollbackImpact) RequiresDestroyClones() bool {
+	return len(r.Clones) > 0
+}
+
+// RollbackImpact reports what rolling back to the receiving snapshot would destroy, without rolling back,
+// e.g. to let users confirm the rollback first.
+func (d *Dataset) RollbackImpact() (*RollbackImpact, error) {
+	if d.Type != DatasetSnapshot {
+		return nil, errors.New("can only rollback snapshots")
+	}
+	pool := strings.SplitN(d.Name, "/", 2)[0]
+	pool = strings.SplitN(pool, "@", 2)[0]
+	out, err := zfsOutput("list", "-Hp", "-r", "-t", "filesystem,volume,snapshot,bookmark", "-o", "name,origin,createtxg", pool)
+	if err != nil {
+		return nil, err
+	}
+	return rollbackImpact(d.Name, out)
+}
+
+// example input for rollbackImpact
+// tank/fs	-	10
+// tank/fs@a	-	11
+// tank/fs@b	-	12
+// tank/fs#b	-	12
+// tank/clone	tank/fs@b	13
+
+func rollbackImpact(snapshot string, lines [][]string) (*RollbackImpact, error) {
+	fs := strings.SplitN(snapshot, "@", 2)[0]
+	txgs := make(map[string]uint64, len(lines))
+	clonesOf := map[string][]string{}
+	var names []string
+	for _, line := range lines {
+		if len(line) != 3 {
+			return nil, errors.New("output does not match what is expected on this platform")
+		}
+		txg, err := strconv.ParseUint(line[2], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		txgs[line[0]] = txg
+		names = append(names, line[0])
+		if line[1] != "-" && line[1] != "" {
+			clonesOf[line[1]] = append(clonesOf[line[1]], line[0])
+		}
+	}
+	txg, ok := txgs[snapshot]
+	if !ok {
+		return nil, ErrDatasetNotFound
+	}
+
+	r := &RollbackImpact{Snapshot: snapshot}
+	var later []string
+	for _, name := range names {
+		if txgs[name] <= txg {
+			continue
+		}
+		if strings.HasPrefix(name, fs+"@") {
+			later = append(later, name)
+		} else if strings.HasPrefix(name, fs+"#") {
+			r.Bookmarks = append(r.Bookmarks, name)
+		}
+	}
+	r.Snapshots = append(r.Snapshots, later...)
+
+	// follow the clones of the more recent snapshots, and the clones of the snapshots below those
+	destroyed := map[string]bool{}
+	for len(later) > 0 {
+		snap := later[0]
+		later = later[1:]
+		for _, clone := range clonesOf[snap] {
+			if destroyed[clone] {
+				continue
+			}
+			for _, name := range names {
+				if !isWithin(name, clone) || destroyed[name] {
+					continue
+				}
+				destroyed[name] = true
+				switch {
+				case strings.Contains(name, "@"):
+					later = append(later, name)
+				case !strings.Contains(name, "#"):
+					r.Clones = append(r.Clones, name)
+				}
+			}
+		}
+	}
+
+	sort.Slice(r.Snapshots, func(i, j int) bool { return txgs[r.Snapshots[i]] < txgs[r.Snapshots[j]] })
+	sort.Slice(r.Bookmarks, func(i, j int) bool { return txgs[r.Bookmarks[i]] < txgs[r.Bookmarks[j]] })
+	sort.Strings(r.Clones)
+	return r, nil
+}
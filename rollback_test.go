@@ -0,0 +1,52 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRollbackImpact(t *testing.T) {
+	out := "tank\t-\t1\n" +
+		"tank/fs\t-\t10\n" +
+		"tank/fs@a\t-\t11\n" +
+		"tank/fs#a\t-\t11\n" +
+		"tank/fs@b\t-\t12\n" +
+		"tank/fs#b\t-\t12\n" +
+		"tank/fs@c\t-\t14\n" +
+		"tank/fs/child\t-\t10\n" +
+		"tank/fs/child@b\t-\t12\n" +
+		"tank/clone\ttank/fs@b\t13\n" +
+		"tank/clone/sub\t-\t13\n" +
+		"tank/clone@x\t-\t15\n" +
+		"tank/clone2\ttank/clone@x\t16\n" +
+		"tank/other\ttank/fs@a\t17\n"
+
+	got, err := rollbackImpact("tank/fs@a", splitOutput(out))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := &RollbackImpact{
+		Snapshot:  "tank/fs@a",
+		Snapshots: []string{"tank/fs@b", "tank/fs@c"},
+		Bookmarks: []string{"tank/fs#b"},
+		Clones:    []string{"tank/clone", "tank/clone/sub", "tank/clone2"},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %+v, got: %+v", want, got)
+	}
+	if !got.RequiresDestroy() || !got.RequiresDestroyClones() {
+		t.Fatalf("wanted destroys to be required, got: %+v", got)
+	}
+
+	got, err = rollbackImpact("tank/fs@c", splitOutput(out))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.RequiresDestroy() || got.RequiresDestroyClones() {
+		t.Fatalf("wanted no destroys for the latest snapshot, got: %+v", got)
+	}
+
+	if _, err := rollbackImpact("tank/fs@missing", splitOutput(out)); err != ErrDatasetNotFound {
+		t.Fatalf("wanted: %v, got: %v", ErrDatasetNotFound, err)
+	}
+}
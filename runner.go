@@ -0,0 +1,50 @@
+package zfs
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+)
+
+// Runner creates the processes used to run zfs and zpool commands.
+// It allows commands to be run somewhere other than the local host, e.g. over SSH.
+type Runner interface {
+	Command(ctx context.Context, name string, arg ...string) *exec.Cmd
+}
+
+// localRunner runs commands on the local host.
+type localRunner struct{}
+
+func (localRunner) Command(ctx context.Context, name string, arg ...string) *exec.Cmd {
+	if ctx == nil {
+		return exec.Command(name, arg...)
+	}
+	return exec.CommandContext(ctx, name, arg...)
+}
+
+// SSHRunner runs commands on a remote host using the local ssh client.
+// The remote command line is quoted for a POSIX shell.
+type SSHRunner struct {
+	// Destination is the remote host, as accepted by ssh, e.g. "root@backup.example.com".
+	Destination string
+	// Options are additional ssh arguments, e.g. []string{"-p", "2222", "-i", "/path/to/key"}.
+	Options []string
+}
+
+// Command returns an ssh command running the named program with the given arguments on the remote host.
+func (r SSHRunner) Command(ctx context.Context, name string, arg ...string) *exec.Cmd {
+	remote := make([]string, 0, len(arg)+1)
+	remote = append(remote, shellQuote(name))
+	for _, a := range arg {
+		remote = append(remote, shellQuote(a))
+	}
+
+	args := append([]string{}, r.Options...)
+	args = append(args, "--", r.Destination, strings.Join(remote, " "))
+	return localRunner{}.Command(ctx, "ssh", args...)
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
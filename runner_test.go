@@ -0,0 +1,17 @@
+package zfs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSSHRunner(t *testing.T) {
+	r := SSHRunner{Destination: "root@backup", Options: []string{"-p", "2222"}}
+	cmd := r.Command(context.Background(), "zfs", "receive", "-s", "tank/it's mine")
+
+	want := []string{"ssh", "-p", "2222", "--", "root@backup", `'zfs' 'receive' '-s' 'tank/it'\''s mine'`}
+	if !reflect.DeepEqual(want, cmd.Args) {
+		t.Fatalf("wanted: %q, got: %q", want, cmd.Args)
+	}
+}
@@ -0,0 +1,137 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ScheduleEntry describes the periodic snapshots of a dataset taken by a Scheduler.
+type ScheduleEntry struct {
+	// Dataset is the name of the dataset to snapshot.
+	Dataset string
+	// Interval is the time between snapshots.
+	Interval time.Duration
+	// Recursive snapshots all descendent datasets atomically, and prunes them together.
+	Recursive bool
+	// Naming generates the snapshot names, and identifies the snapshots subject to Retention.
+	Naming SnapshotNaming
+	// Label is added to the snapshot names, e.g. "hourly", so entries of the same dataset can be told apart.
+	Label string
+	// Retention, if set, prunes the entry's snapshots after each snapshot.
+	// Only snapshots matching Naming and Label are considered.
+	Retention *RetentionPolicy
+	// Hooks are run around each snapshot, e.g. to quiesce an application (see SnapshotWithHooks).
+	Hooks []SnapshotHook
+}
+
+// Scheduler takes periodic snapshots of datasets and prunes them according to their retention policies,
+// essentially sanoid as a library.
+type Scheduler struct {
+	Entries []ScheduleEntry
+	// PreSnapshot, if set, is called before each snapshot, e.g. to quiesce an application.
+	// It runs before the hooks of the entry, and the snapshot is skipped if it returns an error.
+	PreSnapshot func(ctx context.Context, entry ScheduleEntry) error
+	// PostSnapshot, if set, is called after each snapshot attempt for which PreSnapshot succeeded,
+	// even if the snapshot failed, e.g. to resume an application. It runs after the hooks of the entry.
+	PostSnapshot func(ctx context.Context, entry ScheduleEntry) error
+	// OnError, if set, is called with the errors of the scheduled runs, which are otherwise discarded.
+	OnError func(entry ScheduleEntry, err error)
+}
+
+// Run takes the snapshots of all entries at their intervals until ctx is cancelled, and returns ctx.Err().
+// The first snapshot of each entry is taken after its first interval has passed.
+// An error is returned right away if an entry has no positive Interval, or one shorter than its Naming can tell apart.
+func (s *Scheduler) Run(ctx context.Context) error {
+	for _, entry := range s.Entries {
+		if err := entry.validate(); err != nil {
+			return fmt.Errorf("schedule of %s: %w", entry.Dataset, err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, entry := range s.Entries {
+		wg.Add(1)
+		go func(entry ScheduleEntry) {
+			defer wg.Done()
+			ticker := time.NewTicker(entry.Interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+				if err := s.RunOnce(ctx, entry); err != nil && s.OnError != nil {
+					s.OnError(entry, err)
+				}
+			}
+		}(entry)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+func (e ScheduleEntry) validate() error {
+	if e.Interval <= 0 {
+		return errors.New("a positive Interval is required")
+	}
+	// a time aligned to every unit of the layout, so that the next snapshot gets the same name
+	// exactly if the interval is shorter than the layout's resolution
+	t := time.Date(2000, 1, 1, 0, 0, 0, 0, e.Naming.location())
+	if e.Naming.Name(t, e.Label) == e.Naming.Name(t.Add(e.Interval), e.Label) {
+		return fmt.Errorf("interval %s is shorter than the resolution of the naming layout %q", e.Interval, e.Naming.layout())
+	}
+	return nil
+}
+
+// hooks returns the hooks of entry, wrapped by a hook calling PreSnapshot and PostSnapshot if either is set.
+func (s *Scheduler) hooks(entry ScheduleEntry) []SnapshotHook {
+	if s.PreSnapshot == nil && s.PostSnapshot == nil {
+		return entry.Hooks
+	}
+	h := SnapshotHook{Name: "scheduler"}
+	if s.PreSnapshot != nil {
+		h.Pre = func(ctx context.Context, _ string) error { return s.PreSnapshot(ctx, entry) }
+	}
+	if s.PostSnapshot != nil {
+		h.Post = func(ctx context.Context, _ string) error { return s.PostSnapshot(ctx, entry) }
+	}
+	return append([]SnapshotHook{h}, entry.Hooks...)
+}
+
+// RunOnce immediately takes the snapshot of entry, running PreSnapshot, PostSnapshot and the entry's hooks around it,
+// and prunes the entry's snapshots.
+func (s *Scheduler) RunOnce(ctx context.Context, entry ScheduleEntry) error {
+	ds, err := GetDataset(entry.Dataset)
+	if err != nil {
+		return err
+	}
+
+	name := entry.Naming.Name(time.Now(), entry.Label)
+	if _, err := ds.SnapshotWithHooks(ctx, name, entry.Recursive, s.hooks(entry)...); err != nil {
+		return err
+	}
+
+	if entry.Retention == nil {
+		return nil
+	}
+	snapshots, err := ds.SnapshotsSorted()
+	if err != nil {
+		return err
+	}
+	flags := DestroyDefault
+	if entry.Recursive {
+		flags = DestroyRecursive
+	}
+	_, err = destroySnapshotBatches(snapshots, entry.pruneCandidates(snapshots, time.Now()), flags)
+	return err
+}
+
+// pruneCandidates returns the snapshots of the entry that its retention policy does not keep.
+func (e ScheduleEntry) pruneCandidates(snapshots []*Dataset, now time.Time) []*Dataset {
+	_, prune := e.Retention.Apply(e.Naming.Filter(snapshots, e.Label), now)
+	return prune
+}
@@ -0,0 +1,91 @@
+package zfs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSchedulePruneCandidates(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	entry := ScheduleEntry{
+		Naming:    SnapshotNaming{Prefix: "auto"},
+		Label:     "hourly",
+		Retention: &RetentionPolicy{KeepLast: 2},
+	}
+
+	var snapshots []*Dataset
+	for h := 3; h >= 0; h-- {
+		c := now.Add(-time.Duration(h) * time.Hour)
+		snapshots = append(snapshots, &Dataset{Name: "test/fs@" + entry.Naming.Name(c, entry.Label), Creation: c})
+	}
+	// neither manual snapshots nor those of other entries are pruned
+	snapshots = append([]*Dataset{
+		{Name: "test/fs@before-upgrade", Creation: now.Add(-48 * time.Hour)},
+		{Name: "test/fs@auto-2024-04-01_00:00-daily", Creation: now.AddDate(0, -1, 0)},
+	}, snapshots...)
+
+	want := []string{"test/fs@auto-2024-05-01_09:00-hourly", "test/fs@auto-2024-05-01_10:00-hourly"}
+	if got := names(entry.pruneCandidates(snapshots, now)); !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %v, got: %v", want, got)
+	}
+}
+
+func TestSchedulerHooks(t *testing.T) {
+	var calls []string
+	record := func(call string) func(context.Context, string) error {
+		return func(context.Context, string) error {
+			calls = append(calls, call)
+			return nil
+		}
+	}
+	s := &Scheduler{
+		PreSnapshot: func(_ context.Context, entry ScheduleEntry) error {
+			calls = append(calls, "pre "+entry.Dataset)
+			return nil
+		},
+		PostSnapshot: func(_ context.Context, entry ScheduleEntry) error {
+			calls = append(calls, "post "+entry.Dataset)
+			return nil
+		},
+	}
+	entry := ScheduleEntry{Dataset: "test/fs", Hooks: []SnapshotHook{{Name: "db", Pre: record("flush"), Post: record("resume")}}}
+	err := runWithHooks(context.Background(), entry.Dataset, s.hooks(entry), func() error {
+		calls = append(calls, "snapshot")
+		return nil
+	})
+	want := []string{"pre test/fs", "flush", "snapshot", "resume", "post test/fs"}
+	if err != nil || !reflect.DeepEqual(want, calls) {
+		t.Fatalf("wanted: %v, got: %v %v", want, calls, err)
+	}
+
+	if got := (&Scheduler{}).hooks(entry); len(got) != 1 {
+		t.Fatalf("wanted: 1 hook, got: %d", len(got))
+	}
+}
+
+func TestSchedulerRunInvalid(t *testing.T) {
+	tests := map[string]ScheduleEntry{
+		"no interval":       {Dataset: "test/fs"},
+		"negative interval": {Dataset: "test/fs", Interval: -time.Minute},
+		"below a minute":    {Dataset: "test/fs", Interval: 30 * time.Second},
+	}
+	for name, entry := range tests {
+		s := &Scheduler{Entries: []ScheduleEntry{entry}}
+		if err := s.Run(context.Background()); err == nil || err == context.Canceled {
+			t.Fatalf("%s: wanted: an error, got: %v", name, err)
+		}
+	}
+
+	valid := []ScheduleEntry{
+		{Interval: time.Minute},
+		{Interval: 90 * time.Second},
+		{Interval: 30 * time.Second, Naming: SnapshotNaming{Layout: "2006-01-02_15:04:05"}},
+	}
+	for _, entry := range valid {
+		if err := entry.validate(); err != nil {
+			t.Fatalf("%v: unexpected error: %v", entry.Interval, err)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package zfs
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrNoScrub is returned when no matching scrub has been recorded.
+var ErrNoScrub = errors.New("no scrub recorded")
+
+// ZpoolScanStats is the status of the last or current scrub or resilver of a pool, as reported by `zpool status --json`.
+type ZpoolScanStats struct {
+	Function  string `json:"function"`
+	State     string `json:"state"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	ToExamine string `json:"to_examine"`
+	Examined  string `json:"examined"`
+	Processed string `json:"processed"`
+	Errors    string `json:"errors"`
+}
+
+// zpoolStatusTimeLayout is the layout of the scan times in `zpool status --json` without --json-int.
+const zpoolStatusTimeLayout = "Mon Jan _2 15:04:05 2006"
+
+func parseScanTime(s string) (time.Time, error) {
+	if s == "" || s == "-" || s == "0" {
+		return time.Time{}, nil
+	}
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	return time.ParseInLocation(zpoolStatusTimeLayout, s, time.Local)
+}
+
+// ScrubRecord is the result of a finished scrub of a pool.
+type ScrubRecord struct {
+	Pool  string    `json:"pool"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// State is the scan state, "FINISHED" or "CANCELED".
+	State string `json:"state"`
+	// Errors is the number of errors found, and Repaired the number of bytes repaired.
+	Errors   uint64 `json:"errors"`
+	Repaired uint64 `json:"repaired"`
+}
+
+// Succeeded reports whether the scrub ran to completion without finding errors.
+func (r ScrubRecord) Succeeded() bool {
+	return r.State == "FINISHED" && r.Errors == 0
+}
+
+// scrubRecordFromStatus returns the record of the pool's last scrub, or nil if it has not finished a scrub.
+func scrubRecordFromStatus(status *ZpoolStatus) (*ScrubRecord, error) {
+	s := status.ScanStats
+	if s == nil || s.Function != "SCRUB" || (s.State != "FINISHED" && s.State != "CANCELED") {
+		return nil, nil
+	}
+	r := &ScrubRecord{Pool: status.Name, State: s.State}
+	var err error
+	if r.Start, err = parseScanTime(s.StartTime); err != nil {
+		return nil, err
+	}
+	if r.End, err = parseScanTime(s.EndTime); err != nil {
+		return nil, err
+	}
+	if err := setUint(&r.Errors, s.Errors); err != nil {
+		return nil, err
+	}
+	if err := setUint(&r.Repaired, s.Processed); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ScrubStore persists scrub records, e.g. in a file or an SQL database.
+type ScrubStore interface {
+	// Add stores a record.
+	Add(r ScrubRecord) error
+	// Records returns the stored records of the pool, or of all pools if pool is empty, in the order they were added.
+	Records(pool string) ([]ScrubRecord, error)
+}
+
+// FileScrubStore is a ScrubStore keeping records as JSON lines in a file, which is created as needed.
+type FileScrubStore struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// Add appends the record to the file.
+func (s *FileScrubStore) Add(r ScrubRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Records reads the records of the pool from the file.
+func (s *FileScrubStore) Records(pool string) ([]ScrubRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.Open(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []ScrubRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r ScrubRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, err
+		}
+		if pool == "" || r.Pool == pool {
+			records = append(records, r)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// ScrubHistory records the scrubs of pools in a ScrubStore and answers questions about them,
+// e.g. for compliance reporting.
+type ScrubHistory struct {
+	Store ScrubStore
+}
+
+// Record stores the pool's last scrub from its status, unless it has not finished or is already stored.
+// It returns whether a record was added.
+// Call it periodically, or from a ZED hook for "scrub_finish" events.
+func (h *ScrubHistory) Record(status *ZpoolStatus) (bool, error) {
+	r, err := scrubRecordFromStatus(status)
+	if err != nil || r == nil {
+		return false, err
+	}
+	records, err := h.Store.Records(r.Pool)
+	if err != nil {
+		return false, err
+	}
+	for _, old := range records {
+		if old.Start.Equal(r.Start) {
+			return false, nil
+		}
+	}
+	return true, h.Store.Add(*r)
+}
+
+// RecordPool retrieves the status of the named pool and records its last scrub (see Record).
+func (h *ScrubHistory) RecordPool(name string) (bool, error) {
+	status, err := GetZpoolStatusWithOptions(name, ZpoolStatusOptions{Parsable: true})
+	if err != nil {
+		return false, err
+	}
+	return h.Record(status)
+}
+
+// LastSuccessful returns the most recent successful scrub of the pool.
+func (h *ScrubHistory) LastSuccessful(pool string) (*ScrubRecord, error) {
+	last, err := h.LastSuccessfulByPool()
+	if err != nil {
+		return nil, err
+	}
+	r, ok := last[pool]
+	if !ok {
+		return nil, ErrNoScrub
+	}
+	return &r, nil
+}
+
+// LastSuccessfulByPool returns the most recent successful scrub of every pool with one.
+func (h *ScrubHistory) LastSuccessfulByPool() (map[string]ScrubRecord, error) {
+	records, err := h.Store.Records("")
+	if err != nil {
+		return nil, err
+	}
+	last := map[string]ScrubRecord{}
+	for _, r := range records {
+		if !r.Succeeded() {
+			continue
+		}
+		if prev, ok := last[r.Pool]; !ok || r.End.After(prev.End) {
+			last[r.Pool] = r
+		}
+	}
+	return last, nil
+}
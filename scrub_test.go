@@ -0,0 +1,117 @@
+package zfs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestScrubRecordFromStatus(t *testing.T) {
+	var status ZpoolStatus
+	err := json.Unmarshal([]byte(`{
+		"name": "tank",
+		"state": "ONLINE",
+		"scan_stats": {
+			"function": "SCRUB",
+			"state": "FINISHED",
+			"start_time": "1760486400",
+			"end_time": "1760490000",
+			"to_examine": "1099511627776",
+			"examined": "1099511627776",
+			"processed": "4096",
+			"errors": "2"
+		}
+	}`), &status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := scrubRecordFromStatus(&status)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := &ScrubRecord{
+		Pool:     "tank",
+		Start:    time.Unix(1760486400, 0),
+		End:      time.Unix(1760490000, 0),
+		State:    "FINISHED",
+		Errors:   2,
+		Repaired: 4096,
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %+v, got: %+v", want, got)
+	}
+	if got.Succeeded() {
+		t.Fatal("wanted a scrub with errors to not succeed")
+	}
+
+	status.ScanStats.State = "SCANNING"
+	if got, err := scrubRecordFromStatus(&status); got != nil || err != nil {
+		t.Fatalf("wanted no record for a running scrub, got: %v, %v", got, err)
+	}
+
+	status.ScanStats = &ZpoolScanStats{Function: "SCRUB", State: "FINISHED", StartTime: "Wed Oct 15 00:00:00 2025", EndTime: "Wed Oct 15 01:00:00 2025", Errors: "0", Processed: "0"}
+	got, err = scrubRecordFromStatus(&status)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := time.Date(2025, 10, 15, 1, 0, 0, 0, time.Local); !got.End.Equal(want) || !got.Succeeded() {
+		t.Fatalf("wanted a successful scrub ending at %v, got: %+v", want, got)
+	}
+}
+
+func TestScrubHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scrub-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	h := &ScrubHistory{Store: &FileScrubStore{Path: filepath.Join(dir, "scrubs.jsonl")}}
+
+	if _, err := h.LastSuccessful("tank"); err != ErrNoScrub {
+		t.Fatalf("wanted: %v, got: %v", ErrNoScrub, err)
+	}
+
+	scrub := func(pool string, start int64, state, errors string) *ZpoolStatus {
+		return &ZpoolStatus{Name: pool, ScanStats: &ZpoolScanStats{
+			Function:  "SCRUB",
+			State:     state,
+			StartTime: strconv.FormatInt(start, 10),
+			EndTime:   strconv.FormatInt(start+3600, 10),
+			Processed: "0",
+			Errors:    errors,
+		}}
+	}
+	for _, s := range []*ZpoolStatus{
+		scrub("tank", 1000, "FINISHED", "0"),
+		scrub("tank", 1000, "FINISHED", "0"),
+		scrub("tank", 2000, "FINISHED", "1"),
+		scrub("tank", 3000, "CANCELED", "0"),
+		scrub("backup", 1500, "FINISHED", "0"),
+	} {
+		if _, err := h.Record(s); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	records, err := h.Store.Records("tank")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("wanted 3 records for tank, duplicates skipped, got: %+v", records)
+	}
+
+	last, err := h.LastSuccessfulByPool()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(last) != 2 || last["tank"].Start.Unix() != 1000 || last["backup"].Start.Unix() != 1500 {
+		t.Fatalf("unexpected last successful scrubs: %+v", last)
+	}
+}
@@ -0,0 +1,309 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SendOptions controls the stream generated by `zfs send`.
+// The zero value generates a full stream of a single snapshot.
+//
+// The available options are described in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/8/zfs-send.8.html
+type SendOptions struct {
+	// IncrementalBase is the snapshot or bookmark to generate an incremental stream from (-i).
+	// It may be given in full, or in short form (e.g. "@snap" or "#bookmark") relative to the sent snapshot's file system.
+	IncrementalBase string
+	// Intermediate includes all intermediary snapshots between IncrementalBase and the sent snapshot (-I).
+	// Bookmarks cannot be used as the base of such a stream.
+	Intermediate bool
+	// Raw sends the data of encrypted datasets exactly as it is stored on disk (-w).
+	// Raw streams can be sent without loading the dataset's encryption key and are received still encrypted.
+	Raw bool
+	// Compressed sends blocks compressed as they are stored on disk, instead of decompressing them (-c).
+	Compressed bool
+	// EmbedData sends blocks that use the embedded_data feature as WRITE_EMBEDDED records (-e).
+	EmbedData bool
+	// LargeBlock permits blocks larger than 128KiB in the stream (-L).
+	LargeBlock bool
+	// Holds includes the user holds of the sent snapshots (-h).
+	Holds bool
+	// Props includes the dataset properties in the stream (-p).
+	Props bool
+	// Replicate generates a replication stream package of the file system and all its descendents,
+	// including their snapshots and properties (-R). Combined with an Intermediate IncrementalBase it
+	// generates an incremental replication stream.
+	Replicate bool
+	// ExcludeDatasets are left out of a replication stream (-X).
+	ExcludeDatasets []string
+
+	// Progress, if set, is called every ProgressInterval (one second by default) while the stream is sent,
+	// and once more when it has finished. The expected size is estimated before the send starts.
+	Progress         ProgressFunc
+	ProgressInterval time.Duration
+	// RateLimit, if set, caps the stream to this many bytes per second on average.
+	RateLimit uint64
+}
+
+// SendCapabilities reports which optional send flags are supported by the installed zfs command.
+type SendCapabilities struct {
+	Raw        bool `json:"raw"`
+	Compressed bool `json:"compressed"`
+	EmbedData  bool `json:"embed_data"`
+	LargeBlock bool `json:"large_block"`
+	Holds      bool `json:"holds"`
+	Props      bool `json:"props"`
+}
+
+var (
+	sendCapsOnce sync.Once
+	sendCaps     SendCapabilities
+	sendCapsErr  error
+)
+
+// DetectSendCapabilities determines the supported send flags from the usage text of the installed zfs command.
+// The result is detected once and cached for the lifetime of the process.
+func DetectSendCapabilities() (SendCapabilities, error) {
+	sendCapsOnce.Do(func() {
+		// zfs prints its usage to stderr and exits non-zero when send is given no snapshot
+		_, err := zfsOutput("send")
+		var e *Error
+		if !errors.As(err, &e) {
+			sendCapsErr = fmt.Errorf("could not obtain zfs send usage: %w", err)
+			return
+		}
+		sendCaps = parseSendCapabilities(e.Stderr)
+	})
+	return sendCaps, sendCapsErr
+}
+
+// sendUsageFlagsRegex matches the short flag groups in usage text, e.g. [-DLPbcehnpsVvw].
+var sendUsageFlagsRegex = regexp.MustCompile(`\[-([a-zA-Z]+)\]`)
+
+func parseSendCapabilities(usage string) SendCapabilities {
+	var flags string
+	for _, line := range strings.Split(usage, "\n") {
+		if !strings.Contains(line, "send") {
+			continue
+		}
+		for _, m := range sendUsageFlagsRegex.FindAllStringSubmatch(line, -1) {
+			flags += m[1]
+		}
+	}
+	return SendCapabilities{
+		Raw:        strings.Contains(flags, "w"),
+		Compressed: strings.Contains(flags, "c"),
+		EmbedData:  strings.Contains(flags, "e"),
+		LargeBlock: strings.Contains(flags, "L"),
+		Holds:      strings.Contains(flags, "h"),
+		Props:      strings.Contains(flags, "p"),
+	}
+}
+
+// check returns an error naming the first requested option that is not supported.
+func (c SendCapabilities) check(o SendOptions) error {
+	for _, opt := range []struct {
+		name                 string
+		requested, supported bool
+	}{
+		{"Raw", o.Raw, c.Raw},
+		{"Compressed", o.Compressed, c.Compressed},
+		{"EmbedData", o.EmbedData, c.EmbedData},
+		{"LargeBlock", o.LargeBlock, c.LargeBlock},
+		{"Holds", o.Holds, c.Holds},
+		{"Props", o.Props, c.Props},
+	} {
+		if opt.requested && !opt.supported {
+			return fmt.Errorf("send option %s is not supported by the installed zfs", opt.name)
+		}
+	}
+	return nil
+}
+
+// args returns the zfs send command-line arguments for the options.
+func (o SendOptions) args() ([]string, error) {
+	var args []string
+	if o.IncrementalBase != "" {
+		flag := "-i"
+		if o.Intermediate {
+			if strings.Contains(o.IncrementalBase, "#") {
+				return nil, errors.New("an intermediate incremental stream cannot be generated from a bookmark")
+			}
+			flag = "-I"
+		}
+		args = append(args, flag, o.IncrementalBase)
+	} else if o.Intermediate {
+		return nil, errors.New("an intermediate incremental stream requires an IncrementalBase")
+	}
+	if o.Replicate {
+		if strings.Contains(o.IncrementalBase, "#") {
+			return nil, errors.New("a replication stream cannot be generated from a bookmark")
+		}
+		args = append(args, "-R")
+		for _, ds := range o.ExcludeDatasets {
+			args = append(args, "-X", ds)
+		}
+	} else if len(o.ExcludeDatasets) > 0 {
+		return nil, errors.New("ExcludeDatasets requires a replication stream")
+	}
+	if o.Raw {
+		args = append(args, "-w")
+	}
+	if o.Compressed {
+		args = append(args, "-c")
+	}
+	if o.EmbedData {
+		args = append(args, "-e")
+	}
+	if o.LargeBlock {
+		args = append(args, "-L")
+	}
+	if o.Holds {
+		args = append(args, "-h")
+	}
+	if o.Props {
+		args = append(args, "-p")
+	}
+	return args, nil
+}
+
+// SendTo streams `zfs send` output for the receiving snapshot into w.
+// The send is aborted when ctx is cancelled, in which case the returned error wraps ctx.Err().
+// An error will be returned if the receiving dataset is not of snapshot type.
+func (d *Dataset) SendTo(ctx context.Context, w io.Writer, opts SendOptions) error {
+	if d.Type != DatasetSnapshot {
+		return errors.New("can only send snapshots")
+	}
+
+	flags, err := opts.args()
+	if err != nil {
+		return err
+	}
+	// only validate against the detected capabilities when detection succeeded
+	if caps, err := DetectSendCapabilities(); err == nil {
+		if err := caps.check(opts); err != nil {
+			return err
+		}
+	}
+	if opts.Raw && opts.IncrementalBase != "" {
+		if err := checkRawIncremental(d.Name, opts.IncrementalBase); err != nil {
+			return err
+		}
+	}
+
+	args := append([]string{"send"}, flags...)
+	args = append(args, d.Name)
+
+	if opts.RateLimit > 0 {
+		w = RateLimitWriter(w, opts.RateLimit)
+	}
+	if opts.Progress != nil {
+		// an unknown total only disables the ETA, so don't fail the send over it
+		total, _ := d.SendEstimate(opts)
+		p := startProgress(opts.Progress, opts.ProgressInterval, total)
+		defer p.stop()
+		w = &progressWriter{w: w, p: p}
+	}
+
+	c := command{Command: "zfs", Stdout: w, Ctx: ctx}
+	if _, err = c.Run(args...); err != nil {
+		if !opts.Raw && keyUnavailable(d.Name) {
+			return fmt.Errorf("encryption key of %s is not loaded, use a raw send: %w", d.Name, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// resolveIncrementalBase expands a short form ("@snap" or "#bookmark") incremental base to a full name,
+// using the file system of the sent snapshot.
+func resolveIncrementalBase(snapshot, base string) string {
+	if strings.HasPrefix(base, "@") || strings.HasPrefix(base, "#") {
+		return strings.SplitN(snapshot, "@", 2)[0] + base
+	}
+	return base
+}
+
+// checkRawIncremental verifies that a raw incremental stream of snapshot can be generated from base.
+// Raw incremental streams only chain when both ends share the same encryption root.
+func checkRawIncremental(snapshot, base string) error {
+	base = resolveIncrementalBase(snapshot, base)
+
+	snapRoot, err := getProperty(snapshot, "encryptionroot")
+	if err != nil {
+		return err
+	}
+	baseRoot, err := getProperty(base, "encryptionroot")
+	if err != nil {
+		return err
+	}
+	if snapRoot != baseRoot {
+		return fmt.Errorf("raw incremental stream from %s to %s crosses encryption roots (%q, %q)", base, snapshot, baseRoot, snapRoot)
+	}
+	return nil
+}
+
+// keyUnavailable reports whether the dataset is encrypted and its key is not loaded.
+// Errors are treated as the key being available, e.g. on platforms without encryption support.
+func keyUnavailable(name string) bool {
+	status, err := getProperty(name, "keystatus")
+	return err == nil && status == "unavailable"
+}
+
+// SendEstimate returns the estimated size in bytes of the stream `zfs send` would generate for the receiving snapshot with opts.
+// No stream is generated.
+// An error will be returned if the receiving dataset is not of snapshot type.
+func (d *Dataset) SendEstimate(opts SendOptions) (uint64, error) {
+	if d.Type != DatasetSnapshot {
+		return 0, errors.New("can only send snapshots")
+	}
+
+	flags, err := opts.args()
+	if err != nil {
+		return 0, err
+	}
+
+	args := append([]string{"send", "-nP"}, flags...)
+	args = append(args, d.Name)
+
+	out, err := zfsOutput(args...)
+	if err != nil {
+		return 0, err
+	}
+	return parseSendEstimate(out)
+}
+
+// example input for parseSendEstimate
+// incremental     test/fs@base    test/fs@snap    4211712
+// size    4211712
+
+func parseSendEstimate(lines [][]string) (uint64, error) {
+	for _, line := range lines {
+		if len(line) == 2 && line[0] == "size" {
+			var size uint64
+			if err := setUint(&size, line[1]); err != nil {
+				return 0, fmt.Errorf("failed to parse send size estimate: %w", err)
+			}
+			return size, nil
+		}
+	}
+	return 0, errors.New("no size estimate in zfs send output")
+}
+
+// ResumeSend streams the remainder of an interrupted send into w, using the receive_resume_token of the partially received target.
+// The send is aborted when ctx is cancelled, in which case the returned error wraps ctx.Err().
+func ResumeSend(ctx context.Context, token string, w io.Writer) error {
+	if token == "" {
+		return errors.New("empty receive resume token")
+	}
+
+	c := command{Command: "zfs", Stdout: w, Ctx: ctx}
+	_, err := c.Run("send", "-t", token)
+	return err
+}
@@ -0,0 +1,133 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSendOptionsArgs(t *testing.T) {
+	for name, test := range map[string]struct {
+		opts SendOptions
+		want []string
+	}{
+		"zero value": {
+			opts: SendOptions{},
+			want: nil,
+		},
+		"incremental": {
+			opts: SendOptions{IncrementalBase: "test/fs@base"},
+			want: []string{"-i", "test/fs@base"},
+		},
+		"incremental from bookmark": {
+			opts: SendOptions{IncrementalBase: "#base"},
+			want: []string{"-i", "#base"},
+		},
+		"raw incremental": {
+			opts: SendOptions{IncrementalBase: "@base", Raw: true},
+			want: []string{"-i", "@base", "-w"},
+		},
+		"stream format": {
+			opts: SendOptions{Compressed: true, EmbedData: true, LargeBlock: true, Holds: true, Props: true},
+			want: []string{"-c", "-e", "-L", "-h", "-p"},
+		},
+		"replication": {
+			opts: SendOptions{IncrementalBase: "@base", Intermediate: true, Replicate: true, ExcludeDatasets: []string{"test/fs/tmp"}},
+			want: []string{"-I", "@base", "-R", "-X", "test/fs/tmp"},
+		},
+		"intermediate": {
+			opts: SendOptions{IncrementalBase: "@base", Intermediate: true},
+			want: []string{"-I", "@base"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, err := test.opts.args()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(test.want, got) {
+				t.Fatalf("wanted: %v, got: %v", test.want, got)
+			}
+		})
+	}
+
+	for name, opts := range map[string]SendOptions{
+		"intermediate without base":  {Intermediate: true},
+		"intermediate from bookmark": {IncrementalBase: "test/fs#base", Intermediate: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := opts.args(); err == nil {
+				t.Fatal("wanted error, got nil")
+			}
+		})
+	}
+}
+
+func TestResolveIncrementalBase(t *testing.T) {
+	for base, want := range map[string]string{
+		"@base":        "test/fs@base",
+		"#base":        "test/fs#base",
+		"test/fs@base": "test/fs@base",
+		"test/fs#base": "test/fs#base",
+	} {
+		if got := resolveIncrementalBase("test/fs@snap", base); got != want {
+			t.Errorf("resolveIncrementalBase(%q): wanted: %q, got: %q", base, want, got)
+		}
+	}
+}
+
+func TestParseSendCapabilities(t *testing.T) {
+	for name, test := range map[string]struct {
+		usage string
+		want  SendCapabilities
+	}{
+		"openzfs 2.1": {
+			usage: `usage:
+	send [-DLPbcehnpsvw] [-i|-I snapshot]
+	     [-R [-X dataset[,dataset]...]]     <snapshot>
+	send [-DnvPLecw] [-i snapshot|bookmark] <filesystem|volume|snapshot>
+	send [-DnPpvLec] [-i bookmark|snapshot] --redact <bookmark> <snapshot>
+	send [-nvPe] -t <receive_resume_token>
+	send [-Pnv] --saved filesystem
+`,
+			want: SendCapabilities{Raw: true, Compressed: true, EmbedData: true, LargeBlock: true, Holds: true, Props: true},
+		},
+		"zfsonlinux 0.6": {
+			usage: `usage:
+	send [-DnPpRvLe] [-[iI] snapshot] <snapshot>
+	send [-Le] [-i snapshot|bookmark] <filesystem|volume|snapshot>
+`,
+			want: SendCapabilities{EmbedData: true, LargeBlock: true, Props: true},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got := parseSendCapabilities(test.usage)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Fatalf("wanted: %+v, got: %+v", test.want, got)
+			}
+			if err := got.check(SendOptions{}); err != nil {
+				t.Fatalf("unexpected error for zero value: %v", err)
+			}
+		})
+	}
+
+	if err := (SendCapabilities{}).check(SendOptions{Compressed: true}); err == nil {
+		t.Fatal("wanted error for unsupported option, got nil")
+	}
+}
+
+func TestParseSendEstimate(t *testing.T) {
+	got, err := parseSendEstimate([][]string{
+		{"incremental", "test/fs@base", "test/fs@snap", "4211712"},
+		{"size", "4211712"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != 4211712 {
+		t.Fatalf("wanted: 4211712, got: %d", got)
+	}
+
+	if _, err := parseSendEstimate([][]string{{"full", "test/fs@snap", "4211712"}}); err == nil {
+		t.Fatal("wanted error for missing size line, got nil")
+	}
+}
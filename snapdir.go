@@ -0,0 +1,49 @@
+package zfs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotMounted is returned by SnapshotDir when the file system of a snapshot is not mounted.
+var ErrNotMounted = errors.New("file system is not mounted")
+
+// SnapshotDir returns the directory in which the contents of a file system snapshot can be read,
+// <mountpoint>/.zfs/snapshot/<name>. It works regardless of the snapdir property, as the hidden .zfs directory
+// can always be entered, and ZFS mounts the snapshot on first access.
+// The file system must be mounted at a non-legacy mountpoint.
+func SnapshotDir(snapshot string) (string, error) {
+	dataset, snap := splitSnapshotName(snapshot)
+	if snap == "" {
+		return "", fmt.Errorf("%s is not a snapshot", snapshot)
+	}
+	out, err := zfsOutput("get", "-H", "-o", "value", "mountpoint,mounted", dataset)
+	if err != nil {
+		return "", err
+	}
+	if len(out) != 2 || len(out[0]) != 1 || len(out[1]) != 1 {
+		return "", errors.New("output does not match what is expected on this platform")
+	}
+	dir, err := snapshotDir(dataset, snap, out[0][0], out[1][0])
+	if err != nil {
+		return "", err
+	}
+	// entering the directory mounts the snapshot, and fails if it does not exist
+	if _, err := os.Stat(dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+func snapshotDir(dataset, snap, mountpoint, mounted string) (string, error) {
+	if mounted != "yes" || !filepath.IsAbs(mountpoint) {
+		return "", fmt.Errorf("%w: %s", ErrNotMounted, dataset)
+	}
+	if strings.Contains(snap, "/") {
+		return "", fmt.Errorf("%w: %s", ErrInvalidName, snap)
+	}
+	return filepath.Join(mountpoint, ".zfs", "snapshot", snap), nil
+}
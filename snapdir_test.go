@@ -0,0 +1,26 @@
+package zfs
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSnapshotDir(t *testing.T) {
+	tests := []struct {
+		mountpoint, mounted string
+		want                string
+		err                 error
+	}{
+		{"/tank/fs", "yes", "/tank/fs/.zfs/snapshot/daily", nil},
+		{"/tank/fs/", "yes", "/tank/fs/.zfs/snapshot/daily", nil},
+		{"/tank/fs", "no", "", ErrNotMounted},
+		{"legacy", "yes", "", ErrNotMounted},
+		{"none", "no", "", ErrNotMounted},
+	}
+	for _, test := range tests {
+		got, err := snapshotDir("tank/fs", "daily", test.mountpoint, test.mounted)
+		if got != test.want || !errors.Is(err, test.err) {
+			t.Fatalf("wanted: %v %v, got: %v %v", test.want, test.err, got, err)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+//go:build go1.16
+// +build go1.16
+
+package zfs
+
+import (
+	"io/fs"
+	"os"
+)
+
+// SnapshotFS returns the contents of a file system snapshot as an fs.FS, read from its directory
+// below the hidden .zfs directory (see SnapshotDir). Files can then be read with the standard library,
+// e.g. fs.ReadFile or fs.WalkDir, to restore them from a point in time.
+func SnapshotFS(snapshot string) (fs.FS, error) {
+	dir, err := SnapshotDir(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	return os.DirFS(dir), nil
+}
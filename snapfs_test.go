@@ -0,0 +1,35 @@
+//go:build go1.16
+// +build go1.16
+
+package zfs_test
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/mistifyio/go-zfs/v3"
+)
+
+func TestSnapshotFS(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/snapfs", map[string]string{"snapdir": "hidden"})
+	ok(t, err)
+	ok(t, ioutil.WriteFile(filepath.Join(f.Mountpoint, "file"), []byte("before"), 0o644))
+	s, err := f.Snapshot("snap", false)
+	ok(t, err)
+	ok(t, ioutil.WriteFile(filepath.Join(f.Mountpoint, "file"), []byte("after"), 0o644))
+
+	fsys, err := zfs.SnapshotFS(s.Name)
+	ok(t, err)
+	b, err := fs.ReadFile(fsys, "file")
+	ok(t, err)
+	equals(t, "before", string(b))
+
+	_, err = zfs.SnapshotFS("test/snapfs@missing")
+	nok(t, err)
+
+	ok(t, f.Destroy(zfs.DestroyRecursive))
+}
@@ -0,0 +1,49 @@
+package zfs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// tempSnapshotPrefix starts the names of the snapshots created by WithTempSnapshot,
+// so that leftovers, e.g. of a process that was killed, can be recognized.
+const tempSnapshotPrefix = "tmp-"
+
+// tempSnapshotName returns a unique snapshot name, e.g. "tmp-20240102T150405-1a2b3c4d".
+func tempSnapshotName(now time.Time) (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return tempSnapshotPrefix + now.UTC().Format("20060102T150405") + "-" + hex.EncodeToString(b), nil
+}
+
+// WithTempSnapshot snapshots the named dataset with a unique name, calls fn with the snapshot, and destroys
+// the snapshot again once fn returns, even if it fails or panics. fn can e.g. read a consistent copy of the
+// file system from SnapshotDir, or send the snapshot.
+// The snapshot is destroyed regardless of ctx, which only bounds its creation. An error destroying it is
+// returned if fn succeeded.
+func WithTempSnapshot(ctx context.Context, dataset string, fn func(snapshot *Dataset) error) (err error) {
+	name, err := tempSnapshotName(time.Now())
+	if err != nil {
+		return fmt.Errorf("could not generate a snapshot name: %w", err)
+	}
+	snapshot := &Dataset{Name: dataset + "@" + name, Type: DatasetSnapshot}
+	if err := zfsContext(ctx, "snapshot", snapshot.Name); err != nil {
+		return err
+	}
+	defer func() {
+		// a mounted .zfs/snapshot directory is unmounted by the destroy
+		if destroyErr := zfs("destroy", snapshot.Name); destroyErr != nil && err == nil {
+			err = fmt.Errorf("could not destroy temporary snapshot: %w", destroyErr)
+		}
+	}()
+
+	if s, err := GetDataset(snapshot.Name); err == nil {
+		snapshot = s
+	}
+	return fn(snapshot)
+}
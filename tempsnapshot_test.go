@@ -0,0 +1,28 @@
+package zfs
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTempSnapshotName(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	a, err := tempSnapshotName(now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := tempSnapshotName(now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(a, "tmp-20240102T150405-") || len(a) != len("tmp-20240102T150405-")+8 {
+		t.Fatalf("unexpected name: %s", a)
+	}
+	if a == b {
+		t.Fatalf("wanted unique names, got: %s twice", a)
+	}
+	if err := ValidateSnapshotName("tank/fs@" + a); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package zfs
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// StreamTransform is an io-level transformation of a send stream, such as compression or encryption.
+// The sending side of a stream is wrapped with WrapWriter and the receiving side with WrapReader, which undoes the transform.
+type StreamTransform interface {
+	// WrapWriter returns a writer that transforms the data written to it and writes the result to w.
+	// Closing it must flush any buffered data, but must not close w.
+	WrapWriter(w io.Writer) (io.WriteCloser, error)
+	// WrapReader returns a reader that undoes the transform of the data read from r.
+	WrapReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// RemoteDecoder is implemented by a StreamTransform that can be undone by a command on a remote host,
+// as required by ReplicateRemote, e.g. []string{"gzip", "-dc"}.
+type RemoteDecoder interface {
+	DecodeCommand() []string
+}
+
+// GzipTransform compresses streams with gzip.
+type GzipTransform struct {
+	// Level is the gzip compression level, gzip.DefaultCompression is used if 0.
+	Level int
+}
+
+// WrapWriter implements StreamTransform.
+func (g GzipTransform) WrapWriter(w io.Writer) (io.WriteCloser, error) {
+	level := g.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	return gzip.NewWriterLevel(w, level)
+}
+
+// WrapReader implements StreamTransform.
+func (GzipTransform) WrapReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// DecodeCommand implements RemoteDecoder.
+func (GzipTransform) DecodeCommand() []string {
+	return []string{"gzip", "-dc"}
+}
+
+// TransformFuncs adapts a pair of functions to a StreamTransform.
+type TransformFuncs struct {
+	Writer func(w io.Writer) (io.WriteCloser, error)
+	Reader func(r io.Reader) (io.ReadCloser, error)
+}
+
+// WrapWriter implements StreamTransform.
+func (f TransformFuncs) WrapWriter(w io.Writer) (io.WriteCloser, error) {
+	return f.Writer(w)
+}
+
+// WrapReader implements StreamTransform.
+func (f TransformFuncs) WrapReader(r io.Reader) (io.ReadCloser, error) {
+	return f.Reader(r)
+}
+
+// transformChain closes a chain of wrapped writers or readers, outermost first.
+type transformChain struct {
+	io.Writer
+	io.Reader
+	closers []io.Closer
+}
+
+func (c *transformChain) Close() error {
+	var first error
+	for i := len(c.closers) - 1; i >= 0; i-- {
+		if err := c.closers[i].Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// TransformWriter returns a writer that applies the transforms in order to the data written to it and writes the result to w.
+// It must be closed to flush the transforms, which does not close w.
+func TransformWriter(w io.Writer, transforms ...StreamTransform) (io.WriteCloser, error) {
+	c := &transformChain{Writer: w}
+	for i := len(transforms) - 1; i >= 0; i-- {
+		wc, err := transforms[i].WrapWriter(c.Writer)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		c.closers = append(c.closers, wc)
+		c.Writer = wc
+	}
+	return c, nil
+}
+
+// TransformReader returns a reader that undoes the transforms applied by TransformWriter to the data read from r.
+func TransformReader(r io.Reader, transforms ...StreamTransform) (io.ReadCloser, error) {
+	c := &transformChain{Reader: r}
+	for i := len(transforms) - 1; i >= 0; i-- {
+		rc, err := transforms[i].WrapReader(c.Reader)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		c.closers = append(c.closers, rc)
+		c.Reader = rc
+	}
+	return c, nil
+}
+
+// remoteDecodePipeline returns the shell pipeline that undoes transforms on a remote host, ending in a pipe to the next command.
+func remoteDecodePipeline(transforms []StreamTransform) (string, error) {
+	var cmds []string
+	for i := len(transforms) - 1; i >= 0; i-- {
+		d, ok := transforms[i].(RemoteDecoder)
+		if !ok {
+			return "", fmt.Errorf("stream transform %T cannot be undone remotely", transforms[i])
+		}
+		args := d.DecodeCommand()
+		quoted := make([]string, len(args))
+		for j, a := range args {
+			quoted[j] = shellQuote(a)
+		}
+		cmds = append(cmds, strings.Join(quoted, " ")+" | ")
+	}
+	return strings.Join(cmds, ""), nil
+}
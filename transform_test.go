@@ -0,0 +1,96 @@
+package zfs
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// xorTransform is a trivial, self-inverse transform.
+type xorTransform byte
+
+type xorWriter struct {
+	w   io.Writer
+	key byte
+}
+
+func (x *xorWriter) Write(p []byte) (int, error) {
+	b := make([]byte, len(p))
+	for i := range p {
+		b[i] = p[i] ^ x.key
+	}
+	return x.w.Write(b)
+}
+
+func (x *xorWriter) Close() error { return nil }
+
+type xorReader struct {
+	r   io.Reader
+	key byte
+}
+
+func (x *xorReader) Read(p []byte) (int, error) {
+	n, err := x.r.Read(p)
+	for i := 0; i < n; i++ {
+		p[i] ^= x.key
+	}
+	return n, err
+}
+
+func (x *xorReader) Close() error { return nil }
+
+func (k xorTransform) WrapWriter(w io.Writer) (io.WriteCloser, error) {
+	return &xorWriter{w: w, key: byte(k)}, nil
+}
+
+func (k xorTransform) WrapReader(r io.Reader) (io.ReadCloser, error) {
+	return &xorReader{r: r, key: byte(k)}, nil
+}
+
+func TestTransformRoundTrip(t *testing.T) {
+	transforms := []StreamTransform{GzipTransform{}, xorTransform(0x5a)}
+	data := bytes.Repeat([]byte("zfs send stream "), 1024)
+
+	var encoded bytes.Buffer
+	w, err := TransformWriter(&encoded, transforms...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if encoded.Len() >= len(data) {
+		t.Fatalf("wanted compressed stream, got %d bytes for %d", encoded.Len(), len(data))
+	}
+
+	r, err := TransformReader(&encoded, transforms...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.Close()
+	decoded, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(data, decoded) {
+		t.Fatal("decoded stream does not match the original")
+	}
+}
+
+func TestRemoteDecodePipeline(t *testing.T) {
+	got, err := remoteDecodePipeline([]StreamTransform{GzipTransform{}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := `'gzip' '-dc' | `; got != want {
+		t.Fatalf("wanted: %q, got: %q", want, got)
+	}
+
+	if _, err := remoteDecodePipeline([]StreamTransform{xorTransform(1)}); err == nil {
+		t.Fatal("wanted error for transform without RemoteDecoder, got nil")
+	}
+}
@@ -0,0 +1,154 @@
+package zfs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var (
+	propsOnce  sync.Once
+	knownProps map[string]bool
+	propsErr   error
+)
+
+// DetectProperties determines the dataset properties known to the installed zfs command
+// from the property table in its usage text, e.g. whether "direct" is among them.
+// The result is detected once and cached for the lifetime of the process.
+func DetectProperties() (map[string]bool, error) {
+	propsOnce.Do(func() {
+		// zfs prints its usage to stderr and exits non-zero when get is given no property
+		_, err := zfsOutput("get")
+		var e *Error
+		if !errors.As(err, &e) {
+			propsErr = fmt.Errorf("could not obtain zfs get usage: %w", err)
+			return
+		}
+		knownProps = parseUsageProperties(e.Stderr)
+		if len(knownProps) == 0 {
+			propsErr = errors.New("zfs get usage lists no properties")
+		}
+	})
+	return knownProps, propsErr
+}
+
+// example input for parseUsageProperties
+//	PROPERTY       EDIT  INHERIT   VALUES
+//
+//	available        NO       NO   <size>
+//	direct          YES      YES   disabled | standard | always
+
+func parseUsageProperties(usage string) map[string]bool {
+	known := map[string]bool{}
+	for _, line := range strings.Split(usage, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[0] == "PROPERTY" {
+			continue
+		}
+		if (fields[1] == "YES" || fields[1] == "NO") && (fields[2] == "YES" || fields[2] == "NO") {
+			known[fields[0]] = true
+		}
+	}
+	return known
+}
+
+// requireProperty returns an error if the installed zfs command does not know the named property.
+// Nothing is checked if the properties cannot be detected, in which case zfs has to reject it itself.
+func requireProperty(name string) error {
+	known, err := DetectProperties()
+	if err == nil && !known[name] {
+		return fmt.Errorf("property %s is not supported by the installed zfs", name)
+	}
+	return nil
+}
+
+// DirectIO is a value of the direct property, which controls whether O_DIRECT reads and writes bypass the ARC.
+type DirectIO string
+
+// Direct I/O modes. The direct property was added in OpenZFS 2.3.
+const (
+	// DirectStandard bypasses the ARC for O_DIRECT requests that are suitably aligned, the default.
+	DirectStandard DirectIO = "standard"
+	// DirectAlways bypasses the ARC for all suitably aligned requests.
+	DirectAlways DirectIO = "always"
+	// DirectDisabled ignores O_DIRECT.
+	DirectDisabled DirectIO = "disabled"
+)
+
+// SetDirect sets the direct property of the receiving dataset.
+func (d *Dataset) SetDirect(mode DirectIO) error {
+	switch mode {
+	case DirectStandard, DirectAlways, DirectDisabled:
+	default:
+		return fmt.Errorf("invalid direct mode %q", mode)
+	}
+	if err := requireProperty("direct"); err != nil {
+		return err
+	}
+	return d.SetProperty("direct", string(mode))
+}
+
+// Direct returns the direct property of the receiving dataset.
+func (d *Dataset) Direct() (DirectIO, error) {
+	if err := requireProperty("direct"); err != nil {
+		return "", err
+	}
+	val, err := d.GetProperty("direct")
+	return DirectIO(val), err
+}
+
+// Prefetch is a value of the prefetch property, which controls which data is read ahead for sequential reads.
+type Prefetch string
+
+// Prefetch modes. The prefetch property was added in OpenZFS 2.2.
+const (
+	// PrefetchAll prefetches data and metadata, the default.
+	PrefetchAll      Prefetch = "all"
+	PrefetchMetadata Prefetch = "metadata"
+	PrefetchNone     Prefetch = "none"
+)
+
+// SetPrefetch sets the prefetch property of the receiving dataset.
+func (d *Dataset) SetPrefetch(mode Prefetch) error {
+	switch mode {
+	case PrefetchAll, PrefetchMetadata, PrefetchNone:
+	default:
+		return fmt.Errorf("invalid prefetch mode %q", mode)
+	}
+	if err := requireProperty("prefetch"); err != nil {
+		return err
+	}
+	return d.SetProperty("prefetch", string(mode))
+}
+
+// Prefetch returns the prefetch property of the receiving dataset.
+func (d *Dataset) Prefetch() (Prefetch, error) {
+	if err := requireProperty("prefetch"); err != nil {
+		return "", err
+	}
+	val, err := d.GetProperty("prefetch")
+	return Prefetch(val), err
+}
+
+// SetRelatime sets the relatime property of the receiving dataset, with which access times are only updated
+// if they are older than the modification or change time, or a day old. It only matters while atime is on.
+func (d *Dataset) SetRelatime(on bool) error {
+	if err := requireProperty("relatime"); err != nil {
+		return err
+	}
+	val := "off"
+	if on {
+		val = "on"
+	}
+	return d.SetProperty("relatime", val)
+}
+
+// Relatime returns whether the relatime property of the receiving dataset is on.
+func (d *Dataset) Relatime() (bool, error) {
+	if err := requireProperty("relatime"); err != nil {
+		return false, err
+	}
+	val, err := d.GetProperty("relatime")
+	return val == "on", err
+}
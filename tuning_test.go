@@ -0,0 +1,30 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseUsageProperties(t *testing.T) {
+	usage := "missing property argument\n" +
+		"usage:\n" +
+		"\tget [-rHp] [-d max] [-o \"all\" | field[,...]]\n" +
+		"\t    [-t type[,...]] [-s source[,...]]\n" +
+		"\t    <\"all\" | property[,...]> [filesystem|volume|snapshot|bookmark] ...\n" +
+		"\n" +
+		"The following properties are supported:\n" +
+		"\n" +
+		"\tPROPERTY       EDIT  INHERIT   VALUES\n" +
+		"\n" +
+		"\tavailable        NO       NO   <size>\n" +
+		"\tdirect          YES      YES   disabled | standard | always\n" +
+		"\tprefetch        YES      YES   none | metadata | all\n" +
+		"\trelatime        YES      YES   on | off\n" +
+		"\tuserused@...     NO       NO   <size>\n" +
+		"\n" +
+		"Sizes are specified in bytes with standard units such as K, M, G, etc.\n"
+	want := map[string]bool{"available": true, "direct": true, "prefetch": true, "relatime": true, "userused@...": true}
+	if got := parseUsageProperties(usage); !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %v, got: %v", want, got)
+	}
+}
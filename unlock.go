@@ -0,0 +1,149 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// KeyProvider supplies the key of a locked encryption root, for example by fetching it from a KMS or secret store.
+// Returning a nil io.Reader loads the key from the root's keylocation property instead.
+type KeyProvider func(ctx context.Context, root string, status *EncryptionStatus) (io.Reader, error)
+
+// UnlockResult is the outcome of unlocking a single encryption root.
+type UnlockResult struct {
+	// Root is the encryption root whose key was loaded.
+	Root string
+	// Mounted lists the file systems that were mounted after the key was loaded.
+	Mounted []string
+	// Err is the first error encountered for this root, if any.
+	Err error
+}
+
+// unlockPlan is a locked encryption root along with the file systems to mount once it is unlocked.
+type unlockPlan struct {
+	root   string
+	status *EncryptionStatus
+	mounts []string
+}
+
+// UnlockAll loads the keys of all locked encryption roots and mounts the file systems below them,
+// which is the usual workflow at boot time.
+// The provider is called once per encryption root.
+// A failure to unlock one root does not stop the others; it is reported in that root's UnlockResult.
+func UnlockAll(ctx context.Context, provider KeyProvider) ([]UnlockResult, error) {
+	if provider == nil {
+		return nil, errors.New("a KeyProvider is required")
+	}
+	c := command{Command: "zfs", Ctx: ctx}
+	out, err := c.Run("get", "-Hp", "-t", "filesystem,volume", "-o", "name,property,value",
+		"type,encryption,keystatus,keyformat,keylocation,encryptionroot,canmount,mountpoint")
+	if err != nil {
+		return nil, err
+	}
+	plans, err := parseUnlockPlans(out)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]UnlockResult, 0, len(plans))
+	for _, p := range plans {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		results = append(results, unlock(ctx, p, provider))
+	}
+	return results, nil
+}
+
+func unlock(ctx context.Context, p unlockPlan, provider KeyProvider) UnlockResult {
+	res := UnlockResult{Root: p.root}
+
+	key, err := provider(ctx, p.root, p.status)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	flags, err := LoadKeyOptions{Key: key}.args()
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	c := command{Command: "zfs", Stdin: key, Ctx: ctx}
+	if _, err := c.Run(append(append([]string{"load-key"}, flags...), p.root)...); err != nil {
+		res.Err = err
+		return res
+	}
+
+	for _, name := range p.mounts {
+		c := command{Command: "zfs", Ctx: ctx}
+		if _, err := c.Run("mount", name); err != nil {
+			res.Err = err
+			return res
+		}
+		res.Mounted = append(res.Mounted, name)
+	}
+	return res
+}
+
+// parseUnlockPlans groups the locked datasets of a `zfs get -o name,property,value` listing by encryption root.
+// Roots are kept in listing order, so parents are unlocked and mounted before their children.
+func parseUnlockPlans(lines [][]string) ([]unlockPlan, error) {
+	type dsProps struct {
+		name  string
+		props map[string]string
+	}
+	var datasets []*dsProps
+	byName := map[string]*dsProps{}
+	for _, line := range lines {
+		if len(line) != 3 {
+			return nil, errors.New("output does not match what is expected on this platform")
+		}
+		ds, ok := byName[line[0]]
+		if !ok {
+			ds = &dsProps{name: line[0], props: map[string]string{}}
+			byName[line[0]] = ds
+			datasets = append(datasets, ds)
+		}
+		ds.props[line[1]] = line[2]
+	}
+
+	var plans []unlockPlan
+	index := map[string]int{}
+	for _, ds := range datasets {
+		if KeyStatus(ds.props["keystatus"]) != KeyStatusUnavailable {
+			continue
+		}
+		root := ds.props["encryptionroot"]
+		i, ok := index[root]
+		if !ok {
+			i = len(plans)
+			index[root] = i
+			plans = append(plans, unlockPlan{root: root})
+		}
+		if ds.name == root {
+			status, err := parseEncryptionStatus([][]string{
+				{"encryption", ds.props["encryption"]},
+				{"keystatus", ds.props["keystatus"]},
+				{"keyformat", ds.props["keyformat"]},
+				{"keylocation", ds.props["keylocation"]},
+				{"encryptionroot", root},
+			})
+			if err != nil {
+				return nil, err
+			}
+			plans[i].status = status
+		}
+		if ds.props["type"] == DatasetFilesystem && ds.props["canmount"] == "on" &&
+			ds.props["mountpoint"] != "none" && ds.props["mountpoint"] != "legacy" {
+			plans[i].mounts = append(plans[i].mounts, ds.name)
+		}
+	}
+	for i := range plans {
+		if plans[i].status == nil {
+			return nil, fmt.Errorf("encryption root %s is not listed", plans[i].root)
+		}
+	}
+	return plans, nil
+}
@@ -0,0 +1,80 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseUnlockPlans(t *testing.T) {
+	out := splitOutput(`test	type	filesystem
+test	encryption	off
+test	keystatus	-
+test	keyformat	none
+test	keylocation	none
+test	encryptionroot	-
+test	canmount	on
+test	mountpoint	/test
+test/secret	type	filesystem
+test/secret	encryption	aes-256-gcm
+test/secret	keystatus	unavailable
+test/secret	keyformat	hex
+test/secret	keylocation	file:///etc/zfs/secret.key
+test/secret	encryptionroot	test/secret
+test/secret	canmount	on
+test/secret	mountpoint	/test/secret
+test/secret/legacy	type	filesystem
+test/secret/legacy	encryption	aes-256-gcm
+test/secret/legacy	keystatus	unavailable
+test/secret/legacy	keyformat	hex
+test/secret/legacy	keylocation	none
+test/secret/legacy	encryptionroot	test/secret
+test/secret/legacy	canmount	on
+test/secret/legacy	mountpoint	legacy
+test/secret/vol	type	volume
+test/secret/vol	encryption	aes-256-gcm
+test/secret/vol	keystatus	unavailable
+test/secret/vol	keyformat	hex
+test/secret/vol	keylocation	none
+test/secret/vol	encryptionroot	test/secret
+test/secret/vol	canmount	-
+test/secret/vol	mountpoint	-
+test/secret/data	type	filesystem
+test/secret/data	encryption	aes-256-gcm
+test/secret/data	keystatus	unavailable
+test/secret/data	keyformat	hex
+test/secret/data	keylocation	none
+test/secret/data	encryptionroot	test/secret
+test/secret/data	canmount	on
+test/secret/data	mountpoint	/test/secret/data
+test/open	type	filesystem
+test/open	encryption	aes-256-gcm
+test/open	keystatus	available
+test/open	keyformat	passphrase
+test/open	keylocation	prompt
+test/open	encryptionroot	test/open
+test/open	canmount	on
+test/open	mountpoint	/test/open
+`)
+	got, err := parseUnlockPlans(out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []unlockPlan{{
+		root: "test/secret",
+		status: &EncryptionStatus{
+			Encryption:     "aes-256-gcm",
+			KeyStatus:      KeyStatusUnavailable,
+			KeyFormat:      KeyFormatHex,
+			KeyLocation:    "file:///etc/zfs/secret.key",
+			EncryptionRoot: "test/secret",
+		},
+		mounts: []string{"test/secret", "test/secret/data"},
+	}}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %+v, got: %+v", want, got)
+	}
+
+	if _, err := parseUnlockPlans(out[16:24]); err == nil {
+		t.Fatal("expected an error for a locked dataset without its encryption root")
+	}
+}
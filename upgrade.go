@@ -0,0 +1,107 @@
+package zfs
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// UpgradeableDataset is a file system formatted with an older version than the current one.
+type UpgradeableDataset struct {
+	Name string `json:"name"`
+	// Version is the file system version the dataset is formatted with.
+	Version uint64 `json:"version"`
+	// Current is the file system version of the running system, which Upgrade upgrades to by default.
+	Current uint64 `json:"current"`
+}
+
+// ListUpgradeableDatasets returns the file systems whose version is older than the current file system version,
+// e.g. after a pool was upgraded.
+// Upgraded file systems, and send streams of their snapshots, are not accessible by older software versions.
+//
+// More information regarding zfs upgrade can be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/8/zfs-upgrade.8.html
+func ListUpgradeableDatasets() ([]UpgradeableDataset, error) {
+	out, err := zfsOutput("upgrade")
+	if err != nil {
+		return nil, err
+	}
+	return parseUpgradeable(out)
+}
+
+var upgradeVersionRe = regexp.MustCompile(`filesystem version (\d+)`)
+
+// example input for parseUpgradeable
+// This system is currently running ZFS filesystem version 5.
+//
+// The following filesystems are out of date, and can be upgraded.  After being
+// upgraded, these filesystems (and any 'zfs send' streams generated from
+// subsequent snapshots) will no longer be accessible by older software versions.
+//
+//
+// VER  FILESYSTEM
+// ---  ------------
+//  4   tank/old
+//
+// The following filesystems are formatted using a newer software version and
+// cannot be accessed on the current system.
+// ...
+
+func parseUpgradeable(lines [][]string) ([]UpgradeableDataset, error) {
+	var (
+		current    uint64
+		upgradable bool
+		rows       bool
+		datasets   []UpgradeableDataset
+	)
+	for _, line := range lines {
+		text := strings.TrimSpace(strings.Join(line, "\t"))
+		switch {
+		case current == 0:
+			m := upgradeVersionRe.FindStringSubmatch(text)
+			if m == nil {
+				continue
+			}
+			v, err := strconv.ParseUint(m[1], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			current = v
+		case strings.HasPrefix(text, "The following filesystems"):
+			upgradable = strings.Contains(text, "out of date")
+			rows = false
+		case strings.HasPrefix(text, "---"):
+			rows = true
+		case text == "":
+			rows = false
+		case rows && upgradable:
+			fields := strings.Fields(text)
+			if len(fields) != 2 {
+				return nil, errors.New("output does not match what is expected on this platform")
+			}
+			ds := UpgradeableDataset{Name: fields[1], Current: current}
+			if err := setUint(&ds.Version, fields[0]); err != nil {
+				return nil, err
+			}
+			datasets = append(datasets, ds)
+		}
+	}
+	if current == 0 {
+		return nil, errors.New("output does not match what is expected on this platform")
+	}
+	return datasets, nil
+}
+
+// Upgrade upgrades the receiving file system to the given file system version, or to the current one if version is 0.
+// See ListUpgradeableDatasets.
+func (d *Dataset) Upgrade(version uint64) error {
+	if d.Type != "" && d.Type != DatasetFilesystem {
+		return errors.New("can only upgrade filesystems")
+	}
+	args := []string{"upgrade"}
+	if version > 0 {
+		args = append(args, "-V", strconv.FormatUint(version, 10))
+	}
+	return zfs(append(args, d.Name)...)
+}
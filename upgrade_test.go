@@ -0,0 +1,44 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseUpgradeable(t *testing.T) {
+	out := `This system is currently running ZFS filesystem version 5.
+
+The following filesystems are out of date, and can be upgraded.  After being
+upgraded, these filesystems (and any 'zfs send' streams generated from
+subsequent snapshots) will no longer be accessible by older software versions.
+
+
+VER  FILESYSTEM
+---  ------------
+ 3   tank/ancient
+ 4   tank/old
+
+The following filesystems are formatted using a newer software version and
+cannot be accessed on the current system.
+
+VER  FILESYSTEM
+---  ------------
+ 6   tank/future
+`
+	got, err := parseUpgradeable(splitOutput(out))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []UpgradeableDataset{{Name: "tank/ancient", Version: 3, Current: 5}, {Name: "tank/old", Version: 4, Current: 5}}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %v, got: %v", want, got)
+	}
+
+	got, err = parseUpgradeable(splitOutput("This system is currently running ZFS filesystem version 5.\n\nAll filesystems are formatted with the current version.\n"))
+	if err != nil || len(got) != 0 {
+		t.Fatalf("wanted: none, got: %v %v", got, err)
+	}
+	if _, err := parseUpgradeable(splitOutput("unexpected\n")); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -2,14 +2,15 @@ package zfs
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
-	"os/exec"
 	"regexp"
-	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -18,10 +19,16 @@ type command struct {
 	Command string
 	Stdin   io.Reader
 	Stdout  io.Writer
+	Ctx     context.Context
+	Runner  Runner
 }
 
 func (c *command) Run(arg ...string) ([][]string, error) {
-	cmd := exec.Command(c.Command, arg...)
+	runner := c.Runner
+	if runner == nil {
+		runner = localRunner{}
+	}
+	cmd := runner.Command(c.Ctx, c.Command, arg...)
 
 	var stdout, stderr bytes.Buffer
 
@@ -37,13 +44,24 @@ func (c *command) Run(arg ...string) ([][]string, error) {
 	cmd.Stderr = &stderr
 
 	id := uuid.New().String()
-	joinedArgs := cmd.Path
+	var args []string
 	if len(cmd.Args) > 1 {
-		joinedArgs = strings.Join(append([]string{cmd.Path}, cmd.Args[1:]...), " ")
+		args = cmd.Args[1:]
 	}
+	joinedArgs := joinArgs(cmd.Path, args)
+
+	release, err := acquireCommand(c.Ctx, c.Command, arg)
+	if err != nil {
+		return nil, &Error{Err: err, Debug: joinedArgs}
+	}
+	defer release()
 
 	logger.Log([]string{"ID:" + id, "START", joinedArgs})
 	if err := cmd.Run(); err != nil {
+		// report cancellation rather than the resulting "signal: killed"
+		if c.Ctx != nil && c.Ctx.Err() != nil {
+			err = c.Ctx.Err()
+		}
 		return nil, &Error{
 			Err:    err,
 			Debug:  joinedArgs,
@@ -91,47 +109,81 @@ func setUint(field *uint64, value string) error {
 	return nil
 }
 
-func (d *Dataset) parseLine(line []string) error {
-	var err error
-
-	if len(line) != len(dsPropList) {
-		return errors.New("output does not match what is expected on this platform")
+func setTime(field *time.Time, value string) error {
+	var v time.Time
+	if value != "-" {
+		secs, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v = time.Unix(secs, 0)
 	}
-	setString(&d.Name, line[0])
-	setString(&d.Origin, line[1])
+	*field = v
+	return nil
+}
 
-	if err = setUint(&d.Used, line[2]); err != nil {
-		return err
-	}
-	if err = setUint(&d.Avail, line[3]); err != nil {
-		return err
+// joinArgs joins a command line for logs and errors, quoting arguments that would otherwise be ambiguous,
+// such as dataset names containing spaces. Commands are never run through a shell.
+func joinArgs(path string, args []string) string {
+	s := make([]string, 1, len(args)+1)
+	s[0] = path
+	for _, arg := range args {
+		if arg == "" || strings.IndexFunc(arg, needsQuoting) >= 0 {
+			arg = shellQuote(arg)
+		}
+		s = append(s, arg)
 	}
+	return strings.Join(s, " ")
+}
 
-	setString(&d.Mountpoint, line[4])
-	setString(&d.Compression, line[5])
-	setString(&d.Type, line[6])
-
-	if err = setUint(&d.Volsize, line[7]); err != nil {
-		return err
-	}
-	if err = setUint(&d.Quota, line[8]); err != nil {
-		return err
-	}
-	if err = setUint(&d.Referenced, line[9]); err != nil {
-		return err
+func needsQuoting(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
 	}
+	return !strings.ContainsRune("@%+=:,./_-#", r)
+}
 
-	if runtime.GOOS == "solaris" {
-		return nil
-	}
+func (d *Dataset) parseProperty(prop, val string) error {
+	var err error
 
-	if err = setUint(&d.Written, line[10]); err != nil {
-		return err
-	}
-	if err = setUint(&d.Logicalused, line[11]); err != nil {
-		return err
+	switch prop {
+	case "name":
+		setString(&d.Name, val)
+	case "origin":
+		setString(&d.Origin, val)
+	case "used":
+		err = setUint(&d.Used, val)
+	case "available":
+		err = setUint(&d.Avail, val)
+	case "mountpoint":
+		setString(&d.Mountpoint, val)
+	case "compression":
+		setString(&d.Compression, val)
+	case "type":
+		setString(&d.Type, val)
+	case "volsize":
+		err = setUint(&d.Volsize, val)
+	case "quota":
+		err = setUint(&d.Quota, val)
+	case "referenced":
+		err = setUint(&d.Referenced, val)
+	case "creation":
+		err = setTime(&d.Creation, val)
+	case "guid":
+		err = setUint(&d.GUID, val)
+	case "createtxg":
+		err = setUint(&d.Createtxg, val)
+	case "objsetid":
+		err = setUint(&d.Objsetid, val)
+	case "written":
+		err = setUint(&d.Written, val)
+	case "logicalused":
+		err = setUint(&d.Logicalused, val)
+	case "usedbydataset":
+		err = setUint(&d.Usedbydataset, val)
 	}
-	return setUint(&d.Usedbydataset, line[12])
+	return err
 }
 
 /*
@@ -281,29 +333,132 @@ func parseInodeChanges(lines [][]string) ([]*InodeChange, error) {
 	return changes, nil
 }
 
-func listByType(t, filter string) ([]*Dataset, error) {
-	args := []string{"list", "-rHp", "-t", t, "-o", dsPropListOptions}
+// parseDiffTimestamp parses the seconds.fraction time stamp printed by `zfs diff -t`.
+// The fraction is usually nanoseconds, but is read by its length, and truncated beyond nanoseconds.
+func parseDiffTimestamp(field string) (time.Time, error) {
+	parts := strings.SplitN(strings.TrimSpace(field), ".", 2)
+	secs, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var nsecs uint64
+	if len(parts) == 2 {
+		frac := parts[1]
+		if len(frac) > 9 {
+			frac = frac[:9]
+		}
+		nsecs, err = strconv.ParseUint(frac+strings.Repeat("0", 9-len(frac)), 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+	return time.Unix(secs, int64(nsecs)), nil
+}
+
+// example input for parseTimestampedInodeChanges
+// 1650000000.123456789    M       /       /testpool/bar/
+// 1650000000.123456789    +       F       /testpool/bar/hello.txt
+
+func parseTimestampedInodeChanges(lines [][]string) ([]*InodeChange, error) {
+	changes := make([]*InodeChange, len(lines))
 
-	if filter != "" {
-		args = append(args, filter)
+	for i, line := range lines {
+		if len(line) < 2 {
+			return nil, fmt.Errorf("failed to parse line %d of zfs diff: empty line passed, got: '%s'", i, line)
+		}
+		ts, err := parseDiffTimestamp(line[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse line %d of zfs diff: invalid time stamp: %w, got: '%s'", i, err, line)
+		}
+		c, err := parseInodeChange(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse line %d of zfs diff: %w, got: '%s'", i, err, line)
+		}
+		c.Timestamp = ts
+		changes[i] = c
 	}
-	out, err := zfsOutput(args...)
+	return changes, nil
+}
+
+func listByType(t, filter string) ([]*Dataset, error) {
+	return listDatasetsWithOptional(func(props []string) []string {
+		args := []string{"list", "-rHp", "-t", t, "-o", strings.Join(props, ",")}
+		if filter != "" {
+			args = append(args, filter)
+		}
+		return args
+	})
+}
+
+// listSortedByCreation returns the datasets of the given types (e.g. "snapshot,bookmark") directly below name,
+// ordered from oldest to newest by creation time and txg.
+func listSortedByCreation(name, types string) ([]*Dataset, error) {
+	datasets, err := listDatasetsWithOptional(func(props []string) []string {
+		return []string{"list", "-Hp", "-d", "1", "-t", types, "-o", strings.Join(props, ","), name}
+	})
 	if err != nil {
 		return nil, err
 	}
+	sortByCreation(datasets)
+	return datasets, nil
+}
 
+func sortByCreation(datasets []*Dataset) {
+	sort.SliceStable(datasets, func(i, j int) bool {
+		if !datasets[i].Creation.Equal(datasets[j].Creation) {
+			return datasets[i].Creation.Before(datasets[j].Creation)
+		}
+		return datasets[i].Createtxg < datasets[j].Createtxg
+	})
+}
+
+// listDatasetsWithOptional runs zfs with the arguments args returns for dsPropList and as many groups of
+// dsOptionalPropList as the release knows, retrying with one group less as long as zfs rejects the list,
+// and parses the datasets it lists.
+func listDatasetsWithOptional(args func(props []string) []string) ([]*Dataset, error) {
+	for n := len(dsOptionalPropList); ; n-- {
+		props := dsProps(n)
+		out, err := zfsOutput(args(props)...)
+		if n > 0 && isBadPropertyList(err) {
+			// the release does not know some of the optional properties
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return parseDatasetColumns(out, props)
+	}
+}
+
+// dsProps returns dsPropList, followed by the first n groups of dsOptionalPropList.
+func dsProps(n int) []string {
+	props := append([]string{}, dsPropList...)
+	for _, group := range dsOptionalPropList[:n] {
+		props = append(props, group...)
+	}
+	return props
+}
+
+// parseDatasetColumns parses the output of a `zfs list -Hp` invocation listing the given properties,
+// the first of which is the name.
+func parseDatasetColumns(out [][]string, props []string) ([]*Dataset, error) {
 	var datasets []*Dataset
 
 	name := ""
 	var ds *Dataset
 	for _, line := range out {
+		if len(line) != len(props) {
+			return nil, errors.New("output does not match what is expected on this platform")
+		}
 		if name != line[0] {
 			name = line[0]
 			ds = &Dataset{Name: name}
 			datasets = append(datasets, ds)
 		}
-		if err := ds.parseLine(line); err != nil {
-			return nil, err
+		for i, prop := range props {
+			if err := ds.parseProperty(prop, line[i]); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -319,6 +474,16 @@ func propsSlice(properties map[string]string) []string {
 	return args
 }
 
+func parseDedupTableQuota(val string) (uint64, error) {
+	switch val {
+	case "auto":
+		return DedupTableQuotaAuto, nil
+	case "none", "-":
+		return 0, nil
+	}
+	return strconv.ParseUint(val, 10, 64)
+}
+
 func (z *Zpool) parseLine(line []string) error {
 	prop := line[1]
 	val := line[2]
@@ -345,13 +510,33 @@ func (z *Zpool) parseLine(line []string) error {
 		err = setUint(&z.Fragmentation, val[:i])
 	case "readonly":
 		z.ReadOnly = val == "on"
+	case "altroot":
+		setString(&z.AltRoot, val)
+	case "guid":
+		err = setUint(&z.GUID, val)
+	case "expandsize":
+		err = setUint(&z.ExpandSize, val)
+	case "autoexpand":
+		z.AutoExpand = val == "on"
 	case "freeing":
 		err = setUint(&z.Freeing, val)
 	case "leaked":
 		err = setUint(&z.Leaked, val)
 	case "dedupratio":
-		// Trim trailing "x" before parsing float64
-		z.DedupRatio, err = strconv.ParseFloat(val[:len(val)-1], 64)
+		// Exact (-p) output has no trailing "x", but trim it in case it is present
+		z.DedupRatio, err = strconv.ParseFloat(strings.TrimSuffix(val, "x"), 64)
+	case "bcloneused":
+		err = setUint(&z.BCloneUsed, val)
+	case "bclonesaved":
+		err = setUint(&z.BCloneSaved, val)
+	case "bcloneratio":
+		z.BCloneRatio, err = strconv.ParseFloat(strings.TrimSuffix(val, "x"), 64)
+	case "dedup_table_size":
+		err = setUint(&z.DedupTableSize, val)
+	case "dedup_table_quota":
+		z.DedupTableQuota, err = parseDedupTableQuota(val)
+	case "dedupcached":
+		err = setUint(&z.DedupCached, val)
 	}
 	return err
 }
@@ -7,13 +7,25 @@ import "strings"
 
 var (
 	// List of ZFS properties to retrieve from zfs list command on a non-Solaris platform.
-	dsPropList = []string{"name", "origin", "used", "available", "mountpoint", "compression", "type", "volsize", "quota", "referenced", "written", "logicalused", "usedbydataset"}
+	dsPropList = []string{"name", "origin", "used", "available", "mountpoint", "compression", "type", "volsize", "quota", "referenced", "creation", "guid", "written", "logicalused", "usedbydataset"}
 
-	dsPropListOptions = strings.Join(dsPropList, ",")
+	// Groups of ZFS properties retrieved along with dsPropList where the platform's release supports them,
+	// in the order they were added: createtxg, then objsetid in OpenZFS 0.8.
+	dsOptionalPropList = [][]string{
+		{"createtxg"},
+		{"objsetid"},
+	}
 
 	// List of Zpool properties to retrieve from zpool list command on a non-Solaris platform.
-	zpoolPropList = []string{"name", "health", "allocated", "size", "free", "readonly", "dedupratio", "fragmentation", "freeing", "leaked"}
+	zpoolPropList = []string{"name", "health", "allocated", "size", "free", "readonly", "dedupratio", "fragmentation", "freeing", "leaked", "altroot", "guid", "expandsize", "autoexpand"}
 
 	zpoolPropListOptions = strings.Join(zpoolPropList, ",")
 	zpoolArgs            = []string{"get", "-Hp", zpoolPropListOptions}
+
+	// Groups of Zpool properties retrieved along with zpoolPropList where the platform's release supports them,
+	// in the order they were added: the block cloning properties in OpenZFS 2.2, the dedup table ones in 2.3.
+	zpoolOptionalPropList = [][]string{
+		{"bcloneused", "bclonesaved", "bcloneratio"},
+		{"dedup_table_size", "dedup_table_quota", "dedupcached"},
+	}
 )
@@ -7,13 +7,17 @@ import "strings"
 
 var (
 	// List of ZFS properties to retrieve from zfs list command on a Solaris platform
-	dsPropList = []string{"name", "origin", "used", "available", "mountpoint", "compression", "type", "volsize", "quota", "referenced"}
+	dsPropList = []string{"name", "origin", "used", "available", "mountpoint", "compression", "type", "volsize", "quota", "referenced", "creation", "guid", "createtxg"}
 
-	dsPropListOptions = strings.Join(dsPropList, ",")
+	// Groups of ZFS properties retrieved along with dsPropList where the platform's release supports them.
+	dsOptionalPropList [][]string
 
 	// List of Zpool properties to retrieve from zpool list command on a non-Solaris platform
-	zpoolPropList = []string{"name", "health", "allocated", "size", "free", "readonly", "dedupratio"}
+	zpoolPropList = []string{"name", "health", "allocated", "size", "free", "readonly", "dedupratio", "altroot", "guid", "autoexpand"}
 
 	zpoolPropListOptions = strings.Join(zpoolPropList, ",")
 	zpoolArgs            = []string{"get", "-Hp", zpoolPropListOptions}
+
+	// Groups of Zpool properties retrieved along with zpoolPropList where the platform's release supports them.
+	zpoolOptionalPropList [][]string
 )
@@ -1,10 +1,15 @@
 package zfs
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"os/exec"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseLine(t *testing.T) {
@@ -28,6 +33,66 @@ func TestParseLine(t *testing.T) {
 			value: "-",
 			want:  Zpool{Fragmentation: 0},
 		},
+		"exact dedupratio": {
+			prop:  "dedupratio",
+			value: "1.25",
+			want:  Zpool{DedupRatio: 1.25},
+		},
+		"human dedupratio": {
+			prop:  "dedupratio",
+			value: "1.25x",
+			want:  Zpool{DedupRatio: 1.25},
+		},
+		"altroot": {
+			prop:  "altroot",
+			value: "/mnt",
+			want:  Zpool{AltRoot: "/mnt"},
+		},
+		"autoexpand": {
+			prop:  "autoexpand",
+			value: "on",
+			want:  Zpool{AutoExpand: true},
+		},
+		"no altroot": {
+			prop:  "altroot",
+			value: "-",
+			want:  Zpool{},
+		},
+		"bcloneused": {
+			prop:  "bcloneused",
+			value: "1048576",
+			want:  Zpool{BCloneUsed: 1 << 20},
+		},
+		"bclonesaved": {
+			prop:  "bclonesaved",
+			value: "3145728",
+			want:  Zpool{BCloneSaved: 3 << 20},
+		},
+		"bcloneratio": {
+			prop:  "bcloneratio",
+			value: "4.00x",
+			want:  Zpool{BCloneRatio: 4},
+		},
+		"dedup_table_size": {
+			prop:  "dedup_table_size",
+			value: "2097152",
+			want:  Zpool{DedupTableSize: 2 << 20},
+		},
+		"dedup_table_quota": {
+			prop:  "dedup_table_quota",
+			value: "1073741824",
+			want:  Zpool{DedupTableQuota: 1 << 30},
+		},
+		"auto dedup_table_quota": {
+			prop:  "dedup_table_quota",
+			value: "auto",
+			want:  Zpool{DedupTableQuota: DedupTableQuotaAuto},
+		},
+		"no dedup_table_quota": {
+			prop:  "dedup_table_quota",
+			value: "none",
+			want:  Zpool{},
+		},
 	} {
 		t.Run(name, func(t *testing.T) {
 			got := Zpool{}
@@ -39,6 +104,93 @@ func TestParseLine(t *testing.T) {
 	}
 }
 
+func TestDatasetParseProperty(t *testing.T) {
+	for name, test := range map[string]struct {
+		prop  string
+		value string
+		want  Dataset
+	}{
+		"creation": {
+			prop:  "creation",
+			value: "1650000000",
+			want:  Dataset{Creation: time.Unix(1650000000, 0)},
+		},
+		"no creation": {
+			prop:  "creation",
+			value: "-",
+			want:  Dataset{},
+		},
+		"guid": {
+			prop:  "guid",
+			value: "17942367498373635346",
+			want:  Dataset{GUID: 17942367498373635346},
+		},
+		"used": {
+			prop:  "used",
+			value: "1234",
+			want:  Dataset{Used: 1234},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got := Dataset{}
+			if err := got.parseProperty(test.prop, test.value); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(test.want, got) {
+				t.Fatalf("parse failure: wanted: %v, got: %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestParseTimestampedInodeChanges(t *testing.T) {
+	got, err := parseTimestampedInodeChanges([][]string{
+		{"1650000000.000000042", "M", "/", "/test/origin/"},
+		{"1650000001.500000000", "R", "F", "/test/origin/file", "/test/origin/file-new"},
+		{"1650000002.000000000", "+", "F", "/test/origin/i\\040\\342\\235\\244\\040unicode"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []*InodeChange{
+		{Change: Modified, Type: Directory, Path: "/test/origin/", Timestamp: time.Unix(1650000000, 42)},
+		{Change: Renamed, Type: File, Path: "/test/origin/file", NewPath: "/test/origin/file-new", Timestamp: time.Unix(1650000001, 500000000)},
+		{Change: Created, Type: File, Path: "/test/origin/i ❤ unicode", Timestamp: time.Unix(1650000002, 0)},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("parse failure: wanted: %v, got: %v", want, got)
+	}
+
+	if _, err := parseTimestampedInodeChanges([][]string{{"yesterday", "M", "/", "/test/origin/"}}); err == nil {
+		t.Fatal("wanted error for invalid time stamp, got nil")
+	}
+}
+
+func TestParseDiffTimestamp(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		want time.Time
+	}{
+		"nanoseconds":  {in: "1650000000.000000042", want: time.Unix(1650000000, 42)},
+		"microseconds": {in: "1650000000.123456", want: time.Unix(1650000000, 123456000)},
+		"tenths":       {in: "1650000000.5", want: time.Unix(1650000000, 500000000)},
+		"picoseconds":  {in: "1650000000.123456789999", want: time.Unix(1650000000, 123456789)},
+		"no fraction":  {in: "1650000000", want: time.Unix(1650000000, 0)},
+	}
+	for name, test := range tests {
+		got, err := parseDiffTimestamp(test.in)
+		if err != nil || !got.Equal(test.want) {
+			t.Fatalf("%s: wanted: %v, got: %v %v", name, test.want, got, err)
+		}
+	}
+	for _, in := range []string{"1650000000.-5", "1650000000.5x", "1650000000,5"} {
+		if _, err := parseDiffTimestamp(in); err == nil {
+			t.Fatalf("wanted: error for %q, got: nil", in)
+		}
+	}
+}
+
 func TestCommandError(t *testing.T) {
 	cmd := &command{Command: "false"}
 	expectedPath, err := exec.LookPath(cmd.Command)
@@ -69,3 +221,127 @@ func TestCommandError(t *testing.T) {
 		})
 	}
 }
+
+func TestCommandContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cmd := &command{Command: "sleep", Ctx: ctx}
+	_, err := cmd.Run("10")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("command.Run: wanted context.Canceled, got %v", err)
+	}
+}
+
+func TestJoinArgs(t *testing.T) {
+	got := joinArgs("/sbin/zfs", []string{"snapshot", "-o", "com.example:note=a b", "tank/my fs@it's", "tank/fs@a%b,c", ""})
+	want := `/sbin/zfs snapshot -o 'com.example:note=a b' 'tank/my fs@it'\''s' tank/fs@a%b,c ''`
+	if got != want {
+		t.Fatalf("wanted: %v, got: %v", want, got)
+	}
+}
+
+// TestExoticNames checks that names with spaces and other characters ZFS allows pass ValidateDatasetName,
+// while names with unicode or shell special characters do not, and that all of them survive
+// argument construction and parsing unchanged.
+func TestExoticNames(t *testing.T) {
+	names := map[string]bool{
+		"tank/my fs":           true,
+		"tank/trailing ":       true,
+		"tank/a:b-c_d.e":       true,
+		"tank/$HOME; rm -rf /": false,
+		"tank/ünï`code`":       false,
+	}
+
+	for name, valid := range names {
+		if err := ValidateDatasetName(name); (err == nil) != valid {
+			t.Fatalf("%q: wanted valid: %v, got: %v", name, valid, err)
+		}
+
+		// arguments are passed without shell interpolation
+		c := command{Command: "printf"}
+		out, err := c.Run(`%s\t%s\n`, name, name+"@snap")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := [][]string{{name, name + "@snap"}}; !reflect.DeepEqual(want, out) {
+			t.Fatalf("wanted: %q, got: %q", want, out)
+		}
+
+		line := make([]string, len(dsPropList))
+		for i := range line {
+			line[i] = "-"
+		}
+		line[0] = name
+		datasets, err := parseDatasetColumns([][]string{line}, dsPropList)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if datasets[0].Name != name {
+			t.Fatalf("wanted: %q, got: %q", name, datasets[0].Name)
+		}
+
+		token, err := parseResumeToken([][]string{{"nvlist version: 0"}, {"", "toname = " + name + "@snap"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if token.ToName != name+"@snap" {
+			t.Fatalf("wanted: %q, got: %q", name+"@snap", token.ToName)
+		}
+
+		perms, err := parsePermissions([][]string{{"---- Permissions on " + name + " ----------"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if perms[0].Dataset != name {
+			t.Fatalf("wanted: %q, got: %q", name, perms[0].Dataset)
+		}
+
+		owner, err := datasetForPath([][]string{{name, "/" + name, "yes"}}, "/"+name+"/file")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if owner != name {
+			t.Fatalf("wanted: %q, got: %q", name, owner)
+		}
+	}
+}
+
+func TestJSONKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want []string
+	}{
+		{"Dataset", Dataset{}, []string{"Avail", "Compression", "Createtxg", "Creation", "GUID", "Logicalused", "Mountpoint", "Name", "Objsetid", "Origin", "Quota", "Referenced", "Type", "Used", "Usedbydataset", "Volsize", "Written"}},
+		{"Zpool", Zpool{}, []string{"Allocated", "AltRoot", "AutoExpand", "BCloneRatio", "BCloneSaved", "BCloneUsed", "DedupCached", "DedupRatio", "DedupTableQuota", "DedupTableSize", "ExpandSize", "Fragmentation", "Free", "Freeing", "GUID", "Health", "Leaked", "Name", "ReadOnly", "Size"}},
+		{"InodeChange", InodeChange{}, []string{"Change", "NewPath", "Path", "ReferenceCountChange", "Timestamp", "Type"}},
+		{"ResumeToken", ResumeToken{}, []string{"bytes", "compress_ok", "embed_ok", "from_guid", "large_block_ok", "object", "offset", "raw_ok", "to_guid", "to_name"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(tt.v)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var m map[string]interface{}
+			if err := json.Unmarshal(b, &m); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []string
+			for k := range m {
+				got = append(got, k)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(tt.want, got) {
+				t.Fatalf("wanted: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+
+	// GUIDs exceed the integers JavaScript represents exactly
+	b, err := json.Marshal(Dataset{GUID: 18446744073709551615})
+	if err != nil || !strings.Contains(string(b), `"GUID":"18446744073709551615"`) {
+		t.Fatalf("wanted: GUID as a string, got: %s %v", b, err)
+	}
+}
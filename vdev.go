@@ -0,0 +1,158 @@
+package zfs
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoEnclosureSlot is returned when a device is not located in an enclosure slot known to the system.
+var ErrNoEnclosureSlot = errors.New("device is not in an enclosure slot")
+
+// VdevProperties returns the properties of the given vdev of the receiving pool, e.g. "guid" or "checksum_n".
+// All properties are returned if none are given. Vdev properties require OpenZFS 2.2 or newer.
+//
+// A full list of available vdev properties may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/7/vdevprops.7.html
+func (z *Zpool) VdevProperties(vdev string, props ...string) (map[string]string, error) {
+	list := "all"
+	if len(props) > 0 {
+		list = strings.Join(props, ",")
+	}
+	out, err := zpoolOutput("get", "-Hp", "-o", "property,value", list, z.Name, vdev)
+	if err != nil {
+		return nil, err
+	}
+	return parseVdevProperties(out)
+}
+
+func parseVdevProperties(lines [][]string) (map[string]string, error) {
+	props := make(map[string]string, len(lines))
+	for _, line := range lines {
+		if len(line) != 2 {
+			return nil, errors.New("output does not match what is expected on this platform")
+		}
+		props[line[0]] = line[1]
+	}
+	return props, nil
+}
+
+// SetVdevProperty sets a property of the given vdev of the receiving pool.
+func (z *Zpool) SetVdevProperty(vdev, key, val string) error {
+	return zpool("set", key+"="+val, z.Name, vdev)
+}
+
+// VdevExpandSize returns the unused space of the given vdev of the receiving pool that it can be expanded into,
+// see Zpool.ExpandDevice. It requires OpenZFS 2.2 or newer, as VdevProperties does.
+func (z *Zpool) VdevExpandSize(vdev string) (uint64, error) {
+	props, err := z.VdevProperties(vdev, "expandsize")
+	if err != nil {
+		return 0, err
+	}
+	var size uint64
+	err = setUint(&size, props["expandsize"])
+	return size, err
+}
+
+// EnclosureSlot is the physical slot of a disk in a storage enclosure, as exposed by the kernel's SES driver.
+type EnclosureSlot struct {
+	// Enclosure is the enclosure's identifier, e.g. its SCSI address "0:0:8:0".
+	Enclosure string `json:"enclosure"`
+	// Slot is the slot's name as reported by the enclosure, e.g. "Slot 03" or "3".
+	Slot string `json:"slot"`
+	// Path is the sysfs directory of the slot.
+	Path string `json:"path"`
+}
+
+// SetLocate turns the locate LED of the slot on or off.
+func (s *EnclosureSlot) SetLocate(on bool) error {
+	return s.setLED("locate", on)
+}
+
+// SetFault turns the fault LED of the slot on or off.
+func (s *EnclosureSlot) SetFault(on bool) error {
+	return s.setLED("fault", on)
+}
+
+func (s *EnclosureSlot) setLED(led string, on bool) error {
+	val := "0"
+	if on {
+		val = "1"
+	}
+	return ioutil.WriteFile(filepath.Join(s.Path, led), []byte(val), 0)
+}
+
+// FindEnclosureSlot returns the enclosure slot holding the given device, e.g. /dev/disk/by-id/wwn-0x5000c500a1b2c3d4.
+func FindEnclosureSlot(device string) (*EnclosureSlot, error) {
+	if currentPlatform.sysfs == "" {
+		return nil, fmt.Errorf("enclosures are not supported on %s", currentPlatform.name)
+	}
+	dev, err := resolveDevice(currentPlatform, device)
+	if err != nil {
+		return nil, err
+	}
+	return findEnclosureSlot(currentPlatform.sysfs, filepath.Base(dev))
+}
+
+// findEnclosureSlot looks for the slot holding the block device with the given name, e.g. "sda".
+func findEnclosureSlot(sysfs, name string) (*EnclosureSlot, error) {
+	// partitions are located by their disk
+	block := filepath.Join(sysfs, "class", "block", name)
+	if _, err := os.Stat(filepath.Join(block, "partition")); err == nil {
+		dir, err := filepath.EvalSymlinks(block)
+		if err != nil {
+			return nil, err
+		}
+		name = filepath.Base(filepath.Dir(dir))
+	}
+
+	enclosureDir := filepath.Join(sysfs, "class", "enclosure")
+	enclosures, err := ioutil.ReadDir(enclosureDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoEnclosureSlot
+		}
+		return nil, err
+	}
+	for _, enc := range enclosures {
+		encPath := filepath.Join(enclosureDir, enc.Name())
+		slots, err := ioutil.ReadDir(encPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, slot := range slots {
+			slotPath := filepath.Join(encPath, slot.Name())
+			blocks, err := ioutil.ReadDir(filepath.Join(slotPath, "device", "block"))
+			if err != nil {
+				// not a slot, or an empty one
+				continue
+			}
+			for _, b := range blocks {
+				if b.Name() == name {
+					return &EnclosureSlot{Enclosure: enc.Name(), Slot: slot.Name(), Path: slotPath}, nil
+				}
+			}
+		}
+	}
+	return nil, ErrNoEnclosureSlot
+}
+
+// VdevSlot returns the enclosure slot of the given leaf vdev of the receiving pool, identified by GUID, path or name,
+// so that e.g. a failed disk can be found for replacement.
+func (z *Zpool) VdevSlot(vdev string) (*EnclosureSlot, error) {
+	status, err := z.Status()
+	if err != nil {
+		return nil, err
+	}
+	v := status.FindVdev(vdev)
+	if v == nil {
+		return nil, fmt.Errorf("vdev %s not found in pool %s", vdev, z.Name)
+	}
+	if v.Path == "" {
+		return nil, fmt.Errorf("vdev %s is not a disk", vdev)
+	}
+	return FindEnclosureSlot(v.Path)
+}
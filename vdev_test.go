@@ -0,0 +1,144 @@
+package zfs
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseVdevProperties(t *testing.T) {
+	got, err := parseVdevProperties(splitOutput("guid\t1234567890\nstate\tONLINE\nchecksum_n\t-\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{"guid": "1234567890", "state": "ONLINE", "checksum_n": "-"}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %v, got: %v", want, got)
+	}
+
+	if _, err := parseVdevProperties(splitOutput("tank\tguid\t1234567890\n")); err == nil {
+		t.Fatal("expected an error for unexpected columns")
+	}
+}
+
+func TestFindVdev(t *testing.T) {
+	disk := &ZpoolVdev{Name: "sdb", GUID: "44444", Path: "/dev/disk/by-id/ata-DISK2-part1"}
+	spare := &ZpoolVdev{Name: "sdc", GUID: "55555", Path: "/dev/sdc1"}
+	s := &ZpoolStatus{
+		Vdevs: map[string]*ZpoolVdev{"tank": {Name: "tank", GUID: "11111", VdevType: "root", Vdevs: map[string]*ZpoolVdev{
+			"mirror-0": {Name: "mirror-0", VdevType: "mirror", Vdevs: map[string]*ZpoolVdev{
+				"sda": {Name: "sda", Path: "/dev/sda1"},
+				"spare-1": {Name: "spare-1", VdevType: "spare", Vdevs: map[string]*ZpoolVdev{
+					"sdb": disk,
+					"sdc": spare,
+				}},
+			}},
+		}}},
+		Spares: map[string]*ZpoolVdev{"sdc": {Name: "sdc", GUID: "55555", Path: "/dev/sdc1", State: "INUSE"}},
+	}
+
+	for _, id := range []string{"sdb", "/dev/disk/by-id/ata-DISK2-part1", "44444"} {
+		if got := s.FindVdev(id); got != disk {
+			t.Fatalf("wanted: %v, got: %v", disk, got)
+		}
+	}
+	if got := s.FindVdev("55555"); got != spare {
+		t.Fatalf("wanted: %v, got: %v", spare, got)
+	}
+	for _, id := range []string{"sdz", ""} {
+		if got := s.FindVdev(id); got != nil {
+			t.Fatalf("wanted: nil, got: %v", got)
+		}
+	}
+
+	var walked []string
+	err := s.WalkVdevs(func(v, parent *ZpoolVdev) error {
+		p := ""
+		if parent != nil {
+			p = parent.Name
+		}
+		walked = append(walked, p+">"+v.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{">tank", "tank>mirror-0", "mirror-0>sda", "mirror-0>spare-1", "spare-1>sdb", "spare-1>sdc", ">sdc"}
+	if !reflect.DeepEqual(want, walked) {
+		t.Fatalf("wanted: %v, got: %v", want, walked)
+	}
+
+	stop := errors.New("stop")
+	walked = nil
+	err = s.WalkVdevs(func(v, _ *ZpoolVdev) error {
+		walked = append(walked, v.Name)
+		if v.Name == "mirror-0" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || !reflect.DeepEqual(walked, []string{"tank", "mirror-0"}) {
+		t.Fatalf("wanted: %v [tank mirror-0], got: %v %v", stop, err, walked)
+	}
+}
+
+func TestFindEnclosureSlot(t *testing.T) {
+	sysfs, err := ioutil.TempDir("", "sysfs-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(sysfs)
+
+	mkdir := func(path ...string) string {
+		dir := filepath.Join(append([]string{sysfs}, path...)...)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+	symlink := func(target, link string) {
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a disk with a partition, and an enclosure whose second slot holds the disk
+	sdc := mkdir("devices", "host0", "0:0:3:0", "block", "sdc")
+	sdc1 := mkdir("devices", "host0", "0:0:3:0", "block", "sdc", "sdc1")
+	if err := ioutil.WriteFile(filepath.Join(sdc1, "partition"), []byte("1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mkdir("class", "block")
+	symlink(sdc, filepath.Join(sysfs, "class", "block", "sdc"))
+	symlink(sdc1, filepath.Join(sysfs, "class", "block", "sdc1"))
+
+	mkdir("class", "enclosure", "0:0:8:0", "Slot 01")
+	slot := mkdir("class", "enclosure", "0:0:8:0", "Slot 02")
+	symlink(filepath.Join(sysfs, "devices", "host0", "0:0:3:0"), filepath.Join(slot, "device"))
+
+	want := &EnclosureSlot{Enclosure: "0:0:8:0", Slot: "Slot 02", Path: slot}
+	for _, name := range []string{"sdc", "sdc1"} {
+		got, err := findEnclosureSlot(sysfs, name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Fatalf("wanted: %+v, got: %+v", want, got)
+		}
+	}
+
+	if _, err := findEnclosureSlot(sysfs, "sdz"); err != ErrNoEnclosureSlot {
+		t.Fatalf("wanted: %v, got: %v", ErrNoEnclosureSlot, err)
+	}
+
+	if err := want.SetLocate(true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(slot, "locate"))
+	if err != nil || string(b) != "1" {
+		t.Fatalf("wanted locate to be 1, got: %q, %v", b, err)
+	}
+}
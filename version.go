@@ -0,0 +1,81 @@
+package zfs
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Version is an OpenZFS release, e.g. 2.2.3.
+type Version struct {
+	Major int `json:"major"`
+	Minor int `json:"minor"`
+	Patch int `json:"patch"`
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// AtLeast reports whether v is the release other or a later one.
+func (v Version) AtLeast(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major > other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor > other.Minor
+	}
+	return v.Patch >= other.Patch
+}
+
+var (
+	versionOnce sync.Once
+	version     Version
+	versionErr  error
+)
+
+// DetectVersion determines the OpenZFS release of the loaded kernel module, or of the userland tools
+// if the module's is not reported, with `zfs version`. Releases before 0.8 lack that command, and so do
+// Solaris and illumos, for which an error is returned.
+// The result is detected once and cached for the lifetime of the process.
+func DetectVersion() (Version, error) {
+	versionOnce.Do(func() {
+		out, err := zfsOutput("version")
+		if err != nil {
+			versionErr = fmt.Errorf("could not obtain the zfs version: %w", err)
+			return
+		}
+		version, versionErr = parseVersion(out)
+	})
+	return version, versionErr
+}
+
+// versionRegex matches the release in lines like "zfs-2.2.3-1" or "zfs-kmod-2.2.3-1ubuntu1".
+var versionRegex = regexp.MustCompile(`^zfs-(kmod-)?(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// example input for parseVersion
+// zfs-2.2.3-1
+// zfs-kmod-2.2.3-1
+
+func parseVersion(lines [][]string) (Version, error) {
+	var (
+		v     Version
+		found bool
+	)
+	for _, line := range lines {
+		m := versionRegex.FindStringSubmatch(strings.TrimSpace(strings.Join(line, " ")))
+		if m == nil || (found && m[1] == "") {
+			continue
+		}
+		v.Major, _ = strconv.Atoi(m[2])
+		v.Minor, _ = strconv.Atoi(m[3])
+		v.Patch, _ = strconv.Atoi(m[4])
+		found = true
+	}
+	if !found {
+		return Version{}, fmt.Errorf("unexpected zfs version output %q", lines)
+	}
+	return v, nil
+}
@@ -0,0 +1,33 @@
+package zfs
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		out  string
+		want Version
+	}{
+		{"zfs-2.2.3-1\nzfs-kmod-2.2.3-1\n", Version{2, 2, 3}},
+		{"zfs-2.1.5-1ubuntu6~22.04.1\nzfs-kmod-2.2.0-0ubuntu1~23.10\n", Version{2, 2, 0}},
+		{"zfs-kmod-0.8.3-1ubuntu12\nzfs-0.8.3-1ubuntu12\n", Version{0, 8, 3}},
+		{"zfs-2.2.99-365_g8f2f6cd2a\n", Version{2, 2, 99}},
+		{"zfs-2.3\n", Version{2, 3, 0}},
+	}
+	for _, test := range tests {
+		got, err := parseVersion(splitOutput(test.out))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != test.want {
+			t.Fatalf("wanted: %v, got: %v", test.want, got)
+		}
+	}
+
+	if _, err := parseVersion(splitOutput("unrecognized command 'version'\n")); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if !(Version{2, 2, 0}).AtLeast(Version{0, 7, 0}) || (Version{2, 1, 9}).AtLeast(Version{2, 2, 0}) {
+		t.Fatal("unexpected version ordering")
+	}
+}
@@ -0,0 +1,91 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecommendVolBlockSize(t *testing.T) {
+	tests := []struct {
+		guest, want uint64
+	}{
+		{0, 16 << 10},
+		{4096, 16 << 10},
+		{16 << 10, 16 << 10},
+		{24 << 10, 32 << 10},
+		{64 << 10, 64 << 10},
+		{1 << 20, 128 << 10},
+	}
+	for _, test := range tests {
+		if got := RecommendVolBlockSize(test.guest); got != test.want {
+			t.Fatalf("wanted: %v, got: %v", test.want, got)
+		}
+	}
+}
+
+func TestVMDiskOptionsArgs(t *testing.T) {
+	tests := []struct {
+		opts VMDiskOptions
+		want []string
+	}{
+		{
+			VMDiskOptions{Size: 10 << 30, GuestBlockSize: 64 << 10},
+			[]string{"create", "-p", "-V", "10737418240", "-o", "volblocksize=65536", "tank/vm/disk0"},
+		},
+		{
+			VMDiskOptions{Size: 1000, Sparse: true},
+			[]string{"create", "-p", "-s", "-V", "16384", "-o", "volblocksize=16384", "tank/vm/disk0"},
+		},
+		{
+			VMDiskOptions{Size: 100 << 10, GuestBlockSize: 4096, Properties: map[string]string{"volblocksize": "64K"}},
+			[]string{"create", "-p", "-V", "131072", "-o", "volblocksize=64K", "tank/vm/disk0"},
+		},
+	}
+	for _, test := range tests {
+		got, err := test.opts.args("tank/vm/disk0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("wanted: %v, got: %v", test.want, got)
+		}
+	}
+	if _, err := (VMDiskOptions{}).args("tank/vm/disk0"); err == nil {
+		t.Fatal("wanted: error for a missing size, got: nil")
+	}
+}
+
+func TestWaitForZvolDevice(t *testing.T) {
+	if currentPlatform.zvolDir == "" {
+		t.Skip("zvol device nodes are not supported on", currentPlatform.name)
+	}
+	defer func() { statDevice = os.Stat }()
+
+	checks := 0
+	statDevice = func(path string) (os.FileInfo, error) {
+		if want, _ := ZvolDevicePath("tank/vm/disk0"); path != want {
+			t.Fatalf("wanted: %v, got: %v", want, path)
+		}
+		if checks++; checks < 3 {
+			return nil, os.ErrNotExist
+		}
+		return nil, nil
+	}
+	if _, err := WaitForZvolDevice(context.Background(), "tank/vm/disk0"); err != nil {
+		t.Fatal(err)
+	}
+	if checks != 3 {
+		t.Fatalf("wanted: 3 checks, got: %v", checks)
+	}
+
+	statDevice = func(string) (os.FileInfo, error) { return nil, os.ErrNotExist }
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := WaitForZvolDevice(ctx, "tank/vm/disk0"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wanted: %v, got: %v", context.DeadlineExceeded, err)
+	}
+}
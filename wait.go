@@ -0,0 +1,25 @@
+package zfs
+
+import (
+	"context"
+)
+
+// DatasetActivity is a background activity of a dataset that Wait can wait for.
+type DatasetActivity string
+
+// Dataset activities supported by `zfs wait`.
+const (
+	// ActivityDeleteQueue is the processing of the delete queue, which frees the space of unlinked files.
+	ActivityDeleteQueue DatasetActivity = "deleteq"
+)
+
+// Wait blocks until the given background activity of the receiving dataset has finished.
+// It returns early with an error wrapping ctx.Err() when ctx is cancelled.
+//
+// More information regarding zfs wait can be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/8/zfs-wait.8.html
+func (d *Dataset) Wait(ctx context.Context, activity DatasetActivity) error {
+	c := command{Command: "zfs", Ctx: ctx}
+	_, err := c.Run("wait", "-t", string(activity), d.Name)
+	return err
+}
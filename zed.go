@@ -0,0 +1,110 @@
+package zfs
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// zedEventPrefix is the prefix of the environment variables ZED passes the event's payload in.
+const zedEventPrefix = "ZEVENT_"
+
+// ZedEvent is a ZFS event as passed by the ZFS Event Daemon (ZED) to its zedlets,
+// so that a Go program can be installed as a zedlet.
+//
+// More information regarding ZED and its environment can be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/8/zed.8.html
+type ZedEvent struct {
+	// EID is the event's ID, unique since the system booted.
+	EID uint64 `json:"eid"`
+	// Class is the full event class, e.g. "sysevent.fs.zfs.scrub_finish", and Subclass its last part, e.g. "scrub_finish".
+	Class    string    `json:"class"`
+	Subclass string    `json:"subclass"`
+	Time     time.Time `json:"time"`
+
+	Pool        string `json:"pool"`
+	PoolGUID    string `json:"pool_guid"`
+	PoolState   string `json:"pool_state"`
+	PoolContext string `json:"pool_context"`
+
+	VdevPath         string `json:"vdev_path"`
+	VdevGUID         string `json:"vdev_guid"`
+	VdevType         string `json:"vdev_type"`
+	VdevState        string `json:"vdev_state"`
+	VdevPhysPath     string `json:"vdev_phys_path"`
+	VdevEncSysfsPath string `json:"vdev_enc_sysfs_path"`
+
+	// HistoryDataset, HistoryInternalName and HistoryInternalStr are set by history events.
+	HistoryDataset      string `json:"history_dataset"`
+	HistoryInternalName string `json:"history_internal_name"`
+	HistoryInternalStr  string `json:"history_internal_str"`
+
+	// Env holds all ZEVENT_ variables with the prefix removed, including those without a field above.
+	Env map[string]string `json:"env"`
+}
+
+// ZedEventFromEnv parses the event from the environment of the current process, as set up by ZED.
+func ZedEventFromEnv() (*ZedEvent, error) {
+	return ParseZedEvent(os.Environ())
+}
+
+// ParseZedEvent parses the event from environ, a list of "KEY=value" strings as returned by os.Environ.
+func ParseZedEvent(environ []string) (*ZedEvent, error) {
+	env := map[string]string{}
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, zedEventPrefix) {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimPrefix(kv, zedEventPrefix), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		env[parts[0]] = parts[1]
+	}
+
+	if env["EID"] == "" || env["CLASS"] == "" {
+		return nil, errors.New("not a ZED event, ZEVENT_EID or ZEVENT_CLASS is missing")
+	}
+	e := &ZedEvent{
+		Class:               env["CLASS"],
+		Subclass:            env["SUBCLASS"],
+		Pool:                env["POOL"],
+		PoolGUID:            env["POOL_GUID"],
+		PoolState:           env["POOL_STATE_STR"],
+		PoolContext:         env["POOL_CONTEXT"],
+		VdevPath:            env["VDEV_PATH"],
+		VdevGUID:            env["VDEV_GUID"],
+		VdevType:            env["VDEV_TYPE"],
+		VdevState:           env["VDEV_STATE_STR"],
+		VdevPhysPath:        env["VDEV_PHYSPATH"],
+		VdevEncSysfsPath:    env["VDEV_ENC_SYSFS_PATH"],
+		HistoryDataset:      env["HISTORY_DSNAME"],
+		HistoryInternalName: env["HISTORY_INTERNAL_NAME"],
+		HistoryInternalStr:  env["HISTORY_INTERNAL_STR"],
+		Env:                 env,
+	}
+
+	var err error
+	if e.EID, err = strconv.ParseUint(env["EID"], 10, 64); err != nil {
+		return nil, err
+	}
+	if e.Subclass == "" {
+		e.Subclass = e.Class[strings.LastIndex(e.Class, ".")+1:]
+	}
+	if secs := env["TIME_SECS"]; secs != "" {
+		s, err := strconv.ParseInt(secs, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		var ns int64
+		if nsecs := env["TIME_NSECS"]; nsecs != "" {
+			if ns, err = strconv.ParseInt(nsecs, 10, 64); err != nil {
+				return nil, err
+			}
+		}
+		e.Time = time.Unix(s, ns)
+	}
+	return e, nil
+}
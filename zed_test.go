@@ -0,0 +1,61 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseZedEvent(t *testing.T) {
+	environ := []string{
+		"PATH=/usr/bin:/bin",
+		"ZED_PID=1234",
+		"ZEVENT_EID=42",
+		"ZEVENT_CLASS=resource.fs.zfs.statechange",
+		"ZEVENT_TIME_SECS=1700000000",
+		"ZEVENT_TIME_NSECS=500",
+		"ZEVENT_POOL=tank",
+		"ZEVENT_POOL_GUID=0x1234",
+		"ZEVENT_POOL_STATE_STR=ACTIVE",
+		"ZEVENT_POOL_CONTEXT=0",
+		"ZEVENT_VDEV_PATH=/dev/disk/by-id/ata-DISK1-part1",
+		"ZEVENT_VDEV_GUID=0x5678",
+		"ZEVENT_VDEV_TYPE=disk",
+		"ZEVENT_VDEV_STATE_STR=FAULTED",
+		"ZEVENT_VDEV_ENC_SYSFS_PATH=/sys/class/enclosure/0:0:8:0/Slot 02",
+		"ZEVENT_ZIO_ERR=5",
+	}
+	got, err := ParseZedEvent(environ)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := &ZedEvent{
+		EID:              42,
+		Class:            "resource.fs.zfs.statechange",
+		Subclass:         "statechange",
+		Time:             time.Unix(1700000000, 500),
+		Pool:             "tank",
+		PoolGUID:         "0x1234",
+		PoolState:        "ACTIVE",
+		PoolContext:      "0",
+		VdevPath:         "/dev/disk/by-id/ata-DISK1-part1",
+		VdevGUID:         "0x5678",
+		VdevType:         "disk",
+		VdevState:        "FAULTED",
+		VdevEncSysfsPath: "/sys/class/enclosure/0:0:8:0/Slot 02",
+	}
+	if got.Env["ZIO_ERR"] != "5" || len(got.Env) != 14 {
+		t.Fatalf("unexpected Env: %v", got.Env)
+	}
+	got.Env = nil
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %+v, got: %+v", want, got)
+	}
+
+	if _, err := ParseZedEvent([]string{"ZEVENT_CLASS=sysevent.fs.zfs.scrub_finish"}); err == nil {
+		t.Fatal("expected an error without an EID")
+	}
+	if _, err := ParseZedEvent([]string{"ZEVENT_EID=x", "ZEVENT_CLASS=sysevent.fs.zfs.scrub_finish"}); err == nil {
+		t.Fatal("expected an error for an invalid EID")
+	}
+}
@@ -2,11 +2,13 @@
 package zfs
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // ZFS dataset types, which can indicate if a dataset is a filesystem, snapshot, or volume.
@@ -14,6 +16,7 @@ const (
 	DatasetFilesystem = "filesystem"
 	DatasetSnapshot   = "snapshot"
 	DatasetVolume     = "volume"
+	DatasetBookmark   = "bookmark"
 )
 
 // Dataset is a ZFS dataset.  A dataset could be a clone, filesystem, snapshot, or volume.
@@ -21,6 +24,7 @@ const (
 //
 // The field definitions can be found in the ZFS manual:
 // https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html.
+// In JSON, GUID is encoded as a string, as it may exceed the integers JavaScript represents exactly.
 type Dataset struct {
 	Name          string
 	Origin        string
@@ -35,8 +39,15 @@ type Dataset struct {
 	Usedbydataset uint64
 	Quota         uint64
 	Referenced    uint64
+	Creation      time.Time
+	GUID          uint64 `json:",string"`
+	Createtxg     uint64
+	Objsetid      uint64
 }
 
+// ErrNoSnapshots is returned by LatestSnapshot when the dataset has no snapshots.
+var ErrNoSnapshots = errors.New("dataset has no snapshots")
+
 // InodeType is the type of inode as reported by Diff.
 type InodeType int
 
@@ -85,6 +96,7 @@ type InodeChange struct {
 	Path                 string
 	NewPath              string
 	ReferenceCountChange int
+	Timestamp            time.Time
 }
 
 // Logger can be used to log commands/actions.
@@ -145,19 +157,27 @@ func Volumes(filter string) ([]*Dataset, error) {
 // GetDataset retrieves a single ZFS dataset by name.
 // This dataset could be any valid ZFS dataset type, such as a clone, filesystem, snapshot, or volume.
 func GetDataset(name string) (*Dataset, error) {
-	out, err := zfsOutput("list", "-Hp", "-o", dsPropListOptions, name)
+	datasets, err := listDatasetsWithOptional(func(props []string) []string {
+		return []string{"list", "-Hp", "-o", strings.Join(props, ","), name}
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	ds := &Dataset{Name: name}
-	for _, line := range out {
-		if err := ds.parseLine(line); err != nil {
-			return nil, err
-		}
+	if len(datasets) == 0 {
+		return &Dataset{Name: name}, nil
 	}
+	return datasets[0], nil
+}
 
-	return ds, nil
+// Refresh re-reads the properties of the receiving dataset in place,
+// so that long-lived references to it stay current.
+func (d *Dataset) Refresh() error {
+	ds, err := GetDataset(d.Name)
+	if err != nil {
+		return err
+	}
+	*d = *ds
+	return nil
 }
 
 // Clone clones a ZFS snapshot and returns a clone dataset.
@@ -179,6 +199,15 @@ func (d *Dataset) Clone(dest string, properties map[string]string) (*Dataset, er
 	return GetDataset(dest)
 }
 
+// Promote promotes the receiving clone, so that it no longer depends on its origin snapshot.
+// The origin snapshot, and all earlier snapshots of its file system, are moved to the clone.
+func (d *Dataset) Promote() error {
+	if d.Origin == "" {
+		return errors.New("can only promote clones")
+	}
+	return zfs("promote", d.Name)
+}
+
 // Unmount unmounts currently mounted ZFS file systems.
 func (d *Dataset) Unmount(force bool) (*Dataset, error) {
 	if d.Type == DatasetSnapshot {
@@ -220,23 +249,13 @@ func (d *Dataset) Mount(overlay bool, options []string) (*Dataset, error) {
 // ReceiveSnapshot receives a ZFS stream from the input io.Reader.
 // A new snapshot is created with the specified name, and streams the input data into the newly-created snapshot.
 func ReceiveSnapshot(input io.Reader, name string) (*Dataset, error) {
-	c := command{Command: "zfs", Stdin: input}
-	if _, err := c.Run("receive", name); err != nil {
-		return nil, err
-	}
-	return GetDataset(name)
+	return ReceiveFrom(context.Background(), input, name, ReceiveOptions{})
 }
 
 // SendSnapshot sends a ZFS stream of a snapshot to the input io.Writer.
 // An error will be returned if the input dataset is not of snapshot type.
 func (d *Dataset) SendSnapshot(output io.Writer) error {
-	if d.Type != DatasetSnapshot {
-		return errors.New("can only send snapshots")
-	}
-
-	c := command{Command: "zfs", Stdout: output}
-	_, err := c.Run("send", d.Name)
-	return err
+	return d.SendTo(context.Background(), output, SendOptions{})
 }
 
 // IncrementalSend sends a ZFS stream of a snapshot to the input io.Writer using the baseSnapshot as the starting point.
@@ -245,9 +264,7 @@ func (d *Dataset) IncrementalSend(baseSnapshot *Dataset, output io.Writer) error
 	if d.Type != DatasetSnapshot || baseSnapshot.Type != DatasetSnapshot {
 		return errors.New("can only send snapshots")
 	}
-	c := command{Command: "zfs", Stdout: output}
-	_, err := c.Run("send", "-i", baseSnapshot.Name, d.Name)
-	return err
+	return d.SendTo(context.Background(), output, SendOptions{IncrementalBase: baseSnapshot.Name})
 }
 
 // CreateVolume creates a new ZFS volume with the specified name, size, and properties.
@@ -274,7 +291,15 @@ func CreateVolume(name string, size uint64, properties map[string]string) (*Data
 // If the destroy bit flag is set, any descendents of the dataset will be recursively destroyed, including snapshots.
 // If the deferred bit flag is set, the snapshot is marked for deferred deletion.
 func (d *Dataset) Destroy(flags DestroyFlag) error {
-	args := make([]string, 1, 3)
+	args := destroyArgs(flags)
+	args = append(args, d.Name)
+	err := zfs(args...)
+	return err
+}
+
+// destroyArgs returns the zfs destroy command-line arguments for flags, without the dataset name.
+func destroyArgs(flags DestroyFlag) []string {
+	args := make([]string, 1, 6)
 	args[0] = "destroy"
 	if flags&DestroyRecursive != 0 {
 		args = append(args, "-r")
@@ -291,10 +316,7 @@ func (d *Dataset) Destroy(flags DestroyFlag) error {
 	if flags&DestroyForceUmount != 0 {
 		args = append(args, "-f")
 	}
-
-	args = append(args, d.Name)
-	err := zfs(args...)
-	return err
+	return args
 }
 
 // SetProperty sets a ZFS property on the receiving dataset.
@@ -307,7 +329,8 @@ func (d *Dataset) SetProperty(key, val string) error {
 	return err
 }
 
-// GetProperty returns the current value of a ZFS property from the receiving dataset.
+// GetProperty returns the current value of a ZFS property from the receiving dataset,
+// formatted for humans as zfs get prints it, e.g. "1.50G" or a date. Use GetPropertyParsable for exact values.
 //
 // A full list of available ZFS properties may be found in the ZFS manual:
 // https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html.
@@ -320,6 +343,22 @@ func (d *Dataset) GetProperty(key string) (string, error) {
 	return out[0][2], nil
 }
 
+// GetPropertyParsable returns the current value of a ZFS property from the receiving dataset in parsable form (-p),
+// i.e. sizes in bytes and dates in seconds since the epoch.
+func (d *Dataset) GetPropertyParsable(key string) (string, error) {
+	return getProperty(d.Name, key)
+}
+
+// getProperty returns the current value of a ZFS property of the named dataset, with exact numbers (-p).
+func getProperty(name, key string) (string, error) {
+	out, err := zfsOutput("get", "-Hp", key, name)
+	if err != nil {
+		return "", err
+	}
+
+	return out[0][2], nil
+}
+
 // Rename renames a dataset.
 func (d *Dataset) Rename(name string, createParent, recursiveRenameSnapshots bool) (*Dataset, error) {
 	args := make([]string, 3, 5)
@@ -344,6 +383,25 @@ func (d *Dataset) Snapshots() ([]*Dataset, error) {
 	return Snapshots(d.Name)
 }
 
+// SnapshotsSorted returns a slice of the snapshots of the receiving dataset, ordered from oldest to newest by creation time.
+// Unlike Snapshots, snapshots of descendent datasets are not included.
+func (d *Dataset) SnapshotsSorted() ([]*Dataset, error) {
+	return listSortedByCreation(d.Name, DatasetSnapshot)
+}
+
+// LatestSnapshot returns the most recently created snapshot of the receiving dataset.
+// ErrNoSnapshots is returned if the dataset has no snapshots.
+func (d *Dataset) LatestSnapshot() (*Dataset, error) {
+	snapshots, err := d.SnapshotsSorted()
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, ErrNoSnapshots
+	}
+	return snapshots[len(snapshots)-1], nil
+}
+
 // CreateFilesystem creates a new ZFS filesystem with the specified name and properties.
 //
 // A full list of available ZFS properties may be found in the ZFS manual:
@@ -409,27 +467,14 @@ func (d *Dataset) Children(depth uint64) ([]*Dataset, error) {
 	} else {
 		args = append(args, "-r")
 	}
-	args = append(args, "-t", "all", "-Hp", "-o", dsPropListOptions)
-	args = append(args, d.Name)
+	args = append(args, "-t", "all", "-Hp", "-o")
 
-	out, err := zfsOutput(args...)
+	datasets, err := listDatasetsWithOptional(func(props []string) []string {
+		return append(args, strings.Join(props, ","), d.Name)
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	var datasets []*Dataset
-	name := ""
-	var ds *Dataset
-	for _, line := range out {
-		if name != line[0] {
-			name = line[0]
-			ds = &Dataset{Name: name}
-			datasets = append(datasets, ds)
-		}
-		if err := ds.parseLine(line); err != nil {
-			return nil, err
-		}
-	}
 	return datasets[1:], nil
 }
 
@@ -447,3 +492,14 @@ func (d *Dataset) Diff(snapshot string) ([]*InodeChange, error) {
 	}
 	return inodeChanges, nil
 }
+
+// Diff returns changes between a snapshot and a later snapshot or the current state of a ZFS filesystem.
+// Both names must include the filesystem part.
+// Each change carries the inode change time as reported by `zfs diff -t`.
+func Diff(snapshot, otherSnapshotOrFS string) ([]*InodeChange, error) {
+	out, err := zfsOutput("diff", "-FHt", snapshot, otherSnapshotOrFS)
+	if err != nil {
+		return nil, err
+	}
+	return parseTimestampedInodeChanges(out)
+}
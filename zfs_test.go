@@ -1,12 +1,18 @@
 package zfs_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	zfs "github.com/mistifyio/go-zfs/v3"
 )
@@ -21,6 +27,8 @@ func TestDatasets(t *testing.T) {
 	ok(t, err)
 	equals(t, zfs.DatasetFilesystem, ds.Type)
 	equals(t, "", ds.Origin)
+	assert(t, ds.GUID != 0, "GUID is not set")
+	assert(t, ds.Createtxg != 0, "Createtxg is not set")
 	if runtime.GOOS != "solaris" {
 		assert(t, ds.Logicalused != 0, "Logicalused is not greater than 0")
 	}
@@ -46,6 +54,66 @@ func TestDatasetGetProperty(t *testing.T) {
 	if len(strings.Fields(prop)) != 5 {
 		t.Errorf("expected a string with spaces in it, got: %v", prop)
 	}
+
+	// unless it is parsable
+	prop, err = ds.GetPropertyParsable("creation")
+	ok(t, err)
+	if _, err := strconv.ParseInt(prop, 10, 64); err != nil {
+		t.Errorf("expected seconds since the epoch, got: %v", prop)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	ds, err := zfs.GetDataset("test")
+	ok(t, err)
+	equals(t, "off", ds.Compression)
+	ok(t, ds.SetProperty("compression", "lz4"))
+	ok(t, ds.Refresh())
+	equals(t, "lz4", ds.Compression)
+
+	pool, err := zfs.GetZpool("test")
+	ok(t, err)
+	pool.Health = ""
+	ok(t, pool.Refresh())
+	equals(t, zfs.ZpoolOnline, pool.Health)
+}
+
+func TestListDatasets(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	datasets, err := zfs.ListDatasets("test", zfs.ListOptions{NamesOnly: true})
+	ok(t, err)
+	equals(t, 1, len(datasets))
+	equals(t, "test", datasets[0].Name)
+	equals(t, "", datasets[0].Type)
+
+	ok(t, datasets[0].Refresh())
+	equals(t, zfs.DatasetFilesystem, datasets[0].Type)
+
+	datasets, err = zfs.ListDatasets("test", zfs.ListOptions{Types: []string{zfs.DatasetFilesystem}, Properties: []string{"compression"}})
+	ok(t, err)
+	equals(t, 1, len(datasets))
+	equals(t, "off", datasets[0].Compression)
+	equals(t, uint64(0), datasets[0].Used)
+}
+
+func TestDatasetForPathMounted(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/path-lookup", nil)
+	ok(t, err)
+
+	ds, err := zfs.DatasetForPath(f.Mountpoint)
+	ok(t, err)
+	equals(t, "test/path-lookup", ds.Name)
+
+	ds, err = zfs.DatasetForPath(filepath.Join(f.Mountpoint, "missing", "file"))
+	ok(t, err)
+	equals(t, "test/path-lookup", ds.Name)
+
+	ok(t, f.Destroy(zfs.DestroyDefault))
 }
 
 func TestSnapshots(t *testing.T) {
@@ -203,6 +271,498 @@ func TestSendSnapshot(t *testing.T) {
 	ok(t, f.Destroy(zfs.DestroyDefault))
 }
 
+func TestSendTo(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/snapshot-test", nil)
+	ok(t, err)
+
+	s, err := f.Snapshot("test", false)
+	ok(t, err)
+
+	var buf bytes.Buffer
+	ok(t, s.SendTo(context.Background(), &buf, zfs.SendOptions{}))
+	assert(t, buf.Len() > 0, "send stream is empty")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = s.SendTo(ctx, &buf, zfs.SendOptions{})
+	assert(t, errors.Is(err, context.Canceled), "expected context.Canceled, got %v", err)
+
+	nok(t, f.SendTo(context.Background(), &buf, zfs.SendOptions{}))
+
+	estimate, err := s.SendEstimate(zfs.SendOptions{})
+	ok(t, err)
+	assert(t, estimate > 0, "send estimate is 0")
+
+	ok(t, s.Destroy(zfs.DestroyDefault))
+	ok(t, f.Destroy(zfs.DestroyDefault))
+}
+
+func TestReceiveFrom(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/snapshot-test", nil)
+	ok(t, err)
+
+	s, err := f.Snapshot("test", false)
+	ok(t, err)
+
+	var buf bytes.Buffer
+	ok(t, s.SendTo(context.Background(), &buf, zfs.SendOptions{}))
+
+	r, err := zfs.ReceiveFrom(context.Background(), &buf, "test/received", zfs.ReceiveOptions{
+		NoMount:    true,
+		Properties: map[string]string{"readonly": "on"},
+	})
+	ok(t, err)
+	equals(t, zfs.DatasetFilesystem, r.Type)
+
+	prop, err := r.GetProperty("readonly")
+	ok(t, err)
+	equals(t, "on", prop)
+
+	token, err := r.ReceiveResumeToken()
+	ok(t, err)
+	equals(t, "", token)
+
+	ok(t, r.Destroy(zfs.DestroyRecursive))
+	ok(t, f.Destroy(zfs.DestroyRecursive))
+}
+
+func TestReplicationStream(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/source", map[string]string{"compression": "lz4"})
+	ok(t, err)
+	_, err = zfs.CreateFilesystem("test/source/child", nil)
+	ok(t, err)
+
+	s, err := f.Snapshot("test", true)
+	ok(t, err)
+
+	var buf bytes.Buffer
+	ok(t, s.SendTo(context.Background(), &buf, zfs.SendOptions{Replicate: true}))
+
+	r, err := zfs.ReceiveFrom(context.Background(), &buf, "test/replica", zfs.ReceiveOptions{
+		NoMount:    true,
+		Properties: map[string]string{"compression": "off"},
+	})
+	ok(t, err)
+
+	_, err = zfs.GetDataset("test/replica/child@test")
+	ok(t, err)
+
+	received, err := r.ReceivedProperties()
+	ok(t, err)
+	equals(t, "lz4", received["compression"])
+	equals(t, "off", r.Compression)
+
+	ok(t, r.Destroy(zfs.DestroyRecursive))
+	ok(t, f.Destroy(zfs.DestroyRecursive))
+}
+
+func TestReplicate(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/source", nil)
+	ok(t, err)
+
+	s, err := f.Snapshot("test", false)
+	ok(t, err)
+
+	r, err := zfs.Replicate(context.Background(), s, "test/replica", zfs.ReplicateOptions{
+		Receive: zfs.ReceiveOptions{NoMount: true},
+	})
+	ok(t, err)
+	equals(t, zfs.DatasetFilesystem, r.Type)
+
+	rs, err := zfs.GetDataset("test/replica@test")
+	ok(t, err)
+	equals(t, s.GUID, rs.GUID)
+
+	// receiving the same snapshot again must fail and stop the send
+	_, err = zfs.Replicate(context.Background(), s, "test/replica", zfs.ReplicateOptions{})
+	nok(t, err)
+
+	ok(t, r.Destroy(zfs.DestroyRecursive))
+	ok(t, f.Destroy(zfs.DestroyRecursive))
+}
+
+func TestPropertyOverrides(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/source", map[string]string{"compression": "gzip"})
+	ok(t, err)
+	s, err := f.Snapshot("test", false)
+	ok(t, err)
+	r, err := zfs.Replicate(context.Background(), s, "test/replica", zfs.ReplicateOptions{
+		Send:    zfs.SendOptions{Props: true},
+		Receive: zfs.ReceiveOptions{NoMount: true},
+	})
+	ok(t, err)
+
+	ok(t, r.SetProperty("compression", "off"))
+	overrides, err := r.PropertyOverrides(false)
+	ok(t, err)
+	equals(t, []zfs.PropertyOverride{{Dataset: "test/replica", Property: "compression", Local: "off", Received: "gzip"}}, overrides)
+
+	ok(t, zfs.RevertToReceived(context.Background(), overrides, 0))
+	overrides, err = r.PropertyOverrides(false)
+	ok(t, err)
+	equals(t, 0, len(overrides))
+	compression, err := r.GetProperty("compression")
+	ok(t, err)
+	equals(t, "gzip", compression)
+
+	ok(t, r.Destroy(zfs.DestroyRecursive))
+	ok(t, f.Destroy(zfs.DestroyRecursive))
+}
+
+func TestWithTempSnapshot(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/temp-snapshot", nil)
+	ok(t, err)
+
+	var name string
+	ok(t, zfs.WithTempSnapshot(context.Background(), f.Name, func(s *zfs.Dataset) error {
+		name = s.Name
+		equals(t, zfs.DatasetSnapshot, s.Type)
+		_, err := zfs.GetDataset(s.Name)
+		return err
+	}))
+	_, err = zfs.GetDataset(name)
+	nok(t, err)
+
+	// the snapshot is destroyed when the callback panics as well
+	func() {
+		defer func() { _ = recover() }()
+		_ = zfs.WithTempSnapshot(context.Background(), f.Name, func(s *zfs.Dataset) error {
+			name = s.Name
+			panic("failed")
+		})
+	}()
+	_, err = zfs.GetDataset(name)
+	nok(t, err)
+
+	ok(t, f.Destroy(zfs.DestroyDefault))
+}
+
+func TestSendToFile(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/archive-test", nil)
+	ok(t, err)
+	base, err := f.Snapshot("base", false)
+	ok(t, err)
+	s, err := f.Snapshot("snap", false)
+	ok(t, err)
+
+	dir, err := ioutil.TempDir("", "archive-")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snap.zfs")
+
+	m, err := s.SendToFile(context.Background(), path, zfs.SendOptions{IncrementalBase: "@base"})
+	ok(t, err)
+	equals(t, s.GUID, m.GUID)
+	equals(t, base.Name, m.IncrementalBase)
+	equals(t, base.GUID, m.BaseGUID)
+
+	verified, err := zfs.VerifyStreamFile(path)
+	ok(t, err)
+	equals(t, m.SHA256, verified.SHA256)
+
+	ok(t, f.Destroy(zfs.DestroyRecursive))
+}
+
+func TestResumableReceive(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/snapshot-test", nil)
+	ok(t, err)
+
+	s, err := f.Snapshot("test", false)
+	ok(t, err)
+
+	var buf bytes.Buffer
+	ok(t, s.SendTo(context.Background(), &buf, zfs.SendOptions{}))
+	stream := buf.Bytes()
+
+	// receive a truncated stream to leave a partially received state behind
+	_, err = zfs.ReceiveFrom(context.Background(), bytes.NewReader(stream[:len(stream)/2]), "test/received", zfs.ReceiveOptions{Resumable: true})
+	nok(t, err)
+
+	partial, err := zfs.GetDataset("test/received")
+	ok(t, err)
+	token, err := partial.ReceiveResumeToken()
+	ok(t, err)
+	assert(t, token != "", "receive_resume_token is not set")
+
+	decoded, err := zfs.DecodeResumeToken(token)
+	ok(t, err)
+	equals(t, s.Name, decoded.ToName)
+	equals(t, s.GUID, decoded.ToGUID)
+
+	buf.Reset()
+	ok(t, zfs.ResumeSend(context.Background(), token, &buf))
+	_, err = zfs.ReceiveFrom(context.Background(), &buf, "test/received", zfs.ReceiveOptions{Resumable: true})
+	ok(t, err)
+
+	r, err := zfs.GetDataset("test/received@test")
+	ok(t, err)
+	equals(t, s.GUID, r.GUID)
+
+	ok(t, partial.Destroy(zfs.DestroyRecursive))
+	ok(t, f.Destroy(zfs.DestroyRecursive))
+}
+
+func TestBookmarks(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/bookmark-test", nil)
+	ok(t, err)
+
+	s1, err := f.Snapshot("one", false)
+	ok(t, err)
+
+	b, err := s1.Bookmark("one")
+	ok(t, err)
+	equals(t, "test/bookmark-test#one", b.Name)
+	equals(t, zfs.DatasetBookmark, b.Type)
+	equals(t, s1.GUID, b.GUID)
+
+	_, err = f.Bookmark("fs")
+	nok(t, err)
+
+	// the bookmark is an incremental base once the snapshot is gone
+	ok(t, s1.Destroy(zfs.DestroyDefault))
+	s2, err := f.Snapshot("two", false)
+	ok(t, err)
+	var buf bytes.Buffer
+	ok(t, s2.SendTo(context.Background(), &buf, zfs.SendOptions{IncrementalBase: "#one"}))
+
+	bookmarks, err := zfs.ListBookmarks(f.Name)
+	ok(t, err)
+	equals(t, 1, len(bookmarks))
+	equals(t, b.Name, bookmarks[0].Name)
+
+	nok(t, zfs.DestroyBookmark(s2.Name))
+	ok(t, zfs.DestroyBookmark(b.Name))
+
+	ok(t, f.Destroy(zfs.DestroyRecursive))
+}
+
+func TestDestroySafe(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/destroy-test", nil)
+	ok(t, err)
+
+	s, err := f.Snapshot("test", false)
+	ok(t, err)
+
+	c, err := s.Clone("test/clone-test", nil)
+	ok(t, err)
+
+	blockers, err := f.DestroySafe(false)
+	equals(t, zfs.ErrDestroyBlocked, err)
+	equals(t, []string{s.Name}, blockers.Children)
+	equals(t, []string{c.Name}, blockers.Clones)
+
+	_, err = zfs.GetDataset(f.Name)
+	ok(t, err)
+
+	preview, err := f.DestroyPreview(zfs.DestroyRecursive | zfs.DestroyRecursiveClones)
+	ok(t, err)
+	assert(t, len(preview.Datasets) == 3, "expected 3 datasets to be destroyed, got: %v", preview.Datasets)
+
+	_, err = f.DestroySafe(true)
+	ok(t, err)
+
+	_, err = zfs.GetDataset(c.Name)
+	nok(t, err)
+}
+
+func TestWait(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/wait-test", nil)
+	ok(t, err)
+
+	ok(t, f.Wait(context.Background(), zfs.ActivityDeleteQueue))
+
+	ok(t, f.Destroy(zfs.DestroyDefault))
+}
+
+func TestCreateEncryptedFilesystem(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateEncryptedFilesystem("test/encrypted", nil, zfs.EncryptionOptions{
+		KeyFormat: zfs.KeyFormatPassphrase,
+	}, strings.NewReader("correct horse battery staple\n"))
+	ok(t, err)
+
+	prop, err := f.GetProperty("encryption")
+	ok(t, err)
+	equals(t, "aes-256-gcm", prop)
+
+	_, err = f.Unmount(false)
+	ok(t, err)
+	ok(t, f.UnloadKey(false))
+
+	status, err := f.EncryptionStatus()
+	ok(t, err)
+	equals(t, zfs.KeyStatusUnavailable, status.KeyStatus)
+	equals(t, f.Name, status.EncryptionRoot)
+
+	locked, err := zfs.ListLockedDatasets()
+	ok(t, err)
+	equals(t, 1, len(locked))
+	equals(t, f.Name, locked[0].Name)
+
+	nok(t, f.LoadKey(zfs.LoadKeyOptions{Key: strings.NewReader("wrong passphrase\n")}))
+	ok(t, f.LoadKey(zfs.LoadKeyOptions{Key: strings.NewReader("correct horse battery staple\n")}))
+
+	prop, err = f.GetProperty("keystatus")
+	ok(t, err)
+	equals(t, "available", prop)
+
+	ok(t, f.ChangeKey(zfs.ChangeKeyOptions{KeyLocation: "prompt", Key: strings.NewReader("rotated passphrase\n")}))
+	ok(t, f.LoadKey(zfs.LoadKeyOptions{DryRun: true, Key: strings.NewReader("rotated passphrase\n")}))
+
+	_, err = f.Unmount(false)
+	ok(t, err)
+	ok(t, f.UnloadKey(false))
+
+	results, err := zfs.UnlockAll(context.Background(), func(context.Context, string, *zfs.EncryptionStatus) (io.Reader, error) {
+		return strings.NewReader("rotated passphrase\n"), nil
+	})
+	ok(t, err)
+	equals(t, 1, len(results))
+	ok(t, results[0].Err)
+	equals(t, []string{f.Name}, results[0].Mounted)
+
+	ok(t, f.Destroy(zfs.DestroyDefault))
+}
+
+func TestAllow(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/allow-test", nil)
+	ok(t, err)
+
+	everyone := zfs.Delegation{
+		Target:      zfs.TargetEveryone,
+		Scope:       zfs.ScopeLocal,
+		Permissions: []zfs.Permission{zfs.PermissionMount, zfs.PermissionSnapshot},
+	}
+	ok(t, f.Allow(everyone))
+	ok(t, f.DefinePermissionSet("@backup", zfs.PermissionSend, zfs.PermissionHold))
+
+	tables, err := f.Permissions()
+	ok(t, err)
+	assert(t, len(tables) > 0, "no permission tables")
+	equals(t, f.Name, tables[0].Dataset)
+	equals(t, []zfs.Delegation{everyone}, tables[0].Delegations)
+	equals(t, []zfs.Permission{zfs.PermissionHold, zfs.PermissionSend}, tables[0].Sets["@backup"])
+
+	ok(t, f.Unallow(zfs.Delegation{Target: zfs.TargetEveryone, Scope: zfs.ScopeLocal}, false))
+
+	ok(t, f.Destroy(zfs.DestroyDefault))
+}
+
+func TestACLOptions(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("test relies on POSIX ACLs")
+	}
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/acl-test", nil)
+	ok(t, err)
+
+	ok(t, f.SetACLOptions(zfs.ACLOptions{ACLType: zfs.ACLTypePOSIX, Xattr: zfs.XattrSA}))
+
+	opts, err := f.GetACLOptions()
+	ok(t, err)
+	equals(t, zfs.ACLTypePOSIX, opts.ACLType)
+	equals(t, zfs.XattrSA, opts.Xattr)
+
+	ok(t, f.Destroy(zfs.DestroyDefault))
+}
+
+func TestRunChannelProgram(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	z, err := zfs.GetZpool("test")
+	ok(t, err)
+
+	r, err := z.RunChannelProgram(`args = ...
+local value, source = zfs.get_prop(args["argv"][1], "type")
+return value`, "test")
+	ok(t, err)
+
+	var typ string
+	ok(t, r.Decode(&typ))
+	equals(t, "filesystem", typ)
+}
+
+func TestChannelProgramLibrary(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/program-test", nil)
+	ok(t, err)
+	_, err = f.Snapshot("old", false)
+	ok(t, err)
+
+	props, err := zfs.ListSnapshotProperties(f.Name, "type", "guid")
+	ok(t, err)
+	equals(t, "snapshot", props[f.Name+"@old"]["type"])
+
+	destroyed, err := zfs.DestroySnapshotsOlderThan(f.Name, time.Now().Add(time.Hour), false, false)
+	ok(t, err)
+	equals(t, []string{f.Name + "@old"}, destroyed)
+
+	g, err := zfs.CreateFilesystem("test/program-group", nil)
+	ok(t, err)
+	snapshots, err := zfs.SnapshotGroup([]string{f.Name, g.Name}, "group")
+	ok(t, err)
+	equals(t, []string{f.Name + "@group", g.Name + "@group"}, snapshots)
+
+	// nothing is snapshotted if one of the datasets cannot be
+	_, err = zfs.SnapshotGroup([]string{f.Name, g.Name + "/missing"}, "partial")
+	nok(t, err)
+	_, err = zfs.GetDataset(f.Name + "@partial")
+	nok(t, err)
+
+	ok(t, g.Destroy(zfs.DestroyRecursive))
+	ok(t, f.Destroy(zfs.DestroyRecursive))
+}
+
+func TestBulkOperations(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	var names []string
+	for _, name := range []string{"test/bulk-a", "test/bulk-b", "test/bulk-c"} {
+		_, err := zfs.CreateFilesystem(name, nil)
+		ok(t, err)
+		names = append(names, name)
+	}
+
+	ctx := context.Background()
+	ok(t, zfs.BulkSetProperty(ctx, names, "compression", "lz4", 2))
+	ok(t, zfs.BulkSnapshot(ctx, names, "bulk", false, 2))
+
+	err := zfs.BulkSnapshot(ctx, append(names, "test/missing"), "bulk2", false, 2)
+	var bulkErr *zfs.BulkError
+	assert(t, errors.As(err, &bulkErr), "expected a *BulkError, got %v", err)
+	equals(t, 1, len(bulkErr.Errors))
+	equals(t, "test/missing", bulkErr.Errors[0].Name)
+
+	ok(t, zfs.BulkDestroy(ctx, names, zfs.DestroyRecursive, 2))
+}
+
 func TestChildren(t *testing.T) {
 	defer setupZPool(t).cleanUp()
 
@@ -225,6 +785,38 @@ func TestChildren(t *testing.T) {
 	ok(t, f.Destroy(zfs.DestroyDefault))
 }
 
+func TestSnapshotsSorted(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/snapshot-test", nil)
+	ok(t, err)
+
+	_, err = f.LatestSnapshot()
+	equals(t, zfs.ErrNoSnapshots, err)
+
+	s1, err := f.Snapshot("b", false)
+	ok(t, err)
+
+	// creation has a resolution of one second
+	sleep(1)
+
+	s2, err := f.Snapshot("a", false)
+	ok(t, err)
+
+	snapshots, err := f.SnapshotsSorted()
+	ok(t, err)
+	equals(t, 2, len(snapshots))
+	equals(t, s1.Name, snapshots[0].Name)
+	equals(t, s2.Name, snapshots[1].Name)
+	assert(t, !snapshots[0].Creation.IsZero(), "Creation is not set")
+
+	latest, err := f.LatestSnapshot()
+	ok(t, err)
+	equals(t, s2.Name, latest.Name)
+
+	ok(t, f.Destroy(zfs.DestroyRecursive))
+}
+
 func TestListZpool(t *testing.T) {
 	defer setupZPool(t).cleanUp()
 
@@ -239,6 +831,52 @@ func TestListZpool(t *testing.T) {
 	t.Fatal("Failed to find test pool")
 }
 
+func TestZpoolSync(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	pool, err := zfs.GetZpool("test")
+	ok(t, err)
+	ok(t, pool.Sync())
+	ok(t, zfs.SyncAll())
+}
+
+func TestZpoolExpand(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	pool, err := zfs.GetZpool("test")
+	ok(t, err)
+	ok(t, pool.SetAutoExpand(true))
+	ok(t, pool.Refresh())
+	equals(t, true, pool.AutoExpand)
+
+	status, err := pool.Status()
+	ok(t, err)
+	device := status.TopLevelVdevs(zfs.VdevClassData)[0].Path
+	ok(t, os.Truncate(device, pow2(31)))
+	ok(t, pool.ExpandDevice(device))
+}
+
+func TestZpoolImportTemporary(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	pool, err := zfs.GetZpool("test")
+	ok(t, err)
+	status, err := pool.Status()
+	ok(t, err)
+	dir := filepath.Dir(status.TopLevelVdevs(zfs.VdevClassData)[0].Path)
+
+	ok(t, pool.Export(false))
+	tmp, err := zfs.ImportZpool("test", zfs.ImportZpoolOptions{AltRoot: "/tmp/altroot", TempName: "test-tmp", Dirs: []string{dir}})
+	ok(t, err)
+	equals(t, "test-tmp", tmp.Name)
+	equals(t, "/tmp/altroot", tmp.AltRoot)
+
+	ok(t, tmp.Export(false))
+	pool, err = zfs.ImportZpool("test", zfs.ImportZpoolOptions{Dirs: []string{dir}})
+	ok(t, err)
+	equals(t, "", pool.AltRoot)
+}
+
 func TestRollback(t *testing.T) {
 	defer setupZPool(t).cleanUp()
 
@@ -0,0 +1,269 @@
+// Package zfshttp provides net/http handlers exposing ZFS pools, datasets and snapshots as JSON endpoints.
+//
+// The handler serves the following endpoints, relative to where it is mounted:
+//
+//	GET    /pools                   all pools
+//	GET    /pools/{pool}            a single pool
+//	GET    /pools/{pool}/status     the status of a pool, including its vdevs
+//	GET    /datasets                all datasets, see below for parameters
+//	GET    /datasets/{name}         a single dataset, snapshot or bookmark
+//	GET    /snapshots?dataset={name} the snapshots of a dataset and its descendants
+//
+// The dataset list takes the parameters filter (a dataset to list recursively), type (repeatable, e.g. snapshot)
+// and names_only (true to load only the names).
+// Unless mutations are allowed, all other requests are answered with 405 Method Not Allowed:
+//
+//	POST   /snapshots               take a snapshot, given {"dataset": ..., "name": ..., "recursive": ...}
+//	PATCH  /datasets/{name}         set the properties given as a JSON object of strings
+//	DELETE /datasets/{name}         destroy a dataset or snapshot, recursively with ?recursive=true
+//
+// Embedding it into an existing service takes a single line:
+//
+//	mux.Handle("/zfs/", http.StripPrefix("/zfs", zfshttp.NewHandler(zfshttp.Options{})))
+package zfshttp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	zfs "github.com/mistifyio/go-zfs/v3"
+)
+
+// Options controls the endpoints served by the handler.
+type Options struct {
+	// AllowMutations enables the endpoints taking snapshots, setting properties and destroying datasets.
+	AllowMutations bool
+}
+
+// backend is the subset of the zfs package used by the handler, replaced in tests.
+type backend interface {
+	ListZpools() ([]*zfs.Zpool, error)
+	GetZpool(name string) (*zfs.Zpool, error)
+	ZpoolStatus(name string) (*zfs.ZpoolStatus, error)
+	ListDatasets(filter string, opts zfs.ListOptions) ([]*zfs.Dataset, error)
+	GetDataset(name string) (*zfs.Dataset, error)
+	Snapshot(dataset, name string, recursive bool) (*zfs.Dataset, error)
+	SetProperty(name, key, val string) error
+	Destroy(name string, flags zfs.DestroyFlag) error
+}
+
+type localBackend struct{}
+
+func (localBackend) ListZpools() ([]*zfs.Zpool, error)        { return zfs.ListZpools() }
+func (localBackend) GetZpool(name string) (*zfs.Zpool, error) { return zfs.GetZpool(name) }
+func (localBackend) ZpoolStatus(name string) (*zfs.ZpoolStatus, error) {
+	return zfs.GetZpoolStatusWithOptions(name, zfs.ZpoolStatusOptions{Parsable: true})
+}
+
+func (localBackend) ListDatasets(filter string, opts zfs.ListOptions) ([]*zfs.Dataset, error) {
+	return zfs.ListDatasets(filter, opts)
+}
+func (localBackend) GetDataset(name string) (*zfs.Dataset, error) { return zfs.GetDataset(name) }
+func (localBackend) Snapshot(dataset, name string, recursive bool) (*zfs.Dataset, error) {
+	return (&zfs.Dataset{Name: dataset}).Snapshot(name, recursive)
+}
+
+func (localBackend) SetProperty(name, key, val string) error {
+	return (&zfs.Dataset{Name: name}).SetProperty(key, val)
+}
+
+func (localBackend) Destroy(name string, flags zfs.DestroyFlag) error {
+	return (&zfs.Dataset{Name: name}).Destroy(flags)
+}
+
+type handler struct {
+	opts    Options
+	backend backend
+}
+
+// NewHandler returns a handler serving the endpoints described in the package documentation.
+func NewHandler(opts Options) http.Handler {
+	return &handler{opts: opts, backend: localBackend{}}
+}
+
+// snapshotRequest is the body of POST /snapshots.
+type snapshotRequest struct {
+	Dataset   string `json:"dataset"`
+	Name      string `json:"name"`
+	Recursive bool   `json:"recursive"`
+}
+
+// errorResponse is the body of all error responses.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	collection, name := path, ""
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		collection, name = path[:i], path[i+1:]
+	}
+
+	switch {
+	case collection == "pools" && r.Method == http.MethodGet:
+		h.getPools(w, name)
+	case collection == "datasets" && name == "" && r.Method == http.MethodGet:
+		h.listDatasets(w, r)
+	case collection == "datasets" && name != "" && r.Method == http.MethodGet:
+		h.reply(w, http.StatusOK)(h.backend.GetDataset(name))
+	case collection == "datasets" && name != "" && r.Method == http.MethodPatch && h.opts.AllowMutations:
+		h.setProperties(w, r, name)
+	case collection == "datasets" && name != "" && r.Method == http.MethodDelete && h.opts.AllowMutations:
+		h.destroy(w, r, name)
+	case collection == "snapshots" && name == "" && r.Method == http.MethodGet:
+		h.listSnapshots(w, r)
+	case collection == "snapshots" && name == "" && r.Method == http.MethodPost && h.opts.AllowMutations:
+		h.snapshot(w, r)
+	case collection == "pools" || collection == "datasets" || collection == "snapshots":
+		h.writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	default:
+		h.writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func (h *handler) getPools(w http.ResponseWriter, name string) {
+	switch {
+	case name == "":
+		pools, err := h.backend.ListZpools()
+		if pools == nil {
+			pools = []*zfs.Zpool{}
+		}
+		h.reply(w, http.StatusOK)(pools, err)
+	case strings.HasSuffix(name, "/status"):
+		h.reply(w, http.StatusOK)(h.backend.ZpoolStatus(strings.TrimSuffix(name, "/status")))
+	case strings.Contains(name, "/"):
+		h.writeError(w, http.StatusNotFound, errors.New("not found"))
+	default:
+		h.reply(w, http.StatusOK)(h.backend.GetZpool(name))
+	}
+}
+
+func (h *handler) listDatasets(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	namesOnly, _ := strconv.ParseBool(q.Get("names_only"))
+	h.replyDatasets(w)(h.backend.ListDatasets(q.Get("filter"), zfs.ListOptions{
+		Types:     q["type"],
+		NamesOnly: namesOnly,
+	}))
+}
+
+func (h *handler) listSnapshots(w http.ResponseWriter, r *http.Request) {
+	dataset := r.URL.Query().Get("dataset")
+	if dataset == "" {
+		h.writeError(w, http.StatusBadRequest, errors.New("the dataset parameter is required"))
+		return
+	}
+	h.replyDatasets(w)(h.backend.ListDatasets(dataset, zfs.ListOptions{Types: []string{zfs.DatasetSnapshot}}))
+}
+
+func (h *handler) snapshot(w http.ResponseWriter, r *http.Request) {
+	var req snapshotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := zfs.ValidateSnapshotName(req.Dataset + "@" + req.Name); err != nil {
+		h.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	h.reply(w, http.StatusCreated)(h.backend.Snapshot(req.Dataset, req.Name, req.Recursive))
+}
+
+func (h *handler) setProperties(w http.ResponseWriter, r *http.Request, name string) {
+	if err := validateName(name); err != nil {
+		h.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var props map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&props); err != nil {
+		h.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := h.backend.SetProperty(name, k, props[k]); err != nil {
+			h.writeError(w, statusCode(err), err)
+			return
+		}
+	}
+	h.reply(w, http.StatusOK)(h.backend.GetDataset(name))
+}
+
+func (h *handler) destroy(w http.ResponseWriter, r *http.Request, name string) {
+	// a range such as tank/fs@a%z must not reach zfs destroy
+	if err := validateName(name); err != nil {
+		h.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	flags := zfs.DestroyDefault
+	if recursive, _ := strconv.ParseBool(r.URL.Query().Get("recursive")); recursive {
+		flags = zfs.DestroyRecursive
+	}
+	if err := h.backend.Destroy(name, flags); err != nil {
+		h.writeError(w, statusCode(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validateName checks the name of a dataset or snapshot given in a path.
+func validateName(name string) error {
+	if strings.Contains(name, "@") {
+		return zfs.ValidateSnapshotName(name)
+	}
+	return zfs.ValidateDatasetName(name)
+}
+
+// reply returns a function writing the result of a backend call, which is passed to it directly.
+func (h *handler) reply(w http.ResponseWriter, code int) func(v interface{}, err error) {
+	return func(v interface{}, err error) {
+		if err != nil {
+			h.writeError(w, statusCode(err), err)
+			return
+		}
+		writeJSON(w, code, v)
+	}
+}
+
+// replyDatasets is like reply for lists of datasets, which are written as an empty array rather than null.
+func (h *handler) replyDatasets(w http.ResponseWriter) func(datasets []*zfs.Dataset, err error) {
+	return func(datasets []*zfs.Dataset, err error) {
+		if datasets == nil {
+			datasets = []*zfs.Dataset{}
+		}
+		h.reply(w, http.StatusOK)(datasets, err)
+	}
+}
+
+func (h *handler) writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// statusCode returns the HTTP status code for errors of the zfs package.
+func statusCode(err error) int {
+	var zfsErr *zfs.Error
+	switch {
+	case errors.Is(err, zfs.ErrInvalidName):
+		return http.StatusBadRequest
+	case errors.As(err, &zfsErr) && strings.Contains(zfsErr.Stderr, "does not exist"):
+		return http.StatusNotFound
+	case errors.As(err, &zfsErr) && strings.Contains(zfsErr.Stderr, "already exists"):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
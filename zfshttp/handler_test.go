@@ -0,0 +1,135 @@
+package zfshttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	zfs "github.com/mistifyio/go-zfs/v3"
+)
+
+// fakeBackend serves a single pool "tank" with the file system "tank/fs" and records mutations.
+type fakeBackend struct {
+	calls []string
+}
+
+var errNotExist = &zfs.Error{Err: errors.New("exit status 1"), Stderr: "cannot open: dataset does not exist"}
+
+func (b *fakeBackend) ListZpools() ([]*zfs.Zpool, error) {
+	return []*zfs.Zpool{{Name: "tank", Health: zfs.ZpoolOnline}}, nil
+}
+
+func (b *fakeBackend) GetZpool(name string) (*zfs.Zpool, error) {
+	if name != "tank" {
+		return nil, errNotExist
+	}
+	return &zfs.Zpool{Name: "tank", Health: zfs.ZpoolOnline}, nil
+}
+
+func (b *fakeBackend) ZpoolStatus(name string) (*zfs.ZpoolStatus, error) {
+	return &zfs.ZpoolStatus{Name: name, State: zfs.ZpoolOnline}, nil
+}
+
+func (b *fakeBackend) ListDatasets(filter string, opts zfs.ListOptions) ([]*zfs.Dataset, error) {
+	b.calls = append(b.calls, "list "+filter+" "+strings.Join(opts.Types, ","))
+	if filter == "tank/empty" {
+		return nil, nil
+	}
+	return []*zfs.Dataset{{Name: "tank"}, {Name: "tank/fs"}}, nil
+}
+
+func (b *fakeBackend) GetDataset(name string) (*zfs.Dataset, error) {
+	if name != "tank/fs" && name != "tank/fs@snap" {
+		return nil, errNotExist
+	}
+	return &zfs.Dataset{Name: name, Compression: "lz4"}, nil
+}
+
+func (b *fakeBackend) Snapshot(dataset, name string, recursive bool) (*zfs.Dataset, error) {
+	b.calls = append(b.calls, "snapshot "+dataset+"@"+name)
+	return &zfs.Dataset{Name: dataset + "@" + name, Type: zfs.DatasetSnapshot}, nil
+}
+
+func (b *fakeBackend) SetProperty(name, key, val string) error {
+	b.calls = append(b.calls, "set "+name+" "+key+"="+val)
+	return nil
+}
+
+func (b *fakeBackend) Destroy(name string, flags zfs.DestroyFlag) error {
+	call := "destroy " + name
+	if flags&zfs.DestroyRecursive != 0 {
+		call += " -r"
+	}
+	b.calls = append(b.calls, call)
+	return nil
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		mutable  bool
+		method   string
+		path     string
+		body     string
+		code     int
+		response string
+		calls    []string
+	}{
+		{"pools", false, "GET", "/pools", "", 200, `"Health":"ONLINE"`, nil},
+		{"pool", false, "GET", "/pools/tank", "", 200, `"Name":"tank"`, nil},
+		{"missing pool", false, "GET", "/pools/other", "", 404, `"error":`, nil},
+		{"pool status", false, "GET", "/pools/tank/status", "", 200, `"state":"ONLINE"`, nil},
+		{"datasets", false, "GET", "/datasets?filter=tank&type=filesystem&type=volume", "", 200, `"Name":"tank/fs"`, []string{"list tank filesystem,volume"}},
+		{"dataset", false, "GET", "/datasets/tank/fs", "", 200, `"Compression":"lz4"`, nil},
+		{"snapshot dataset", false, "GET", "/datasets/tank/fs@snap", "", 200, `"Name":"tank/fs@snap"`, nil},
+		{"missing dataset", false, "GET", "/datasets/tank/other", "", 404, `"error":`, nil},
+		{"snapshots", false, "GET", "/snapshots?dataset=tank/empty", "", 200, `[]`, []string{"list tank/empty snapshot"}},
+		{"snapshots without dataset", false, "GET", "/snapshots", "", 400, `"error":`, nil},
+		{"unknown", false, "GET", "/volumes", "", 404, `"error":`, nil},
+
+		{"read-only snapshot", false, "POST", "/snapshots", `{"dataset":"tank/fs","name":"snap"}`, 405, `"error":`, nil},
+		{"read-only set", false, "PATCH", "/datasets/tank/fs", `{"compression":"off"}`, 405, `"error":`, nil},
+		{"read-only destroy", false, "DELETE", "/datasets/tank/fs", "", 405, `"error":`, nil},
+
+		{"snapshot", true, "POST", "/snapshots", `{"dataset":"tank/fs","name":"snap"}`, 201, `"Type":"snapshot"`, []string{"snapshot tank/fs@snap"}},
+		{"invalid snapshot", true, "POST", "/snapshots", `{"dataset":"tank/fs","name":"a/b"}`, 400, `invalid name`, nil},
+		{"set", true, "PATCH", "/datasets/tank/fs", `{"compression":"off","atime":"off"}`, 200, `"Name":"tank/fs"`, []string{"set tank/fs atime=off", "set tank/fs compression=off"}},
+		{"bad body", true, "PATCH", "/datasets/tank/fs", `[]`, 400, `"error":`, nil},
+		{"destroy", true, "DELETE", "/datasets/tank/fs?recursive=true", "", 204, ``, []string{"destroy tank/fs -r"}},
+		{"destroy snapshot range", true, "DELETE", "/datasets/tank/fs@a%25z", "", 400, `invalid name`, nil},
+		{"destroy bookmark", true, "DELETE", "/datasets/tank/fs%23mark", "", 400, `invalid name`, nil},
+		{"set invalid name", true, "PATCH", "/datasets/tank/../fs", `{"compression":"off"}`, 400, `invalid name`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &fakeBackend{}
+			h := &handler{opts: Options{AllowMutations: tt.mutable}, backend: b}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if rec.Code != tt.code {
+				t.Fatalf("wanted: %d, got: %d (%s)", tt.code, rec.Code, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.response) {
+				t.Fatalf("wanted a response containing %s, got: %s", tt.response, rec.Body)
+			}
+			if !reflect.DeepEqual(tt.calls, b.calls) {
+				t.Fatalf("wanted: %v, got: %v", tt.calls, b.calls)
+			}
+		})
+	}
+}
+
+func TestNewHandlerStripPrefix(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/zfs/", http.StripPrefix("/zfs", NewHandler(Options{})))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("DELETE", "/zfs/datasets/tank/fs", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("wanted: %d, got: %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
@@ -0,0 +1,13 @@
+// Package zfsrpc exposes the operations of the zfs package as a gRPC service, for running a remote ZFS management agent.
+//
+// It is a separate module, so that users of the zfs package do not depend on gRPC.
+// A server allowing changes to datasets is set up like this:
+//
+//	srv := zfsrpc.NewServer()
+//	srv.ReadOnly = false
+//	s := grpc.NewServer()
+//	zfsrpc.RegisterZFSServer(s, srv)
+//	s.Serve(listener)
+package zfsrpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative zfsrpc.proto
@@ -0,0 +1,20 @@
+module github.com/mistifyio/go-zfs/v3/zfsrpc
+
+go 1.17
+
+require (
+	github.com/mistifyio/go-zfs/v3 v3.0.0
+	google.golang.org/grpc v1.56.3
+	google.golang.org/protobuf v1.31.0
+)
+
+require (
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/google/uuid v1.3.0 // indirect
+	golang.org/x/net v0.9.0 // indirect
+	golang.org/x/sys v0.7.0 // indirect
+	golang.org/x/text v0.9.0 // indirect
+	google.golang.org/genproto v0.0.0-20230410155749-daa745c078e1 // indirect
+)
+
+replace github.com/mistifyio/go-zfs/v3 => ../
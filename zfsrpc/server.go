@@ -0,0 +1,316 @@
+package zfsrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	zfs "github.com/mistifyio/go-zfs/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// chunkSize is the maximum size of the chunks a send stream is split into.
+const chunkSize = 64 << 10
+
+// Server implements the ZFS service using the local zfs and zpool commands.
+type Server struct {
+	UnimplementedZFSServer
+
+	// ReadOnly rejects all calls that change datasets with codes.PermissionDenied.
+	ReadOnly bool
+}
+
+// NewServer returns a read-only server.
+// Set ReadOnly to false to allow calls that change datasets.
+func NewServer() *Server {
+	return &Server{ReadOnly: true}
+}
+
+// ListDatasets recursively lists the datasets below the request's filter.
+func (s *Server) ListDatasets(ctx context.Context, req *ListDatasetsRequest) (*ListDatasetsResponse, error) {
+	datasets, err := zfs.ListDatasets(req.Filter, zfs.ListOptions{
+		Types:      req.Types,
+		NamesOnly:  req.NamesOnly,
+		Properties: req.Properties,
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &ListDatasetsResponse{Datasets: make([]*Dataset, len(datasets))}
+	for i, ds := range datasets {
+		resp.Datasets[i] = datasetToProto(ds)
+	}
+	return resp, nil
+}
+
+// GetDataset returns the requested dataset.
+func (s *Server) GetDataset(ctx context.Context, req *GetDatasetRequest) (*Dataset, error) {
+	ds, err := zfs.GetDataset(req.Name)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return datasetToProto(ds), nil
+}
+
+// GetProperty returns the requested property of a dataset.
+func (s *Server) GetProperty(ctx context.Context, req *GetPropertyRequest) (*GetPropertyResponse, error) {
+	val, err := (&zfs.Dataset{Name: req.Name}).GetProperty(req.Property)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &GetPropertyResponse{Value: val}, nil
+}
+
+// SetProperty sets a property of a dataset.
+func (s *Server) SetProperty(ctx context.Context, req *SetPropertyRequest) (*SetPropertyResponse, error) {
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	if err := (&zfs.Dataset{Name: req.Name}).SetProperty(req.Property, req.Value); err != nil {
+		return nil, toStatus(err)
+	}
+	return &SetPropertyResponse{}, nil
+}
+
+// Snapshot takes a snapshot of a dataset.
+func (s *Server) Snapshot(ctx context.Context, req *SnapshotRequest) (*Dataset, error) {
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	if err := zfs.ValidateSnapshotName(req.Dataset + "@" + req.Name); err != nil {
+		return nil, toStatus(err)
+	}
+	snap, err := (&zfs.Dataset{Name: req.Dataset}).Snapshot(req.Name, req.Recursive)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return datasetToProto(snap), nil
+}
+
+// Destroy destroys a dataset or snapshot.
+func (s *Server) Destroy(ctx context.Context, req *DestroyRequest) (*DestroyResponse, error) {
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	flags := zfs.DestroyDefault
+	if req.Recursive {
+		flags |= zfs.DestroyRecursive
+	}
+	if req.RecursiveClones {
+		flags |= zfs.DestroyRecursiveClones
+	}
+	if req.DeferDeletion {
+		flags |= zfs.DestroyDeferDeletion
+	}
+	if req.ForceUnmount {
+		flags |= zfs.DestroyForceUmount
+	}
+	validate := zfs.ValidateDatasetName
+	if strings.Contains(req.Name, "@") {
+		validate = zfs.ValidateSnapshotName
+	}
+	if err := validate(req.Name); err != nil {
+		return nil, toStatus(err)
+	}
+	if err := (&zfs.Dataset{Name: req.Name}).Destroy(flags); err != nil {
+		return nil, toStatus(err)
+	}
+	return &DestroyResponse{}, nil
+}
+
+// Send streams the requested snapshot to the client.
+func (s *Server) Send(req *SendRequest, stream ZFS_SendServer) error {
+	opts := zfs.SendOptions{
+		IncrementalBase: req.IncrementalBase,
+		Intermediate:    req.Intermediate,
+		Raw:             req.Raw,
+		Compressed:      req.Compressed,
+		EmbedData:       req.EmbedData,
+		LargeBlock:      req.LargeBlock,
+		Holds:           req.Holds,
+		Props:           req.Props,
+		Replicate:       req.Replicate,
+		ExcludeDatasets: req.ExcludeDatasets,
+	}
+	if err := zfs.ValidateSnapshotName(req.Snapshot); err != nil {
+		return toStatus(err)
+	}
+	w := &chunkWriter{send: stream.Send}
+	snap := &zfs.Dataset{Name: req.Snapshot, Type: zfs.DatasetSnapshot}
+	if err := snap.SendTo(stream.Context(), w, opts); err != nil {
+		return toStatus(err)
+	}
+	return nil
+}
+
+// Receive receives a stream from the client into the target named by the first message.
+func (s *Server) Receive(stream ZFS_ReceiveServer) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	h := first.GetHeader()
+	if h == nil {
+		return status.Error(codes.InvalidArgument, "the first message must be the receive header")
+	}
+	opts := zfs.ReceiveOptions{
+		Force:             h.Force,
+		NoMount:           h.NoMount,
+		Resumable:         h.Resumable,
+		DiscardFirstName:  h.DiscardFirstName,
+		UseLastName:       h.UseLastName,
+		Properties:        h.Properties,
+		ExcludeProperties: h.ExcludeProperties,
+	}
+	ds, err := zfs.ReceiveFrom(stream.Context(), &chunkReader{recv: stream.Recv}, h.Target, opts)
+	if err != nil {
+		return toStatus(err)
+	}
+	return stream.SendAndClose(datasetToProto(ds))
+}
+
+// ListZpools lists all pools.
+func (s *Server) ListZpools(ctx context.Context, req *ListZpoolsRequest) (*ListZpoolsResponse, error) {
+	pools, err := zfs.ListZpools()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &ListZpoolsResponse{Zpools: make([]*Zpool, len(pools))}
+	for i, z := range pools {
+		resp.Zpools[i] = zpoolToProto(z)
+	}
+	return resp, nil
+}
+
+// GetZpool returns the requested pool.
+func (s *Server) GetZpool(ctx context.Context, req *GetZpoolRequest) (*Zpool, error) {
+	z, err := zfs.GetZpool(req.Name)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return zpoolToProto(z), nil
+}
+
+func (s *Server) checkWritable() error {
+	if s.ReadOnly {
+		return status.Error(codes.PermissionDenied, "the server is read-only")
+	}
+	return nil
+}
+
+// toStatus translates errors of the zfs package to gRPC status errors.
+func toStatus(err error) error {
+	code := codes.Unknown
+	var zfsErr *zfs.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, zfs.ErrInvalidName):
+		code = codes.InvalidArgument
+	case errors.As(err, &zfsErr):
+		switch {
+		case strings.Contains(zfsErr.Stderr, "does not exist"):
+			code = codes.NotFound
+		case strings.Contains(zfsErr.Stderr, "already exists"):
+			code = codes.AlreadyExists
+		case strings.Contains(zfsErr.Stderr, "permission denied"):
+			code = codes.PermissionDenied
+		}
+	}
+	return status.Error(code, err.Error())
+}
+
+func datasetToProto(ds *zfs.Dataset) *Dataset {
+	d := &Dataset{
+		Name:          ds.Name,
+		Origin:        ds.Origin,
+		Used:          ds.Used,
+		Available:     ds.Avail,
+		Mountpoint:    ds.Mountpoint,
+		Compression:   ds.Compression,
+		Type:          ds.Type,
+		Written:       ds.Written,
+		Volsize:       ds.Volsize,
+		Logicalused:   ds.Logicalused,
+		Usedbydataset: ds.Usedbydataset,
+		Quota:         ds.Quota,
+		Referenced:    ds.Referenced,
+		Guid:          ds.GUID,
+		Createtxg:     ds.Createtxg,
+		Objsetid:      ds.Objsetid,
+	}
+	if !ds.Creation.IsZero() {
+		d.Creation = timestamppb.New(ds.Creation)
+	}
+	return d
+}
+
+func zpoolToProto(z *zfs.Zpool) *Zpool {
+	return &Zpool{
+		Name:          z.Name,
+		Health:        z.Health,
+		Allocated:     z.Allocated,
+		Size:          z.Size,
+		Free:          z.Free,
+		Fragmentation: z.Fragmentation,
+		Readonly:      z.ReadOnly,
+		Freeing:       z.Freeing,
+		Leaked:        z.Leaked,
+		Dedupratio:    z.DedupRatio,
+	}
+}
+
+// chunkWriter sends everything written to it as chunks of at most chunkSize bytes.
+type chunkWriter struct {
+	send func(*Chunk) error
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		c := p
+		if len(c) > chunkSize {
+			c = c[:chunkSize]
+		}
+		// the chunk is marshaled before send returns, so p may be reused afterwards
+		if err := w.send(&Chunk{Data: c}); err != nil {
+			return n, err
+		}
+		n += len(c)
+		p = p[len(c):]
+	}
+	return n, nil
+}
+
+// chunkReader reads the data of received chunks.
+type chunkReader struct {
+	recv func() (*ReceiveRequest, error)
+	buf  []byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		req, err := r.recv()
+		if err != nil {
+			return 0, err
+		}
+		c := req.GetChunk()
+		if c == nil {
+			return 0, errors.New("expected a stream chunk")
+		}
+		r.buf = c.Data
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+var _ io.Reader = (*chunkReader)(nil)
@@ -0,0 +1,511 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: zfsrpc.proto
+
+package zfsrpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// ZFSClient is the client API for ZFS service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ZFSClient interface {
+	// ListDatasets recursively lists the datasets below a filter.
+	ListDatasets(ctx context.Context, in *ListDatasetsRequest, opts ...grpc.CallOption) (*ListDatasetsResponse, error)
+	// GetDataset returns a single dataset.
+	GetDataset(ctx context.Context, in *GetDatasetRequest, opts ...grpc.CallOption) (*Dataset, error)
+	// GetProperty returns the value of a single property of a dataset.
+	GetProperty(ctx context.Context, in *GetPropertyRequest, opts ...grpc.CallOption) (*GetPropertyResponse, error)
+	// SetProperty sets a property of a dataset.
+	SetProperty(ctx context.Context, in *SetPropertyRequest, opts ...grpc.CallOption) (*SetPropertyResponse, error)
+	// Snapshot takes a snapshot of a dataset and returns it.
+	Snapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*Dataset, error)
+	// Destroy destroys a dataset or snapshot.
+	Destroy(ctx context.Context, in *DestroyRequest, opts ...grpc.CallOption) (*DestroyResponse, error)
+	// Send streams a snapshot as a sequence of chunks.
+	Send(ctx context.Context, in *SendRequest, opts ...grpc.CallOption) (ZFS_SendClient, error)
+	// Receive receives a stream whose first message is the header, followed by the stream's chunks.
+	Receive(ctx context.Context, opts ...grpc.CallOption) (ZFS_ReceiveClient, error)
+	// ListZpools lists all pools.
+	ListZpools(ctx context.Context, in *ListZpoolsRequest, opts ...grpc.CallOption) (*ListZpoolsResponse, error)
+	// GetZpool returns a single pool.
+	GetZpool(ctx context.Context, in *GetZpoolRequest, opts ...grpc.CallOption) (*Zpool, error)
+}
+
+type zFSClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewZFSClient(cc grpc.ClientConnInterface) ZFSClient {
+	return &zFSClient{cc}
+}
+
+func (c *zFSClient) ListDatasets(ctx context.Context, in *ListDatasetsRequest, opts ...grpc.CallOption) (*ListDatasetsResponse, error) {
+	out := new(ListDatasetsResponse)
+	err := c.cc.Invoke(ctx, "/zfsrpc.ZFS/ListDatasets", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *zFSClient) GetDataset(ctx context.Context, in *GetDatasetRequest, opts ...grpc.CallOption) (*Dataset, error) {
+	out := new(Dataset)
+	err := c.cc.Invoke(ctx, "/zfsrpc.ZFS/GetDataset", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *zFSClient) GetProperty(ctx context.Context, in *GetPropertyRequest, opts ...grpc.CallOption) (*GetPropertyResponse, error) {
+	out := new(GetPropertyResponse)
+	err := c.cc.Invoke(ctx, "/zfsrpc.ZFS/GetProperty", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *zFSClient) SetProperty(ctx context.Context, in *SetPropertyRequest, opts ...grpc.CallOption) (*SetPropertyResponse, error) {
+	out := new(SetPropertyResponse)
+	err := c.cc.Invoke(ctx, "/zfsrpc.ZFS/SetProperty", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *zFSClient) Snapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*Dataset, error) {
+	out := new(Dataset)
+	err := c.cc.Invoke(ctx, "/zfsrpc.ZFS/Snapshot", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *zFSClient) Destroy(ctx context.Context, in *DestroyRequest, opts ...grpc.CallOption) (*DestroyResponse, error) {
+	out := new(DestroyResponse)
+	err := c.cc.Invoke(ctx, "/zfsrpc.ZFS/Destroy", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *zFSClient) Send(ctx context.Context, in *SendRequest, opts ...grpc.CallOption) (ZFS_SendClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ZFS_ServiceDesc.Streams[0], "/zfsrpc.ZFS/Send", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &zFSSendClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ZFS_SendClient interface {
+	Recv() (*Chunk, error)
+	grpc.ClientStream
+}
+
+type zFSSendClient struct {
+	grpc.ClientStream
+}
+
+func (x *zFSSendClient) Recv() (*Chunk, error) {
+	m := new(Chunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *zFSClient) Receive(ctx context.Context, opts ...grpc.CallOption) (ZFS_ReceiveClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ZFS_ServiceDesc.Streams[1], "/zfsrpc.ZFS/Receive", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &zFSReceiveClient{stream}
+	return x, nil
+}
+
+type ZFS_ReceiveClient interface {
+	Send(*ReceiveRequest) error
+	CloseAndRecv() (*Dataset, error)
+	grpc.ClientStream
+}
+
+type zFSReceiveClient struct {
+	grpc.ClientStream
+}
+
+func (x *zFSReceiveClient) Send(m *ReceiveRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *zFSReceiveClient) CloseAndRecv() (*Dataset, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Dataset)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *zFSClient) ListZpools(ctx context.Context, in *ListZpoolsRequest, opts ...grpc.CallOption) (*ListZpoolsResponse, error) {
+	out := new(ListZpoolsResponse)
+	err := c.cc.Invoke(ctx, "/zfsrpc.ZFS/ListZpools", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *zFSClient) GetZpool(ctx context.Context, in *GetZpoolRequest, opts ...grpc.CallOption) (*Zpool, error) {
+	out := new(Zpool)
+	err := c.cc.Invoke(ctx, "/zfsrpc.ZFS/GetZpool", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ZFSServer is the server API for ZFS service.
+// All implementations must embed UnimplementedZFSServer
+// for forward compatibility
+type ZFSServer interface {
+	// ListDatasets recursively lists the datasets below a filter.
+	ListDatasets(context.Context, *ListDatasetsRequest) (*ListDatasetsResponse, error)
+	// GetDataset returns a single dataset.
+	GetDataset(context.Context, *GetDatasetRequest) (*Dataset, error)
+	// GetProperty returns the value of a single property of a dataset.
+	GetProperty(context.Context, *GetPropertyRequest) (*GetPropertyResponse, error)
+	// SetProperty sets a property of a dataset.
+	SetProperty(context.Context, *SetPropertyRequest) (*SetPropertyResponse, error)
+	// Snapshot takes a snapshot of a dataset and returns it.
+	Snapshot(context.Context, *SnapshotRequest) (*Dataset, error)
+	// Destroy destroys a dataset or snapshot.
+	Destroy(context.Context, *DestroyRequest) (*DestroyResponse, error)
+	// Send streams a snapshot as a sequence of chunks.
+	Send(*SendRequest, ZFS_SendServer) error
+	// Receive receives a stream whose first message is the header, followed by the stream's chunks.
+	Receive(ZFS_ReceiveServer) error
+	// ListZpools lists all pools.
+	ListZpools(context.Context, *ListZpoolsRequest) (*ListZpoolsResponse, error)
+	// GetZpool returns a single pool.
+	GetZpool(context.Context, *GetZpoolRequest) (*Zpool, error)
+	mustEmbedUnimplementedZFSServer()
+}
+
+// UnimplementedZFSServer must be embedded to have forward compatible implementations.
+type UnimplementedZFSServer struct {
+}
+
+func (UnimplementedZFSServer) ListDatasets(context.Context, *ListDatasetsRequest) (*ListDatasetsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDatasets not implemented")
+}
+func (UnimplementedZFSServer) GetDataset(context.Context, *GetDatasetRequest) (*Dataset, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDataset not implemented")
+}
+func (UnimplementedZFSServer) GetProperty(context.Context, *GetPropertyRequest) (*GetPropertyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetProperty not implemented")
+}
+func (UnimplementedZFSServer) SetProperty(context.Context, *SetPropertyRequest) (*SetPropertyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetProperty not implemented")
+}
+func (UnimplementedZFSServer) Snapshot(context.Context, *SnapshotRequest) (*Dataset, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Snapshot not implemented")
+}
+func (UnimplementedZFSServer) Destroy(context.Context, *DestroyRequest) (*DestroyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Destroy not implemented")
+}
+func (UnimplementedZFSServer) Send(*SendRequest, ZFS_SendServer) error {
+	return status.Errorf(codes.Unimplemented, "method Send not implemented")
+}
+func (UnimplementedZFSServer) Receive(ZFS_ReceiveServer) error {
+	return status.Errorf(codes.Unimplemented, "method Receive not implemented")
+}
+func (UnimplementedZFSServer) ListZpools(context.Context, *ListZpoolsRequest) (*ListZpoolsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListZpools not implemented")
+}
+func (UnimplementedZFSServer) GetZpool(context.Context, *GetZpoolRequest) (*Zpool, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetZpool not implemented")
+}
+func (UnimplementedZFSServer) mustEmbedUnimplementedZFSServer() {}
+
+// UnsafeZFSServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ZFSServer will
+// result in compilation errors.
+type UnsafeZFSServer interface {
+	mustEmbedUnimplementedZFSServer()
+}
+
+func RegisterZFSServer(s grpc.ServiceRegistrar, srv ZFSServer) {
+	s.RegisterService(&ZFS_ServiceDesc, srv)
+}
+
+func _ZFS_ListDatasets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDatasetsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ZFSServer).ListDatasets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zfsrpc.ZFS/ListDatasets",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ZFSServer).ListDatasets(ctx, req.(*ListDatasetsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ZFS_GetDataset_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDatasetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ZFSServer).GetDataset(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zfsrpc.ZFS/GetDataset",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ZFSServer).GetDataset(ctx, req.(*GetDatasetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ZFS_GetProperty_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPropertyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ZFSServer).GetProperty(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zfsrpc.ZFS/GetProperty",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ZFSServer).GetProperty(ctx, req.(*GetPropertyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ZFS_SetProperty_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetPropertyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ZFSServer).SetProperty(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zfsrpc.ZFS/SetProperty",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ZFSServer).SetProperty(ctx, req.(*SetPropertyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ZFS_Snapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ZFSServer).Snapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zfsrpc.ZFS/Snapshot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ZFSServer).Snapshot(ctx, req.(*SnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ZFS_Destroy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DestroyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ZFSServer).Destroy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zfsrpc.ZFS/Destroy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ZFSServer).Destroy(ctx, req.(*DestroyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ZFS_Send_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SendRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ZFSServer).Send(m, &zFSSendServer{stream})
+}
+
+type ZFS_SendServer interface {
+	Send(*Chunk) error
+	grpc.ServerStream
+}
+
+type zFSSendServer struct {
+	grpc.ServerStream
+}
+
+func (x *zFSSendServer) Send(m *Chunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _ZFS_Receive_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ZFSServer).Receive(&zFSReceiveServer{stream})
+}
+
+type ZFS_ReceiveServer interface {
+	SendAndClose(*Dataset) error
+	Recv() (*ReceiveRequest, error)
+	grpc.ServerStream
+}
+
+type zFSReceiveServer struct {
+	grpc.ServerStream
+}
+
+func (x *zFSReceiveServer) SendAndClose(m *Dataset) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *zFSReceiveServer) Recv() (*ReceiveRequest, error) {
+	m := new(ReceiveRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _ZFS_ListZpools_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListZpoolsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ZFSServer).ListZpools(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zfsrpc.ZFS/ListZpools",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ZFSServer).ListZpools(ctx, req.(*ListZpoolsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ZFS_GetZpool_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetZpoolRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ZFSServer).GetZpool(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zfsrpc.ZFS/GetZpool",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ZFSServer).GetZpool(ctx, req.(*GetZpoolRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ZFS_ServiceDesc is the grpc.ServiceDesc for ZFS service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ZFS_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "zfsrpc.ZFS",
+	HandlerType: (*ZFSServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListDatasets",
+			Handler:    _ZFS_ListDatasets_Handler,
+		},
+		{
+			MethodName: "GetDataset",
+			Handler:    _ZFS_GetDataset_Handler,
+		},
+		{
+			MethodName: "GetProperty",
+			Handler:    _ZFS_GetProperty_Handler,
+		},
+		{
+			MethodName: "SetProperty",
+			Handler:    _ZFS_SetProperty_Handler,
+		},
+		{
+			MethodName: "Snapshot",
+			Handler:    _ZFS_Snapshot_Handler,
+		},
+		{
+			MethodName: "Destroy",
+			Handler:    _ZFS_Destroy_Handler,
+		},
+		{
+			MethodName: "ListZpools",
+			Handler:    _ZFS_ListZpools_Handler,
+		},
+		{
+			MethodName: "GetZpool",
+			Handler:    _ZFS_GetZpool_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Send",
+			Handler:       _ZFS_Send_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Receive",
+			Handler:       _ZFS_Receive_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "zfsrpc.proto",
+}
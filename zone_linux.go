@@ -0,0 +1,42 @@
+//go:build linux
+// +build linux
+
+package zfs
+
+import "strconv"
+
+// UserNamespacePath returns the path of the user namespace file of the process with the given PID,
+// e.g. the init process of a container, for use with Zone and Unzone.
+func UserNamespacePath(pid int) string {
+	return "/proc/" + strconv.Itoa(pid) + "/ns/user"
+}
+
+// Zone delegates the receiving file system to the user namespace referred to by nsfile, so it can be managed from within it.
+// The zoned property of the file system must be set (see SetZoned) first.
+func (d *Dataset) Zone(nsfile string) error {
+	return zfs("zone", nsfile, d.Name)
+}
+
+// Unzone revokes the delegation of the receiving file system to the user namespace referred to by nsfile.
+func (d *Dataset) Unzone(nsfile string) error {
+	return zfs("unzone", nsfile, d.Name)
+}
+
+// Zoned reports whether the zoned property of the receiving dataset is set.
+func (d *Dataset) Zoned() (bool, error) {
+	val, err := d.GetProperty("zoned")
+	if err != nil {
+		return false, err
+	}
+	return val == "on", nil
+}
+
+// SetZoned sets or clears the zoned property of the receiving dataset.
+// A zoned file system is not mounted on the host and may be delegated to a user namespace.
+func (d *Dataset) SetZoned(zoned bool) error {
+	val := "off"
+	if zoned {
+		val = "on"
+	}
+	return d.SetProperty("zoned", val)
+}
@@ -0,0 +1,24 @@
+//go:build linux
+// +build linux
+
+package zfs_test
+
+import (
+	"testing"
+
+	zfs "github.com/mistifyio/go-zfs/v3"
+)
+
+func TestZoned(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/zone-test", nil)
+	ok(t, err)
+
+	ok(t, f.SetZoned(true))
+	zoned, err := f.Zoned()
+	ok(t, err)
+	assert(t, zoned, "file system is not zoned")
+
+	ok(t, f.Destroy(zfs.DestroyDefault))
+}
@@ -1,9 +1,14 @@
 package zfs
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os/exec"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 // ZFS zpool states, which can indicate if a pool is online, offline, degraded, etc.
@@ -21,6 +26,7 @@ const (
 
 // Zpool is a ZFS zpool.
 // A pool is a top-level structure in ZFS, and can contain many descendent datasets.
+// In JSON, GUID is encoded as a string, as it may exceed the integers JavaScript represents exactly.
 type Zpool struct {
 	Name          string
 	Health        string
@@ -32,8 +38,28 @@ type Zpool struct {
 	Freeing       uint64
 	Leaked        uint64
 	DedupRatio    float64
+	// AltRoot is the directory the pool's file systems are mounted relative to, if it was created or imported with one.
+	AltRoot string
+	GUID    uint64 `json:",string"`
+	// ExpandSize is the unused space of the pool's devices, which the pool can be expanded into, e.g. after a LUN was grown.
+	ExpandSize uint64
+	AutoExpand bool
+	// BCloneUsed, BCloneSaved and BCloneRatio account for blocks shared by block cloning, e.g. through
+	// cp --reflink or copy_file_range(2). They are zero on releases without block cloning, before OpenZFS 2.2.
+	BCloneUsed  uint64
+	BCloneSaved uint64
+	BCloneRatio float64
+	// DedupTableSize is the on-disk size of the deduplication table, and DedupCached the part of it held in the ARC.
+	// DedupTableQuota bounds DedupTableSize, see SetDedupTableQuota. They are zero before OpenZFS 2.3.
+	DedupTableSize  uint64
+	DedupTableQuota uint64
+	DedupCached     uint64
 }
 
+// DedupTableQuotaAuto is the DedupTableQuota bounding the deduplication table by the size of the pool's
+// dedup and special vdevs, the default. A DedupTableQuota of 0 does not bound it at all.
+const DedupTableQuotaAuto = math.MaxUint64
+
 // zpool is a helper function to wrap typical calls to zpool and ignores stdout.
 func zpool(arg ...string) error {
 	_, err := zpoolOutput(arg...)
@@ -46,11 +72,21 @@ func zpoolOutput(arg ...string) ([][]string, error) {
 	return c.Run(arg...)
 }
 
+// zpoolJSON runs zpool and returns its unsplit output, e.g. of --json.
+func zpoolJSON(arg ...string) ([]byte, error) {
+	var stdout bytes.Buffer
+	c := command{Command: "zpool", Stdout: &stdout}
+	if _, err := c.Run(arg...); err != nil {
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
 // GetZpool retrieves a single ZFS zpool by name.
 func GetZpool(name string) (*Zpool, error) {
-	args := zpoolArgs
-	args = append(args, name)
-	out, err := zpoolOutput(args...)
+	out, _, err := zpoolWithOptional(func(props []string) []string {
+		return []string{"get", "-Hp", strings.Join(props, ","), name}
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -65,6 +101,56 @@ func GetZpool(name string) (*Zpool, error) {
 	return z, nil
 }
 
+// zpoolWithOptional runs zpool with the arguments args returns for zpoolPropList and as many groups of
+// zpoolOptionalPropList as the release knows, retrying with one group less as long as zpool rejects the list.
+// It returns the output and the properties it was retrieved for.
+func zpoolWithOptional(args func(props []string) []string) ([][]string, []string, error) {
+	for n := len(zpoolOptionalPropList); ; n-- {
+		props := zpoolProps(n)
+		out, err := zpoolOutput(args(props)...)
+		if n > 0 && isBadPropertyList(err) {
+			// the release does not know some of the optional properties
+			continue
+		}
+		return out, props, err
+	}
+}
+
+// zpoolProps returns zpoolPropList, followed by the first n groups of zpoolOptionalPropList.
+func zpoolProps(n int) []string {
+	props := append([]string{}, zpoolPropList...)
+	for _, group := range zpoolOptionalPropList[:n] {
+		props = append(props, group...)
+	}
+	return props
+}
+
+// isBadPropertyList returns whether err is zfs or zpool rejecting a property it does not know.
+func isBadPropertyList(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && strings.Contains(e.Stderr, "bad property list")
+}
+
+// BlockCloneSavings returns the share of the pool's data, in percent, whose space is saved by block cloning,
+// i.e. BCloneSaved relative to the space the data would take up without sharing any cloned blocks.
+func (z *Zpool) BlockCloneSavings() float64 {
+	if z.BCloneSaved == 0 {
+		return 0
+	}
+	return float64(z.BCloneSaved) * 100 / float64(z.Allocated+z.BCloneSaved)
+}
+
+// Refresh re-reads the properties of the receiving pool in place,
+// so that long-lived references to it stay current.
+func (z *Zpool) Refresh() error {
+	p, err := GetZpool(z.Name)
+	if err != nil {
+		return err
+	}
+	*z = *p
+	return nil
+}
+
 // Datasets returns a slice of all ZFS datasets in a zpool.
 func (z *Zpool) Datasets() ([]*Dataset, error) {
 	return Datasets(z.Name)
@@ -81,18 +167,363 @@ func (z *Zpool) Snapshots() ([]*Dataset, error) {
 // https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html.
 // https://openzfs.github.io/openzfs-docs/man/8/zpool-create.8.html
 func CreateZpool(name string, properties map[string]string, args ...string) (*Zpool, error) {
+	return CreateZpoolWithOptions(name, CreateZpoolOptions{Properties: properties}, args...)
+}
+
+// CreateZpoolOptions controls how CreateZpoolWithOptions creates a pool.
+type CreateZpoolOptions struct {
+	// Properties are the pool properties to set (-o).
+	Properties map[string]string
+	// StableDevicePaths translates the devices of the vdev specification to stable device paths (see StableVdevArgs).
+	StableDevicePaths bool
+	// CheckDevices refuses to create the pool with a *DevicesInUseError if any device contains existing data (see CheckDevices).
+	CheckDevices bool
+	// Force creates the pool even if zpool itself finds the devices in use (-f).
+	Force bool
+	// AltRoot mounts the pool's file systems relative to this directory instead of / (-R), e.g. while installing a system
+	// onto the pool. It implies the cachefile=none property, so the pool is not imported automatically on boot.
+	AltRoot string
+	// TempName is the name the pool goes by until it is exported (-t), e.g. to avoid a clash with the pool of
+	// the running system. The pool keeps the name it was created with on disk, which it is imported by later.
+	TempName string
+}
+
+// CreateZpoolWithOptions creates a new ZFS zpool with the specified name from the vdev specification in args.
+func CreateZpoolWithOptions(name string, opts CreateZpoolOptions, args ...string) (*Zpool, error) {
+	cli, err := opts.args(name, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := zpool(cli...); err != nil {
+		return nil, err
+	}
+
+	if opts.TempName != "" {
+		name = opts.TempName
+	}
+	return &Zpool{Name: name, AltRoot: opts.AltRoot}, nil
+}
+
+// CreateZpoolDryRun validates the vdev specification in args as CreateZpoolWithOptions would use it,
+// without creating the pool or writing to the devices (zpool create -n), and returns the layout the pool would have,
+// e.g. to show a plan before touching any disks.
+// Only the name and the vdev trees of the returned status are set, with the vdevs named as zpool would name them;
+// TopLevelVdevs and ClassRedundancy can be used to check the topology.
+func CreateZpoolDryRun(name string, opts CreateZpoolOptions, args ...string) (*ZpoolStatus, error) {
+	cli, err := opts.args(name, args)
+	if err != nil {
+		return nil, err
+	}
+	cli = append([]string{cli[0], "-n"}, cli[1:]...)
+	out, err := zpoolOutput(cli...)
+	if err != nil {
+		return nil, err
+	}
+	return parseCreateLayout(out)
+}
+
+func (o CreateZpoolOptions) args(name string, args []string) ([]string, error) {
 	cli := make([]string, 1, 4)
 	cli[0] = "create"
-	if properties != nil {
-		cli = append(cli, propsSlice(properties)...)
+	if o.Force {
+		cli = append(cli, "-f")
+	}
+	if o.Properties != nil {
+		cli = append(cli, propsSlice(o.Properties)...)
+	}
+	if o.AltRoot != "" {
+		cli = append(cli, "-R", o.AltRoot)
+	}
+	if o.TempName != "" {
+		cli = append(cli, "-t", o.TempName)
+	}
+	if o.StableDevicePaths {
+		var err error
+		if args, err = StableVdevArgs(args...); err != nil {
+			return nil, err
+		}
+	}
+	if o.CheckDevices {
+		if err := checkVdevArgs(args); err != nil {
+			return nil, err
+		}
 	}
 	cli = append(cli, name)
-	cli = append(cli, args...)
-	if err := zpool(cli...); err != nil {
+	return append(cli, args...), nil
+}
+
+// ImportZpoolOptions controls how ImportZpool imports a pool.
+type ImportZpoolOptions struct {
+	// Properties are the pool properties to set on import (-o), e.g. readonly=on.
+	Properties map[string]string
+	// AltRoot mounts the pool's file systems relative to this directory instead of / (-R), e.g. to repair
+	// the pool of another system. It implies the cachefile=none property.
+	AltRoot string
+	// TempName is the name the pool goes by until it is exported (-t), e.g. to import the pool of another system
+	// alongside a pool of the same name. The name stored on disk is not changed.
+	TempName string
+	// Dirs are the directories to search for devices or files of the pool (-d), /dev by default.
+	Dirs []string
+	// NoMount imports the pool without mounting its file systems (-N).
+	NoMount bool
+	// Force imports the pool even if it appears to be in use by another system (-f).
+	Force bool
+}
+
+func (o ImportZpoolOptions) args(name string) []string {
+	args := []string{"import"}
+	if o.Properties != nil {
+		args = append(args, propsSlice(o.Properties)...)
+	}
+	if o.AltRoot != "" {
+		args = append(args, "-R", o.AltRoot)
+	}
+	for _, dir := range o.Dirs {
+		args = append(args, "-d", dir)
+	}
+	if o.NoMount {
+		args = append(args, "-N")
+	}
+	if o.Force {
+		args = append(args, "-f")
+	}
+	if o.TempName != "" {
+		return append(args, "-t", name, o.TempName)
+	}
+	return append(args, name)
+}
+
+// ImportZpool imports the named pool, and returns it by the name it goes by,
+// which is opts.TempName if set.
+//
+// More information regarding zpool import can be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/8/zpool-import.8.html
+func ImportZpool(name string, opts ImportZpoolOptions) (*Zpool, error) {
+	if err := zpool(opts.args(name)...); err != nil {
 		return nil, err
 	}
+	if opts.TempName != "" {
+		name = opts.TempName
+	}
+	return GetZpool(name)
+}
+
+// Export exports the receiving pool, unmounting its file systems, so that it can be imported elsewhere.
+// Force unmounts file systems that are in use (-f).
+func (z *Zpool) Export(force bool) error {
+	args := []string{"export"}
+	if force {
+		args = append(args, "-f")
+	}
+	return zpool(append(args, z.Name)...)
+}
+
+// layoutClasses are the classes of the headings in the output of `zpool create -n`.
+var layoutClasses = map[string]VdevClass{
+	"dedup":   VdevClassDedup,
+	"special": VdevClassSpecial,
+	"logs":    VdevClassLog,
+	"cache":   VdevClassCache,
+	"spares":  VdevClassSpare,
+}
+
+// parseCreateLayout parses the layout printed by `zpool create -n`, which indents each vdev by two spaces per level:
+//
+//	would create 'tank' with the following layout:
+//
+//		tank
+//		  mirror
+//		    sda
+//		    sdb
+//		logs
+//		  sdc
+func parseCreateLayout(lines [][]string) (*ZpoolStatus, error) {
+	s := &ZpoolStatus{order: map[VdevClass][]string{}}
+	var (
+		class VdevClass
+		stack []*ZpoolVdev
+	)
+	for _, line := range lines {
+		text := strings.TrimPrefix(strings.Join(line, "\t"), "\t")
+		name := strings.TrimLeft(text, " ")
+		if name == "" || strings.HasPrefix(name, "would create ") {
+			continue
+		}
+		depth := (len(text) - len(name)) / 2
+
+		if depth == 0 {
+			v := &ZpoolVdev{Name: name, VdevType: "root", Class: VdevClassData, Vdevs: map[string]*ZpoolVdev{}}
+			switch c, ok := layoutClasses[name]; {
+			case s.Name == "":
+				s.Name = name
+				s.Vdevs = map[string]*ZpoolVdev{name: v}
+				s.order[VdevClassData] = []string{name}
+				class = VdevClassData
+			case ok:
+				class = c
+				v = nil
+			default:
+				return nil, fmt.Errorf("unexpected line in zpool create output: %q", text)
+			}
+			stack = []*ZpoolVdev{v}
+			continue
+		}
+		if depth > len(stack) {
+			return nil, fmt.Errorf("unexpected indentation in zpool create output: %q", text)
+		}
+
+		v := &ZpoolVdev{Name: name, Class: class}
+		stack = stack[:depth]
+		if parent := stack[depth-1]; parent != nil {
+			if parent.Vdevs == nil {
+				parent.Vdevs = map[string]*ZpoolVdev{}
+			}
+			parent.Vdevs[name] = v
+			parent.order = append(parent.order, name)
+		} else {
+			// the top-level vdevs of the other classes are kept apart
+			m := map[VdevClass]*map[string]*ZpoolVdev{
+				VdevClassDedup:   &s.Dedup,
+				VdevClassSpecial: &s.Special,
+				VdevClassLog:     &s.Logs,
+				VdevClassCache:   &s.L2Cache,
+				VdevClassSpare:   &s.Spares,
+			}[class]
+			if *m == nil {
+				*m = map[string]*ZpoolVdev{}
+			}
+			(*m)[name] = v
+			s.order[class] = append(s.order[class], name)
+		}
+		stack = append(stack, v)
+	}
+	if s.Name == "" {
+		return nil, errors.New("output does not match what is expected on this platform")
+	}
+	_ = s.WalkVdevs(func(v, _ *ZpoolVdev) error {
+		setLayoutVdevType(v)
+		return nil
+	})
+	return s, nil
+}
+
+// setLayoutVdevType sets the type and path of a vdev parsed from the output of `zpool create -n`.
+// Interior vdevs are named after their type, e.g. "mirror" or "raidz2", and leaves are disks or files.
+func setLayoutVdevType(v *ZpoolVdev) {
+	switch {
+	case v.VdevType != "":
+	case len(v.Vdevs) > 0:
+		v.VdevType = strings.TrimRight(strings.SplitN(strings.SplitN(v.Name, "-", 2)[0], ":", 2)[0], "0123456789")
+	case strings.HasPrefix(v.Name, "/dev/"):
+		v.VdevType, v.Path = "disk", v.Name
+	case strings.HasPrefix(v.Name, "/"):
+		v.VdevType, v.Path = "file", v.Name
+	default:
+		v.VdevType = "disk"
+	}
+}
+
+// AddVdevOptions controls how Zpool.Add adds vdevs to a pool.
+type AddVdevOptions struct {
+	// StableDevicePaths translates the devices of the vdev specification to stable device paths (see StableVdevArgs).
+	StableDevicePaths bool
+	// CheckDevices refuses to add the vdevs with a *DevicesInUseError if any device contains existing data (see CheckDevices).
+	CheckDevices bool
+	// Force adds the vdevs even if zpool itself finds the devices in use or their replication level mismatched (-f).
+	Force bool
+}
+
+// Add adds the vdevs of the vdev specification in args to the receiving pool.
+//
+// More information regarding adding vdevs can be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/8/zpool-add.8.html
+func (z *Zpool) Add(opts AddVdevOptions, args ...string) error {
+	if opts.StableDevicePaths {
+		var err error
+		if args, err = StableVdevArgs(args...); err != nil {
+			return err
+		}
+	}
+	if opts.CheckDevices {
+		if err := checkVdevArgs(args); err != nil {
+			return err
+		}
+	}
+	cli := []string{"add"}
+	if opts.Force {
+		cli = append(cli, "-f")
+	}
+	cli = append(cli, z.Name)
+	return zpool(append(cli, args...)...)
+}
+
+// Attach attaches newDevice to device, which is part of the receiving pool, turning it into a mirror
+// or adding another side to an existing mirror.
+// Optionally, newDevice is translated to a stable device path first (see StableDevicePath).
+func (z *Zpool) Attach(device, newDevice string, stablePath bool) error {
+	if stablePath {
+		args, err := StableVdevArgs(newDevice)
+		if err != nil {
+			return err
+		}
+		newDevice = args[0]
+	}
+	return zpool("attach", z.Name, device, newDevice)
+}
+
+// ExpandDevice expands the given device of the receiving pool to use all of its space (zpool online -e),
+// e.g. after the LUN or partition beneath it was grown. See Zpool.ExpandSize for the space to be gained,
+// and SetAutoExpand to expand devices automatically.
+func (z *Zpool) ExpandDevice(device string) error {
+	return zpool("online", "-e", z.Name, device)
+}
+
+// SetAutoExpand sets the autoexpand property of the receiving pool, which expands devices automatically
+// once they were grown, as ExpandDevice does.
+func (z *Zpool) SetAutoExpand(on bool) error {
+	val := "off"
+	if on {
+		val = "on"
+	}
+	if err := zpool("set", "autoexpand="+val, z.Name); err != nil {
+		return err
+	}
+	z.AutoExpand = on
+	return nil
+}
+
+// SetDedupTableQuota sets the dedup_table_quota property of the receiving pool, which bounds the on-disk size
+// of its deduplication table: once reached, new blocks are no longer deduplicated.
+// quota is a size in bytes, DedupTableQuotaAuto or 0 for no bound. This requires OpenZFS 2.3.
+func (z *Zpool) SetDedupTableQuota(quota uint64) error {
+	if err := zpool("set", "dedup_table_quota="+formatDedupTableQuota(quota), z.Name); err != nil {
+		return err
+	}
+	z.DedupTableQuota = quota
+	return nil
+}
+
+func formatDedupTableQuota(quota uint64) string {
+	switch quota {
+	case 0:
+		return "none"
+	case DedupTableQuotaAuto:
+		return "auto"
+	}
+	return strconv.FormatUint(quota, 10)
+}
 
-	return &Zpool{Name: name}, nil
+// FastDedup reports whether the fast_dedup feature of the receiving pool is enabled, with which
+// deduplication tables created from then on use the log-based format of OpenZFS 2.3.
+func (z *Zpool) FastDedup() (bool, error) {
+	out, err := zpoolOutput("get", "-H", "-o", "value", "feature@fast_dedup", z.Name)
+	if err != nil {
+		return false, err
+	}
+	if len(out) == 0 || len(out[0]) == 0 {
+		return false, errors.New("output does not match what is expected on this platform")
+	}
+	return out[0][0] == "enabled" || out[0][0] == "active", nil
 }
 
 // Destroy destroys a ZFS zpool by name.
@@ -101,38 +532,312 @@ func (z *Zpool) Destroy() error {
 	return err
 }
 
-// ZpoolVdev represents a vdev (virtual device) in a ZFS pool
+// Sync forces all in-flight writes of the receiving pool to be committed in a transaction group,
+// e.g. before snapshotting the block devices beneath the pool for a crash-consistent copy.
+//
+// More information regarding zpool sync can be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/8/zpool-sync.8.html
+func (z *Zpool) Sync() error {
+	return zpool("sync", z.Name)
+}
+
+// SyncAll forces all in-flight writes of all imported pools to be committed, see Zpool.Sync.
+func SyncAll() error {
+	return zpool("sync")
+}
+
+// VdevClass is the allocation class of a vdev, which determines what it stores.
+type VdevClass string
+
+// Vdev classes, as reported in the class field of `zpool status --json`.
+const (
+	// VdevClassData vdevs store the pool's data, and any metadata not stored on special or dedup vdevs.
+	VdevClassData VdevClass = "normal"
+	// VdevClassLog vdevs hold the ZFS intent log (SLOG).
+	VdevClassLog VdevClass = "log"
+	// VdevClassCache vdevs hold the level 2 ARC. Their data is redundant, so losing them loses no data.
+	VdevClassCache VdevClass = "l2cache"
+	// VdevClassSpare vdevs are hot spares, which store nothing until they replace a failed disk.
+	VdevClassSpare VdevClass = "spare"
+	// VdevClassSpecial vdevs store metadata and, optionally, small blocks.
+	VdevClassSpecial VdevClass = "special"
+	// VdevClassDedup vdevs store the deduplication table.
+	VdevClassDedup VdevClass = "dedup"
+)
+
+// ZpoolVdev represents a vdev (virtual device) in a ZFS pool.
+// Numeric fields are zero if zpool reported them in a form that is not an integer,
+// e.g. human-readable sizes without the -p flag; the values as reported are kept in Raw.
 type ZpoolVdev struct {
 	Name           string                `json:"name"`
 	VdevType       string                `json:"vdev_type"`
 	GUID           string                `json:"guid"`
-	Class          string                `json:"class"`
+	Class          VdevClass             `json:"class"`
 	State          string                `json:"state"`
 	Path           string                `json:"path,omitempty"`
 	PhysPath       string                `json:"phys_path,omitempty"`
 	DevID          string                `json:"devid,omitempty"`
-	AllocSpace     string                `json:"alloc_space,omitempty"`
-	TotalSpace     string                `json:"total_space,omitempty"`
-	DefSpace       string                `json:"def_space,omitempty"`
-	RepDevSize     string                `json:"rep_dev_size,omitempty"`
-	PhysSpace      string                `json:"phys_space,omitempty"`
-	ReadErrors     string                `json:"read_errors"`
-	WriteErrors    string                `json:"write_errors"`
-	ChecksumErrors string                `json:"checksum_errors"`
-	SlowIOs        string                `json:"slow_ios,omitempty"`
+	AllocSpace     uint64                `json:"alloc_space,omitempty"`
+	TotalSpace     uint64                `json:"total_space,omitempty"`
+	DefSpace       uint64                `json:"def_space,omitempty"`
+	RepDevSize     uint64                `json:"rep_dev_size,omitempty"`
+	PhysSpace      uint64                `json:"phys_space,omitempty"`
+	ReadErrors     uint64                `json:"read_errors"`
+	WriteErrors    uint64                `json:"write_errors"`
+	ChecksumErrors uint64                `json:"checksum_errors"`
+	SlowIOs        uint64                `json:"slow_ios,omitempty"`
 	Vdevs          map[string]*ZpoolVdev `json:"vdevs,omitempty"`
+	// Raw holds the numeric fields as reported by zpool, keyed by their JSON names.
+	Raw map[string]string `json:"-"`
+	// Extra holds the fields reported by zpool that have no field of their own, keyed by their JSON names,
+	// e.g. the output of the scripts selected by ZpoolStatusOptions.Scripts.
+	Extra map[string]string `json:"-"`
+
+	// order holds the names of Vdevs in the order zpool reported them.
+	order []string
+}
+
+// UnmarshalJSON decodes a vdev from `zpool status --json` output, where numbers are strings unless --json-int is given.
+func (v *ZpoolVdev) UnmarshalJSON(b []byte) error {
+	type vdev ZpoolVdev
+	aux := struct {
+		*vdev
+		AllocSpace     statusNumber `json:"alloc_space"`
+		TotalSpace     statusNumber `json:"total_space"`
+		DefSpace       statusNumber `json:"def_space"`
+		RepDevSize     statusNumber `json:"rep_dev_size"`
+		PhysSpace      statusNumber `json:"phys_space"`
+		ReadErrors     statusNumber `json:"read_errors"`
+		WriteErrors    statusNumber `json:"write_errors"`
+		ChecksumErrors statusNumber `json:"checksum_errors"`
+		SlowIOs        statusNumber `json:"slow_ios"`
+	}{vdev: (*vdev)(v)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	v.Raw = map[string]string{}
+	aux.AllocSpace.set(&v.AllocSpace, "alloc_space", v.Raw)
+	aux.TotalSpace.set(&v.TotalSpace, "total_space", v.Raw)
+	aux.DefSpace.set(&v.DefSpace, "def_space", v.Raw)
+	aux.RepDevSize.set(&v.RepDevSize, "rep_dev_size", v.Raw)
+	aux.PhysSpace.set(&v.PhysSpace, "phys_space", v.Raw)
+	aux.ReadErrors.set(&v.ReadErrors, "read_errors", v.Raw)
+	aux.WriteErrors.set(&v.WriteErrors, "write_errors", v.Raw)
+	aux.ChecksumErrors.set(&v.ChecksumErrors, "checksum_errors", v.Raw)
+	aux.SlowIOs.set(&v.SlowIOs, "slow_ios", v.Raw)
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	v.Extra = map[string]string{}
+	for key, value := range fields {
+		if !vdevFields[key] {
+			setExtra(v.Extra, key, value)
+		}
+	}
+	order, err := jsonObjectKeys(fields["vdevs"])
+	v.order = order
+	return err
+}
+
+// vdevFields are the JSON names of the fields of ZpoolVdev.
+var vdevFields = map[string]bool{
+	"name": true, "vdev_type": true, "guid": true, "class": true, "state": true,
+	"path": true, "phys_path": true, "devid": true,
+	"alloc_space": true, "total_space": true, "def_space": true, "rep_dev_size": true, "phys_space": true,
+	"read_errors": true, "write_errors": true, "checksum_errors": true, "slow_ios": true, "vdevs": true,
+}
+
+// setExtra stores value in extra if it is a string, number or boolean. Objects, arrays and nulls are skipped.
+func setExtra(extra map[string]string, key string, value json.RawMessage) {
+	if len(value) == 0 {
+		return
+	}
+	switch value[0] {
+	case '"':
+		var s string
+		if json.Unmarshal(value, &s) == nil {
+			extra[key] = s
+		}
+	case '{', '[', 'n':
+	default:
+		extra[key] = string(value)
+	}
 }
 
-// ZpoolStatus represents the status information of a ZFS pool
+// PowerOnHours returns the power-on hours of the receiving disk, as reported by the "hours" zpool.d script,
+// and false if it was not run or the disk does not report them.
+func (v *ZpoolVdev) PowerOnHours() (uint64, bool) {
+	hours, err := strconv.ParseUint(strings.TrimSpace(v.Extra["hours"]), 10, 64)
+	return hours, err == nil
+}
+
+// Children returns the vdevs below the receiving one in the order zpool reported them,
+// which is the order of `zpool status` output. Vdevs not decoded from zpool output are ordered by name.
+func (v *ZpoolVdev) Children() []*ZpoolVdev {
+	return orderedVdevs(v.Vdevs, v.order)
+}
+
+// ZpoolStatus represents the status information of a ZFS pool.
+// Numeric fields are parsed as for ZpoolVdev, with the values as reported kept in Raw.
+//
+// Vdevs holds the root vdev, with the data vdevs below it. Vdevs of the other classes are kept apart in
+// Logs, L2Cache, Spares, Special and Dedup, keyed by name; see TopLevelVdevs to list the vdevs of a class.
 type ZpoolStatus struct {
 	Name       string                `json:"name"`
 	State      string                `json:"state"`
 	PoolGUID   string                `json:"pool_guid"`
-	TXG        string                `json:"txg"`
-	SPAVersion string                `json:"spa_version"`
-	ZPLVersion string                `json:"zpl_version"`
+	TXG        uint64                `json:"txg"`
+	SPAVersion uint64                `json:"spa_version"`
+	ZPLVersion uint64                `json:"zpl_version"`
 	Vdevs      map[string]*ZpoolVdev `json:"vdevs"`
-	ErrorCount string                `json:"error_count"`
+	Logs       map[string]*ZpoolVdev `json:"logs,omitempty"`
+	L2Cache    map[string]*ZpoolVdev `json:"l2cache,omitempty"`
+	Spares     map[string]*ZpoolVdev `json:"spares,omitempty"`
+	Special    map[string]*ZpoolVdev `json:"special,omitempty"`
+	Dedup      map[string]*ZpoolVdev `json:"dedup,omitempty"`
+	ErrorCount uint64                `json:"error_count"`
+	ScanStats  *ZpoolScanStats       `json:"scan_stats,omitempty"`
+	// Status and Action describe a problem with the pool and how to resolve it, as the status: and action:
+	// lines of `zpool status` do. They are empty for healthy pools.
+	Status string `json:"status,omitempty"`
+	Action string `json:"action,omitempty"`
+	// MsgID identifies the problem, e.g. "ZFS-8000-9P", and See links to its documentation.
+	MsgID string `json:"msgid,omitempty"`
+	See   string `json:"moreinfo,omitempty"`
+	// Raw holds the numeric fields as reported by zpool, keyed by their JSON names.
+	Raw map[string]string `json:"-"`
+
+	// order holds the names of the vdevs of each class in the order zpool reported them.
+	order map[VdevClass][]string
+}
+
+// UnmarshalJSON decodes the status of a pool from `zpool status --json` output.
+func (s *ZpoolStatus) UnmarshalJSON(b []byte) error {
+	type status ZpoolStatus
+	aux := struct {
+		*status
+		TXG        statusNumber `json:"txg"`
+		SPAVersion statusNumber `json:"spa_version"`
+		ZPLVersion statusNumber `json:"zpl_version"`
+		ErrorCount statusNumber `json:"error_count"`
+	}{status: (*status)(s)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	s.Raw = map[string]string{}
+	aux.TXG.set(&s.TXG, "txg", s.Raw)
+	aux.SPAVersion.set(&s.SPAVersion, "spa_version", s.Raw)
+	aux.ZPLVersion.set(&s.ZPLVersion, "zpl_version", s.Raw)
+	aux.ErrorCount.set(&s.ErrorCount, "error_count", s.Raw)
+
+	var classes struct {
+		Vdevs   json.RawMessage `json:"vdevs"`
+		Logs    json.RawMessage `json:"logs"`
+		L2Cache json.RawMessage `json:"l2cache"`
+		Spares  json.RawMessage `json:"spares"`
+		Special json.RawMessage `json:"special"`
+		Dedup   json.RawMessage `json:"dedup"`
+	}
+	if err := json.Unmarshal(b, &classes); err != nil {
+		return err
+	}
+	s.order = map[VdevClass][]string{}
+	for class, raw := range map[VdevClass]json.RawMessage{
+		VdevClassData:    classes.Vdevs,
+		VdevClassLog:     classes.Logs,
+		VdevClassCache:   classes.L2Cache,
+		VdevClassSpare:   classes.Spares,
+		VdevClassSpecial: classes.Special,
+		VdevClassDedup:   classes.Dedup,
+	} {
+		order, err := jsonObjectKeys(raw)
+		if err != nil {
+			return err
+		}
+		s.order[class] = order
+	}
+	return nil
+}
+
+// jsonObjectKeys returns the keys of the JSON object b in order, or nil if b is empty or null.
+func jsonObjectKeys(b json.RawMessage) ([]string, error) {
+	if len(b) == 0 || string(b) == "null" {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	var keys []string
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key.(string))
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// orderedVdevs returns the vdevs of m, those named in order first and in that order, the others by name.
+func orderedVdevs(m map[string]*ZpoolVdev, order []string) []*ZpoolVdev {
+	vdevs := make([]*ZpoolVdev, 0, len(m))
+	seen := make(map[string]bool, len(order))
+	for _, name := range order {
+		if v, ok := m[name]; ok && !seen[name] {
+			vdevs = append(vdevs, v)
+			seen[name] = true
+		}
+	}
+	var rest []string
+	for name := range m {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	for _, name := range rest {
+		vdevs = append(vdevs, m[name])
+	}
+	return vdevs
+}
+
+// statusNumber is a number in `zpool status --json` output, which is either a JSON string or a JSON number.
+type statusNumber struct {
+	raw   string
+	valid bool
+}
+
+func (n *statusNumber) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	if len(b) > 0 && b[0] == '"' {
+		if err := json.Unmarshal(b, &n.raw); err != nil {
+			return err
+		}
+	} else {
+		n.raw = string(b)
+	}
+	n.valid = true
+	return nil
+}
+
+// set stores the number in field if it is an unsigned integer, and records it as reported in raw.
+func (n statusNumber) set(field *uint64, key string, raw map[string]string) {
+	if !n.valid {
+		return
+	}
+	raw[key] = n.raw
+	if v, err := strconv.ParseUint(n.raw, 10, 64); err == nil {
+		*field = v
+	}
 }
 
 // ZpoolStatusJSON represents the JSON output structure from 'zpool status --json'
@@ -149,19 +854,29 @@ type OutputVersion struct {
 }
 
 // ListZpools list all ZFS zpools accessible on the current system.
+// The properties of all pools are retrieved with a single zpool list.
 func ListZpools() ([]*Zpool, error) {
-	args := []string{"list", "-Ho", "name"}
-	out, err := zpoolOutput(args...)
+	out, props, err := zpoolWithOptional(func(props []string) []string {
+		return []string{"list", "-Hp", "-o", strings.Join(props, ",")}
+	})
 	if err != nil {
 		return nil, err
 	}
+	return parseZpoolList(out, props)
+}
 
-	var pools []*Zpool
-
-	for _, line := range out {
-		z, err := GetZpool(line[0])
-		if err != nil {
-			return nil, err
+// parseZpoolList parses the output of `zpool list -Hp -o props`.
+func parseZpoolList(lines [][]string, props []string) ([]*Zpool, error) {
+	pools := make([]*Zpool, 0, len(lines))
+	for _, line := range lines {
+		if len(line) != len(props) {
+			return nil, errors.New("output does not match what is expected on this platform")
+		}
+		z := &Zpool{}
+		for i, prop := range props {
+			if err := z.parseLine([]string{z.Name, prop, line[i]}); err != nil {
+				return nil, err
+			}
 		}
 		pools = append(pools, z)
 	}
@@ -169,22 +884,44 @@ func ListZpools() ([]*Zpool, error) {
 }
 
 // Status retrieves the status information of a ZFS pool using 'zpool status'
-// Uses default parsable=false to show exact bytes without unit conversion
+// Exact byte values are reported, without unit conversion
 func (z *Zpool) Status() (*ZpoolStatus, error) {
-	return GetZpoolStatus(z.Name, false)
+	return GetZpoolStatusWithOptions(z.Name, ZpoolStatusOptions{Parsable: true})
 }
 
 // GetZpoolStatus retrieves the status information of a ZFS pool by name using JSON format
-// parsable controls whether to show exact byte values (true) or human-readable units (false)
-// When parsable is false (default), uses -p flag to show exact bytes without unit conversion
+// Contrary to its name, parsable set to false reports exact byte values, using the -p flag,
+// and set to true reports human-readable units. GetZpoolStatusWithOptions avoids this confusion.
 func GetZpoolStatus(name string, parsable bool) (*ZpoolStatus, error) {
+	return GetZpoolStatusWithOptions(name, ZpoolStatusOptions{Parsable: !parsable})
+}
+
+// ZpoolStatusOptions controls which columns GetZpoolStatusWithOptions retrieves.
+type ZpoolStatusOptions struct {
+	// Parsable reports exact byte values without unit conversion (-p).
+	Parsable bool
+	// SlowIOs reports the number of slow I/Os of each vdev in ZpoolVdev.SlowIOs (-s).
+	SlowIOs bool
+	// Scripts are the zpool.d scripts to run for each leaf vdev (-c), e.g. "hours" or "temp",
+	// whose output ends up in ZpoolVdev.Extra. Scripts only run as root if ZPOOL_SCRIPTS_AS_ROOT is set.
+	Scripts []string
+}
+
+// GetZpoolStatusWithOptions retrieves the status information of a ZFS pool by name,
+// with the extended columns selected by opts.
+func GetZpoolStatusWithOptions(name string, opts ZpoolStatusOptions) (*ZpoolStatus, error) {
 	args := []string{"status", "--json"}
-	if !parsable {
+	if opts.Parsable {
 		args = append(args, "-p")
 	}
+	if opts.SlowIOs {
+		args = append(args, "-s")
+	}
+	if len(opts.Scripts) > 0 {
+		args = append(args, "-c", strings.Join(opts.Scripts, ","))
+	}
 	args = append(args, name)
-	cmd := exec.Command("zpool", args...)
-	output, err := cmd.Output()
+	output, err := zpoolJSON(args...)
 	if err != nil {
 		return nil, err
 	}
@@ -203,15 +940,14 @@ func GetZpoolStatus(name string, parsable bool) (*ZpoolStatus, error) {
 }
 
 // ListPoolStatus retrieves the status information for all ZFS pools using JSON format
-// parsable controls whether to show exact byte values (true) or human-readable units (false)
-// When parsable is false (default), uses -p flag to show exact bytes without unit conversion
+// As with GetZpoolStatus, parsable set to false reports exact byte values, using the -p flag,
+// and set to true reports human-readable units.
 func ListPoolStatus(parsable bool) ([]*ZpoolStatus, error) {
 	args := []string{"status", "--json"}
 	if !parsable {
 		args = append(args, "-p")
 	}
-	cmd := exec.Command("zpool", args...)
-	output, err := cmd.Output()
+	output, err := zpoolJSON(args...)
 	if err != nil {
 		return nil, err
 	}
@@ -229,3 +965,123 @@ func ListPoolStatus(parsable bool) ([]*ZpoolStatus, error) {
 	return pools, nil
 }
 
+// vdevClasses are the allocation classes, in the order `zpool status` lists them.
+var vdevClasses = []VdevClass{VdevClassData, VdevClassSpecial, VdevClassDedup, VdevClassLog, VdevClassCache, VdevClassSpare}
+
+// classRoots returns the vdevs of the status reported for the given class, in the order zpool reported them.
+func (s *ZpoolStatus) classRoots(class VdevClass) []*ZpoolVdev {
+	m := map[VdevClass]map[string]*ZpoolVdev{
+		VdevClassData:    s.Vdevs,
+		VdevClassLog:     s.Logs,
+		VdevClassCache:   s.L2Cache,
+		VdevClassSpare:   s.Spares,
+		VdevClassSpecial: s.Special,
+		VdevClassDedup:   s.Dedup,
+	}[class]
+	return orderedVdevs(m, s.order[class])
+}
+
+// TopLevelVdevs returns the top-level vdevs of the given class, e.g. the mirrors and raidz groups storing data,
+// or the log devices, in the order `zpool status` lists them.
+// Vdevs reported below the root vdev are sorted into their classes by their Class field, as older releases report them there.
+func (s *ZpoolStatus) TopLevelVdevs(class VdevClass) []*ZpoolVdev {
+	var vdevs []*ZpoolVdev
+	for _, c := range vdevClasses {
+		for _, v := range s.classRoots(c) {
+			if v.VdevType != "root" {
+				if c == class {
+					vdevs = append(vdevs, v)
+				}
+				continue
+			}
+			for _, child := range v.Children() {
+				childClass := child.Class
+				if childClass == "" {
+					childClass = VdevClassData
+				}
+				if childClass == class {
+					vdevs = append(vdevs, child)
+				}
+			}
+		}
+	}
+	return vdevs
+}
+
+// errStopWalk stops WalkVdevs without an error.
+var errStopWalk = errors.New("stop walking vdevs")
+
+// WalkVdevs calls fn for every vdev of the pool, the root and interior vdevs such as mirrors included,
+// along with its parent, which is nil at the top of each class. Vdevs are walked depth first,
+// in the order `zpool status` lists them. If fn returns an error, the walk stops and the error is returned.
+func (s *ZpoolStatus) WalkVdevs(fn func(v, parent *ZpoolVdev) error) error {
+	for _, class := range vdevClasses {
+		for _, v := range s.classRoots(class) {
+			if err := walkVdevs(v, nil, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func walkVdevs(v, parent *ZpoolVdev, fn func(v, parent *ZpoolVdev) error) error {
+	if err := fn(v, parent); err != nil {
+		return err
+	}
+	for _, child := range v.Children() {
+		if err := walkVdevs(child, v, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindVdev returns the vdev of the pool whose GUID, path or name is pathOrGUID, or nil if there is none.
+// Hot spares in use are listed both below the vdev they replace and among the spares, the former is returned.
+func (s *ZpoolStatus) FindVdev(pathOrGUID string) *ZpoolVdev {
+	var found *ZpoolVdev
+	_ = s.WalkVdevs(func(v, _ *ZpoolVdev) error {
+		if vdevMatches(v, pathOrGUID) {
+			found = v
+			return errStopWalk
+		}
+		return nil
+	})
+	return found
+}
+
+// vdevMatches returns whether the GUID, path or name of v is id.
+func vdevMatches(v *ZpoolVdev, id string) bool {
+	return id != "" && (v.GUID == id || v.Path == id || v.Name == id)
+}
+
+// Redundancy returns the number of failed disks the receiving top-level vdev survives,
+// e.g. 1 for a two-way mirror or raidz1, 2 for raidz2, and 0 for a single disk.
+func (v *ZpoolVdev) Redundancy() int {
+	switch v.VdevType {
+	case "mirror":
+		return len(v.Vdevs) - 1
+	case "raidz", "draid":
+		// the parity is part of the name, e.g. "raidz2-0" or "draid2:4d:8c:1s-0"
+		name := strings.TrimPrefix(strings.TrimPrefix(v.Name, "raidz"), "draid")
+		if n, err := strconv.Atoi(strings.SplitN(strings.SplitN(name, "-", 2)[0], ":", 2)[0]); err == nil {
+			return n
+		}
+		return 1
+	}
+	return 0
+}
+
+// ClassRedundancy returns the number of failed disks the vdevs of the given class survive in the worst case,
+// which is the lowest redundancy among them, or -1 if the pool has no vdevs of the class.
+// Losing all log, cache or spare devices loses no data, but data, special and dedup vdevs are vital to the pool.
+func (s *ZpoolStatus) ClassRedundancy(class VdevClass) int {
+	min := -1
+	for _, v := range s.TopLevelVdevs(class) {
+		if r := v.Redundancy(); min < 0 || r < min {
+			min = r
+		}
+	}
+	return min
+}
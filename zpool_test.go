@@ -0,0 +1,387 @@
+package zfs
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// testZpoolStatusJSON is abridged `zpool status --json -p` output of OpenZFS 2.3.
+const testZpoolStatusJSON = `{
+  "output_version": {"command": "zpool status", "vers_major": 0, "vers_minor": 1},
+  "pools": {
+    "tank": {
+      "name": "tank",
+      "state": "ONLINE",
+      "pool_guid": "11111",
+      "txg": "2468",
+      "spa_version": "5000",
+      "zpl_version": "5",
+      "vdevs": {
+        "tank": {
+          "name": "tank",
+          "vdev_type": "root",
+          "guid": "11111",
+          "class": "normal",
+          "state": "ONLINE",
+          "alloc_space": "1048576",
+          "total_space": "10737418240",
+          "def_space": "10737418240",
+          "read_errors": "0",
+          "write_errors": "0",
+          "checksum_errors": "0",
+          "vdevs": {
+            "mirror-0": {
+              "name": "mirror-0",
+              "vdev_type": "mirror",
+              "guid": "22222",
+              "class": "normal",
+              "state": "DEGRADED",
+              "read_errors": "0",
+              "write_errors": "0",
+              "checksum_errors": "0",
+              "vdevs": {
+                "sda": {
+                  "name": "sda",
+                  "vdev_type": "disk",
+                  "guid": "33333",
+                  "path": "/dev/sda1",
+                  "class": "normal",
+                  "state": "ONLINE",
+                  "rep_dev_size": "5368709120",
+                  "phys_space": "5368709120",
+                  "read_errors": "1",
+                  "write_errors": "2",
+                  "checksum_errors": "3",
+                  "slow_ios": "4"
+                },
+                "sdb": {
+                  "name": "sdb",
+                  "vdev_type": "disk",
+                  "guid": "44444",
+                  "path": "/dev/sdb1",
+                  "class": "normal",
+                  "state": "FAULTED",
+                  "read_errors": "0",
+                  "write_errors": "0",
+                  "checksum_errors": "0"
+                }
+              }
+            }
+          }
+        }
+      },
+      "error_count": "7"
+    }
+  }
+}`
+
+func TestZpoolStatusUnmarshal(t *testing.T) {
+	var out ZpoolStatusJSON
+	if err := json.Unmarshal([]byte(testZpoolStatusJSON), &out); err != nil {
+		t.Fatal(err)
+	}
+	s := out.Pools["tank"]
+	if s.TXG != 2468 || s.SPAVersion != 5000 || s.ZPLVersion != 5 || s.ErrorCount != 7 {
+		t.Fatalf("wanted: 2468 5000 5 7, got: %v %v %v %v", s.TXG, s.SPAVersion, s.ZPLVersion, s.ErrorCount)
+	}
+	if s.Raw["txg"] != "2468" {
+		t.Fatalf("wanted: %v, got: %v", "2468", s.Raw["txg"])
+	}
+
+	root := s.Vdevs["tank"]
+	if root.AllocSpace != 1<<20 || root.TotalSpace != 10<<30 {
+		t.Fatalf("wanted: %v %v, got: %v %v", 1<<20, 10<<30, root.AllocSpace, root.TotalSpace)
+	}
+	sda := root.Vdevs["mirror-0"].Vdevs["sda"]
+	got := []uint64{sda.ReadErrors, sda.WriteErrors, sda.ChecksumErrors, sda.SlowIOs, sda.RepDevSize}
+	if want := []uint64{1, 2, 3, 4, 5 << 30}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted: %v, got: %v", want, got)
+	}
+	if sda.Path != "/dev/sda1" || sda.GUID != "33333" {
+		t.Fatalf("wanted: /dev/sda1 33333, got: %v %v", sda.Path, sda.GUID)
+	}
+
+	// numbers with --json-int, human-readable sizes without -p, and output encoded by this package
+	var v ZpoolVdev
+	if err := json.Unmarshal([]byte(`{"name": "sdc", "alloc_space": "1.50G", "read_errors": 5, "slow_ios": null}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.AllocSpace != 0 || v.Raw["alloc_space"] != "1.50G" || v.ReadErrors != 5 || v.Raw["read_errors"] != "5" {
+		t.Fatalf("wanted: 0 1.50G 5 5, got: %v %v %v %v", v.AllocSpace, v.Raw["alloc_space"], v.ReadErrors, v.Raw["read_errors"])
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again ZpoolStatus
+	if err := json.Unmarshal(b, &again); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&again, s) {
+		t.Fatalf("wanted: %+v, got: %+v", s, &again)
+	}
+}
+
+func TestTopLevelVdevs(t *testing.T) {
+	disk := func(name string) *ZpoolVdev { return &ZpoolVdev{Name: name, VdevType: "disk"} }
+	mirror := func(name string, class VdevClass, disks ...string) *ZpoolVdev {
+		v := &ZpoolVdev{Name: name, VdevType: "mirror", Class: class, Vdevs: map[string]*ZpoolVdev{}}
+		for _, d := range disks {
+			v.Vdevs[d] = disk(d)
+		}
+		return v
+	}
+	raidz2 := &ZpoolVdev{Name: "raidz2-1", VdevType: "raidz", Class: VdevClassData}
+	s := &ZpoolStatus{
+		Vdevs: map[string]*ZpoolVdev{"tank": {Name: "tank", VdevType: "root", Vdevs: map[string]*ZpoolVdev{
+			"mirror-0": mirror("mirror-0", VdevClassData, "sda", "sdb", "sdc"),
+			"raidz2-1": raidz2,
+			// older releases report the vdevs of other classes below the root
+			"mirror-2": mirror("mirror-2", VdevClassSpecial, "sdd", "sde"),
+		}}},
+		Logs:    map[string]*ZpoolVdev{"nvme0n1": disk("nvme0n1")},
+		L2Cache: map[string]*ZpoolVdev{"nvme1n1": disk("nvme1n1")},
+		Spares:  map[string]*ZpoolVdev{"sdf": disk("sdf"), "sdg": disk("sdg")},
+		Dedup:   map[string]*ZpoolVdev{"mirror-3": mirror("mirror-3", VdevClassDedup, "sdh", "sdi")},
+	}
+
+	names := func(vdevs []*ZpoolVdev) []string {
+		var n []string
+		for _, v := range vdevs {
+			n = append(n, v.Name)
+		}
+		return n
+	}
+	tests := []struct {
+		class      VdevClass
+		names      []string
+		redundancy int
+	}{
+		{VdevClassData, []string{"mirror-0", "raidz2-1"}, 2},
+		{VdevClassLog, []string{"nvme0n1"}, 0},
+		{VdevClassCache, []string{"nvme1n1"}, 0},
+		{VdevClassSpare, []string{"sdf", "sdg"}, 0},
+		{VdevClassSpecial, []string{"mirror-2"}, 1},
+		{VdevClassDedup, []string{"mirror-3"}, 1},
+	}
+	for _, test := range tests {
+		if got := names(s.TopLevelVdevs(test.class)); !reflect.DeepEqual(got, test.names) {
+			t.Fatalf("%s: wanted: %v, got: %v", test.class, test.names, got)
+		}
+		if got := s.ClassRedundancy(test.class); got != test.redundancy {
+			t.Fatalf("%s: wanted: %v, got: %v", test.class, test.redundancy, got)
+		}
+	}
+	if got := (&ZpoolStatus{}).ClassRedundancy(VdevClassLog); got != -1 {
+		t.Fatalf("wanted: -1, got: %v", got)
+	}
+	if got := (&ZpoolVdev{Name: "draid3:8d:20c:2s-0", VdevType: "draid"}).Redundancy(); got != 3 {
+		t.Fatalf("wanted: 3, got: %v", got)
+	}
+}
+
+func TestVdevChildrenOrder(t *testing.T) {
+	const status = `{"name": "tank", "vdevs": {"tank": {"name": "tank", "vdev_type": "root", "vdevs": {
+		"mirror-2": {"name": "mirror-2", "vdev_type": "mirror", "vdevs": {"sdc": {"name": "sdc"}, "sda": {"name": "sda"}}},
+		"mirror-10": {"name": "mirror-10", "vdev_type": "mirror", "vdevs": {"sdd": {"name": "sdd"}, "sdb": {"name": "sdb"}}}}}},
+		"spares": {"sdf": {"name": "sdf"}, "sde": {"name": "sde"}}}`
+
+	names := func(vdevs []*ZpoolVdev) []string {
+		var n []string
+		for _, v := range vdevs {
+			n = append(n, v.Name)
+		}
+		return n
+	}
+	var s ZpoolStatus
+	if err := json.Unmarshal([]byte(status), &s); err != nil {
+		t.Fatal(err)
+	}
+	// repeat, as map iteration order varies
+	for i := 0; i < 10; i++ {
+		if got, want := names(s.TopLevelVdevs(VdevClassData)), []string{"mirror-2", "mirror-10"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("wanted: %v, got: %v", want, got)
+		}
+		if got, want := names(s.TopLevelVdevs(VdevClassSpare)), []string{"sdf", "sde"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("wanted: %v, got: %v", want, got)
+		}
+		var leaves []string
+		walkLeafVdevs(s.Vdevs["tank"], false, func(v *ZpoolVdev, replacing bool) { leaves = append(leaves, v.Name) })
+		if want := []string{"sdc", "sda", "sdd", "sdb"}; !reflect.DeepEqual(leaves, want) {
+			t.Fatalf("wanted: %v, got: %v", want, leaves)
+		}
+	}
+
+	// vdevs built by hand are ordered by name
+	v := &ZpoolVdev{Vdevs: map[string]*ZpoolVdev{"sdb": {Name: "sdb"}, "sda": {Name: "sda"}}}
+	if got, want := names(v.Children()), []string{"sda", "sdb"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted: %v, got: %v", want, got)
+	}
+}
+
+func TestZpoolStatusAdvisory(t *testing.T) {
+	const status = `{
+	  "name": "tank",
+	  "state": "DEGRADED",
+	  "status": "One or more devices are faulted in response to persistent errors.",
+	  "action": "Replace the faulted device, or use 'zpool clear' to mark the device repaired.",
+	  "msgid": "ZFS-8000-K4",
+	  "moreinfo": "https://openzfs.github.io/openzfs-docs/msg/ZFS-8000-K4",
+	  "vdevs": {"tank": {"name": "tank", "vdev_type": "root", "vdevs": {
+	    "sda": {"name": "sda", "vdev_type": "disk", "state": "FAULTED", "slow_ios": "12", "hours": "31337", "temp": 41, "smart": null},
+	    "sdb": {"name": "sdb", "vdev_type": "disk", "state": "ONLINE", "hours": "-"}}}}
+	}`
+	var s ZpoolStatus
+	if err := json.Unmarshal([]byte(status), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.MsgID != "ZFS-8000-K4" || s.See != "https://openzfs.github.io/openzfs-docs/msg/ZFS-8000-K4" {
+		t.Fatalf("wanted: ZFS-8000-K4, got: %v %v", s.MsgID, s.See)
+	}
+	if s.Status == "" || s.Action == "" {
+		t.Fatalf("wanted: status and action, got: %q %q", s.Status, s.Action)
+	}
+
+	sda := s.FindVdev("sda")
+	if want := map[string]string{"hours": "31337", "temp": "41"}; !reflect.DeepEqual(sda.Extra, want) {
+		t.Fatalf("wanted: %v, got: %v", want, sda.Extra)
+	}
+	if hours, ok := sda.PowerOnHours(); !ok || hours != 31337 || sda.SlowIOs != 12 {
+		t.Fatalf("wanted: 31337 true 12, got: %v %v %v", hours, ok, sda.SlowIOs)
+	}
+	if hours, ok := s.FindVdev("sdb").PowerOnHours(); ok {
+		t.Fatalf("wanted: no power-on hours, got: %v", hours)
+	}
+}
+
+func TestBlockCloneSavings(t *testing.T) {
+	for _, test := range []struct {
+		z    Zpool
+		want float64
+	}{
+		{Zpool{Allocated: 3 << 30, BCloneSaved: 1 << 30}, 25},
+		{Zpool{Allocated: 3 << 30}, 0},
+		{Zpool{}, 0},
+	} {
+		if got := test.z.BlockCloneSavings(); got != test.want {
+			t.Fatalf("wanted: %v, got: %v", test.want, got)
+		}
+	}
+
+	for _, stderr := range []string{
+		"bad property list: invalid property 'bcloneused'\n",
+		"bad property list: invalid property 'objsetid'\nusage:\n\tlist [-Hp] [-r|-d max] [-o property[,...]] ...\n",
+	} {
+		if !isBadPropertyList(&Error{Stderr: stderr}) {
+			t.Fatal("wanted: bad property list")
+		}
+	}
+	if isBadPropertyList(&Error{Stderr: "cannot open 'tank': no such pool\n"}) || isBadPropertyList(nil) {
+		t.Fatal("wanted: not a bad property list")
+	}
+}
+
+func TestFormatDedupTableQuota(t *testing.T) {
+	for quota, want := range map[uint64]string{0: "none", DedupTableQuotaAuto: "auto", 1 << 30: "1073741824"} {
+		if got := formatDedupTableQuota(quota); got != want {
+			t.Fatalf("wanted: %v, got: %v", want, got)
+		}
+		if got, _ := parseDedupTableQuota(want); got != quota {
+			t.Fatalf("wanted: %v, got: %v", quota, got)
+		}
+	}
+}
+
+func TestParseCreateLayout(t *testing.T) {
+	out := "would create 'tank' with the following layout:\n\n" +
+		"\ttank\n" +
+		"\t  mirror\n\t    sda\n\t    sdb\n" +
+		"\t  raidz2\n\t    sdc\n\t    sdd\n\t    sde\n\t    sdf\n" +
+		"\tspecial\n\t  mirror\n\t    nvme0n1\n\t    nvme1n1\n" +
+		"\tlogs\n\t  /dev/nvme2n1\n" +
+		"\tcache\n\t  /var/tmp/cache\n" +
+		"\tspares\n\t  sdh\n\t  sdg\n"
+	s, err := parseCreateLayout(splitOutput(out))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Name != "tank" {
+		t.Fatalf("wanted: tank, got: %v", s.Name)
+	}
+
+	var walked []string
+	_ = s.WalkVdevs(func(v, _ *ZpoolVdev) error {
+		walked = append(walked, string(v.Class)+":"+v.VdevType+":"+v.Name)
+		return nil
+	})
+	want := []string{
+		"normal:root:tank", "normal:mirror:mirror", "normal:disk:sda", "normal:disk:sdb",
+		"normal:raidz:raidz2", "normal:disk:sdc", "normal:disk:sdd", "normal:disk:sde", "normal:disk:sdf",
+		"special:mirror:mirror", "special:disk:nvme0n1", "special:disk:nvme1n1",
+		"log:disk:/dev/nvme2n1", "l2cache:file:/var/tmp/cache", "spare:disk:sdh", "spare:disk:sdg",
+	}
+	if !reflect.DeepEqual(want, walked) {
+		t.Fatalf("wanted: %v, got: %v", want, walked)
+	}
+	if got := s.ClassRedundancy(VdevClassData); got != 1 {
+		t.Fatalf("wanted: 1, got: %v", got)
+	}
+	if got := s.FindVdev("/dev/nvme2n1"); got == nil || got.Path != "/dev/nvme2n1" {
+		t.Fatalf("wanted: /dev/nvme2n1, got: %v", got)
+	}
+
+	for _, out := range []string{"", "\ttank\n\t      sda\n", "\ttank\n\tbogus\n\t  sda\n"} {
+		if _, err := parseCreateLayout(splitOutput(out)); err == nil {
+			t.Fatalf("expected an error for %q", out)
+		}
+	}
+}
+
+func TestZpoolAltRootArgs(t *testing.T) {
+	got, err := CreateZpoolOptions{AltRoot: "/mnt", TempName: "rpool-install"}.args("rpool", []string{"mirror", "sda", "sdb"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"create", "-R", "/mnt", "-t", "rpool-install", "rpool", "mirror", "sda", "sdb"}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %v, got: %v", want, got)
+	}
+
+	for _, test := range []struct {
+		opts ImportZpoolOptions
+		want []string
+	}{
+		{ImportZpoolOptions{}, []string{"import", "rpool"}},
+		{
+			ImportZpoolOptions{AltRoot: "/mnt", TempName: "rescue", Dirs: []string{"/dev/disk/by-id"}, NoMount: true, Force: true},
+			[]string{"import", "-R", "/mnt", "-d", "/dev/disk/by-id", "-N", "-f", "-t", "rpool", "rescue"},
+		},
+		{ImportZpoolOptions{Properties: map[string]string{"readonly": "on"}}, []string{"import", "-o", "readonly=on", "rpool"}},
+	} {
+		if got := test.opts.args("rpool"); !reflect.DeepEqual(test.want, got) {
+			t.Fatalf("wanted: %v, got: %v", test.want, got)
+		}
+	}
+}
+
+func TestParseZpoolList(t *testing.T) {
+	props := []string{"name", "health", "allocated", "size", "free", "readonly", "dedupratio", "fragmentation", "altroot", "guid", "expandsize"}
+	out := "tank\tONLINE\t1073741824\t4294967296\t3221225472\toff\t1.00\t12\t-\t1234567890\t-\n" +
+		"rescue\tDEGRADED\t0\t1073741824\t1073741824\ton\t1.50\t-\t/mnt\t42\t2147483648\n"
+	got, err := parseZpoolList(splitOutput(out), props)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []*Zpool{
+		{Name: "tank", Health: "ONLINE", Allocated: 1 << 30, Size: 4 << 30, Free: 3 << 30, DedupRatio: 1, Fragmentation: 12, GUID: 1234567890},
+		{Name: "rescue", Health: "DEGRADED", Size: 1 << 30, Free: 1 << 30, ReadOnly: true, DedupRatio: 1.5, AltRoot: "/mnt", GUID: 42, ExpandSize: 2 << 30},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %+v, got: %+v", want, got)
+	}
+
+	if _, err := parseZpoolList(splitOutput("tank\tONLINE\n"), props); err == nil {
+		t.Fatal("expected an error")
+	}
+}
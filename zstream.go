@@ -0,0 +1,158 @@
+package zfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Send stream feature flags, as found in the BEGIN records of a stream.
+const (
+	streamFeatureEmbedData   = 1 << 16
+	streamFeatureLargeBlocks = 1 << 19
+	streamFeatureCompressed  = 1 << 22
+	streamFeatureRaw         = 1 << 24
+)
+
+// StreamSnapshot describes a snapshot contained in a send stream.
+type StreamSnapshot struct {
+	Name     string `json:"name"`
+	ToGUID   uint64 `json:"to_guid"`
+	FromGUID uint64 `json:"from_guid"`
+	Features uint64 `json:"features"`
+}
+
+// Incremental reports whether the snapshot is sent incrementally.
+func (s StreamSnapshot) Incremental() bool {
+	return s.FromGUID != 0
+}
+
+// StreamSummary summarizes a send stream as reported by `zstream dump`.
+type StreamSummary struct {
+	// Snapshots are the snapshots contained in the stream, in stream order.
+	Snapshots []StreamSnapshot `json:"snapshots"`
+	// Records counts the records of the stream by type, e.g. "DRR_WRITE".
+	Records      map[string]uint64 `json:"records"`
+	TotalRecords uint64            `json:"total_records"`
+	PayloadSize  uint64            `json:"payload_size"`
+	StreamLength uint64            `json:"stream_length"`
+	// The stream features used by any of the contained snapshots.
+	Raw         bool `json:"raw"`
+	Compressed  bool `json:"compressed"`
+	LargeBlocks bool `json:"large_blocks"`
+	EmbedData   bool `json:"embed_data"`
+}
+
+// DumpStream reads a send stream from r and summarizes it using `zstream dump`
+// (or `zstreamdump` on systems predating zstream), without receiving it.
+func DumpStream(r io.Reader) (*StreamSummary, error) {
+	c := command{Command: "zstream", Stdin: r}
+	out, err := c.Run("dump")
+	var e *Error
+	if errors.As(err, &e) && errors.Is(e.Err, exec.ErrNotFound) {
+		c = command{Command: "zstreamdump", Stdin: r}
+		out, err = c.Run()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseStreamDump(out)
+}
+
+// example input for parseStreamDump
+// BEGIN record
+//         hdrtype = 1
+//         features = 4
+//         magic = 2f5bacbac
+//         creation_time = 5e5b0c4c
+//         type = 2
+//         flags = 0x4
+//         toguid = 3b1c1a8a3f0f6d4e
+//         fromguid = 0
+//         toname = test/fs@snap
+// END checksum = 14c8b0c0a1/6ab0e3c1b9d58/1a8ee1e0e3e1e3a/3a1fbb7e2bb01d5c
+// SUMMARY:
+//         Total DRR_BEGIN records = 1 (0 bytes)
+//         Total DRR_WRITE records = 1 (512 bytes)
+//         Total records = 20
+//         Total payload size = 1472 (0x5c0)
+//         Total header overhead = 6240 (0x1860)
+//         Total stream length = 7712 (0x1e20)
+
+func parseStreamDump(lines [][]string) (*StreamSummary, error) {
+	s := &StreamSummary{Records: map[string]uint64{}}
+
+	var begin *StreamSnapshot
+	// the outer BEGIN record of a replication stream package (hdrtype 2) describes no snapshot itself
+	compound := false
+	for i, fields := range lines {
+		raw := strings.Join(fields, "\t")
+		// only the indentation is trimmed, names may end in spaces
+		line := strings.TrimLeft(raw, " \t")
+		indented := raw != line
+
+		if !indented && begin != nil {
+			if !compound {
+				s.Snapshots = append(s.Snapshots, *begin)
+			}
+			begin = nil
+		}
+
+		if line == "BEGIN record" {
+			begin = &StreamSnapshot{}
+			compound = false
+			continue
+		}
+
+		kv := strings.SplitN(line, " = ", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, val := kv[0], kv[1]
+
+		var err error
+		switch {
+		case begin != nil && key == "hdrtype":
+			compound = val == "2"
+		case begin != nil && key == "toname":
+			begin.Name = val
+		case begin != nil && key == "toguid":
+			begin.ToGUID, err = strconv.ParseUint(val, 16, 64)
+		case begin != nil && key == "fromguid":
+			begin.FromGUID, err = strconv.ParseUint(val, 16, 64)
+		case begin != nil && key == "features":
+			begin.Features, err = strconv.ParseUint(val, 16, 64)
+		case strings.HasPrefix(key, "Total DRR_") && strings.HasSuffix(key, " records"):
+			recordType := strings.TrimSuffix(strings.TrimPrefix(key, "Total "), " records")
+			var n uint64
+			n, err = strconv.ParseUint(strings.Fields(val)[0], 10, 64)
+			s.Records[recordType] = n
+		case key == "Total records":
+			s.TotalRecords, err = strconv.ParseUint(val, 10, 64)
+		case key == "Total payload size":
+			s.PayloadSize, err = strconv.ParseUint(strings.Fields(val)[0], 10, 64)
+		case key == "Total stream length":
+			s.StreamLength, err = strconv.ParseUint(strings.Fields(val)[0], 10, 64)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse line %d of zstream dump: %w, got: '%s'", i, err, line)
+		}
+	}
+	if begin != nil && !compound {
+		s.Snapshots = append(s.Snapshots, *begin)
+	}
+
+	if len(s.Snapshots) == 0 {
+		return nil, errors.New("no snapshots found in stream")
+	}
+	for _, snap := range s.Snapshots {
+		s.Raw = s.Raw || snap.Features&streamFeatureRaw != 0
+		s.Compressed = s.Compressed || snap.Features&streamFeatureCompressed != 0
+		s.LargeBlocks = s.LargeBlocks || snap.Features&streamFeatureLargeBlocks != 0
+		s.EmbedData = s.EmbedData || snap.Features&streamFeatureEmbedData != 0
+	}
+	return s, nil
+}
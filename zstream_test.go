@@ -0,0 +1,85 @@
+package zfs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func splitOutput(s string) [][]string {
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	out := make([][]string, len(lines))
+	for i, l := range lines {
+		out[i] = strings.Split(l, "\t")
+	}
+	return out
+}
+
+func TestParseStreamDump(t *testing.T) {
+	got, err := parseStreamDump(splitOutput(`BEGIN record
+	hdrtype = 2
+	features = 4
+	magic = 2f5bacbac
+	creation_time = 0
+	type = 0
+	flags = 0x0
+	toguid = 0
+	fromguid = 0
+	toname = test/fs@b
+	payloadlen = 1028
+BEGIN record
+	hdrtype = 1
+	features = 1420004
+	magic = 2f5bacbac
+	creation_time = 5e5b0c4c
+	type = 2
+	flags = 0xc
+	toguid = 3b1c1a8a3f0f6d4e
+	fromguid = 0
+	toname = test/fs@a
+	payloadlen = 0
+END checksum = 14c8b0c0a1/6ab0e3c1b9d58/1a8ee1e0e3e1e3a/3a1fbb7e2bb01d5c
+BEGIN record
+	hdrtype = 1
+	features = 1420004
+	magic = 2f5bacbac
+	creation_time = 5e5b0c4d
+	type = 2
+	flags = 0xc
+	toguid = 5cc1b96d5b2bbfc2
+	fromguid = 3b1c1a8a3f0f6d4e
+	toname = test/fs@b
+	payloadlen = 0
+END checksum = 14c8b0c0a1/6ab0e3c1b9d58/1a8ee1e0e3e1e3a/3a1fbb7e2bb01d5c
+SUMMARY:
+	Total DRR_BEGIN records = 3 (1028 bytes)
+	Total DRR_END records = 3 (0 bytes)
+	Total DRR_WRITE records = 2 (1024 bytes)
+	Total records = 8
+	Total payload size = 2052 (0x804)
+	Total header overhead = 2496 (0x9c0)
+	Total stream length = 4548 (0x11c4)
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &StreamSummary{
+		Snapshots: []StreamSnapshot{
+			{Name: "test/fs@a", ToGUID: 0x3b1c1a8a3f0f6d4e, Features: 0x1420004},
+			{Name: "test/fs@b", ToGUID: 0x5cc1b96d5b2bbfc2, FromGUID: 0x3b1c1a8a3f0f6d4e, Features: 0x1420004},
+		},
+		Records:      map[string]uint64{"DRR_BEGIN": 3, "DRR_END": 3, "DRR_WRITE": 2},
+		TotalRecords: 8,
+		PayloadSize:  2052,
+		StreamLength: 4548,
+		Raw:          true,
+		Compressed:   true,
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("parse failure: wanted: %+v, got: %+v", want, got)
+	}
+	if !got.Snapshots[1].Incremental() {
+		t.Fatal("wanted incremental snapshot")
+	}
+}